@@ -177,8 +177,8 @@ func (o MockManifest) GetOperatorConfig(file string) (*v1alpha3.OperatorConfigSc
 	return ocs, nil
 }
 
-func (o MockManifest) GetRelatedImagesFromCatalogByFilter(filePath, label string, op v1alpha2.Operator, mp map[string]v1alpha3.ISCPackage) (map[string][]v1alpha3.RelatedImage, error) {
-	return nil, nil
+func (o MockManifest) GetRelatedImagesFromCatalogByFilter(filePath, label string, op v1alpha2.Operator, mp map[string]v1alpha3.ISCPackage) (map[string][]v1alpha3.RelatedImage, []string, error) {
+	return nil, nil, nil
 }
 
 func (o MockManifest) GetReleaseSchema(filePath string) ([]v1alpha3.RelatedImage, error) {
@@ -204,6 +204,10 @@ func (o MockManifest) GetImageIndex(name string) (*v1alpha3.OCISchema, error) {
 	}, nil
 }
 
+func (o MockManifest) GetPlatformManifest(dir string, oci *v1alpha3.OCISchema) (*v1alpha3.OCISchema, error) {
+	return o.GetImageManifest(dir)
+}
+
 func (o MockManifest) GetImageManifest(name string) (*v1alpha3.OCISchema, error) {
 	return &v1alpha3.OCISchema{
 		SchemaVersion: 2,
@@ -88,5 +88,35 @@ func (o LocalStorageCollector) AdditionalImagesCollector(ctx context.Context) ([
 			allImages = append(allImages, v1alpha3.CopyImageSchema{Origin: img.Name, Source: src, Destination: dest})
 		}
 	}
+
+	if o.Opts.IsMirrorToMirror() {
+		for _, img := range o.Config.ImageSetConfigurationSpec.Mirror.AdditionalImages {
+			var src string
+			var dest string
+
+			if !strings.HasPrefix(img.Name, ociProtocol) {
+				imgSpec, err := image.ParseRef(img.Name)
+				if err != nil {
+					o.Log.Error("%s", err.Error())
+					return nil, err
+				}
+				src = imgSpec.ReferenceWithTransport
+
+				if imgSpec.IsImageByDigest() {
+					dest = strings.Join([]string{o.Opts.Destination, imgSpec.PathComponent + ":" + imgSpec.Digest[:hashTruncLen]}, "/")
+				} else {
+					dest = strings.Join([]string{o.Opts.Destination, imgSpec.PathComponent}, "/") + ":" + imgSpec.Tag
+				}
+			} else {
+				src = img.Name
+				transportAndPath := strings.Split(img.Name, "://")
+				dest = strings.Join([]string{o.Opts.Destination, transportAndPath[1]}, "/")
+			}
+
+			o.Log.Debug("source %s", src)
+			o.Log.Debug("destination %s", dest)
+			allImages = append(allImages, v1alpha3.CopyImageSchema{Origin: img.Name, Source: src, Destination: dest})
+		}
+	}
 	return allImages, nil
 }
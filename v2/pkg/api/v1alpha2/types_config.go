@@ -42,7 +42,13 @@ type Mirror struct {
 	// BlockedImages define a list of images that will be blocked
 	// from the mirroring process if they exist in other content
 	// types in the configuration.
+	// Each name is an exact reference (a repository blocks all of its tags and digests),
+	// a glob pattern (i.e. registry.example.com/legacy/*) or a regular expression
+	// prefixed with regex:
 	BlockedImages []Image `json:"blockedImages,omitempty"`
+	// DestinationRewrites define how the repositories of the images
+	// are named in the destination registry, the first matching rule applies.
+	DestinationRewrites []DestinationRewrite `json:"destinationRewrites,omitempty"`
 	// Samples defines the configuration for Sample content types.
 	// This is currently not implemented.
 	Samples []SampleImages `json:"samples,omitempty"`
@@ -63,6 +69,9 @@ type Platform struct {
 	// This new field will allow the diskToMirror functionality
 	// to copy from a release location on disk
 	Release string `json:"release,omitempty"`
+	// Signatures defines where the signatures of the release images
+	// are looked up, and the keys trusted to verify them.
+	Signatures ReleaseSignatures `json:"signatures,omitempty"`
 }
 
 func (p Platform) DeepCopy() Platform {
@@ -76,9 +85,28 @@ func (p Platform) DeepCopy() Platform {
 	platformCopy.Architectures = make([]string, len(p.Architectures))
 	copy(platformCopy.Architectures, p.Architectures)
 
+	platformCopy.Signatures.Sources = make([]string, len(p.Signatures.Sources))
+	copy(platformCopy.Signatures.Sources, p.Signatures.Sources)
+
+	platformCopy.Signatures.Keyrings = make([]string, len(p.Signatures.Keyrings))
+	copy(platformCopy.Signatures.Keyrings, p.Signatures.Keyrings)
+
 	return platformCopy
 }
 
+// ReleaseSignatures defines the lookup and the verification
+// of the signatures of the release images.
+type ReleaseSignatures struct {
+	// Sources defines the locations of the signatures, tried in order.
+	// A source is an http(s) url, a file:// url or a local directory,
+	// holding the signatures as <source>/sha256=<digest>/signature-1.
+	// The Red Hat signature store is used when no source is set.
+	Sources []string `json:"sources,omitempty"`
+	// Keyrings defines files of public keys (armored or binary)
+	// trusted in addition to the Red Hat release key.
+	Keyrings []string `json:"keyrings,omitempty"`
+}
+
 // ReleaseChannel defines the configuration for individual
 // OCP and OKD channels
 type ReleaseChannel struct {
@@ -126,6 +154,11 @@ type Operator struct {
 	// the catalog will be publish with the provided tag in the Catalog
 	// field or a tag calculated from the partial digest.
 	TargetTag string `json:"targetTag,omitempty"`
+	// TargetCatalogSourceTemplate is the path to a CatalogSource yaml used
+	// as a template for the CatalogSource generated for the catalog.
+	// Its name, source type and image are set by oc-mirror, the other
+	// fields (i.e. updateStrategy, grpcPodConfig) are kept.
+	TargetCatalogSourceTemplate string `json:"targetCatalogSourceTemplate,omitempty"`
 	// Full defines whether all packages within the catalog
 	// or specified IncludeConfig will be mirrored or just channel heads.
 	Full bool `json:"full,omitempty"`
@@ -221,9 +254,76 @@ type Image struct {
 	Name string `json:"name"`
 }
 
+// DestinationRewrite rewrites the repository of the images
+// in the destination registry.
+type DestinationRewrite struct {
+	// Source is the original repository of the images (registry included).
+	// A source ending with /* matches all the repositories under it,
+	// i.e. registry.redhat.io/*
+	Source string `json:"source"`
+	// Destination is the repository of the images in the destination registry,
+	// relative to the destination. The * is replaced by the part of the repository
+	// matched by the * of the source, i.e. redhat/*
+	Destination string `json:"destination"`
+	// Flatten replaces the / of the part of the repository matched by the *
+	// of the source with -, so that it is a single level of the destination
+	Flatten bool `json:"flatten,omitempty"`
+}
+
 // SampleImages define the configuration
 // for Sameple content types.
 // Not implemented.
 type SampleImages struct {
 	Image `json:",inline"`
 }
+
+// DeleteImageSetConfiguration object kind.
+const DeleteImageSetConfigurationKind = "DeleteImageSetConfiguration"
+
+// DeleteImageSetConfiguration configures the images
+// to be deleted from a mirror registry.
+type DeleteImageSetConfiguration struct {
+	metav1.TypeMeta `json:",inline"`
+	// DeleteImageSetConfigurationSpec defines the images to delete.
+	DeleteImageSetConfigurationSpec `json:",inline"`
+}
+
+// DeleteImageSetConfigurationSpec defines the images to delete.
+type DeleteImageSetConfigurationSpec struct {
+	// Delete defines the configuration for content types to delete.
+	Delete Delete `json:"delete"`
+}
+
+// Delete defines the configuration for content types to delete.
+// It uses the same content types as Mirror, the images resolved
+// from them are deleted from the destination registry.
+type Delete struct {
+	// Platform defines the configuration for OpenShift and OKD platform types.
+	Platform Platform `json:"platform,omitempty"`
+	// Operators defines the configuration for Operator content types.
+	Operators []Operator `json:"operators,omitempty"`
+	// AdditionalImages defines the configuration for a list
+	// of individual image content types.
+	AdditionalImages []Image `json:"additionalImages,omitempty"`
+	// DestinationRewrites define how the repositories of the images
+	// were named in the destination registry when they were mirrored.
+	DestinationRewrites []DestinationRewrite `json:"destinationRewrites,omitempty"`
+}
+
+// ToImageSetConfiguration returns an ImageSetConfiguration mirroring
+// the content to delete, so that the same collectors can resolve
+// the list of images.
+func (d DeleteImageSetConfiguration) ToImageSetConfiguration() ImageSetConfiguration {
+	isc := ImageSetConfiguration{
+		ImageSetConfigurationSpec: ImageSetConfigurationSpec{
+			Mirror: Mirror{
+				Platform:            d.Delete.Platform,
+				Operators:           d.Delete.Operators,
+				AdditionalImages:    d.Delete.AdditionalImages,
+				DestinationRewrites: d.Delete.DestinationRewrites,
+			},
+		},
+	}
+	isc.SetGroupVersionKind(GroupVersion.WithKind(ImageSetConfigurationKind))
+	return isc
+}
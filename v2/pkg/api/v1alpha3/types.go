@@ -94,9 +94,17 @@ type OCISchema struct {
 }
 
 type OCIManifest struct {
-	MediaType string `json:"mediaType"`
-	Digest    string `json:"digest"`
-	Size      int    `json:"size"`
+	MediaType string       `json:"mediaType"`
+	Digest    string       `json:"digest"`
+	Size      int          `json:"size"`
+	Platform  *OCIPlatform `json:"platform,omitempty"`
+}
+
+// OCIPlatform - the platform of a manifest of an OCI index or a Docker manifest list
+type OCIPlatform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
 }
 
 // OperatorConfigSchema
@@ -233,6 +241,10 @@ type Bundle struct {
 type RelatedImage struct {
 	Name  string `json:"name"`
 	Image string `json:"image"`
+	// Package: the operator package of the bundle the image is related to
+	Package string `json:"-"`
+	// Catalog: the catalog image of the bundle the image is related to
+	Catalog string `json:"-"`
 }
 
 // DeclarativeConfig this updates the existing dclrcfg
@@ -253,6 +265,7 @@ type ISCPackage struct {
 	Channel    string
 	MinVersion string
 	MaxVersion string
+	MinBundle  string
 	Full       bool
 }
 
@@ -271,8 +284,22 @@ type CopyImageSchema struct {
 	Destination string
 	// Origin: Original reference to the image
 	Origin string
+	// Type: the collector that found the image (release, operator, additional or helm)
+	Type string
+	// Parent: the release or catalog image the image belongs to, when known
+	Parent string
+	// Package: the operator package the image belongs to, when known
+	Package string
 }
 
+// the types of the images, by collector
+const (
+	TypeRelease    = "release"
+	TypeOperator   = "operator"
+	TypeAdditional = "additional"
+	TypeHelm       = "helm"
+)
+
 // SignatureContentSchema
 type SignatureContentSchema struct {
 	Critical struct {
@@ -288,3 +315,19 @@ type SignatureContentSchema struct {
 		Creator string `json:"creator"`
 	} `json:"optional"`
 }
+
+// DeleteImageList - the delete plan, listing the images
+// that will be deleted from the destination registry
+type DeleteImageList struct {
+	Kind       string       `json:"kind"`
+	APIVersion string       `json:"apiVersion"`
+	Items      []DeleteItem `json:"items"`
+}
+
+// DeleteItem
+type DeleteItem struct {
+	// ImageName: original reference to the image
+	ImageName string `json:"imageName"`
+	// ImageReference: reference of the image in the destination registry
+	ImageReference string `json:"imageReference"`
+}
@@ -5,9 +5,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	digest "github.com/opencontainers/go-digest"
@@ -15,6 +15,7 @@ import (
 	"github.com/openshift/oc-mirror/v2/pkg/history"
 	clog "github.com/openshift/oc-mirror/v2/pkg/log"
 	"github.com/openshift/oc-mirror/v2/pkg/mirror"
+	"github.com/openshift/oc-mirror/v2/pkg/request"
 )
 
 type MirrorArchive struct {
@@ -23,46 +24,63 @@ type MirrorArchive struct {
 	iscPath      string
 	workingDir   string
 	cacheDir     string
-	archiveFile  *os.File
-	tarWriter    *tar.Writer
+	writer       *chunkWriter
 	history      history.History
 	blobGatherer BlobsGatherer
 }
 
 // The caller must call Close!
-func NewMirrorArchive(opts *mirror.CopyOptions, destination, iscPath, workingDir, cacheDir string, logg clog.PluggableLoggerInterface) (MirrorArchive, error) {
-	//TODO handle several chunks
-	chunk := 1
-	archiveFileName := fmt.Sprintf("%s_%06d.tar", archiveFilePrefix, chunk)
-	err := os.MkdirAll(destination, 0755)
-	if err != nil {
-		return MirrorArchive{}, err
+// archiveSize is the maximum size of each chunk of the archive in GB,
+// 0 meaning that the archive is written in a single chunk.
+// The chunks are compressed as set by --archive-compression (none by default)
+func NewMirrorArchive(opts *mirror.CopyOptions, destination, iscPath, workingDir, cacheDir string, archiveSize int64, logg clog.PluggableLoggerInterface) (MirrorArchive, error) {
+	compression := CompressionNone
+	if opts.Global != nil && opts.Global.ArchiveCompression != "" {
+		compression = opts.Global.ArchiveCompression
+	}
+	// the blobs are diffed against the latest history snapshot
+	// unless --since or --since-snapshot choose an older one
+	before := time.Time{}
+	if opts.Global != nil {
+		var err error
+		before, err = history.Before(workingDir, opts.Global.Since, opts.Global.SinceSnapshot)
+		if err != nil {
+			return MirrorArchive{}, err
+		}
+	}
+	// with --from-request, they are diffed against the blobs the enclave registry
+	// holds, as listed by the diskToMirror side, instead
+	var requestBlobs map[string]string
+	if opts.Global != nil && opts.Global.FromRequest != "" {
+		req, err := request.Read(opts.Global.FromRequest)
+		if err != nil {
+			return MirrorArchive{}, err
+		}
+		requestBlobs = req.BlobSet()
 	}
-	archivePath := filepath.Join(destination, archiveFileName)
-	// Create a new tar archive file
+
+	// Create the first chunk of the archive
 	// to be closed by BuildArchive
-	archiveFile, err := os.Create(archivePath)
+	writer, err := newChunkWriter(destination, archiveSize*gigabyte, compression, logg)
 	if err != nil {
 		return MirrorArchive{}, err
 	}
 
 	// create the history interface
-	history, err := history.NewHistory(workingDir, time.Time{}, logg, history.OSFileCreator{})
+	hist, err := history.NewHistory(workingDir, before, logg, history.OSFileCreator{})
 	if err != nil {
 		return MirrorArchive{}, err
 	}
-
-	// Create a new tar writer
-	// to be closed by BuildArchive
-	tarWriter := tar.NewWriter(archiveFile)
+	if requestBlobs != nil {
+		hist = history.WithBlobs(hist, requestBlobs)
+	}
 
 	bg := NewImageBlobGatherer(opts)
 
 	ma := MirrorArchive{
 		destination:  destination,
-		archiveFile:  archiveFile,
-		tarWriter:    tarWriter,
-		history:      history,
+		writer:       writer,
+		history:      hist,
 		blobGatherer: bg,
 		workingDir:   workingDir,
 		cacheDir:     cacheDir,
@@ -76,6 +94,9 @@ func NewMirrorArchive(opts *mirror.CopyOptions, destination, iscPath, workingDir
 // * docker/v2/blobs/sha256 : blobs that haven't been mirrored (diff)
 // * working-dir
 // * image set config
+// the archive is split in chunks (mirror_000001.tar, mirror_000002.tar...) of at most archiveSize,
+// counted before compression,
+// and the names of all the chunks are returned
 func (o MirrorArchive) BuildArchive(ctx context.Context, collectedImages []v1alpha3.CopyImageSchema) (string, error) {
 
 	// 1 - Add files and directories under the cache's docker/v2/repositories to the archive
@@ -111,9 +132,11 @@ func (o MirrorArchive) BuildArchive(ctx context.Context, collectedImages []v1alp
 	if err != nil {
 		return "", fmt.Errorf("unable to update history metadata: %v", err)
 	}
-	o.tarWriter.Flush()
-	o.tarWriter.Close()
-	return o.archiveFile.Name(), nil
+	err = o.writer.finish()
+	if err != nil {
+		return "", fmt.Errorf("unable to close the archive : %v", err)
+	}
+	return strings.Join(o.writer.chunks, ", "), nil
 }
 
 func (o MirrorArchive) addImagesDiff(ctx context.Context, collectedImages []v1alpha3.CopyImageSchema, historyBlobs map[string]string, cacheDir string) (map[string]string, error) {
@@ -171,9 +194,6 @@ func (o MirrorArchive) addFile(pathToFile string, pathInTar string) error {
 	}
 	header.Name = pathInTar
 
-	if err := o.tarWriter.WriteHeader(header); err != nil {
-		return err
-	}
 	// Open the file for reading
 	file, err := os.Open(pathToFile)
 	if err != nil {
@@ -181,11 +201,8 @@ func (o MirrorArchive) addFile(pathToFile string, pathInTar string) error {
 	}
 	defer file.Close()
 
-	// Copy the file contents to the tar archive
-	if _, err := io.Copy(o.tarWriter, file); err != nil {
-		return err
-	}
-	return nil
+	// Write the header and copy the file contents to the tar archive
+	return o.writer.writeFile(header, file)
 }
 func (o MirrorArchive) addAllFolder(folderToAdd string, relativeTo string) error {
 	return filepath.Walk(folderToAdd, func(path string, info os.FileInfo, incomingError error) error {
@@ -210,11 +227,6 @@ func (o MirrorArchive) addAllFolder(folderToAdd string, relativeTo string) error
 			return err
 		}
 
-		// Write the header to the tar archive
-		if err := o.tarWriter.WriteHeader(header); err != nil {
-			return err
-		}
-
 		// Open the file for reading
 		file, err := os.Open(path)
 		if err != nil {
@@ -222,25 +234,11 @@ func (o MirrorArchive) addAllFolder(folderToAdd string, relativeTo string) error
 		}
 		defer file.Close()
 
-		// Copy the file contents to the tar archive
-		if _, err := io.Copy(o.tarWriter, file); err != nil {
-			return err
-		}
-
-		return nil
+		// Write the header and copy the file contents to the tar archive
+		return o.writer.writeFile(header, file)
 	})
 }
 
 func (o MirrorArchive) Close() error {
-
-	err1 := o.archiveFile.Close()
-	err2 := o.tarWriter.Close()
-
-	if err1 != nil {
-		return err1
-	}
-	if err2 != nil {
-		return err2
-	}
-	return nil
+	return o.writer.close()
 }
@@ -49,6 +49,8 @@ var expectedTarContents = []string{
 	"docker/registry/v2/repositories/ubi8/ubi/_uploads/97e2891e-4cb2-4289-a87a-e9b8cd006d20/hashstates/sha256/0",
 	"docker/registry/v2/repositories/ubi8/ubi/_uploads/97e2891e-4cb2-4289-a87a-e9b8cd006d20/startedat",
 	"isc",
+	"mirror_chunks",
+	"mirror_manifest.json",
 	"working-dir-fake/hold-release/ocp-release/4.14.1-x86_64/release-manifests/image-references",
 	"working-dir-fake/hold-release/ocp-release/4.14.1-x86_64/release-manifests/release-metadata",
 	"working-dir-fake/release-filters/d5f8153de54b0327ad20d24d4dbba7a6"}
@@ -76,7 +78,7 @@ func newMirrorArchiveWithMocks(testFolder string) (MirrorArchive, error) {
 	}
 	cfg := "../../tests/isc.yaml"
 
-	ma, err := NewMirrorArchive(&opts, testFolder, cfg, "../../tests/working-dir-fake", "../../tests/cache-fake", clog.New("trace"))
+	ma, err := NewMirrorArchive(&opts, testFolder, cfg, "../../tests/working-dir-fake", "../../tests/cache-fake", 0, clog.New("trace"))
 	if err != nil {
 		return MirrorArchive{}, err
 	}
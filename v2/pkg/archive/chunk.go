@@ -0,0 +1,268 @@
+package archive
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	clog "github.com/openshift/oc-mirror/v2/pkg/log"
+)
+
+// chunkFilePattern - the name of the chunks of an archive: mirror_000001.tar, mirror_000002.tar...
+// followed by .gz or .zst when the archive is compressed
+var chunkFilePattern = regexp.MustCompile("^" + archiveFilePrefix + `_(\d{6})\.tar(\.gz|\.zst)?$`)
+
+// chunkWriter - writes the archive in chunks, and rolls over to a new chunk
+// when the next file would make the current chunk exceed maxSize.
+// Files are never split across chunks: a file larger than maxSize
+// is written alone in its own chunk.
+// Each chunk is compressed on its own, and the sizes are counted before compression
+type chunkWriter struct {
+	destination string
+	maxSize     int64
+	compression string
+	chunk       int
+	size        int64
+	file        *os.File
+	compressor  io.WriteCloser
+	tarWriter   *tar.Writer
+	chunks      []string
+	entries     []manifestEntry
+}
+
+// newChunkWriter - creates the first chunk of the archive in destination.
+// a maxSize of 0 means that the archive is written in a single chunk.
+// The chunks of a previous archive in destination are removed (with a warning)
+func newChunkWriter(destination string, maxSize int64, compression string, logg clog.PluggableLoggerInterface) (*chunkWriter, error) {
+	if !IsValidCompression(compression) {
+		return nil, fmt.Errorf("unsupported archive compression %s", compression)
+	}
+	err := os.MkdirAll(destination, 0755)
+	if err != nil {
+		return nil, err
+	}
+	// chunks left by a previous archive would be taken as part of this one
+	entries, err := os.ReadDir(destination)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && chunkFilePattern.MatchString(entry.Name()) {
+			chunkPath := filepath.Join(destination, entry.Name())
+			logg.Warn("removing %s, left by a previous archive", chunkPath)
+			if err := os.Remove(chunkPath); err != nil {
+				return nil, err
+			}
+		}
+	}
+	cw := &chunkWriter{destination: destination, maxSize: maxSize, compression: compression}
+	if err := cw.nextChunk(); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+// nextChunk - closes the current chunk (if any) and creates the next one
+func (cw *chunkWriter) nextChunk() error {
+	if cw.tarWriter != nil {
+		if err := cw.closeChunk(); err != nil {
+			return err
+		}
+	}
+	cw.chunk++
+	cw.size = 0
+	chunkPath := filepath.Join(cw.destination, chunkFileName(cw.chunk)+compressionExtension(cw.compression))
+	// Create a new tar archive file
+	// to be closed by closeChunk
+	file, err := os.Create(chunkPath)
+	if err != nil {
+		return err
+	}
+	compressor, err := newCompressor(file, cw.compression)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	cw.file = file
+	cw.compressor = compressor
+	cw.tarWriter = tar.NewWriter(compressor)
+	cw.chunks = append(cw.chunks, chunkPath)
+	return nil
+}
+
+func (cw *chunkWriter) closeChunk() error {
+	if err := cw.tarWriter.Close(); err != nil {
+		cw.compressor.Close()
+		cw.file.Close()
+		return err
+	}
+	if err := cw.compressor.Close(); err != nil {
+		cw.file.Close()
+		return err
+	}
+	return cw.file.Close()
+}
+
+// writeFile - writes the header and the content of a file in the current chunk,
+// rolling over to a new chunk first when needed
+func (cw *chunkWriter) writeFile(header *tar.Header, content io.Reader) error {
+	// the header and the padding of the content count as well, and room is kept
+	// for the end of the tar. The metadata of the last chunk is checked by finish
+	entrySize := tarBlockSize + roundUpToBlock(header.Size)
+	if cw.maxSize > 0 && cw.size > 0 && cw.size+entrySize+tarTrailerSize > cw.maxSize {
+		if err := cw.nextChunk(); err != nil {
+			return err
+		}
+	}
+	if err := cw.tarWriter.WriteHeader(header); err != nil {
+		return err
+	}
+	// the sha256 of the content is recorded in the archive manifest
+	hr := newHashingReader(content)
+	if content != nil {
+		if _, err := io.Copy(cw.tarWriter, hr); err != nil {
+			return err
+		}
+	}
+	cw.entries = append(cw.entries, manifestEntry{Name: header.Name, Chunk: cw.chunk, Size: hr.size, Digest: hr.digest()})
+	cw.size += entrySize
+	return nil
+}
+
+// finish - writes the archive manifest and the chunk count in the last chunk,
+// so that the extractor can verify every entry and tell whether trailing chunks are missing,
+// and closes the last chunk. When they don't fit in the last chunk, they are written in a new one
+func (cw *chunkWriter) finish() error {
+	manifest, err := json.Marshal(newArchiveManifest(cw.entries))
+	if err != nil {
+		return err
+	}
+	// the chunk count takes one block of content at most
+	metadataSize := tarBlockSize + roundUpToBlock(int64(len(manifest))) + 2*tarBlockSize
+	if cw.maxSize > 0 && cw.size > 0 && cw.size+metadataSize+tarTrailerSize > cw.maxSize {
+		if err := cw.nextChunk(); err != nil {
+			return err
+		}
+	}
+	if err := cw.writeMetadata(archiveManifestFile, manifest); err != nil {
+		return err
+	}
+	if err := cw.writeMetadata(chunksFile, []byte(strconv.Itoa(cw.chunk))); err != nil {
+		return err
+	}
+	err = cw.closeChunk()
+	cw.tarWriter = nil
+	return err
+}
+
+// writeMetadata - writes an entry that describes the archive itself, it is not part of the manifest
+func (cw *chunkWriter) writeMetadata(name string, data []byte) error {
+	header := &tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(data)),
+		Typeflag: tar.TypeReg,
+	}
+	if err := cw.tarWriter.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := cw.tarWriter.Write(data)
+	return err
+}
+
+// close - closes the current chunk, when finish was not called
+func (cw *chunkWriter) close() error {
+	if cw.tarWriter == nil {
+		return nil
+	}
+	err := cw.closeChunk()
+	cw.tarWriter = nil
+	return err
+}
+
+func chunkFileName(chunk int) string {
+	return fmt.Sprintf("%s_%06d.tar", archiveFilePrefix, chunk)
+}
+
+func roundUpToBlock(size int64) int64 {
+	return (size + tarBlockSize - 1) / tarBlockSize * tarBlockSize
+}
+
+// findChunks - returns the chunks of the archive found in archivePath, in order,
+// and the archive manifest. An error lists the chunks that are missing
+func findChunks(archivePath string) ([]string, archiveManifest, error) {
+	var manifest archiveManifest
+	entries, err := os.ReadDir(archivePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, manifest, nil
+		}
+		return nil, manifest, err
+	}
+	numbers := []int{}
+	names := map[int]string{}
+	for _, entry := range entries {
+		match := chunkFilePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		n, _ := strconv.Atoi(match[1])
+		if _, ok := names[n]; ok {
+			return nil, manifest, fmt.Errorf("archive in %s has several files for chunk %d", archivePath, n)
+		}
+		numbers = append(numbers, n)
+		names[n] = entry.Name()
+	}
+	if len(numbers) == 0 {
+		return nil, manifest, nil
+	}
+	sort.Ints(numbers)
+
+	// the last chunk holds the total number of chunks
+	last := numbers[len(numbers)-1]
+	count, manifest, err := readArchiveMetadata(filepath.Join(archivePath, names[last]))
+	if err != nil {
+		return nil, manifest, err
+	}
+
+	// the missing chunks are reported with the extension of the last one
+	extension := strings.TrimPrefix(names[last], chunkFileName(last))
+	missing := []string{}
+	expected := count
+	if count == 0 {
+		// the chunk count was not found: at least the chunk that follows is missing
+		expected = last + 1
+	}
+	for n := 1; n <= expected; n++ {
+		if _, ok := names[n]; !ok {
+			missing = append(missing, chunkFileName(n)+extension)
+		}
+	}
+	if len(missing) > 0 {
+		if count == 0 {
+			return nil, manifest, fmt.Errorf("archive in %s is incomplete, missing chunks: %s (and possibly following ones)", archivePath, strings.Join(missing, ", "))
+		}
+		return nil, manifest, fmt.Errorf("archive in %s is incomplete, missing chunks: %s", archivePath, strings.Join(missing, ", "))
+	}
+	if manifest.Entries == nil {
+		return nil, manifest, fmt.Errorf("archive manifest not found in %s", names[last])
+	}
+
+	chunks := []string{}
+	for n := 1; n <= count; n++ {
+		chunks = append(chunks, filepath.Join(archivePath, names[n]))
+	}
+	return chunks, manifest, nil
+}
+
+// parseChunkCount - parses the content of the chunk count entry
+func parseChunkCount(data []byte) (int, error) {
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
@@ -0,0 +1,115 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// the compression of the chunks of the archive:
+// layer blobs are already compressed, but the working-dir, the catalogs
+// and the release manifests compress very well
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// IsValidCompression - true for the compressions supported for the archive
+func IsValidCompression(compression string) bool {
+	switch compression {
+	case "", CompressionNone, CompressionGzip, CompressionZstd:
+		return true
+	}
+	return false
+}
+
+// compressionExtension - the extension added to the name of the chunks (mirror_000001.tar.gz...)
+func compressionExtension(compression string) string {
+	switch compression {
+	case CompressionGzip:
+		return ".gz"
+	case CompressionZstd:
+		return ".zst"
+	}
+	return ""
+}
+
+// newCompressor - wraps w so that what is written to it is compressed
+func newCompressor(w io.Writer, compression string) (io.WriteCloser, error) {
+	switch compression {
+	case "", CompressionNone:
+		return nopWriteCloser{w}, nil
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CompressionZstd:
+		return zstd.NewWriter(w)
+	}
+	return nil, fmt.Errorf("unsupported archive compression %s", compression)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// openChunk - opens a chunk of the archive for reading, the compression
+// is detected from the content, whatever the name of the chunk
+func openChunk(chunkPath string) (io.ReadCloser, error) {
+	f, err := os.Open(chunkPath)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(f)
+	magic, err := br.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		f.Close()
+		return nil, fmt.Errorf("error reading archive %s: %v", chunkPath, err)
+	}
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("error reading archive %s: %v", chunkPath, err)
+		}
+		return &chunkReader{Reader: gr, closers: []func() error{gr.Close, f.Close}}, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("error reading archive %s: %v", chunkPath, err)
+		}
+		return &chunkReader{Reader: zr, closers: []func() error{func() error { zr.Close(); return nil }, f.Close}}, nil
+	}
+	return &chunkReader{Reader: br, closers: []func() error{f.Close}}, nil
+}
+
+// chunkReader - the decompressed content of a chunk, closing it closes the decompressor and the file
+type chunkReader struct {
+	io.Reader
+	closers []func() error
+}
+
+func (c *chunkReader) Close() error {
+	var firstErr error
+	for _, closer := range c.closers {
+		if err := closer(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,153 @@
+package archive
+
+import (
+	"archive/tar"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	clog "github.com/openshift/oc-mirror/v2/pkg/log"
+)
+
+func TestArchive_Compression(t *testing.T) {
+	content := strings.Repeat("catalog content that compresses very well ", 1000)
+
+	writeArchive := func(t *testing.T, compression string) (string, []string) {
+		archiveDir := t.TempDir()
+		cw, err := newChunkWriter(archiveDir, 100*1024, compression, clog.New("trace"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 4; i++ {
+			header := &tar.Header{Name: fmt.Sprintf("working-dir/file-%d", i), Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+			if err := cw.writeFile(header, strings.NewReader(content)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := cw.finish(); err != nil {
+			t.Fatal(err)
+		}
+		return archiveDir, cw.chunks
+	}
+
+	archiveSize := func(t *testing.T, chunks []string) int64 {
+		var size int64
+		for _, chunk := range chunks {
+			fi, err := os.Stat(chunk)
+			if err != nil {
+				t.Fatal(err)
+			}
+			size += fi.Size()
+		}
+		return size
+	}
+
+	_, plainChunks := writeArchive(t, CompressionNone)
+	plainSize := archiveSize(t, plainChunks)
+
+	type testCase struct {
+		caseName    string
+		compression string
+		extension   string
+	}
+	testCases := []testCase{
+		{caseName: "Testing Compression (none) : should pass", compression: CompressionNone, extension: ".tar"},
+		{caseName: "Testing Compression (gzip) : should pass", compression: CompressionGzip, extension: ".tar.gz"},
+		{caseName: "Testing Compression (zstd) : should pass", compression: CompressionZstd, extension: ".tar.zst"},
+	}
+	for _, c := range testCases {
+		t.Run(c.caseName, func(t *testing.T) {
+			archiveDir, chunks := writeArchive(t, c.compression)
+			if len(chunks) != 2 {
+				t.Fatalf("expected 2 chunks, got %d", len(chunks))
+			}
+			for _, chunk := range chunks {
+				if !strings.HasSuffix(chunk, c.extension) {
+					t.Fatalf("unexpected chunk name %s", chunk)
+				}
+			}
+			if c.compression != CompressionNone && archiveSize(t, chunks) >= plainSize/10 {
+				t.Fatalf("the archive should be compressed: %d bytes vs %d bytes", archiveSize(t, chunks), plainSize)
+			}
+
+			dst := t.TempDir()
+			o, err := NewArchiveExtractor(archiveDir, filepath.Join(dst, "working-dir"), filepath.Join(dst, "cache"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := o.Verify(); err != nil {
+				t.Fatal(err)
+			}
+			if err := o.Unarchive(); err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 4; i++ {
+				data, err := os.ReadFile(filepath.Join(dst, "working-dir", fmt.Sprintf("file-%d", i)))
+				if err != nil || string(data) != content {
+					t.Fatalf("file-%d not extracted: %v", i, err)
+				}
+			}
+		})
+	}
+
+	t.Run("Testing Compression (detected from the content) : should pass", func(t *testing.T) {
+		archiveDir, chunks := writeArchive(t, CompressionZstd)
+		// the extension is lost, for example when the chunks are copied by hand
+		for n, chunk := range chunks {
+			if err := os.Rename(chunk, filepath.Join(archiveDir, chunkFileName(n+1))); err != nil {
+				t.Fatal(err)
+			}
+		}
+		dst := t.TempDir()
+		o, err := NewArchiveExtractor(archiveDir, filepath.Join(dst, "working-dir"), filepath.Join(dst, "cache"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := o.Unarchive(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Testing Compression (missing chunk) : should report the compressed name", func(t *testing.T) {
+		archiveDir, chunks := writeArchive(t, CompressionGzip)
+		if err := os.Remove(chunks[0]); err != nil {
+			t.Fatal(err)
+		}
+		_, err := NewArchiveExtractor(archiveDir, "", "")
+		if err == nil || !strings.Contains(err.Error(), chunkFileName(1)+".gz") {
+			t.Fatalf("should fail reporting the missing chunk: %v", err)
+		}
+	})
+
+	t.Run("Testing Compression (chunks of a previous archive) : should pass", func(t *testing.T) {
+		archiveDir := t.TempDir()
+		for _, name := range []string{chunkFileName(1) + ".gz", chunkFileName(7), "other.tar"} {
+			if err := os.WriteFile(filepath.Join(archiveDir, name), []byte("previous"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		cw, err := newChunkWriter(archiveDir, 0, CompressionNone, clog.New("trace"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cw.finish(); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{chunkFileName(1) + ".gz", chunkFileName(7)} {
+			if _, err := os.Stat(filepath.Join(archiveDir, name)); !os.IsNotExist(err) {
+				t.Fatalf("the chunk %s of the previous archive should be removed", name)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(archiveDir, "other.tar")); err != nil {
+			t.Fatalf("the other files should be kept: %v", err)
+		}
+	})
+
+	t.Run("Testing Compression (unsupported) : should fail", func(t *testing.T) {
+		if _, err := newChunkWriter(t.TempDir(), 0, "bzip2", clog.New("trace")); err == nil {
+			t.Fatalf("should fail")
+		}
+	})
+}
@@ -7,4 +7,10 @@ const (
 	cacheBlobsDir        = "docker/registry/v2/blobs"
 	cacheFilePrefix      = "docker/registry/v2"
 	workingDirectory     = "working-dir"
+	chunksFile           = "mirror_chunks"
+	archiveManifestFile  = "mirror_manifest.json"
+	embeddedConfigFile   = "archive-imageset-config.yaml"
+	tarBlockSize         = 512
+	tarTrailerSize       = 2 * tarBlockSize
+	gigabyte             = 1024 * 1024 * 1024
 )
@@ -3,7 +3,6 @@ package archive
 import (
 	"context"
 	"fmt"
-	"strconv"
 
 	"github.com/containers/image/v5/manifest"
 	"github.com/containers/image/v5/transports/alltransports"
@@ -16,15 +15,42 @@ type ImageBlobGatherer struct {
 }
 
 func NewImageBlobGatherer(opts *mirror.CopyOptions) BlobsGatherer {
+	return newImageBlobGatherer(opts)
+}
+
+// NewImageBlobSizesGatherer - the gatherer of the blobs of an image, with their sizes
+func NewImageBlobSizesGatherer(opts *mirror.CopyOptions) BlobSizesGatherer {
+	return newImageBlobGatherer(opts)
+}
+
+// newImageBlobGatherer - the gatherer works on its own copy of the options,
+// so that the images can be gathered concurrently without changing the options of the caller
+func newImageBlobGatherer(opts *mirror.CopyOptions) *ImageBlobGatherer {
+	gathererOpts := *opts
+	gathererOpts.RemoveSignatures = true
 	return &ImageBlobGatherer{
-		opts: opts,
+		opts: &gathererOpts,
 	}
 }
+
 func (o *ImageBlobGatherer) GatherBlobs(ctx context.Context, imgRef string) (blobs map[string]string, retErr error) {
+	sizes, err := o.GatherBlobSizes(ctx, imgRef)
+	if err != nil {
+		return nil, err
+	}
 	blobs = map[string]string{}
+	for digest := range sizes {
+		blobs[digest] = ""
+	}
+	return blobs, nil
+}
+
+// GatherBlobSizes - the digests of the manifests, configs and layers of the image
+// (of all its instances for a manifest list), with their sizes in bytes.
+// Only the manifests are fetched, the size is -1 when the manifest doesn't tell it
+func (o *ImageBlobGatherer) GatherBlobSizes(ctx context.Context, imgRef string) (blobs map[string]int64, retErr error) {
+	blobs = map[string]int64{}
 	o.opts.DeprecatedTLSVerify.WarnIfUsed([]string{"--src-tls-verify", "--dest-tls-verify"})
-	// o.opts.All = true
-	o.opts.RemoveSignatures, _ = strconv.ParseBool("true")
 
 	if err := mirror.ReexecIfNecessaryForImages([]string{imgRef}...); err != nil {
 		return blobs, err
@@ -48,6 +74,7 @@ func (o *ImageBlobGatherer) GatherBlobs(ctx context.Context, imgRef string) (blo
 	if err != nil {
 		return nil, err
 	}
+	defer img.Close()
 
 	manifestBytes, mime, err := img.GetManifest(ctx, nil)
 	if err != nil {
@@ -58,7 +85,7 @@ func (o *ImageBlobGatherer) GatherBlobs(ctx context.Context, imgRef string) (blo
 	if err != nil {
 		return nil, err
 	}
-	blobs[digest.String()] = ""
+	blobs[digest.String()] = int64(len(manifestBytes))
 
 	if manifest.MIMETypeIsMultiImage(mime) {
 		manifestList, err := manifest.ListFromBlob(manifestBytes, mime)
@@ -67,17 +94,17 @@ func (o *ImageBlobGatherer) GatherBlobs(ctx context.Context, imgRef string) (blo
 		}
 		instances := manifestList.Instances()
 		for _, digest := range instances {
-			blobs[digest.String()] = ""
 			singleArchManifest, singleArchMime, err := img.GetManifest(ctx, &digest)
 			if err != nil {
 				return nil, err
 			}
+			blobs[digest.String()] = int64(len(singleArchManifest))
 			singleArchBlobs, err := o.getBlobsOfManifest(singleArchManifest, singleArchMime)
 			if err != nil {
 				return nil, err
 			}
-			for _, digest := range singleArchBlobs {
-				blobs[digest] = ""
+			for digest, size := range singleArchBlobs {
+				blobs[digest] = size
 			}
 			if err != nil {
 				return nil, err
@@ -89,22 +116,23 @@ func (o *ImageBlobGatherer) GatherBlobs(ctx context.Context, imgRef string) (blo
 		if err != nil {
 			return nil, err
 		}
-		for _, digest := range manifestBlobs {
-			blobs[digest] = ""
+		for digest, size := range manifestBlobs {
+			blobs[digest] = size
 		}
 	}
 	return blobs, nil
 }
 
-func (o *ImageBlobGatherer) getBlobsOfManifest(manifestBytes []byte, mimeType string) ([]string, error) {
-	blobs := []string{}
+func (o *ImageBlobGatherer) getBlobsOfManifest(manifestBytes []byte, mimeType string) (map[string]int64, error) {
+	blobs := map[string]int64{}
 	singleArchManifest, err := manifest.FromBlob(manifestBytes, mimeType)
 	if err != nil {
 		return nil, fmt.Errorf("error unmarshalling manifest: %v", err)
 	}
 	for _, layer := range singleArchManifest.LayerInfos() {
-		blobs = append(blobs, layer.Digest.String())
+		blobs[layer.Digest.String()] = layer.Size
 	}
-	blobs = append(blobs, singleArchManifest.ConfigInfo().Digest.String())
+	config := singleArchManifest.ConfigInfo()
+	blobs[config.Digest.String()] = config.Size
 	return blobs, nil
 }
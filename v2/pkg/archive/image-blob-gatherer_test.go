@@ -7,6 +7,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/google/go-containerregistry/pkg/registry"
@@ -73,4 +74,32 @@ func TestImageBlobGatherer_GatherBlobs(t *testing.T) {
 	}
 
 	assert.Equal(t, expectedBlobs, blobs)
+
+	// the sizes are gathered concurrently, as the estimator does,
+	// without changing the options of the caller (which the copy above changed)
+	opts.RemoveSignatures = false
+	sizesGatherer := NewImageBlobSizesGatherer(&opts)
+	var wg sync.WaitGroup
+	results := make([]map[string]int64, 4)
+	errs := make([]error, len(results))
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = sizesGatherer.GatherBlobSizes(ctx, "docker://"+u.Host+"/test:latest")
+		}(i)
+	}
+	wg.Wait()
+	for i, sizes := range results {
+		if errs[i] != nil {
+			t.Fatalf("GatherBlobSizes failed: %v", errs[i])
+		}
+		assert.Equal(t, len(expectedBlobs), len(sizes))
+		for digest, size := range sizes {
+			if _, ok := expectedBlobs[digest]; !ok || size <= 0 {
+				t.Fatalf("unexpected blob %s of size %d", digest, size)
+			}
+		}
+	}
+	assert.False(t, opts.RemoveSignatures, "the options of the caller should not be changed")
 }
@@ -0,0 +1,223 @@
+package archive
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	digest "github.com/opencontainers/go-digest"
+)
+
+// archiveManifest - lists every entry of the archive with its size and sha256,
+// it is written at the end of the last chunk. The manifest is checksummed, not signed:
+// it detects the corruption of the archive, not who made it
+type archiveManifest struct {
+	// Digest is the checksum of the entries, in order: it detects a corrupted manifest
+	Digest  string          `json:"digest"`
+	Entries []manifestEntry `json:"entries"`
+}
+
+type manifestEntry struct {
+	Name   string `json:"name"`
+	Chunk  int    `json:"chunk"`
+	Size   int64  `json:"size"`
+	Digest string `json:"digest"`
+}
+
+// entriesDigest - the sha256 of all the entries of the manifest
+func entriesDigest(entries []manifestEntry) string {
+	h := sha256.New()
+	for _, e := range entries {
+		fmt.Fprintf(h, "%s\x00%d\x00%d\x00%s\n", e.Name, e.Chunk, e.Size, e.Digest)
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+func newArchiveManifest(entries []manifestEntry) archiveManifest {
+	return archiveManifest{Digest: entriesDigest(entries), Entries: entries}
+}
+
+// byName - the entries of the manifest by name, after checking the manifest checksum
+func (m archiveManifest) byName() (map[string]manifestEntry, error) {
+	if m.Digest != entriesDigest(m.Entries) {
+		return nil, fmt.Errorf("the archive manifest is corrupted: checksum mismatch")
+	}
+	result := make(map[string]manifestEntry, len(m.Entries))
+	for _, e := range m.Entries {
+		result[e.Name] = e
+	}
+	return result, nil
+}
+
+// hashingReader - computes the sha256 and the size of the content while it is read
+type hashingReader struct {
+	reader io.Reader
+	hash   hash.Hash
+	size   int64
+}
+
+func newHashingReader(r io.Reader) *hashingReader {
+	return &hashingReader{reader: r, hash: sha256.New()}
+}
+
+func (h *hashingReader) Read(p []byte) (int, error) {
+	n, err := h.reader.Read(p)
+	if n > 0 {
+		h.hash.Write(p[:n])
+		h.size += int64(n)
+	}
+	return n, err
+}
+
+func (h *hashingReader) digest() string {
+	return "sha256:" + hex.EncodeToString(h.hash.Sum(nil))
+}
+
+// verifyEntry - checks the size and the sha256 of an entry read from the archive against the manifest,
+// and for the blobs of the cache, against the digest found in their path
+func verifyEntry(name string, size int64, sum string, manifest map[string]manifestEntry) error {
+	expected, ok := manifest[name]
+	if !ok {
+		return fmt.Errorf("%s is not listed in the archive manifest", name)
+	}
+	if expected.Size != size || expected.Digest != sum {
+		return fmt.Errorf("%s is corrupted: expected size %d and digest %s, got size %d and digest %s", name, expected.Size, expected.Digest, size, sum)
+	}
+	if blobDigest, ok := blobDigestFromPath(name); ok && blobDigest.Algorithm() == digest.SHA256 && blobDigest.String() != sum {
+		return fmt.Errorf("blob %s is corrupted: got digest %s", blobDigest, sum)
+	}
+	return nil
+}
+
+// blobDigestFromPath - the digest of a blob of the cache: docker/registry/v2/blobs/sha256/xx/<digest>/data
+func blobDigestFromPath(name string) (digest.Digest, bool) {
+	if !strings.Contains(name, cacheBlobsDir) || path.Base(name) != "data" {
+		return "", false
+	}
+	encoded := path.Base(path.Dir(name))
+	algorithm := path.Base(path.Dir(path.Dir(path.Dir(name))))
+	d := digest.NewDigestFromEncoded(digest.Algorithm(algorithm), encoded)
+	if d.Validate() != nil {
+		return "", false
+	}
+	return d, true
+}
+
+// isArchiveMetadata - the entries that describe the archive itself
+func isArchiveMetadata(name string) bool {
+	return name == chunksFile || name == archiveManifestFile
+}
+
+// readArchiveMetadata - reads the chunk count and the archive manifest from the last chunk,
+// the chunk count is 0 when it is not found
+func readArchiveMetadata(chunkPath string) (int, archiveManifest, error) {
+	var count int
+	var manifest archiveManifest
+	chunkFile, err := openChunk(chunkPath)
+	if err != nil {
+		return 0, manifest, err
+	}
+	defer chunkFile.Close()
+	reader := tar.NewReader(chunkFile)
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return count, manifest, nil
+		}
+		if err != nil {
+			return 0, manifest, fmt.Errorf("error reading archive %s: %v", chunkPath, err)
+		}
+		switch header.Name {
+		case chunksFile:
+			data, err := io.ReadAll(reader)
+			if err != nil {
+				return 0, manifest, fmt.Errorf("error reading archive %s: %v", chunkPath, err)
+			}
+			count, err = parseChunkCount(data)
+			if err != nil {
+				return 0, manifest, fmt.Errorf("invalid chunk count in %s: %v", chunkPath, err)
+			}
+		case archiveManifestFile:
+			data, err := io.ReadAll(reader)
+			if err != nil {
+				return 0, manifest, fmt.Errorf("error reading archive %s: %v", chunkPath, err)
+			}
+			if err := json.Unmarshal(data, &manifest); err != nil {
+				return 0, manifest, fmt.Errorf("invalid archive manifest in %s: %v", chunkPath, err)
+			}
+		}
+	}
+}
+
+// Verify - checks every entry of every chunk against the archive manifest, without extracting anything.
+// All the problems found are reported
+func (o MirrorUnArchiver) Verify() error {
+	if len(o.chunks) == 0 {
+		return fmt.Errorf("no archive found")
+	}
+	problems := []string{}
+	found := map[string]bool{}
+	for _, chunkPath := range o.chunks {
+		err := readChunkEntries(chunkPath, func(header *tar.Header, content io.Reader) error {
+			if header.Typeflag != tar.TypeReg || isArchiveMetadata(header.Name) {
+				return nil
+			}
+			found[header.Name] = true
+			hr := newHashingReader(content)
+			if _, err := io.Copy(io.Discard, hr); err != nil {
+				return err
+			}
+			if err := verifyEntry(header.Name, hr.size, hr.digest(), o.manifest); err != nil {
+				problems = append(problems, err.Error())
+			}
+			return nil
+		})
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	missing := []string{}
+	for name := range o.manifest {
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	for _, name := range missing {
+		problems = append(problems, fmt.Sprintf("%s is missing from the archive", name))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("archive verification failed:\n%s", strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// readChunkEntries - calls fn for every entry of the chunk
+func readChunkEntries(chunkPath string, fn func(header *tar.Header, content io.Reader) error) error {
+	chunkFile, err := openChunk(chunkPath)
+	if err != nil {
+		return err
+	}
+	defer chunkFile.Close()
+	reader := tar.NewReader(chunkFile)
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading archive %s: %v", chunkPath, err)
+		}
+		if err := fn(header, reader); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package archive
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	clog "github.com/openshift/oc-mirror/v2/pkg/log"
+)
+
+func TestArchive_Integrity(t *testing.T) {
+	content := "some content"
+	// sha256 of "some content"
+	contentDigest := "sha256:290f493c44f5d63d06b374d0a5abd292fae38b92cab2fae5efefe1b0e9347f56"
+
+	writeArchive := func(t *testing.T, names []string, corrupt func(cw *chunkWriter)) string {
+		archiveDir := t.TempDir()
+		cw, err := newChunkWriter(archiveDir, 0, CompressionNone, clog.New("trace"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range names {
+			header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+			if err := cw.writeFile(header, strings.NewReader(content)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if corrupt != nil {
+			corrupt(cw)
+		}
+		if err := cw.finish(); err != nil {
+			t.Fatal(err)
+		}
+		return archiveDir
+	}
+
+	t.Run("Testing Verify : should pass", func(t *testing.T) {
+		archiveDir := writeArchive(t, []string{
+			"working-dir/file",
+			"docker/registry/v2/blobs/sha256/29/290f493c44f5d63d06b374d0a5abd292fae38b92cab2fae5efefe1b0e9347f56/data",
+		}, nil)
+		o, err := NewArchiveExtractor(archiveDir, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := o.Verify(); err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		if o.manifest["working-dir/file"].Digest != contentDigest {
+			t.Fatalf("unexpected digest %s", o.manifest["working-dir/file"].Digest)
+		}
+	})
+
+	t.Run("Testing Verify (corrupted file) : should fail", func(t *testing.T) {
+		archiveDir := writeArchive(t, []string{"working-dir/file", "working-dir/other"}, func(cw *chunkWriter) {
+			// the content in the archive no longer matches the manifest
+			cw.entries[1].Digest = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+		})
+		o, err := NewArchiveExtractor(archiveDir, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = o.Verify()
+		if err == nil || !strings.Contains(err.Error(), "working-dir/other is corrupted") {
+			t.Fatalf("should fail reporting the corrupted file: %v", err)
+		}
+		if strings.Contains(err.Error(), "working-dir/file ") {
+			t.Fatalf("only the corrupted file should be reported: %v", err)
+		}
+
+		dst := t.TempDir()
+		o, err = NewArchiveExtractor(archiveDir, filepath.Join(dst, "working-dir"), filepath.Join(dst, "cache"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = o.Unarchive()
+		if err == nil {
+			t.Fatalf("should fail")
+		}
+		if _, err := os.Stat(filepath.Join(dst, "working-dir", "other")); !os.IsNotExist(err) {
+			t.Fatalf("the corrupted file should not be extracted")
+		}
+	})
+
+	t.Run("Testing UnArchive (corrupted file over an existing one) : should keep the existing one", func(t *testing.T) {
+		archiveDir := writeArchive(t, []string{"working-dir/file"}, func(cw *chunkWriter) {
+			cw.entries[0].Digest = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+		})
+		dst := t.TempDir()
+		existing := filepath.Join(dst, "working-dir", "file")
+		if err := os.MkdirAll(filepath.Dir(existing), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(existing, []byte("good content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		o, err := NewArchiveExtractor(archiveDir, filepath.Join(dst, "working-dir"), filepath.Join(dst, "cache"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := o.Unarchive(); err == nil {
+			t.Fatalf("should fail")
+		}
+		data, err := os.ReadFile(existing)
+		if err != nil || string(data) != "good content" {
+			t.Fatalf("the existing file should be kept: %v", err)
+		}
+		entries, err := os.ReadDir(filepath.Dir(existing))
+		if err != nil || len(entries) != 1 {
+			t.Fatalf("no temporary file should be left: %v", entries)
+		}
+	})
+
+	t.Run("Testing Verify (no archive) : should fail", func(t *testing.T) {
+		o, err := NewArchiveExtractor(t.TempDir(), "", "")
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		err = o.Verify()
+		if err == nil || err.Error() != "no archive found" {
+			t.Fatalf("should fail reporting that there is no archive: %v", err)
+		}
+	})
+
+	t.Run("Testing Verify (corrupted blob) : should fail", func(t *testing.T) {
+		archiveDir := writeArchive(t, []string{
+			"docker/registry/v2/blobs/sha256/e6/e6c589cf5f402a60a83a01653304d7a8dcdd47b93a395a797b5622a18904bd66/data",
+		}, nil)
+		o, err := NewArchiveExtractor(archiveDir, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = o.Verify()
+		if err == nil || !strings.Contains(err.Error(), "blob sha256:e6c589cf5f402a60a83a01653304d7a8dcdd47b93a395a797b5622a18904bd66 is corrupted") {
+			t.Fatalf("should fail reporting the corrupted blob: %v", err)
+		}
+	})
+
+	t.Run("Testing Verify (missing file) : should fail", func(t *testing.T) {
+		archiveDir := writeArchive(t, []string{"working-dir/file"}, func(cw *chunkWriter) {
+			cw.entries = append(cw.entries, manifestEntry{Name: "working-dir/lost", Chunk: 1, Size: 1, Digest: contentDigest})
+		})
+		o, err := NewArchiveExtractor(archiveDir, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = o.Verify()
+		if err == nil || !strings.Contains(err.Error(), "working-dir/lost is missing from the archive") {
+			t.Fatalf("should fail reporting the missing file: %v", err)
+		}
+	})
+
+	t.Run("Testing manifest (tampered) : should fail", func(t *testing.T) {
+		m := newArchiveManifest([]manifestEntry{{Name: "working-dir/file", Chunk: 1, Size: 12, Digest: contentDigest}})
+		m.Entries[0].Size = 13
+		if _, err := m.byName(); err == nil {
+			t.Fatalf("should fail")
+		}
+	})
+}
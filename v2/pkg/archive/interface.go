@@ -10,6 +10,10 @@ type BlobsGatherer interface {
 	GatherBlobs(ctx context.Context, imgRef string) (map[string]string, error)
 }
 
+type BlobSizesGatherer interface {
+	GatherBlobSizes(ctx context.Context, imgRef string) (map[string]int64, error)
+}
+
 type Archiver interface {
 	BuildArchive(ctx context.Context, collectedImages []v1alpha3.CopyImageSchema) (string, error)
 	Close() error
@@ -18,4 +22,5 @@ type Archiver interface {
 type UnArchiver interface {
 	Close() error
 	Unarchive() error
+	Verify() error
 }
@@ -6,123 +6,298 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 )
 
 type MirrorUnArchiver struct {
 	UnArchiver
-	workingDir         string
-	cacheDir           string
-	archiveFile        *os.File
-	hasNoFilesToUnpack bool
+	workingDir string
+	cacheDir   string
+	chunks     []string
+	manifest   map[string]manifestEntry
 }
 
+// NewArchiveExtractor - finds all the chunks of the archive in archivePath,
+// and reads the archive manifest used to verify the entries as they are extracted.
+// An error reports the chunks that are missing, there is nothing to extract without chunks
 func NewArchiveExtractor(archivePath, workingDir, cacheDir string) (MirrorUnArchiver, error) {
-	//TODO handle several chunks
-	chunk := 1
-	archiveFileName := fmt.Sprintf("%s_%06d.tar", archiveFilePrefix, chunk)
-	chunkPath := filepath.Join(archivePath, archiveFileName)
-
-	hasNoFilesToUnpack := false
-
-	chunkFile, err := os.Open(chunkPath)
+	chunks, manifest, err := findChunks(archivePath)
 	if err != nil {
-		if os.IsNotExist(err) {
-			hasNoFilesToUnpack = true
-		} else {
-			return MirrorUnArchiver{}, err
-		}
-
+		return MirrorUnArchiver{}, err
+	}
+	if len(chunks) == 0 {
+		return MirrorUnArchiver{workingDir: workingDir, cacheDir: cacheDir}, nil
+	}
+	entries, err := manifest.byName()
+	if err != nil {
+		return MirrorUnArchiver{}, err
 	}
 
 	ae := MirrorUnArchiver{
-		workingDir:         workingDir,
-		cacheDir:           cacheDir,
-		archiveFile:        chunkFile,
-		hasNoFilesToUnpack: hasNoFilesToUnpack,
+		workingDir: workingDir,
+		cacheDir:   cacheDir,
+		chunks:     chunks,
+		manifest:   entries,
 	}
 	return ae, nil
 }
 
 func (o MirrorUnArchiver) Close() error {
-	return o.archiveFile.Close()
+	return nil
 }
 
-// Unarchive extracts:
+// Unarchive extracts, from all the chunks in order:
 // * docker/v2* to cacheDir
 // * working-dir to workingDir
+// each file is checked against the archive manifest while it is extracted
 func (o MirrorUnArchiver) Unarchive() error {
-	if !o.hasNoFilesToUnpack {
-		reader := tar.NewReader(o.archiveFile)
-		// make sure workingDir exists
-		err := os.MkdirAll(o.workingDir, 0755)
+	if len(o.chunks) == 0 {
+		return nil
+	}
+	// make sure workingDir exists
+	err := os.MkdirAll(o.workingDir, 0755)
+	if err != nil {
+		return fmt.Errorf("unable to create folder %s: %v", o.workingDir, err)
+	}
+	// make sure cacheDir exists
+	err = os.MkdirAll(o.cacheDir, 0755)
+	if err != nil {
+		return fmt.Errorf("unable to create folder %s: %v", o.cacheDir, err)
+	}
+	for _, chunk := range o.chunks {
+		if err := o.unarchiveChunk(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unarchiveChunk extracts the files of one chunk of the archive
+func (o MirrorUnArchiver) unarchiveChunk(chunkPath string) error {
+	chunkFile, err := openChunk(chunkPath)
+	if err != nil {
+		return err
+	}
+	defer chunkFile.Close()
+	reader := tar.NewReader(chunkFile)
+	for {
+		header, err := reader.Next()
+
+		// break the infinite loop when EOF
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
 		if err != nil {
-			return fmt.Errorf("unable to create folder %s: %v", o.workingDir, err)
+			return fmt.Errorf("error reading archive %s: %v", chunkPath, err)
+		}
+
+		if header == nil {
+			continue
+		}
+
+		switch header.Typeflag {
+		case tar.TypeReg:
+			// extracted below
+		case tar.TypeDir, tar.TypeXGlobalHeader:
+			// all parent folders are created recursively when the files are extracted
+			continue
+		case tar.TypeSymlink, tar.TypeLink:
+			return fmt.Errorf("error reading archive %s: %s is a link to %s, links are not allowed in the archive", chunkPath, header.Name, header.Linkname)
+		case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+			return fmt.Errorf("error reading archive %s: %s is a device or a named pipe, they are not allowed in the archive", chunkPath, header.Name)
+		default:
+			return fmt.Errorf("error reading archive %s: %s has an unsupported type %q", chunkPath, header.Name, header.Typeflag)
 		}
-		// make sure cacheDir exists
-		err = os.MkdirAll(o.cacheDir, 0755)
+
+		descriptor, err := o.targetPath(header.Name)
 		if err != nil {
-			return fmt.Errorf("unable to create folder %s: %v", o.cacheDir, err)
+			return fmt.Errorf("error reading archive %s: %v", chunkPath, err)
+		}
+		if descriptor == "" {
+			// the imageSetConfig included in the tar is extracted
+			// by ExtractImageSetConfig, we ignore it here
+			// as well as any other files that are not
+			// working-dir or cache
+			continue
 		}
-		for {
-			header, err := reader.Next()
 
-			// break the infinite loop when EOF
-			if errors.Is(err, io.EOF) {
-				break
+		// copy contents, computing the sha256 on the way: a corrupted file
+		// must not replace the one in the cache or the working-dir.
+		// The file is at least writable by the user, since with every
+		// UnArchive we should be able to rewrite it
+		hr := newHashingReader(reader)
+		err = writeFileAtomically(descriptor, hr, os.FileMode(header.Mode).Perm()|0600, func() error {
+			if err := verifyEntry(header.Name, hr.size, hr.digest(), o.manifest); err != nil {
+				return fmt.Errorf("error verifying archive %s: %v", chunkPath, err)
 			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-			if err != nil {
-				return fmt.Errorf("error reading archive %s: %v", o.archiveFile.Name(), err)
-			}
+// errConfigFound - stops reading the chunk once the image set configuration is extracted
+var errConfigFound = errors.New("image set configuration found")
 
-			if header == nil {
-				continue
-			}
-			// taking only files into account
-			// because we are considering that all parent folders will be
-			// created recursively, and that, to the best of our knowledge
-			// the archive doesn't include any symbolic links
-			if header.Typeflag == tar.TypeReg {
-				descriptor := ""
-				// case file belongs to working-dir
-				if strings.Contains(header.Name, workingDirectory) {
-					workingDirParent := filepath.Dir(o.workingDir)
-					descriptor = filepath.Join(workingDirParent, header.Name)
-				} else if strings.Contains(header.Name, cacheFilePrefix) {
-					// case file belongs to the cache
-					descriptor = filepath.Join(o.cacheDir, header.Name)
-				} else {
-					// for the moment we ignore imageSetConfig that is
-					// included in the tar
-					// as well as any other files that are not
-					// working-dir or cache
-					continue
-				}
-				// make sure all the parent directories exist
-				descriptorParent := filepath.Dir(descriptor)
-				if err := os.MkdirAll(descriptorParent, 0755); err != nil {
-					return fmt.Errorf("unable to create folder %s: %v", descriptorParent, err)
-				}
-				// if it's a file create it, making sure it's at least writable and executable by the user
-				// since with every UnArchive, we should be able to rewrite the file
-				f, err := os.OpenFile(descriptor, os.O_CREATE|os.O_RDWR, os.FileMode(header.Mode)|0755)
-				if err != nil {
-					return fmt.Errorf("unable to create file %s: %v", descriptor, err)
-				}
-				// copy  contents
-				if _, err := io.Copy(f, reader); err != nil {
-					return fmt.Errorf("error copying file %s: %v", descriptor, err)
-				}
-
-				// manually close here after each file operation; defering would cause each file close
-				// to wait until all operations have completed.
-				f.Close()
+// ExtractImageSetConfig - extracts the image set configuration embedded in the archive found in
+// archivePath to the working-dir, after checking it against the archive manifest, and returns its path.
+// Without archive, the configuration extracted from the previous one is returned,
+// and an empty path when there is none
+func ExtractImageSetConfig(archivePath, workingDir string) (string, error) {
+	target := filepath.Join(workingDir, embeddedConfigFile)
+	chunks, manifest, err := findChunks(archivePath)
+	if err != nil {
+		return "", err
+	}
+	if len(chunks) == 0 {
+		if _, err := os.Stat(target); err == nil {
+			return target, nil
+		}
+		return "", nil
+	}
+	entries, err := manifest.byName()
+	if err != nil {
+		return "", err
+	}
+	var entry *manifestEntry
+	for i, e := range manifest.Entries {
+		if strings.HasPrefix(e.Name, imageSetConfigPrefix) {
+			entry = &manifest.Entries[i]
+		}
+	}
+	if entry == nil {
+		// the configuration of a previous archive doesn't describe this one
+		os.Remove(target)
+		return "", nil
+	}
+	if entry.Chunk < 1 || entry.Chunk > len(chunks) {
+		return "", fmt.Errorf("the archive manifest lists %s in chunk %d, out of the %d chunks", entry.Name, entry.Chunk, len(chunks))
+	}
+	if err := os.MkdirAll(workingDir, 0755); err != nil {
+		return "", fmt.Errorf("unable to create folder %s: %v", workingDir, err)
+	}
 
+	chunkPath := chunks[entry.Chunk-1]
+	err = readChunkEntries(chunkPath, func(header *tar.Header, content io.Reader) error {
+		if header.Name != entry.Name {
+			return nil
+		}
+		hr := newHashingReader(content)
+		err := writeFileAtomically(target, hr, 0644, func() error {
+			if err := verifyEntry(header.Name, hr.size, hr.digest(), entries); err != nil {
+				return fmt.Errorf("error verifying archive %s: %v", chunkPath, err)
 			}
+			return nil
+		})
+		if err != nil {
+			return err
 		}
-		return nil
+		return errConfigFound
+	})
+	if errors.Is(err, errConfigFound) {
+		return target, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("%s is missing from %s", entry.Name, chunkPath)
+}
+
+// targetPath - the path where an entry of the archive is extracted:
+// working-dir/* goes to the working-dir and docker/registry/v2/* goes to the cache.
+// An empty path is returned for the entries that are not extracted.
+// The name is normalized, and an entry that would be written outside
+// the working-dir or the cache is rejected
+func (o MirrorUnArchiver) targetPath(name string) (string, error) {
+	if name == "" || strings.Contains(name, "\\") {
+		return "", fmt.Errorf("invalid entry name %q", name)
+	}
+	cleaned := path.Clean(name)
+	if path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("entry %s points outside of the working-dir and the cache", name)
+	}
+
+	var root, relative string
+	switch {
+	case strings.HasPrefix(cleaned, workingDirectory+"/"):
+		root = o.workingDir
+		relative = strings.TrimPrefix(cleaned, workingDirectory+"/")
+	case strings.HasPrefix(cleaned, cacheFilePrefix+"/"):
+		root = o.cacheDir
+		relative = cleaned
+	default:
+		return "", nil
+	}
+
+	target := filepath.Join(root, filepath.FromSlash(relative))
+	if !isWithin(root, target) {
+		return "", fmt.Errorf("entry %s points outside of %s", name, root)
+	}
+	// make sure all the parent directories exist, and that none of them
+	// is a symbolic link leading outside of the root
+	parent := filepath.Dir(target)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return "", fmt.Errorf("unable to create folder %s: %v", parent, err)
+	}
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	resolvedParent, err := filepath.EvalSymlinks(parent)
+	if err != nil {
+		return "", err
+	}
+	if !isWithin(resolvedRoot, resolvedParent) {
+		return "", fmt.Errorf("entry %s resolves outside of %s", name, root)
+	}
+	return target, nil
+}
+
+// isWithin - true when target is root or one of its descendants
+func isWithin(root, target string) bool {
+	rel, err := filepath.Rel(root, target)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// writeFileAtomically - writes content to a temporary file next to descriptor, and renames it to descriptor.
+// An existing file is replaced as a whole (a longer previous content never survives), and an existing
+// symbolic link is replaced rather than followed. verify is called once the content is written:
+// on error, the temporary file is removed and descriptor is left untouched
+func writeFileAtomically(descriptor string, content io.Reader, mode os.FileMode, verify func() error) error {
+	f, err := os.CreateTemp(filepath.Dir(descriptor), "."+filepath.Base(descriptor)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("unable to create file %s: %v", descriptor, err)
+	}
+	tmp := f.Name()
+	if _, err := io.Copy(f, content); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("error copying file %s: %v", descriptor, err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("error copying file %s: %v", descriptor, err)
+	}
+	if err := verify(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Chmod(tmp, mode); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("unable to create file %s: %v", descriptor, err)
+	}
+	if err := os.Rename(tmp, descriptor); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("unable to create file %s: %v", descriptor, err)
 	}
 	return nil
 }
@@ -3,25 +3,21 @@ package archive
 import (
 	"archive/tar"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	clog "github.com/openshift/oc-mirror/v2/pkg/log"
+
+	digest "github.com/opencontainers/go-digest"
 )
 
 func TestUnArchiver_UnArchive(t *testing.T) {
 	testFolder := t.TempDir()
 	defer os.RemoveAll(testFolder)
 
-	// Create a new tar archive file
-	archiveFileName := fmt.Sprintf("%s_%06d.tar", archiveFilePrefix, 1)
-	archivePath := filepath.Join(testFolder, archiveFileName)
-	// to be closed by BuildArchive
-	archiveFile, err := os.Create(archivePath)
-	if err != nil {
-		t.Fatalf("should not fail")
-	}
-	err = prepareFakeTar(archiveFile)
+	err := prepareFakeTar(testFolder)
 	if err != nil {
 		t.Fatalf("should not fail")
 	}
@@ -34,101 +30,470 @@ func TestUnArchiver_UnArchive(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	err = o.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
 }
 
-func prepareFakeTar(tarFile *os.File) error {
+func TestUnArchiver_NoArchive(t *testing.T) {
+	t.Run("Testing UnArchive (empty directory) : should pass", func(t *testing.T) {
+		dst := t.TempDir()
+		o, err := NewArchiveExtractor(t.TempDir(), filepath.Join(dst, "working-dir"), filepath.Join(dst, "cache"))
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		if err := o.Unarchive(); err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(dst, "working-dir")); !os.IsNotExist(err) {
+			t.Fatalf("nothing should be extracted")
+		}
+	})
+}
+
+// prepareFakeTar - writes an archive made of the fake working-dir and cache
+func prepareFakeTar(archiveDir string) error {
 	workingDirFake := "../../tests/working-dir-fake"
 	cacheDirFake := "../../tests/cache-fake"
-	tarWriter := tar.NewWriter(tarFile)
+	cw, err := newChunkWriter(archiveDir, 0, CompressionNone, clog.New("trace"))
+	if err != nil {
+		return err
+	}
 
-	err := filepath.Walk(workingDirFake, func(path string, info os.FileInfo, incomingError error) error {
-		if incomingError != nil {
-			return incomingError
-		}
-		if info.IsDir() { // skip directories
-			return nil
-		}
+	addFolder := func(folder string, nameInTar func(rel string) string) error {
+		return filepath.Walk(folder, func(path string, info os.FileInfo, incomingError error) error {
+			if incomingError != nil {
+				return incomingError
+			}
+			if info.IsDir() { // skip directories
+				return nil
+			}
 
-		header, err := tar.FileInfoHeader(info, info.Name())
-		if err != nil {
-			return err
+			header, err := tar.FileInfoHeader(info, info.Name())
+			if err != nil {
+				return err
+			}
+			relativePathToAdd, err := filepath.Rel(folder, path)
+			if err != nil {
+				return err
+			}
+			header.Name = nameInTar(relativePathToAdd)
+
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			return cw.writeFile(header, file)
+		})
+	}
+
+	err = addFolder(workingDirFake, func(rel string) string { return filepath.Join("working-dir", rel) })
+	if err != nil {
+		return err
+	}
+	// the blobs of the fake cache hold placeholder content: they are
+	// stored under the digest of that content, as in a real cache
+	err = addFolder(cacheDirFake, func(rel string) string {
+		if d, ok := blobDigestFromPath(rel); ok {
+			data, err := os.ReadFile(filepath.Join(cacheDirFake, rel))
+			if err == nil {
+				sum := digest.FromBytes(data)
+				return filepath.Join(strings.TrimSuffix(rel, filepath.Join(d.Encoded()[:2], d.Encoded(), "data")), sum.Encoded()[:2], sum.Encoded(), "data")
+			}
 		}
+		return rel
+	})
+	if err != nil {
+		return err
+	}
+	return cw.finish()
+}
 
-		// Use full path as name (FileInfoHeader only takes the basename)
-		// If we don't do this the directory strucuture would
-		// not be preserved
-		// https://golang.org/src/archive/tar/common.go?#L626
-		relativePathToAdd, err := filepath.Rel(workingDirFake, path)
+func TestUnArchiver_MultipleChunks(t *testing.T) {
+	archiveDir := t.TempDir()
+	srcDir := t.TempDir()
+
+	// 6 files of 1000 bytes, in chunks of at most 8192 bytes:
+	// each file takes 1536 bytes in the tar (header and padding),
+	// so the first chunk holds 4 files and the second one 2 files
+	cw, err := newChunkWriter(archiveDir, 8192, CompressionNone, clog.New("trace"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := strings.Repeat("a", 1000)
+	for i := 0; i < 6; i++ {
+		name := fmt.Sprintf("working-dir/file-%d", i)
+		if err := cw.writeFile(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}, strings.NewReader(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a file larger than the chunk size is written alone in its own chunk,
+	// the archive manifest and the chunk count follow in a fourth chunk
+	big := strings.Repeat("b", 10000)
+	if err := cw.writeFile(&tar.Header{Name: "working-dir/big", Mode: 0644, Size: int64(len(big)), Typeflag: tar.TypeReg}, strings.NewReader(big)); err != nil {
+		t.Fatal(err)
+	}
+	if err := cw.finish(); err != nil {
+		t.Fatal(err)
+	}
+	if len(cw.chunks) != 4 {
+		t.Fatalf("expected 4 chunks, got %d", len(cw.chunks))
+	}
+	for _, chunk := range []string{cw.chunks[0], cw.chunks[1], cw.chunks[3]} {
+		fi, err := os.Stat(chunk)
 		if err != nil {
-			return err
+			t.Fatal(err)
 		}
-		header.Name = filepath.Join("working-dir", relativePathToAdd)
-
-		// Write the header to the tar archive
-		if err := tarWriter.WriteHeader(header); err != nil {
-			return err
+		if fi.Size() > 8192 {
+			t.Fatalf("chunk %s is larger than the maximum size: %d", chunk, fi.Size())
 		}
+	}
 
-		// Open the file for reading
-		file, err := os.Open(path)
+	t.Run("Testing UnArchive (all chunks) : should pass", func(t *testing.T) {
+		o, err := NewArchiveExtractor(archiveDir, filepath.Join(srcDir, "working-dir"), filepath.Join(srcDir, "cache"))
 		if err != nil {
-			return err
+			t.Fatal(err)
+		}
+		if err := o.Unarchive(); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 6; i++ {
+			data, err := os.ReadFile(filepath.Join(srcDir, "working-dir", fmt.Sprintf("file-%d", i)))
+			if err != nil || string(data) != content {
+				t.Fatalf("file-%d not extracted: %v", i, err)
+			}
+		}
+		data, err := os.ReadFile(filepath.Join(srcDir, "working-dir", "big"))
+		if err != nil || string(data) != big {
+			t.Fatalf("big file not extracted: %v", err)
 		}
-		defer file.Close()
+	})
 
-		// Copy the file contents to the tar archive
-		if _, err := io.Copy(tarWriter, file); err != nil {
-			return err
+	t.Run("Testing UnArchive (missing chunk) : should fail", func(t *testing.T) {
+		if err := os.Rename(filepath.Join(archiveDir, chunkFileName(2)), filepath.Join(srcDir, chunkFileName(2))); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Rename(filepath.Join(srcDir, chunkFileName(2)), filepath.Join(archiveDir, chunkFileName(2))) //nolint:errcheck
+		_, err := NewArchiveExtractor(archiveDir, filepath.Join(srcDir, "working-dir"), filepath.Join(srcDir, "cache"))
+		if err == nil || !strings.Contains(err.Error(), chunkFileName(2)) {
+			t.Fatalf("should fail reporting the missing chunk: %v", err)
 		}
+	})
 
-		return nil
+	t.Run("Testing UnArchive (missing last chunk) : should fail", func(t *testing.T) {
+		if err := os.Rename(filepath.Join(archiveDir, chunkFileName(4)), filepath.Join(srcDir, chunkFileName(4))); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Rename(filepath.Join(srcDir, chunkFileName(4)), filepath.Join(archiveDir, chunkFileName(4))) //nolint:errcheck
+		_, err := NewArchiveExtractor(archiveDir, filepath.Join(srcDir, "working-dir"), filepath.Join(srcDir, "cache"))
+		if err == nil || !strings.Contains(err.Error(), chunkFileName(4)) {
+			t.Fatalf("should fail reporting the missing chunk: %v", err)
+		}
 	})
+}
+
+func TestUnArchiver_MetadataInNewChunk(t *testing.T) {
+	archiveDir := t.TempDir()
+	srcDir := t.TempDir()
+
+	// 4 files of 1000 bytes fill the first chunk of at most 8192 bytes (4*1536 bytes and the end of the tar):
+	// the archive manifest and the chunk count don't fit, they are written in a second chunk
+	cw, err := newChunkWriter(archiveDir, 8192, CompressionNone, clog.New("trace"))
 	if err != nil {
-		return err
+		t.Fatal(err)
 	}
-	err = filepath.Walk(cacheDirFake, func(path string, info os.FileInfo, incomingError error) error {
-		if incomingError != nil {
-			return incomingError
+	content := strings.Repeat("a", 1000)
+	for i := 0; i < 4; i++ {
+		name := fmt.Sprintf("working-dir/file-%d", i)
+		if err := cw.writeFile(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}, strings.NewReader(content)); err != nil {
+			t.Fatal(err)
 		}
-		if info.IsDir() { // skip directories
-			return nil
+	}
+	if err := cw.finish(); err != nil {
+		t.Fatal(err)
+	}
+	if len(cw.chunks) != 2 {
+		t.Fatalf("expected 2 chunks, got %d", len(cw.chunks))
+	}
+	for _, chunk := range cw.chunks {
+		fi, err := os.Stat(chunk)
+		if err != nil {
+			t.Fatal(err)
 		}
+		if fi.Size() > 8192 {
+			t.Fatalf("chunk %s is larger than the maximum size: %d", chunk, fi.Size())
+		}
+	}
 
-		header, err := tar.FileInfoHeader(info, info.Name())
+	t.Run("Testing UnArchive (metadata in its own chunk) : should pass", func(t *testing.T) {
+		o, err := NewArchiveExtractor(archiveDir, filepath.Join(srcDir, "working-dir"), filepath.Join(srcDir, "cache"))
 		if err != nil {
-			return err
+			t.Fatal(err)
+		}
+		if err := o.Verify(); err != nil {
+			t.Fatal(err)
+		}
+		if err := o.Unarchive(); err != nil {
+			t.Fatal(err)
 		}
+		for i := 0; i < 4; i++ {
+			data, err := os.ReadFile(filepath.Join(srcDir, "working-dir", fmt.Sprintf("file-%d", i)))
+			if err != nil || string(data) != content {
+				t.Fatalf("file-%d not extracted: %v", i, err)
+			}
+		}
+	})
+}
+
+func TestUnArchiver_UnsafeEntries(t *testing.T) {
+	content := "some content"
 
-		// Use full path as name (FileInfoHeader only takes the basename)
-		// If we don't do this the directory strucuture would
-		// not be preserved
-		// https://golang.org/src/archive/tar/common.go?#L626
-		header.Name, err = filepath.Rel(cacheDirFake, path)
+	writeArchive := func(t *testing.T, headers ...*tar.Header) string {
+		archiveDir := t.TempDir()
+		cw, err := newChunkWriter(archiveDir, 0, CompressionNone, clog.New("trace"))
 		if err != nil {
-			return err
+			t.Fatal(err)
 		}
-
-		// Write the header to the tar archive
-		if err := tarWriter.WriteHeader(header); err != nil {
-			return err
+		for _, header := range headers {
+			var reader *strings.Reader
+			if header.Typeflag == tar.TypeReg {
+				header.Size = int64(len(content))
+				reader = strings.NewReader(content)
+			}
+			if reader == nil {
+				err = cw.writeFile(header, nil)
+			} else {
+				err = cw.writeFile(header, reader)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := cw.finish(); err != nil {
+			t.Fatal(err)
 		}
+		return archiveDir
+	}
 
-		// Open the file for reading
-		file, err := os.Open(path)
+	unarchive := func(t *testing.T, archiveDir, dst string) error {
+		o, err := NewArchiveExtractor(archiveDir, filepath.Join(dst, "working-dir"), filepath.Join(dst, "cache"))
 		if err != nil {
-			return err
+			t.Fatal(err)
+		}
+		return o.Unarchive()
+	}
+
+	failCases := []struct {
+		caseName string
+		header   *tar.Header
+		expErr   string
+	}{
+		{
+			caseName: "Testing UnArchive (path traversal) : should fail",
+			header:   &tar.Header{Name: "working-dir/../../escaped", Mode: 0644, Typeflag: tar.TypeReg},
+			expErr:   "points outside of",
+		},
+		{
+			caseName: "Testing UnArchive (path traversal in the cache) : should fail",
+			header:   &tar.Header{Name: "docker/registry/v2/../../../../escaped", Mode: 0644, Typeflag: tar.TypeReg},
+			expErr:   "points outside of",
+		},
+		{
+			caseName: "Testing UnArchive (absolute path) : should fail",
+			header:   &tar.Header{Name: "/working-dir/escaped", Mode: 0644, Typeflag: tar.TypeReg},
+			expErr:   "points outside of",
+		},
+		{
+			caseName: "Testing UnArchive (symbolic link) : should fail",
+			header:   &tar.Header{Name: "working-dir/link", Linkname: "/etc/passwd", Mode: 0777, Typeflag: tar.TypeSymlink},
+			expErr:   "links are not allowed",
+		},
+		{
+			caseName: "Testing UnArchive (hard link) : should fail",
+			header:   &tar.Header{Name: "working-dir/link", Linkname: "/etc/passwd", Mode: 0644, Typeflag: tar.TypeLink},
+			expErr:   "links are not allowed",
+		},
+		{
+			caseName: "Testing UnArchive (device) : should fail",
+			header:   &tar.Header{Name: "working-dir/dev", Mode: 0644, Typeflag: tar.TypeChar, Devmajor: 1, Devminor: 3},
+			expErr:   "is a device",
+		},
+	}
+	for _, c := range failCases {
+		t.Run(c.caseName, func(t *testing.T) {
+			dst := t.TempDir()
+			archiveDir := writeArchive(t, c.header)
+			err := unarchive(t, archiveDir, dst)
+			if err == nil || !strings.Contains(err.Error(), c.expErr) {
+				t.Fatalf("should fail with %q: %v", c.expErr, err)
+			}
+			if _, err := os.Stat(filepath.Join(dst, "escaped")); !os.IsNotExist(err) {
+				t.Fatalf("no file should be written outside of the working-dir and the cache")
+			}
+		})
+	}
+
+	t.Run("Testing UnArchive (normalized names) : should pass", func(t *testing.T) {
+		dst := t.TempDir()
+		archiveDir := writeArchive(t, &tar.Header{Name: "working-dir/./sub//file", Mode: 0644, Typeflag: tar.TypeReg})
+		if err := unarchive(t, archiveDir, dst); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(filepath.Join(dst, "working-dir", "sub", "file"))
+		if err != nil || string(data) != content {
+			t.Fatalf("file not extracted: %v", err)
+		}
+	})
+
+	t.Run("Testing UnArchive (file mode) : should keep the mode of the entry", func(t *testing.T) {
+		dst := t.TempDir()
+		archiveDir := writeArchive(t,
+			&tar.Header{Name: "working-dir/file", Mode: 0644, Typeflag: tar.TypeReg},
+			&tar.Header{Name: "working-dir/script", Mode: 0755, Typeflag: tar.TypeReg},
+			&tar.Header{Name: "working-dir/setuid", Mode: 04755, Typeflag: tar.TypeReg},
+		)
+		if err := unarchive(t, archiveDir, dst); err != nil {
+			t.Fatal(err)
+		}
+		for name, mode := range map[string]os.FileMode{"file": 0644, "script": 0755, "setuid": 0755} {
+			fi, err := os.Stat(filepath.Join(dst, "working-dir", name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fi.Mode() != mode {
+				t.Fatalf("expected mode %v for %s, got %v", mode, name, fi.Mode())
+			}
+		}
+	})
+
+	t.Run("Testing UnArchive (name containing working-dir) : should not be extracted", func(t *testing.T) {
+		dst := t.TempDir()
+		archiveDir := writeArchive(t, &tar.Header{Name: "other/working-dir/file", Mode: 0644, Typeflag: tar.TypeReg})
+		if err := unarchive(t, archiveDir, dst); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(filepath.Join(dst, "working-dir", "file")); !os.IsNotExist(err) {
+			t.Fatalf("the entry should be ignored")
+		}
+	})
+
+	t.Run("Testing UnArchive (existing longer file) : should be replaced", func(t *testing.T) {
+		dst := t.TempDir()
+		existing := filepath.Join(dst, "working-dir", "file")
+		if err := os.MkdirAll(filepath.Dir(existing), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(existing, []byte(strings.Repeat("x", 100)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		archiveDir := writeArchive(t, &tar.Header{Name: "working-dir/file", Mode: 0644, Typeflag: tar.TypeReg})
+		if err := unarchive(t, archiveDir, dst); err != nil {
+			t.Fatal(err)
 		}
-		defer file.Close()
+		data, err := os.ReadFile(existing)
+		if err != nil || string(data) != content {
+			t.Fatalf("file not replaced: %q %v", string(data), err)
+		}
+	})
 
-		// Copy the file contents to the tar archive
-		if _, err := io.Copy(tarWriter, file); err != nil {
-			return err
+	t.Run("Testing UnArchive (existing symbolic link) : should be replaced, not followed", func(t *testing.T) {
+		dst := t.TempDir()
+		outside := filepath.Join(t.TempDir(), "outside")
+		if err := os.WriteFile(outside, []byte("untouched"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		link := filepath.Join(dst, "working-dir", "file")
+		if err := os.MkdirAll(filepath.Dir(link), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(outside, link); err != nil {
+			t.Fatal(err)
 		}
+		archiveDir := writeArchive(t, &tar.Header{Name: "working-dir/file", Mode: 0644, Typeflag: tar.TypeReg})
+		if err := unarchive(t, archiveDir, dst); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(outside)
+		if err != nil || string(data) != "untouched" {
+			t.Fatalf("the target of the link should not be written: %q %v", string(data), err)
+		}
+	})
 
-		return nil
+	t.Run("Testing UnArchive (symbolic link to a folder outside) : should fail", func(t *testing.T) {
+		dst := t.TempDir()
+		outside := t.TempDir()
+		if err := os.MkdirAll(filepath.Join(dst, "working-dir"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(outside, filepath.Join(dst, "working-dir", "sub")); err != nil {
+			t.Fatal(err)
+		}
+		archiveDir := writeArchive(t, &tar.Header{Name: "working-dir/sub/file", Mode: 0644, Typeflag: tar.TypeReg})
+		err := unarchive(t, archiveDir, dst)
+		if err == nil || !strings.Contains(err.Error(), "resolves outside of") {
+			t.Fatalf("should fail: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(outside, "file")); !os.IsNotExist(err) {
+			t.Fatalf("no file should be written outside of the working-dir")
+		}
 	})
+}
+
+func TestExtractImageSetConfig(t *testing.T) {
+	isc, err := os.ReadFile("../../tests/isc.yaml")
 	if err != nil {
-		return err
+		t.Fatal(err)
 	}
-	return nil
+	writeArchive := func(archiveDir string, withConfig bool) {
+		// the image set configuration lands in the second chunk
+		cw, err := newChunkWriter(archiveDir, 4096, CompressionNone, clog.New("trace"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		content := strings.Repeat("a", 2000)
+		if err := cw.writeFile(&tar.Header{Name: "working-dir/file", Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}, strings.NewReader(content)); err != nil {
+			t.Fatal(err)
+		}
+		if withConfig {
+			if err := cw.writeFile(&tar.Header{Name: imageSetConfigPrefix + "2024-05-01T10:00:00Z", Mode: 0644, Size: int64(len(isc)), Typeflag: tar.TypeReg}, strings.NewReader(string(isc))); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := cw.finish(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	workingDir := filepath.Join(t.TempDir(), "working-dir")
+
+	t.Run("Testing ExtractImageSetConfig : should pass", func(t *testing.T) {
+		archiveDir := t.TempDir()
+		writeArchive(archiveDir, true)
+		path, err := ExtractImageSetConfig(archiveDir, workingDir)
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || string(data) != string(isc) {
+			t.Fatalf("the image set configuration should be extracted to %s: %v", path, err)
+		}
+		// without archive, the configuration extracted from the previous one is used
+		previous, err := ExtractImageSetConfig(t.TempDir(), workingDir)
+		if err != nil || previous != path {
+			t.Fatalf("should return the previous configuration %s, got %s: %v", path, previous, err)
+		}
+	})
+
+	t.Run("Testing ExtractImageSetConfig (no configuration) : should pass", func(t *testing.T) {
+		archiveDir := t.TempDir()
+		writeArchive(archiveDir, false)
+		path, err := ExtractImageSetConfig(archiveDir, workingDir)
+		if err != nil || path != "" {
+			t.Fatalf("should not find any configuration, got %s: %v", path, err)
+		}
+		if _, err := os.Stat(filepath.Join(workingDir, embeddedConfigFile)); !os.IsNotExist(err) {
+			t.Fatalf("the configuration of the previous archive should be removed: %v", err)
+		}
+	})
 }
@@ -0,0 +1,175 @@
+package batch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
+	"github.com/openshift/oc-mirror/v2/pkg/mirror"
+)
+
+// stateDir - the folder of the working-dir where the state of the copies is persisted,
+// with one file per workflow: the working-dir is carried in the archive
+// and the diskToMirror state must not be overwritten by the mirrorToDisk one
+const stateDir = "resume"
+
+// stateEntry - an image copied successfully, and the digest of its manifest
+type stateEntry struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Digest      string `json:"digest"`
+}
+
+// copyState - the images copied by the runs of a workflow, persisted as the copies finish:
+// one json entry per line is appended, so that a run that is killed leaves a usable state
+type copyState struct {
+	mu        sync.Mutex
+	file      *os.File
+	completed map[string]string
+}
+
+// newCopyState - opens the state of the workflow in the working-dir.
+// The entries of the previous runs are kept only when resuming,
+// a working-dir that is not set means that no state is persisted
+func newCopyState(workingDir, mode string, resume bool) (*copyState, error) {
+	s := &copyState{completed: map[string]string{}}
+	if workingDir == "" {
+		return s, nil
+	}
+	dir := filepath.Join(workingDir, stateDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, mode+"-state.jsonl")
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if resume {
+		if err := s.load(path); err != nil {
+			return nil, err
+		}
+	} else {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+	s.file = f
+	return s, nil
+}
+
+// load - reads the entries of the previous runs, a line that can't be read
+// (i.e. written by a run that was killed) is ignored
+func (s *copyState) load(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry stateEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || entry.Digest == "" {
+			continue
+		}
+		s.completed[stateKey(entry.Source, entry.Destination)] = entry.Digest
+	}
+	return scanner.Err()
+}
+
+// record - persists an image copied successfully
+func (s *copyState) record(img v1alpha3.CopyImageSchema, digest string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.completed[stateKey(img.Source, img.Destination)] = digest
+	if s.file == nil {
+		return nil
+	}
+	data, err := json.Marshal(stateEntry{Source: img.Source, Destination: img.Destination, Digest: digest})
+	if err != nil {
+		return err
+	}
+	_, err = s.file.Write(append(data, '\n'))
+	return err
+}
+
+// digest - the digest recorded for the image, when it was copied successfully
+func (s *copyState) digest(img v1alpha3.CopyImageSchema) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.completed[stateKey(img.Source, img.Destination)]
+	return d, ok
+}
+
+func (s *copyState) close() error {
+	if s.file == nil {
+		return nil
+	}
+	return s.file.Close()
+}
+
+func stateKey(src, dest string) string {
+	return src + "\x00" + dest
+}
+
+// isComplete - true when the image was copied by a previous run, the destination
+// still holds the manifest with the digest that was recorded (returned),
+// and the tag of the destination still points to that manifest
+func (o *Batch) isComplete(ctx context.Context, state *copyState, img v1alpha3.CopyImageSchema, opts *mirror.CopyOptions) (string, bool) {
+	recorded, ok := state.digest(img)
+	if !ok {
+		return "", false
+	}
+	// a source by digest must still be the image that was copied
+	if i := strings.Index(img.Source, "@"); i >= 0 && img.Source[i+1:] != recorded {
+		return "", false
+	}
+	dest, ok := referenceWithDigest(img.Destination, recorded)
+	if !ok {
+		return "", false
+	}
+	// the destination is read with the --dest-* flags
+	destOpts := opts.WithDestinationAsSource()
+	exists, err := o.Mirror.Check(ctx, dest, &destOpts)
+	if err != nil {
+		o.Log.Warn("[Worker] unable to check %s, it will be copied again: %v", dest, err)
+		return "", false
+	}
+	if !exists || strings.Contains(img.Destination, "@") {
+		return recorded, exists
+	}
+	// the tag may have been moved (or removed) since
+	tagOpts := destOpts
+	tagOpts.Result = &mirror.CopyResult{}
+	exists, err = o.Mirror.Check(ctx, img.Destination, &tagOpts)
+	if err != nil {
+		o.Log.Warn("[Worker] unable to check %s, it will be copied again: %v", img.Destination, err)
+		return "", false
+	}
+	if !exists || tagOpts.Result.Digest != recorded {
+		o.Log.Debug("[Worker] %s no longer points to %s, it will be copied again", img.Destination, recorded)
+		return "", false
+	}
+	return recorded, true
+}
+
+// referenceWithDigest - the docker:// reference ref, with its tag or digest replaced by digest
+func referenceWithDigest(ref, digest string) (string, bool) {
+	if !strings.HasPrefix(ref, dockerProtocol) {
+		return "", false
+	}
+	name := ref
+	if i := strings.Index(name, "@"); i >= 0 {
+		name = name[:i]
+	} else if colon := strings.LastIndex(name, ":"); colon > strings.LastIndex(name, "/") {
+		name = name[:colon]
+	}
+	return name + "@" + digest, true
+}
@@ -4,25 +4,28 @@ import (
 	"bufio"
 	"context"
 	"fmt"
-	"io"
 	"os"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
 	clog "github.com/openshift/oc-mirror/v2/pkg/log"
 	"github.com/openshift/oc-mirror/v2/pkg/manifest"
 	"github.com/openshift/oc-mirror/v2/pkg/mirror"
+	"github.com/openshift/oc-mirror/v2/pkg/report"
 )
 
 const (
-	BATCH_SIZE int    = 8
-	logFile    string = "logs/worker-{batch}.log"
+	DefaultParallelImages uint   = 8
+	DefaultMaxPerRegistry uint   = 6
+	logFile               string = "logs/worker-{batch}.log"
+	dockerProtocol        string = "docker://"
 )
 
 type BatchInterface interface {
-	Worker(ctx context.Context, images []v1alpha3.CopyImageSchema, opts mirror.CopyOptions) error
+	Worker(ctx context.Context, images []v1alpha3.CopyImageSchema, opts mirror.CopyOptions) ([]report.ImageReport, error)
 }
 
 func New(log clog.PluggableLoggerInterface,
@@ -38,93 +41,224 @@ type Batch struct {
 	Manifest manifest.ManifestInterface
 }
 
-type BatchSchema struct {
-	Writer     io.Writer
-	CopyImages []v1alpha3.RelatedImage
-	Items      int
-	Count      int
-	BatchSize  int
-	BatchIndex int
-	Remainder  int
+// FailedImage - an image that could not be copied, and the reason why
+type FailedImage struct {
+	Image v1alpha3.CopyImageSchema
+	Err   error
+}
+
+// failures - the failed images, collected by all the workers
+type failures struct {
+	mu     sync.Mutex
+	images []FailedImage
+}
+
+func (f *failures) add(img v1alpha3.CopyImageSchema, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.images = append(f.images, FailedImage{Image: img, Err: err})
+}
+
+// registryLimiter - caps the number of concurrent copies per registry
+type registryLimiter struct {
+	mu    sync.Mutex
+	max   uint
+	slots map[string]chan struct{}
+}
+
+func newRegistryLimiter(max uint) *registryLimiter {
+	return &registryLimiter{max: max, slots: map[string]chan struct{}{}}
+}
+
+// acquire - blocks until a copy can start for the registry (or the context is done)
+func (r *registryLimiter) acquire(ctx context.Context, registry string) error {
+	r.mu.Lock()
+	slot, ok := r.slots[registry]
+	if !ok {
+		slot = make(chan struct{}, r.max)
+		r.slots[registry] = slot
+	}
+	r.mu.Unlock()
+
+	select {
+	case slot <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *registryLimiter) release(registry string) {
+	r.mu.Lock()
+	slot := r.slots[registry]
+	r.mu.Unlock()
+	<-slot
 }
 
 // Worker - the main batch processor
-func (o *Batch) Worker(ctx context.Context, images []v1alpha3.CopyImageSchema, opts mirror.CopyOptions) error {
+// the images are streamed to a pool of workers (--parallel-images),
+// with at most --max-per-registry copies in progress against the same source registry.
+// The copies from the local storage (or from disk) are only bound by --parallel-images.
+// All the images are processed, and the images that failed are listed at the end.
+// The report of each image is returned, in the same order as images
+func (o *Batch) Worker(ctx context.Context, images []v1alpha3.CopyImageSchema, opts mirror.CopyOptions) ([]report.ImageReport, error) {
 
-	var errArray []error
-	var wg sync.WaitGroup
-	var err error
-
-	var b *BatchSchema
-	imgs := len(images)
-	if imgs < BATCH_SIZE {
-		b = &BatchSchema{Items: imgs, Count: 1, BatchSize: imgs, BatchIndex: 0, Remainder: 0}
-	} else {
-		b = &BatchSchema{Items: imgs, Count: (imgs / BATCH_SIZE), BatchSize: BATCH_SIZE, Remainder: (imgs % BATCH_SIZE)}
+	parallel := DefaultParallelImages
+	if opts.Global.ParallelImages > 0 {
+		parallel = opts.Global.ParallelImages
+	}
+	maxPerRegistry := DefaultMaxPerRegistry
+	if opts.Global.MaxPerRegistry > 0 {
+		maxPerRegistry = opts.Global.MaxPerRegistry
+	}
+	localStorage := "localhost:" + strconv.Itoa(int(opts.Global.Port))
+	if uint(len(images)) < parallel {
+		parallel = uint(len(images))
 	}
 
-	o.Log.Info("images to mirror %d ", b.Items)
-	o.Log.Info("batch count %d ", b.Count)
-	o.Log.Info("batch index %d ", b.BatchIndex)
-	o.Log.Info("batch size %d ", b.BatchSize)
-	o.Log.Info("remainder size %d ", b.Remainder)
-
-	f := make([]*os.File, b.Count)
-	//f, err := make([]os.File)
-	// prepare batching
-	wg.Add(b.BatchSize)
-	for i := 0; i < b.Count; i++ {
-		// create a log file for each batch
-		f[i], err = os.Create(strings.Replace(logFile, "{batch}", strconv.Itoa(i), -1))
+	o.Log.Info("images to mirror %d ", len(images))
+	o.Log.Info("parallel images %d ", parallel)
+	o.Log.Info("max copies per registry %d ", maxPerRegistry)
+
+	opts.MultiArch = "all"
+
+	// the images are recorded as they are copied, so that an interrupted run can be resumed
+	state, err := newCopyState(opts.Global.WorkingDir, opts.Mode, opts.Global.Resume)
+	if err != nil {
+		return nil, fmt.Errorf("[Worker] unable to open the state of the copies: %v", err)
+	}
+	defer state.close()
+
+	// the index of the images to copy
+	queue := make(chan int)
+	limiter := newRegistryLimiter(maxPerRegistry)
+	failed := &failures{}
+	// each worker only writes the reports of the images it copies
+	reports := make([]report.ImageReport, len(images))
+	var wg sync.WaitGroup
+
+	for i := 0; i < int(parallel); i++ {
+		// create a log file for each worker
+		var writer *bufio.Writer
+		f, err := os.Create(strings.Replace(logFile, "{batch}", strconv.Itoa(i), -1))
 		if err != nil {
 			o.Log.Error("[Worker] %v", err)
+			f = nil
+			writer = bufio.NewWriter(os.Stdout)
+		} else {
+			writer = bufio.NewWriter(f)
 		}
-		writer := bufio.NewWriter(f[i])
-		o.Log.Info(fmt.Sprintf("starting batch %d ", i))
-		for x := 0; x < b.BatchSize; x++ {
-			index := (i * b.BatchSize) + x
-			o.Log.Debug("source %s ", images[index].Source)
-			o.Log.Debug("destination %s ", images[index].Destination)
-			opts.MultiArch = "all"
-			go func(ctx context.Context, src, dest string, opts *mirror.CopyOptions, writer bufio.Writer) {
-				defer wg.Done()
-				err := o.Mirror.Run(ctx, src, dest, "copy", opts, writer)
+
+		wg.Add(1)
+		go func(f *os.File, writer *bufio.Writer) {
+			defer wg.Done()
+			for i := range queue {
+				img := images[i]
+				o.Log.Debug("source %s ", img.Source)
+				o.Log.Debug("destination %s ", img.Destination)
+				start := time.Now()
+				if opts.Global.Resume {
+					if digest, ok := o.isComplete(ctx, state, img, &opts); ok {
+						o.Log.Debug("[Worker] %s already copied, skipping", imageName(img))
+						reports[i] = report.NewSkippedImageReport(img, digest)
+						continue
+					}
+				}
+				registry := sourceRegistry(img, localStorage)
+				if registry != "" {
+					if err := limiter.acquire(ctx, registry); err != nil {
+						failed.add(img, err)
+						reports[i] = report.NewImageReport(img, "", 0, time.Since(start), err)
+						continue
+					}
+				}
+				// each copy gets its own result
+				copyOpts := opts
+				copyOpts.Result = &mirror.CopyResult{}
+				err := o.Mirror.Run(ctx, img.Source, img.Destination, "copy", &copyOpts, *writer)
+				if registry != "" {
+					limiter.release(registry)
+				}
 				if err != nil {
-					errArray = append(errArray, err)
+					o.Log.Error("[Worker] %v", err)
+					failed.add(img, err)
+				} else if err := state.record(img, copyOpts.Result.Digest); err != nil {
+					o.Log.Warn("[Worker] unable to record the copy of %s: %v", imageName(img), err)
 				}
-			}(ctx, images[index].Source, images[index].Destination, &opts, *writer)
-		}
-		wg.Wait()
-		// rather than use defer Close we intentianally close the log files
-		for _, f := range f {
-			f.Close()
-		}
-		o.Log.Info("completed batch %d", i)
-		if b.Count > 1 {
-			wg.Add(BATCH_SIZE)
-		}
-		if len(errArray) > 0 {
-			for _, err := range errArray {
-				o.Log.Error("[Worker] errArray %v", err)
+				reports[i] = report.NewImageReport(img, copyOpts.Result.Digest, copyOpts.Result.Bytes, time.Since(start), err)
 			}
-			return fmt.Errorf("[Worker] error in batch - refer to console logs")
-		}
+			writer.Flush()
+			if f != nil {
+				f.Close()
+			}
+		}(f, writer)
 	}
-	if b.Remainder > 0 {
-		// one level of simple recursion
-		i := b.Count * BATCH_SIZE
-		o.Log.Info("executing remainder [batch size of 1]")
-		err := o.Worker(ctx, images[i:], opts)
-		if err != nil {
-			return err
+
+	// stream the images to the workers, a slow image only holds one worker
+	for i, img := range images {
+		if ctx.Err() != nil {
+			failed.add(img, ctx.Err())
+			reports[i] = report.NewImageReport(img, "", 0, 0, ctx.Err())
+			continue
 		}
-		// output the logs to console
-		if !opts.Global.Quiet {
-			consoleLogFromFile(o.Log)
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+
+	// output the logs to console
+	if !opts.Global.Quiet {
+		consoleLogFromFile(o.Log)
+	}
+
+	if len(failed.images) > 0 {
+		o.Log.Error("[Worker] %d/%d images failed to mirror", len(failed.images), len(images))
+		for _, fi := range failed.images {
+			o.Log.Error("[Worker] failed %s -> %s : %v", imageName(fi.Image), fi.Image.Destination, fi.Err)
 		}
-		o.Log.Info("[Worker] successfully completed all batches")
+		return reports, &WorkerError{Failed: failed.images, Total: len(images)}
+	}
+	o.Log.Info("[Worker] successfully completed all images")
+	return reports, nil
+}
+
+// WorkerError - returned by the Worker when some of the images failed to mirror
+type WorkerError struct {
+	Failed []FailedImage
+	Total  int
+}
+
+func (e *WorkerError) Error() string {
+	names := make([]string, 0, len(e.Failed))
+	for _, fi := range e.Failed {
+		names = append(names, imageName(fi.Image))
+	}
+	return fmt.Sprintf("[Worker] %d/%d images failed to mirror: %s", len(e.Failed), e.Total, strings.Join(names, ", "))
+}
+
+// imageName - the name used to report an image, as found in the imagesetconfig when known
+func imageName(img v1alpha3.CopyImageSchema) string {
+	if img.Origin != "" {
+		return img.Origin
+	}
+	return img.Source
+}
+
+// sourceRegistry - the remote registry the image is pulled from,
+// empty when the source is the local storage (or not a registry)
+func sourceRegistry(img v1alpha3.CopyImageSchema, localStorage string) string {
+	if src := registryHost(img.Source); src != localStorage {
+		return src
+	}
+	return ""
+}
+
+// registryHost - the host of a docker:// reference, empty for other transports
+func registryHost(ref string) string {
+	if !strings.HasPrefix(ref, dockerProtocol) {
+		return ""
 	}
-	return nil
+	return strings.SplitN(strings.TrimPrefix(ref, dockerProtocol), "/", 2)[0]
 }
 
 // consoleLogFromFile
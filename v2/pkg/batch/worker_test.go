@@ -3,12 +3,19 @@ package batch
 import (
 	"bufio"
 	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha2"
 	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
 	clog "github.com/openshift/oc-mirror/v2/pkg/log"
 	"github.com/openshift/oc-mirror/v2/pkg/mirror"
+	"github.com/openshift/oc-mirror/v2/pkg/report"
 )
 
 func TestWorker(t *testing.T) {
@@ -46,24 +53,285 @@ func TestWorker(t *testing.T) {
 			{Source: "docker://registry/name/namespace/sometestimage-e@sha256:f30638f60452062aba36a26ee6c036feead2f03b28f2c47f2b0a991e41baebea", Destination: "oci:test"},
 			{Source: "docker://registry/name/namespace/sometestimage-f@sha256:f30638f60452062aba36a26ee6c036feead2f03b28f2c47f2b0a991e41baebea", Destination: "oci:test"},
 		}
-		err := w.Worker(context.Background(), relatedImages, opts)
+		_, err := w.Worker(context.Background(), relatedImages, opts)
 		if err != nil {
 			t.Fatal("should pass")
 		}
 	})
+
+	t.Run("Testing Worker (max per registry) : should pass", func(t *testing.T) {
+		m := &Mirror{Delay: 10 * time.Millisecond}
+		w := New(log, m, &Manifest{})
+		relatedImages := []v1alpha3.CopyImageSchema{}
+		for i := 0; i < 20; i++ {
+			relatedImages = append(relatedImages, v1alpha3.CopyImageSchema{
+				Source:      fmt.Sprintf("docker://registry-%d/name/namespace/sometestimage:%d", i%2, i),
+				Destination: fmt.Sprintf("docker://localhost:5000/name/namespace/sometestimage:%d", i),
+			})
+		}
+		parallelOpts := opts
+		parallelOpts.Global = &mirror.GlobalOptions{ParallelImages: 8, MaxPerRegistry: 2, Quiet: true, Port: 5000}
+		_, err := w.Worker(context.Background(), relatedImages, parallelOpts)
+		if err != nil {
+			t.Fatal("should pass")
+		}
+		if m.copied != 20 {
+			t.Fatalf("expected 20 images copied, got %d", m.copied)
+		}
+		for registry, max := range m.maxInFlight {
+			if max > 2 {
+				t.Fatalf("expected at most 2 copies in parallel for %s, got %d", registry, max)
+			}
+		}
+	})
+
+	t.Run("Testing Worker (max per registry, local storage) : should pass", func(t *testing.T) {
+		m := &Mirror{Delay: 20 * time.Millisecond}
+		w := New(log, m, &Manifest{})
+		relatedImages := []v1alpha3.CopyImageSchema{}
+		for i := 0; i < 20; i++ {
+			relatedImages = append(relatedImages, v1alpha3.CopyImageSchema{
+				Source:      fmt.Sprintf("docker://localhost:5000/name/namespace/sometestimage:%d", i),
+				Destination: fmt.Sprintf("docker://myregistry/name/namespace/sometestimage:%d", i),
+			})
+		}
+		parallelOpts := opts
+		parallelOpts.Global = &mirror.GlobalOptions{ParallelImages: 8, MaxPerRegistry: 2, Quiet: true, Port: 5000}
+		_, err := w.Worker(context.Background(), relatedImages, parallelOpts)
+		if err != nil {
+			t.Fatal("should pass")
+		}
+		if m.maxInFlight["localhost:5000"] <= 2 {
+			t.Fatalf("the copies from the local storage should only be bound by the parallel images, got %d", m.maxInFlight["localhost:5000"])
+		}
+	})
+
+	t.Run("Testing Worker (failed images) : should fail", func(t *testing.T) {
+		m := &Mirror{Fail: map[string]bool{
+			"docker://registry/name/namespace/sometestimage-b:v1": true,
+			"docker://registry/name/namespace/sometestimage-d:v1": true,
+		}}
+		w := New(log, m, &Manifest{})
+		relatedImages := []v1alpha3.CopyImageSchema{
+			{Source: "docker://registry/name/namespace/sometestimage-a:v1", Destination: "oci:test", Type: v1alpha3.TypeOperator},
+			{Origin: "registry/name/namespace/sometestimage-b:v1", Source: "docker://registry/name/namespace/sometestimage-b:v1", Destination: "oci:test", Type: v1alpha3.TypeOperator},
+			{Source: "docker://registry/name/namespace/sometestimage-c:v1", Destination: "oci:test", Type: v1alpha3.TypeOperator},
+			{Source: "docker://registry/name/namespace/sometestimage-d:v1", Destination: "oci:test", Type: v1alpha3.TypeOperator},
+		}
+		reports, err := w.Worker(context.Background(), relatedImages, opts)
+		if err == nil {
+			t.Fatal("should fail")
+		}
+		werr, ok := err.(*WorkerError)
+		if !ok {
+			t.Fatalf("expected a WorkerError, got %v", err)
+		}
+		if len(werr.Failed) != 2 || werr.Total != 4 {
+			t.Fatalf("expected 2/4 failed images, got %d/%d", len(werr.Failed), werr.Total)
+		}
+		// all the other images are still copied
+		if m.copied != 2 {
+			t.Fatalf("expected 2 images copied, got %d", m.copied)
+		}
+		if !strings.Contains(err.Error(), "registry/name/namespace/sometestimage-b:v1") || !strings.Contains(err.Error(), "docker://registry/name/namespace/sometestimage-d:v1") {
+			t.Fatalf("the failed images should be listed: %v", err)
+		}
+		// every image is reported, in order
+		if len(reports) != 4 {
+			t.Fatalf("expected 4 reports, got %d", len(reports))
+		}
+		for i, r := range reports {
+			if r.Source != relatedImages[i].Source || r.Type != v1alpha3.TypeOperator {
+				t.Fatalf("unexpected report %v for %s", r, relatedImages[i].Source)
+			}
+			failed := i == 1 || i == 3
+			if failed && (r.Status != report.StatusFailed || !strings.Contains(r.Error, "forced error") || r.Digest != "") {
+				t.Fatalf("expected a failed report for %s, got %v", r.Source, r)
+			}
+			if !failed && (r.Status != report.StatusSuccess || r.Digest != "sha256:f30638f60452062aba36a26ee6c036feead2f03b28f2c47f2b0a991e41baebea" || r.Bytes != 1024) {
+				t.Fatalf("expected a successful report for %s, got %v", r.Source, r)
+			}
+		}
+	})
+}
+
+func TestWorkerResume(t *testing.T) {
+	log := clog.New("trace")
+	workingDir := t.TempDir()
+	opts := mirror.CopyOptions{
+		Global: &mirror.GlobalOptions{ParallelImages: 2, MaxPerRegistry: 2, Quiet: true, Port: 5000, WorkingDir: workingDir},
+		Mode:   mirror.MirrorToDisk,
+	}
+	relatedImages := []v1alpha3.CopyImageSchema{
+		{Source: "docker://registry/name/namespace/sometestimage-a:v1", Destination: "docker://localhost:5000/name/namespace/sometestimage-a:v1"},
+		{Source: "docker://registry/name/namespace/sometestimage-b:v1", Destination: "docker://localhost:5000/name/namespace/sometestimage-b:v1"},
+		{Source: "docker://registry/name/namespace/sometestimage-c@sha256:f30638f60452062aba36a26ee6c036feead2f03b28f2c47f2b0a991e41baebea", Destination: "docker://localhost:5000/name/namespace/sometestimage-c:f30638f60452"},
+		{Source: "docker://registry/name/namespace/sometestimage-d:v1", Destination: "docker://localhost:5000/name/namespace/sometestimage-d:v1"},
+	}
+
+	// the first run fails for b
+	m := &Mirror{Fail: map[string]bool{"docker://registry/name/namespace/sometestimage-b:v1": true}}
+	_, err := New(log, m, &Manifest{}).Worker(context.Background(), relatedImages, opts)
+	if err == nil {
+		t.Fatal("should fail")
+	}
+	statePath := filepath.Join(workingDir, stateDir, mirror.MirrorToDisk+"-state.jsonl")
+	// a run killed while writing the state leaves an incomplete line
+	f, err := os.OpenFile(statePath, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"source":"docker://registry/name`) //nolint:errcheck
+	f.Close()
+
+	t.Run("Testing Worker (resume) : should pass", func(t *testing.T) {
+		// d is no longer in the destination, and the tag of a points to another image
+		m := &Mirror{
+			Missing: map[string]bool{"docker://localhost:5000/name/namespace/sometestimage-d@sha256:f30638f60452062aba36a26ee6c036feead2f03b28f2c47f2b0a991e41baebea": true},
+			Moved:   map[string]bool{"docker://localhost:5000/name/namespace/sometestimage-a:v1": true},
+		}
+		resumeOpts := opts
+		resumeOpts.Global = &mirror.GlobalOptions{ParallelImages: 2, MaxPerRegistry: 2, Quiet: true, Port: 5000, WorkingDir: workingDir, Resume: true}
+		_, sharedOpts := mirror.SharedImageFlags()
+		_, deprecatedTLSVerifyOpt := mirror.DeprecatedTLSVerifyFlags()
+		_, resumeOpts.SrcImage = mirror.ImageSrcFlags(resumeOpts.Global, sharedOpts, deprecatedTLSVerifyOpt, "src-", "screds")
+		_, resumeOpts.DestImage = mirror.ImageDestFlags(resumeOpts.Global, sharedOpts, deprecatedTLSVerifyOpt, "dest-", "dcreds")
+		reports, err := New(log, m, &Manifest{}).Worker(context.Background(), relatedImages, resumeOpts)
+		if err != nil {
+			t.Fatalf("should pass: %v", err)
+		}
+		// only the image that failed, the image with a moved tag and the image missing from the destination are copied
+		if m.copied != 3 {
+			t.Fatalf("expected 3 images copied, got %d", m.copied)
+		}
+		expected := []string{report.StatusSuccess, report.StatusSuccess, report.StatusSkipped, report.StatusSuccess}
+		for i, r := range reports {
+			if r.Status != expected[i] {
+				t.Fatalf("expected %s for %s, got %s", expected[i], r.Source, r.Status)
+			}
+		}
+		// the digest of the 3 images copied by the first run, then the tag of the images still there
+		if len(m.checked) != 5 {
+			t.Fatalf("expected the 3 images copied by the first run and 2 tags to be checked, got %v", m.checked)
+		}
+		// the destination is checked with the destination options
+		for _, checkedOpts := range m.checkedOpts {
+			if checkedOpts.SrcImage != resumeOpts.WithDestinationAsSource().SrcImage || checkedOpts.SrcImage == resumeOpts.SrcImage {
+				t.Fatalf("the destination should be checked with the --dest-* options")
+			}
+		}
+	})
+
+	t.Run("Testing Worker (no resume) : should copy everything", func(t *testing.T) {
+		m := &Mirror{}
+		_, err := New(log, m, &Manifest{}).Worker(context.Background(), relatedImages, opts)
+		if err != nil {
+			t.Fatalf("should pass: %v", err)
+		}
+		if m.copied != 4 || len(m.checked) != 0 {
+			t.Fatalf("expected 4 images copied without checks, got %d copied and %d checks", m.copied, len(m.checked))
+		}
+	})
+}
+
+func TestReferenceWithDigest(t *testing.T) {
+	t.Run("Testing referenceWithDigest : should pass", func(t *testing.T) {
+		d := "sha256:f30638f60452062aba36a26ee6c036feead2f03b28f2c47f2b0a991e41baebea"
+		tests := []struct {
+			ref      string
+			expected string
+			ok       bool
+		}{
+			{"docker://localhost:5000/a/b:v1", "docker://localhost:5000/a/b@" + d, true},
+			{"docker://localhost:5000/a/b", "docker://localhost:5000/a/b@" + d, true},
+			{"docker://quay.io/a/b@sha256:0000000000000000000000000000000000000000000000000000000000000000", "docker://quay.io/a/b@" + d, true},
+			{"oci:///tmp/a", "", false},
+		}
+		for _, tt := range tests {
+			got, ok := referenceWithDigest(tt.ref, d)
+			if got != tt.expected || ok != tt.ok {
+				t.Fatalf("expected %s, got %s", tt.expected, got)
+			}
+		}
+	})
+}
+
+func TestSourceRegistry(t *testing.T) {
+	t.Run("Testing sourceRegistry : should pass", func(t *testing.T) {
+		tests := []struct {
+			img      v1alpha3.CopyImageSchema
+			expected string
+		}{
+			{v1alpha3.CopyImageSchema{Source: "docker://quay.io/a/b:v1", Destination: "docker://localhost:5000/a/b:v1"}, "quay.io"},
+			{v1alpha3.CopyImageSchema{Source: "docker://quay.io/a/b:v1", Destination: "docker://myregistry:8443/a/b:v1"}, "quay.io"},
+			{v1alpha3.CopyImageSchema{Source: "docker://localhost:5000/a/b:v1", Destination: "docker://myregistry:8443/a/b:v1"}, ""},
+			{v1alpha3.CopyImageSchema{Source: "oci:///tmp/a", Destination: "docker://myregistry/a:v1"}, ""},
+		}
+		for _, tt := range tests {
+			if got := sourceRegistry(tt.img, "localhost:5000"); got != tt.expected {
+				t.Fatalf("expected %s, got %s", tt.expected, got)
+			}
+		}
+	})
 }
 
 // mocks
 
-type Mirror struct{}
+type Mirror struct {
+	Fail        map[string]bool
+	Missing     map[string]bool
+	Moved       map[string]bool
+	checked     []string
+	checkedOpts []mirror.CopyOptions
+	Delay       time.Duration
+	mu          sync.Mutex
+	copied      int
+	inFlight    map[string]int
+	maxInFlight map[string]int
+}
 type Manifest struct{}
 
 func (o *Mirror) Run(ctx context.Context, src, dest string, mode mirror.Mode, opts *mirror.CopyOptions, stdout bufio.Writer) error {
+	registry := registryHost(src)
+	o.mu.Lock()
+	if o.inFlight == nil {
+		o.inFlight = map[string]int{}
+		o.maxInFlight = map[string]int{}
+	}
+	o.inFlight[registry]++
+	if o.inFlight[registry] > o.maxInFlight[registry] {
+		o.maxInFlight[registry] = o.inFlight[registry]
+	}
+	o.mu.Unlock()
+
+	time.Sleep(o.Delay)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.inFlight[registry]--
+	if o.Fail[src] {
+		return fmt.Errorf("forced error %s", src)
+	}
+	o.copied++
+	if opts.Result != nil {
+		opts.Result.Digest = "sha256:f30638f60452062aba36a26ee6c036feead2f03b28f2c47f2b0a991e41baebea"
+		opts.Result.Bytes = 1024
+	}
 	return nil
 }
 
 func (o *Mirror) Check(ctx context.Context, image string, opts *mirror.CopyOptions) (bool, error) {
-	return true, nil
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.checked = append(o.checked, image)
+	o.checkedOpts = append(o.checkedOpts, *opts)
+	if opts.Result != nil {
+		opts.Result.Digest = "sha256:f30638f60452062aba36a26ee6c036feead2f03b28f2c47f2b0a991e41baebea"
+		if o.Moved[image] {
+			opts.Result.Digest = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+		}
+	}
+	return !o.Missing[image], nil
 }
 
 func (o *Manifest) GetOperatorConfig(file string) (*v1alpha3.OperatorConfigSchema, error) {
@@ -73,8 +341,8 @@ func (o *Manifest) GetOperatorConfig(file string) (*v1alpha3.OperatorConfigSchem
 	return ocs, nil
 }
 
-func (o *Manifest) GetRelatedImagesFromCatalogByFilter(filePath, label string, op v1alpha2.Operator, mp map[string]v1alpha3.ISCPackage) (map[string][]v1alpha3.RelatedImage, error) {
-	return nil, nil
+func (o *Manifest) GetRelatedImagesFromCatalogByFilter(filePath, label string, op v1alpha2.Operator, mp map[string]v1alpha3.ISCPackage) (map[string][]v1alpha3.RelatedImage, []string, error) {
+	return nil, nil, nil
 }
 
 func (o *Manifest) GetReleaseSchema(filePath string) ([]v1alpha3.RelatedImage, error) {
@@ -100,6 +368,10 @@ func (o *Manifest) GetImageIndex(name string) (*v1alpha3.OCISchema, error) {
 	}, nil
 }
 
+func (o *Manifest) GetPlatformManifest(dir string, oci *v1alpha3.OCISchema) (*v1alpha3.OCISchema, error) {
+	return o.GetImageManifest(dir)
+}
+
 func (o *Manifest) GetImageManifest(name string) (*v1alpha3.OCISchema, error) {
 	return &v1alpha3.OCISchema{
 		SchemaVersion: 2,
@@ -0,0 +1,160 @@
+package blocked
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
+)
+
+const (
+	// RegexPrefix - the prefix of the blocked images that are regular expressions
+	RegexPrefix   string = "regex:"
+	globChars     string = "*?["
+	transportSep  string = "://"
+	digestSep     string = "@"
+	tagSep        string = ":"
+	repositorySep string = "/"
+)
+
+// BlockedImage - an image found by a collector, and the entry of the block list that matches it
+type BlockedImage struct {
+	Image v1alpha3.CopyImageSchema
+	// Reference: the reference of the image matched (its origin, or its source when not set)
+	Reference string
+	Pattern   string
+}
+
+// matcher - one entry of the block list
+type matcher struct {
+	pattern string
+	match   func(ref string) bool
+}
+
+// Filter - the block list of the ImageSetConfiguration
+type Filter struct {
+	matchers []matcher
+}
+
+// New - compiles the block list. Each entry is either:
+//   - an exact reference: a repository (all its tags and digests are blocked),
+//     or a repository with a tag or a digest
+//   - a glob pattern, with * (any characters, including /), ? and [...]
+//     i.e. registry.example.com/legacy/*
+//   - a regular expression prefixed with regex:, i.e. regex:^quay\.io/.*/debug
+func New(images []v1alpha2.Image) (*Filter, error) {
+	f := &Filter{}
+	for _, img := range images {
+		m, err := newMatcher(img.Name)
+		if err != nil {
+			return nil, err
+		}
+		f.matchers = append(f.matchers, m)
+	}
+	return f, nil
+}
+
+func newMatcher(pattern string) (matcher, error) {
+	switch {
+	case strings.TrimSpace(pattern) == "":
+		return matcher{}, fmt.Errorf("blocked image: empty name")
+	case strings.HasPrefix(pattern, RegexPrefix):
+		re, err := regexp.Compile(strings.TrimPrefix(pattern, RegexPrefix))
+		if err != nil {
+			return matcher{}, fmt.Errorf("blocked image %q: invalid regular expression: %v", pattern, err)
+		}
+		return matcher{pattern: pattern, match: re.MatchString}, nil
+	case strings.ContainsAny(pattern, globChars):
+		re, err := globToRegexp(pattern)
+		if err != nil {
+			return matcher{}, fmt.Errorf("blocked image %q: invalid pattern: %v", pattern, err)
+		}
+		return matcher{pattern: pattern, match: re.MatchString}, nil
+	default:
+		return matcher{pattern: pattern, match: func(ref string) bool {
+			return ref == pattern || repository(ref) == pattern
+		}}, nil
+	}
+}
+
+// IsBlocked - the entry of the block list that matches the reference, if any
+func (f *Filter) IsBlocked(ref string) (string, bool) {
+	ref = trimTransport(ref)
+	for _, m := range f.matchers {
+		if m.match(ref) {
+			return m.pattern, true
+		}
+	}
+	return "", false
+}
+
+// Filter - the images that are not blocked, and the blocked ones.
+// The images are matched on their original reference
+func (f *Filter) Filter(images []v1alpha3.CopyImageSchema) ([]v1alpha3.CopyImageSchema, []BlockedImage) {
+	if len(f.matchers) == 0 {
+		return images, nil
+	}
+	var kept []v1alpha3.CopyImageSchema
+	var blocked []BlockedImage
+	for _, img := range images {
+		ref := img.Origin
+		if ref == "" {
+			ref = img.Source
+		}
+		if pattern, ok := f.IsBlocked(ref); ok {
+			blocked = append(blocked, BlockedImage{Image: img, Reference: ref, Pattern: pattern})
+			continue
+		}
+		kept = append(kept, img)
+	}
+	return kept, blocked
+}
+
+// globToRegexp - the anchored regular expression of the glob pattern
+func globToRegexp(pattern string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		case '[':
+			end := strings.IndexByte(pattern[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated character class")
+			}
+			class := pattern[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + class + "]")
+			i += end
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
+
+// repository - the reference without its tag and digest
+func repository(ref string) string {
+	if i := strings.Index(ref, digestSep); i >= 0 {
+		ref = ref[:i]
+	}
+	if i := strings.LastIndex(ref, tagSep); i > strings.LastIndex(ref, repositorySep) {
+		ref = ref[:i]
+	}
+	return ref
+}
+
+func trimTransport(ref string) string {
+	if i := strings.Index(ref, transportSep); i >= 0 {
+		return ref[i+len(transportSep):]
+	}
+	return ref
+}
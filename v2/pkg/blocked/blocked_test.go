@@ -0,0 +1,72 @@
+package blocked
+
+import (
+	"testing"
+
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
+)
+
+func TestIsBlocked(t *testing.T) {
+	type testCase struct {
+		caseName string
+		blocked  string
+		ref      string
+		expected bool
+	}
+	testCases := []testCase{
+		{caseName: "Testing IsBlocked (exact reference) : should pass", blocked: "quay.io/a/b:v1", ref: "quay.io/a/b:v1", expected: true},
+		{caseName: "Testing IsBlocked (exact reference, other tag) : should pass", blocked: "quay.io/a/b:v1", ref: "quay.io/a/b:v2", expected: false},
+		{caseName: "Testing IsBlocked (repository, tag) : should pass", blocked: "quay.io/a/b", ref: "docker://quay.io/a/b:v1", expected: true},
+		{caseName: "Testing IsBlocked (repository, digest) : should pass", blocked: "localhost:5000/a/b", ref: "localhost:5000/a/b@sha256:f30638f60452062aba36a26ee6c036feead2f03b28f2c47f2b0a991e41baebea", expected: true},
+		{caseName: "Testing IsBlocked (repository, other repository) : should pass", blocked: "quay.io/a/b", ref: "quay.io/a/bc:v1", expected: false},
+		{caseName: "Testing IsBlocked (glob) : should pass", blocked: "registry.example.com/legacy/*", ref: "registry.example.com/legacy/team/app:v1", expected: true},
+		{caseName: "Testing IsBlocked (glob, other namespace) : should pass", blocked: "registry.example.com/legacy/*", ref: "registry.example.com/current/app:v1", expected: false},
+		{caseName: "Testing IsBlocked (glob, character class) : should pass", blocked: "quay.io/a/app-[0-9]:*", ref: "quay.io/a/app-7:latest", expected: true},
+		{caseName: "Testing IsBlocked (glob, single character) : should pass", blocked: "quay.io/a/b:v?", ref: "quay.io/a/b:v10", expected: false},
+		{caseName: "Testing IsBlocked (regex) : should pass", blocked: `regex:^quay\.io/.*/debug`, ref: "quay.io/a/debug-tools:v1", expected: true},
+		{caseName: "Testing IsBlocked (regex, no match) : should pass", blocked: `regex:^quay\.io/.*/debug`, ref: "registry.io/a/debug:v1", expected: false},
+	}
+	for _, c := range testCases {
+		t.Run(c.caseName, func(t *testing.T) {
+			f, err := New([]v1alpha2.Image{{Name: c.blocked}})
+			if err != nil {
+				t.Fatalf("should not fail: %v", err)
+			}
+			pattern, res := f.IsBlocked(c.ref)
+			if res != c.expected {
+				t.Fatalf("%s blocked by %s: expected %v, got %v", c.ref, c.blocked, c.expected, res)
+			}
+			if res && pattern != c.blocked {
+				t.Fatalf("expected the pattern %s, got %s", c.blocked, pattern)
+			}
+		})
+	}
+
+	t.Run("Testing New (invalid entries) : should fail", func(t *testing.T) {
+		for _, name := range []string{"", "regex:quay.io/(a", "quay.io/a/[b"} {
+			if _, err := New([]v1alpha2.Image{{Name: name}}); err == nil {
+				t.Fatalf("%q should fail", name)
+			}
+		}
+	})
+}
+
+func TestFilter(t *testing.T) {
+	f, err := New([]v1alpha2.Image{{Name: "quay.io/a/*"}})
+	if err != nil {
+		t.Fatalf("should not fail: %v", err)
+	}
+	images := []v1alpha3.CopyImageSchema{
+		{Origin: "quay.io/a/b:v1", Source: "docker://localhost:5000/a/b:v1", Type: v1alpha3.TypeOperator},
+		{Origin: "quay.io/c/d:v1", Source: "docker://localhost:5000/c/d:v1", Type: v1alpha3.TypeOperator},
+		{Source: "docker://quay.io/a/e:v1", Type: v1alpha3.TypeAdditional},
+	}
+	kept, blocked := f.Filter(images)
+	if len(kept) != 1 || kept[0].Origin != "quay.io/c/d:v1" {
+		t.Fatalf("unexpected images kept %v", kept)
+	}
+	if len(blocked) != 2 || blocked[0].Image.Origin != "quay.io/a/b:v1" || blocked[1].Reference != "docker://quay.io/a/e:v1" || blocked[0].Pattern != "quay.io/a/*" {
+		t.Fatalf("unexpected images blocked %v", blocked)
+	}
+}
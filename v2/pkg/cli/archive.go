@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/openshift/oc-mirror/v2/pkg/archive"
+	clog "github.com/openshift/oc-mirror/v2/pkg/log"
+)
+
+var (
+	archiveVerifyLongDesc = templates.LongDesc(
+		`
+		Verify an archive generated by the mirrorToDisk workflow.
+
+		All the chunks of the archive (mirror_000001.tar, mirror_000002.tar...) found in the directory
+		are read, and every file is checked against the size and sha256 recorded in the archive manifest.
+		Missing chunks, missing files and corrupted files are reported.
+
+		The archive manifest is checksummed, not signed: the verification detects corrupted media,
+		it doesn't prove where the archive comes from.
+		`,
+	)
+	archiveVerifyExamples = templates.Examples(
+		`
+		# Verify the archive before carrying it to the disconnected environment
+		oc-mirror archive verify file:///home/user/archive --v2
+		`,
+	)
+)
+
+// NewArchiveCommand - cobra entry point for the archive subcommands
+func NewArchiveCommand(log clog.PluggableLoggerInterface) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "archive",
+		Short: "Manages the archives generated by the mirrorToDisk workflow",
+	}
+	cmd.AddCommand(NewArchiveVerifyCommand(log))
+	return cmd
+}
+
+// NewArchiveVerifyCommand - cobra entry point for archive verify
+func NewArchiveVerifyCommand(log clog.PluggableLoggerInterface) *cobra.Command {
+	var v2 bool
+	cmd := &cobra.Command{
+		Use:     "verify <directory>",
+		Short:   "Verifies the checksums of an archive",
+		Long:    archiveVerifyLongDesc,
+		Example: archiveVerifyExamples,
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := VerifyArchive(log, args[0])
+			if err != nil {
+				log.Error("%v ", err)
+				os.Exit(1)
+			}
+		},
+	}
+	cmd.Flags().BoolVar(&v2, "v2", v2, "Redirect the flow to oc-mirror v2 - PLEASE DO NOT USE that. V2 is still under development and it is not ready to be used.")
+	// nolint: errcheck
+	cmd.Flags().MarkHidden("v2")
+	return cmd
+}
+
+// VerifyArchive - checks all the chunks of the archive found in dir
+func VerifyArchive(log clog.PluggableLoggerInterface, dir string) error {
+	archiveDir := strings.TrimPrefix(dir, fileProtocol)
+	fi, err := os.Stat(archiveDir)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%s is not a directory", archiveDir)
+	}
+	// nothing is extracted: the working-dir and the cache are not used
+	verifier, err := archive.NewArchiveExtractor(archiveDir, "", "")
+	if err != nil {
+		return err
+	}
+	defer verifier.Close()
+	log.Info("verifying the checksums of the archive in %s", archiveDir)
+	err = verifier.Verify()
+	if err != nil {
+		return err
+	}
+	log.Info("checksums of the archive in %s verified successfully", archiveDir)
+	return nil
+}
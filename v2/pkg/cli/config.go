@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift/oc-mirror/v2/pkg/archive"
+	"github.com/openshift/oc-mirror/v2/pkg/config"
+)
+
+// resolveConfig - diskToMirror defaults to the image set configuration embedded in the archive
+// found in rootDir, so that the collectors compute the same references as the mirrorToDisk side.
+// When --config is passed, it is compared with the embedded one: the differences are logged,
+// and fail the run with --strict-config
+func (o *ExecutorSchema) resolveConfig(rootDir string) error {
+	embedded, err := archive.ExtractImageSetConfig(rootDir, filepath.Join(rootDir, workingDir))
+	if err != nil {
+		return err
+	}
+	if embedded == "" {
+		if o.Opts.Global.ConfigPath == "" {
+			return fmt.Errorf("no imageset configuration embedded in the archive in %s, use the --config flag", rootDir)
+		}
+		o.Log.Warn("no imageset configuration embedded in the archive, using %s", o.Opts.Global.ConfigPath)
+		return nil
+	}
+	if o.Opts.Global.ConfigPath == "" {
+		o.Log.Info("using the imageset configuration embedded in the archive (%s)", embedded)
+		o.Opts.Global.ConfigPath = embedded
+		return nil
+	}
+
+	diff, err := configDiff(embedded, o.Opts.Global.ConfigPath)
+	if err != nil {
+		return err
+	}
+	if diff == "" {
+		o.Log.Debug("%s matches the imageset configuration embedded in the archive", o.Opts.Global.ConfigPath)
+		return nil
+	}
+	o.Log.Warn("%s differs from the imageset configuration embedded in the archive:\n%s", o.Opts.Global.ConfigPath, diff)
+	if o.Opts.Global.StrictConfig {
+		return fmt.Errorf("%s differs from the imageset configuration embedded in the archive (--strict-config), remove --config to use the embedded one", o.Opts.Global.ConfigPath)
+	}
+	o.Log.Warn("using %s: the images collected may not match the ones in the archive", o.Opts.Global.ConfigPath)
+	return nil
+}
+
+// configDiff - the unified diff between the image set configurations, once parsed
+// (the formatting, the comments and the order of the fields are ignored). Empty when they match
+func configDiff(embedded, configPath string) (string, error) {
+	from, err := normalizedConfig(embedded)
+	if err != nil {
+		return "", err
+	}
+	to, err := normalizedConfig(configPath)
+	if err != nil {
+		return "", err
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(from),
+		B:        difflib.SplitLines(to),
+		FromFile: "archive",
+		ToFile:   configPath,
+		Context:  2,
+	})
+}
+
+func normalizedConfig(path string) (string, error) {
+	cfg, err := config.ReadConfig(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read the imageset configuration %s: %v", path, err)
+	}
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,230 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/openshift/oc-mirror/v2/pkg/additional"
+	"github.com/openshift/oc-mirror/v2/pkg/config"
+	"github.com/openshift/oc-mirror/v2/pkg/deleteimages"
+	"github.com/openshift/oc-mirror/v2/pkg/helm"
+	"github.com/openshift/oc-mirror/v2/pkg/imagebuilder"
+	clog "github.com/openshift/oc-mirror/v2/pkg/log"
+	"github.com/openshift/oc-mirror/v2/pkg/manifest"
+	"github.com/openshift/oc-mirror/v2/pkg/mirror"
+	"github.com/openshift/oc-mirror/v2/pkg/operator"
+	"github.com/openshift/oc-mirror/v2/pkg/release"
+)
+
+var (
+	deleteLongDesc = templates.LongDesc(
+		`
+		Delete images from a mirror registry, using a DeleteImageSetConfiguration.
+
+		The releases, operators and additional images listed in the configuration
+		are resolved to the images that oc-mirror mirrored to the destination registry.
+
+		1. Without --force-delete - a delete plan is written to the working-dir (delete/delete-images.yaml) for review.
+		2. With --force-delete - the images listed in the delete plan are deleted from the destination registry.
+		`,
+	)
+	deleteExamples = templates.Examples(
+		`
+		# Generate the delete plan
+		oc-mirror delete --config delete-config.yaml docker://registry.example:5000 --v2
+
+		# Delete the images listed in the delete plan
+		oc-mirror delete --config delete-config.yaml docker://registry.example:5000 --v2 --force-delete
+		`,
+	)
+)
+
+// NewDeleteCommand - cobra entry point for the delete subcommand
+func NewDeleteCommand(log clog.PluggableLoggerInterface) *cobra.Command {
+	global := &mirror.GlobalOptions{
+		TlsVerify:    false,
+		SecurePolicy: false,
+	}
+
+	flagSharedOpts, sharedOpts := mirror.SharedImageFlags()
+	flagDepTLS, deprecatedTLSVerifyOpt := mirror.DeprecatedTLSVerifyFlags()
+	flagSrcOpts, srcOpts := mirror.ImageSrcFlags(global, sharedOpts, deprecatedTLSVerifyOpt, "src-", "screds")
+	flagDestOpts, destOpts := mirror.ImageDestFlags(global, sharedOpts, deprecatedTLSVerifyOpt, "dest-", "dcreds")
+	flagRetryOpts, retryOpts := mirror.RetryFlags()
+
+	opts := mirror.CopyOptions{
+		Global:              global,
+		DeprecatedTLSVerify: deprecatedTLSVerifyOpt,
+		SrcImage:            srcOpts,
+		DestImage:           destOpts,
+		RetryOpts:           retryOpts,
+		Dev:                 false,
+	}
+
+	ex := &ExecutorSchema{
+		Log:  log,
+		Opts: opts,
+	}
+	cmd := &cobra.Command{
+		Use:     "delete <destination type>:<destination location>",
+		Short:   "Deletes images from a mirror registry, as described in a DeleteImageSetConfiguration",
+		Long:    deleteLongDesc,
+		Example: deleteExamples,
+		Args:    cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := ex.ValidateDelete(args)
+			if err != nil {
+				log.Error("%v ", err)
+				os.Exit(1)
+			}
+			err = ex.CompleteDelete(args)
+			if err != nil {
+				log.Error("%v ", err)
+				os.Exit(1)
+			}
+			// prepare internal storage
+			err = ex.PrepareStorageAndLogs()
+			if err != nil {
+				log.Error(" %v ", err)
+				os.Exit(1)
+			}
+
+			err = ex.RunDelete(cmd, args)
+			if err != nil {
+				log.Error("%v ", err)
+				os.Exit(1)
+			}
+		},
+	}
+	cmd.PersistentFlags().StringVarP(&opts.Global.ConfigPath, "config", "c", "", "Path to delete imageset configuration file")
+	cmd.Flags().StringVar(&opts.Global.LogLevel, "loglevel", "info", "Log level one of (info, debug, trace, error)")
+	cmd.Flags().StringVar(&opts.Global.WorkingDir, "dir", "working-dir", "Assets directory")
+	cmd.Flags().Uint16VarP(&opts.Global.Port, "port", "p", 5000, "HTTP port used by oc-mirror's local storage instance")
+	cmd.Flags().BoolVar(&opts.Global.ForceDelete, "force-delete", false, "Delete the images listed in the delete plan from the destination registry")
+	cmd.Flags().StringVar(&opts.Global.Platform, "platform", manifest.DefaultPlatform, "Platform (os/arch[/variant]) of the catalog and release images to read the contents of, when they are multi-arch")
+	cmd.Flags().IntVar(&opts.Global.MaxNestedPaths, "max-nested-paths", 0, "Maximum number of path components of the repositories in the destination registry (including its namespace, which is kept as it is), the last components of the image path are joined by - above it. 0 for no limit")
+	cmd.Flags().BoolVar(&opts.Global.V2, "v2", opts.Global.V2, "Redirect the flow to oc-mirror v2 - PLEASE DO NOT USE that. V2 is still under development and it is not ready to be used.")
+	// nolint: errcheck
+	cmd.Flags().MarkHidden("v2")
+	cmd.Flags().AddFlagSet(&flagSharedOpts)
+	cmd.Flags().AddFlagSet(&flagRetryOpts)
+	cmd.Flags().AddFlagSet(&flagDepTLS)
+	cmd.Flags().AddFlagSet(&flagSrcOpts)
+	cmd.Flags().AddFlagSet(&flagDestOpts)
+	return cmd
+}
+
+// ValidateDelete - cobra validation
+func (o ExecutorSchema) ValidateDelete(dest []string) error {
+	if len(o.Opts.Global.ConfigPath) == 0 {
+		return fmt.Errorf("use the --config flag it is mandatory")
+	}
+	if !strings.Contains(dest[0], dockerProtocol) {
+		return fmt.Errorf("with delete command, the destination must have the docker:// protocol prefix")
+	}
+	if _, err := manifest.ParsePlatform(o.Opts.Global.Platform); err != nil {
+		return fmt.Errorf("--platform: %v", err)
+	}
+	if o.Opts.Global.MaxNestedPaths < 0 {
+		return fmt.Errorf("--max-nested-paths must be zero or positive")
+	}
+	return nil
+}
+
+// CompleteDelete - do the final setup of modules
+func (o *ExecutorSchema) CompleteDelete(args []string) error {
+	err := o.setupLogsLevelAndDir()
+	if err != nil {
+		return err
+	}
+	o.Log.Debug("delete imagesetconfig file %s ", o.Opts.Global.ConfigPath)
+	// read the DeleteImageSetConfiguration
+	cfg, err := config.ReadDeleteConfig(o.Opts.Global.ConfigPath)
+	if err != nil {
+		return err
+	}
+	o.Log.Trace("delete imagesetconfig : %v ", cfg)
+
+	// update all dependant modules
+	mc := mirror.NewMirrorCopy()
+	md := mirror.NewMirrorDelete()
+	platform, err := manifest.ParsePlatform(o.Opts.Global.Platform)
+	if err != nil {
+		return err
+	}
+	o.Manifest = manifest.New(o.Log, platform)
+	o.Mirror = mirror.New(mc, md)
+	// the collectors resolve the images to delete from
+	// an ImageSetConfiguration with the same content
+	o.Config = cfg.ToImageSetConfiguration()
+	// the graph image is shared by all the releases mirrored to the
+	// registry, it is never deleted
+	o.Config.Mirror.Platform.Graph = false
+
+	// the references in the destination registry are the same
+	// as the ones of the mirrorToMirror workflow
+	o.Opts.Mode = mirror.MirrorToMirror
+	o.Opts.Destination = args[0]
+	// the delete plan is resolved only: as with --dry-run, the collectors
+	// don't rebuild the catalogs nor push anything to the registries
+	o.Opts.Global.DryRun = !o.Opts.Global.ForceDelete
+	o.LocalStorageFQDN = "localhost:" + strconv.Itoa(int(o.Opts.Global.Port))
+
+	err = o.setupWorkingDir()
+	if err != nil {
+		return err
+	}
+
+	err = o.setupLocalStorageDir()
+	if err != nil {
+		return err
+	}
+
+	client, _ := release.NewOCPClient(uuid.New())
+
+	o.ImageBuilder = imagebuilder.NewBuilder(o.Log, o.Opts)
+
+	signature := release.NewSignatureClient(o.Log, o.Config, o.Opts)
+	cn := release.NewCincinnati(o.Log, &o.Config, o.Opts, client, false, signature)
+	o.Release = release.New(o.Log, o.Config, o.Opts, o.Mirror, o.Manifest, cn, signature, o.LocalStorageFQDN, o.ImageBuilder)
+	o.Operator = operator.New(o.Log, o.Config, o.Opts, o.Mirror, o.Manifest, o.LocalStorageFQDN, o.ImageBuilder)
+	o.AdditionalImages = additional.New(o.Log, o.Config, o.Opts, o.Mirror, o.Manifest, o.LocalStorageFQDN)
+	o.Helm = helm.New(o.Log, o.Config, o.Opts, o.LocalStorageFQDN)
+	o.Delete = deleteimages.New(o.Log, o.Opts, o.Mirror)
+	return nil
+}
+
+// RunDelete - writes the delete plan, or deletes the images
+// of the delete plan from the destination registry when --force-delete is set
+func (o *ExecutorSchema) RunDelete(cmd *cobra.Command, args []string) error {
+	if o.Opts.Global.ForceDelete {
+		plan, err := o.Delete.ReadDeletePlan()
+		if err != nil {
+			return fmt.Errorf("%v - run the delete command without --force-delete to generate the delete plan", err)
+		}
+		o.Log.Info("deleting %d images from %s", len(plan.Items), o.Opts.Destination)
+		return o.Delete.DeleteRegistryImages(cmd.Context(), plan)
+	}
+
+	o.Log.Info("starting local storage on localhost:%v", o.Opts.Global.Port)
+	go startLocalRegistry(&o.LocalStorageService, o.localStorageInterruptChannel)
+
+	allImages, err := o.CollectAll(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	planFile, err := o.Delete.WriteDeletePlan(allImages)
+	if err != nil {
+		return err
+	}
+	o.Log.Info("delete plan written to %s", planFile)
+	o.Log.Info("review it, and re-run the delete command with --force-delete to delete the images from %s", o.Opts.Destination)
+	return nil
+}
@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
+)
+
+const (
+	dryRunDir   string = "dry-run"
+	mappingFile string = "mapping.txt"
+	missingFile string = "missing.txt"
+)
+
+// DryRun - writes the mapping of the collected images (source=destination, as in oc-mirror v1)
+// and the estimate of their size in the working-dir instead of copying them. For mirrorToDisk,
+// the images that are not in the cache yet are written to missing.txt as well
+func (o *ExecutorSchema) DryRun(ctx context.Context, allImages []v1alpha3.CopyImageSchema) error {
+	dir := filepath.Join(o.Opts.Global.WorkingDir, dryRunDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	mappingPath := filepath.Join(dir, mappingFile)
+	if err := writeMapping(mappingPath, allImages); err != nil {
+		return err
+	}
+	o.Log.Info("dry-run: %d images to mirror, mapping written to %s", len(allImages), mappingPath)
+
+	if err := o.estimateSize(ctx, dir, allImages); err != nil {
+		return err
+	}
+
+	if !o.Opts.IsMirrorToDisk() {
+		return nil
+	}
+	var missing []v1alpha3.CopyImageSchema
+	for _, img := range allImages {
+		exists, err := o.Mirror.Check(ctx, img.Destination, &o.Opts)
+		if err != nil {
+			o.Log.Debug("unable to check existence of %s in local cache: %v", img.Destination, err)
+		}
+		if err != nil || !exists {
+			missing = append(missing, img)
+		}
+	}
+	missingPath := filepath.Join(dir, missingFile)
+	if err := writeMapping(missingPath, missing); err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		o.Log.Warn("dry-run: %d/%d images missing from the cache, written to %s", len(missing), len(allImages), missingPath)
+	} else {
+		o.Log.Info("dry-run: all %d images are already in the cache", len(allImages))
+	}
+	return nil
+}
+
+// estimateSize - writes the size estimate of the images, reading their manifests only
+func (o *ExecutorSchema) estimateSize(ctx context.Context, dir string, allImages []v1alpha3.CopyImageSchema) error {
+	if o.Estimator == nil {
+		return nil
+	}
+	est, err := o.Estimator.Estimate(ctx, allImages)
+	if err != nil {
+		return err
+	}
+	files, err := est.Write(dir)
+	if err != nil {
+		return err
+	}
+	for _, g := range est.Breakdown {
+		o.Log.Info("dry-run: %-10s %s: %d images, %s (%s new)", g.Kind, g.Name, g.Images, humanSize(g.Bytes), humanSize(g.NewBytes))
+	}
+	if len(est.Unknown) > 0 {
+		o.Log.Warn("dry-run: the size of %d images is unknown, see %s", len(est.Unknown), files[0])
+	}
+	o.Log.Info("dry-run: %d blobs, %s in total, %d new blobs, %s new, archive of %s", est.Blobs, humanSize(est.Bytes), est.NewBlobs, humanSize(est.NewBytes), humanSize(est.ArchiveBytes))
+	o.Log.Info("dry-run: size estimate written to %s", strings.Join(files, ", "))
+	return nil
+}
+
+// humanSize - the size in bytes, in a human readable unit
+func humanSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+// writeMapping - writes the images in the mapping format of oc-mirror v1 (source=destination),
+// sorted, with the docker:// transport removed
+func writeMapping(path string, images []v1alpha3.CopyImageSchema) error {
+	lines := make([]string, 0, len(images))
+	for _, img := range images {
+		lines = append(lines, fmt.Sprintf("%s=%s", strings.TrimPrefix(img.Source, dockerProtocol), strings.TrimPrefix(img.Destination, dockerProtocol)))
+	}
+	sort.Strings(lines)
+	var buff bytes.Buffer
+	for _, line := range lines {
+		buff.WriteString(line + "\n")
+	}
+	return os.WriteFile(path, buff.Bytes(), 0644)
+}
@@ -27,14 +27,22 @@ import (
 	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
 	"github.com/openshift/oc-mirror/v2/pkg/archive"
 	"github.com/openshift/oc-mirror/v2/pkg/batch"
+	"github.com/openshift/oc-mirror/v2/pkg/blocked"
 	"github.com/openshift/oc-mirror/v2/pkg/clusterresources"
 	"github.com/openshift/oc-mirror/v2/pkg/config"
+	"github.com/openshift/oc-mirror/v2/pkg/deleteimages"
+	"github.com/openshift/oc-mirror/v2/pkg/estimate"
+	"github.com/openshift/oc-mirror/v2/pkg/helm"
+	"github.com/openshift/oc-mirror/v2/pkg/history"
 	"github.com/openshift/oc-mirror/v2/pkg/imagebuilder"
 	clog "github.com/openshift/oc-mirror/v2/pkg/log"
 	"github.com/openshift/oc-mirror/v2/pkg/manifest"
 	"github.com/openshift/oc-mirror/v2/pkg/mirror"
 	"github.com/openshift/oc-mirror/v2/pkg/operator"
 	"github.com/openshift/oc-mirror/v2/pkg/release"
+	"github.com/openshift/oc-mirror/v2/pkg/report"
+	"github.com/openshift/oc-mirror/v2/pkg/request"
+	"github.com/openshift/oc-mirror/v2/pkg/rewrite"
 	"github.com/spf13/cobra"
 )
 
@@ -63,8 +71,9 @@ var (
 
 		The podman location for credentials is also supported as a secondary location.
 
-		1. Destination prefix is docker:// - The current working directory will be used.
-		2. Destination prefix is oci:// - The destination directory specified will be used.
+		1. Destination prefix is file:// - mirrorToDisk: images are mirrored to the local cache and an archive is generated in the destination directory.
+		2. Destination prefix is docker:// with --from - diskToMirror: the archive found in --from is mirrored to the destination registry, with the imageset configuration embedded in the archive unless --config is passed.
+		3. Destination prefix is docker:// without --from - mirrorToMirror: images are mirrored directly from the source registries to the destination registry.
 
 		`,
 	)
@@ -72,6 +81,9 @@ var (
 		`
 		# Mirror to a directory
 		oc-mirror oci:mirror --config mirror-config.yaml
+
+		# Mirror directly from the source registries to a registry (mirrorToMirror)
+		oc-mirror --config mirror-config.yaml docker://registry.example:5000 --v2
 		`,
 	)
 	registryLogFile *os.File
@@ -84,6 +96,7 @@ type ExecutorSchema struct {
 	Operator                     operator.CollectorInterface
 	Release                      release.CollectorInterface
 	AdditionalImages             additional.CollectorInterface
+	Helm                         helm.CollectorInterface
 	Mirror                       mirror.MirrorInterface
 	Manifest                     manifest.ManifestInterface
 	Batch                        batch.BatchInterface
@@ -95,6 +108,12 @@ type ExecutorSchema struct {
 	ImageBuilder                 imagebuilder.ImageBuilderInterface
 	MirrorArchiver               archive.Archiver
 	MirrorUnArchiver             archive.UnArchiver
+	Delete                       deleteimages.DeleteInterface
+	imageReports                 []report.ImageReport
+	blockedReports               []report.ImageReport
+	unsatisfiedDependencies      []string
+	Estimator                    estimate.EstimatorInterface
+	Request                      request.BuilderInterface
 }
 
 // NewMirrorCmd - cobra entry point
@@ -160,13 +179,29 @@ func NewMirrorCmd(log clog.PluggableLoggerInterface) *cobra.Command {
 		},
 	}
 	cmd.AddCommand(NewPrepareCommand(log))
-	cmd.PersistentFlags().StringVarP(&opts.Global.ConfigPath, "config", "c", "", "Path to imageset configuration file")
+	cmd.AddCommand(NewDeleteCommand(log))
+	cmd.AddCommand(NewArchiveCommand(log))
+	cmd.AddCommand(NewHistoryCommand(log))
+	cmd.PersistentFlags().StringVarP(&opts.Global.ConfigPath, "config", "c", "", "Path to imageset configuration file, diskToMirror defaults to the one embedded in the archive")
 	cmd.Flags().StringVar(&opts.Global.LogLevel, "loglevel", "info", "Log level one of (info, debug, trace, error)")
 	cmd.Flags().StringVar(&opts.Global.WorkingDir, "dir", "working-dir", "Assets directory")
 	cmd.Flags().StringVar(&opts.Global.From, "from", "", "local storage directory for disk to mirror workflow")
 	cmd.Flags().Uint16VarP(&opts.Global.Port, "port", "p", 5000, "HTTP port used by oc-mirror's local storage instance")
 	cmd.Flags().BoolVarP(&opts.Global.Quiet, "quiet", "q", false, "enable detailed logging when copying images")
 	cmd.Flags().BoolVarP(&opts.Global.Force, "force", "f", false, "force the copy and mirror functionality")
+	cmd.Flags().UintVar(&opts.Global.ParallelImages, "parallel-images", batch.DefaultParallelImages, "Number of images copied in parallel")
+	cmd.Flags().UintVar(&opts.Global.MaxPerRegistry, "max-per-registry", batch.DefaultMaxPerRegistry, "Number of concurrent copies allowed per source registry (the copies from the local storage are only bound by --parallel-images)")
+	cmd.Flags().StringVar(&opts.Global.ArchiveCompression, "archive-compression", archive.CompressionNone, "Compression of the archive generated by the mirrorToDisk workflow, one of (none, gzip, zstd)")
+	cmd.Flags().StringVar(&opts.Global.MirrorSetScope, "mirror-set-scope", clusterresources.NamespaceScope, "Scope of the sources of the generated ImageDigestMirrorSet and ImageTagMirrorSet, one of (registry, namespace, repository)")
+	cmd.Flags().StringVar(&opts.Global.Platform, "platform", manifest.DefaultPlatform, "Platform (os/arch[/variant]) of the catalog and release images to read the contents of, when they are multi-arch")
+	cmd.Flags().IntVar(&opts.Global.MaxNestedPaths, "max-nested-paths", 0, "Maximum number of path components of the repositories in the destination registry (including its namespace, which is kept as it is), the last components of the image path are joined by - above it. 0 for no limit")
+	cmd.Flags().BoolVar(&opts.Global.DryRun, "dry-run", false, "Collect the images to mirror and write mapping.txt (and missing.txt for mirrorToDisk) and the size estimate of the images in the working-dir, without copying the images")
+	cmd.Flags().StringVar(&opts.Global.Since, "since", "", "mirrorToDisk: build the archive against the latest history snapshot taken before this date (RFC3339 or YYYY-MM-DD), i.e. to rebuild an archive that was lost")
+	cmd.Flags().StringVar(&opts.Global.SinceSnapshot, "since-snapshot", "", "mirrorToDisk: build the archive against the history snapshot written when that archive was built (see oc-mirror history list)")
+	cmd.Flags().StringVar(&opts.Global.WriteRequest, "write-request", "", "diskToMirror: instead of mirroring, write to this file the delta request listing the images and blobs already in the destination registry, for the mirrorToDisk side")
+	cmd.Flags().StringVar(&opts.Global.FromRequest, "from-request", "", "mirrorToDisk: build the archive with only the blobs and manifests missing from the delta request written by the diskToMirror side (--write-request), instead of the history")
+	cmd.Flags().BoolVar(&opts.Global.StrictConfig, "strict-config", false, "diskToMirror: fail when --config differs from the image set configuration embedded in the archive (used when --config is not passed)")
+	cmd.Flags().BoolVar(&opts.Global.Resume, "resume", false, "Skip the images that the previous run of the same workflow copied successfully, and copy only the images that failed or were not copied")
 	cmd.Flags().BoolVar(&opts.Global.V2, "v2", opts.Global.V2, "Redirect the flow to oc-mirror v2 - PLEASE DO NOT USE that. V2 is still under development and it is not ready to be used.")
 	cmd.Flags().BoolVar(&opts.Global.SecurePolicy, "secure-policy", opts.Global.SecurePolicy, "If set (default is false), will enable signature verification (secure policy for signature verification).")
 	// nolint: errcheck
@@ -181,22 +216,64 @@ func NewMirrorCmd(log clog.PluggableLoggerInterface) *cobra.Command {
 
 // Validate - cobra validation
 func (o ExecutorSchema) Validate(dest []string) error {
-	if len(o.Opts.Global.ConfigPath) == 0 {
+	// diskToMirror defaults to the image set configuration embedded in the archive
+	if len(o.Opts.Global.ConfigPath) == 0 && (!strings.Contains(dest[0], dockerProtocol) || o.Opts.Global.From == "") {
 		return fmt.Errorf("use the --config flag it is mandatory")
 	}
-	if strings.Contains(dest[0], dockerProtocol) && o.Opts.Global.From == "" {
-		return fmt.Errorf("when destination is docker://, diskToMirror workflow is assumed, and the --from argument become mandatory")
-	}
 	if strings.Contains(dest[0], fileProtocol) && o.Opts.Global.From != "" {
 		return fmt.Errorf("when destination is file://, mirrorToDisk workflow is assumed, and the --from argument is not needed")
 	}
 	if len(o.Opts.Global.From) > 0 && !strings.Contains(o.Opts.Global.From, fileProtocol) {
 		return fmt.Errorf("when --from is used, it must have file:// prefix")
 	}
+	if o.Opts.Global.ParallelImages == 0 || o.Opts.Global.MaxPerRegistry == 0 {
+		return fmt.Errorf("--parallel-images and --max-per-registry must be greater than 0")
+	}
+	if !archive.IsValidCompression(o.Opts.Global.ArchiveCompression) {
+		return fmt.Errorf("--archive-compression must be one of none, gzip or zstd")
+	}
+	if !clusterresources.IsValidScope(o.Opts.Global.MirrorSetScope) {
+		return fmt.Errorf("--mirror-set-scope must be one of registry, namespace or repository")
+	}
+	if _, err := manifest.ParsePlatform(o.Opts.Global.Platform); err != nil {
+		return fmt.Errorf("--platform: %v", err)
+	}
+	if o.Opts.Global.MaxNestedPaths < 0 {
+		return fmt.Errorf("--max-nested-paths must be zero or positive")
+	}
+	if strings.Contains(dest[0], dockerProtocol) {
+		if err := rewrite.ValidateNestedPaths(o.Opts.Global.MaxNestedPaths, dest[0]); err != nil {
+			return fmt.Errorf("--max-nested-paths: %v", err)
+		}
+	}
+	if o.Opts.Global.Since != "" || o.Opts.Global.SinceSnapshot != "" {
+		if !strings.Contains(dest[0], fileProtocol) {
+			return fmt.Errorf("--since and --since-snapshot can only be used with the mirrorToDisk workflow (file:// destination)")
+		}
+		if o.Opts.Global.Since != "" && o.Opts.Global.SinceSnapshot != "" {
+			return fmt.Errorf("--since and --since-snapshot can't be used together")
+		}
+		if o.Opts.Global.Since != "" {
+			if _, err := history.ParseDate(o.Opts.Global.Since); err != nil {
+				return fmt.Errorf("--since: %v", err)
+			}
+		}
+	}
+	if o.Opts.Global.FromRequest != "" {
+		if !strings.Contains(dest[0], fileProtocol) {
+			return fmt.Errorf("--from-request can only be used with the mirrorToDisk workflow (file:// destination)")
+		}
+		if o.Opts.Global.Since != "" || o.Opts.Global.SinceSnapshot != "" {
+			return fmt.Errorf("--from-request can't be used with --since or --since-snapshot")
+		}
+	}
+	if o.Opts.Global.WriteRequest != "" && (!strings.Contains(dest[0], dockerProtocol) || o.Opts.Global.From == "") {
+		return fmt.Errorf("--write-request can only be used with the diskToMirror workflow (docker:// destination and --from)")
+	}
 	if strings.Contains(dest[0], fileProtocol) || strings.Contains(dest[0], dockerProtocol) {
 		return nil
 	} else {
-		return fmt.Errorf("destination must have either file:// (mirror to disk) or docker:// (diskToMirror) protocol prefixes")
+		return fmt.Errorf("destination must have either file:// (mirror to disk) or docker:// (diskToMirror or mirrorToMirror) protocol prefixes")
 	}
 }
 
@@ -312,6 +389,12 @@ func (o *ExecutorSchema) Complete(args []string) error {
 	if err != nil {
 		return err
 	}
+	if strings.Contains(args[0], dockerProtocol) && o.Opts.Global.From != "" {
+		err = o.resolveConfig(strings.TrimPrefix(o.Opts.Global.From, fileProtocol))
+		if err != nil {
+			return err
+		}
+	}
 	o.Log.Debug("imagesetconfig file %s ", o.Opts.Global.ConfigPath)
 	// read the ImageSetConfiguration
 	cfg, err := config.ReadConfig(o.Opts.Global.ConfigPath)
@@ -323,7 +406,11 @@ func (o *ExecutorSchema) Complete(args []string) error {
 	// update all dependant modules
 	mc := mirror.NewMirrorCopy()
 	md := mirror.NewMirrorDelete()
-	o.Manifest = manifest.New(o.Log)
+	platform, err := manifest.ParsePlatform(o.Opts.Global.Platform)
+	if err != nil {
+		return err
+	}
+	o.Manifest = manifest.New(o.Log, platform)
 	o.Mirror = mirror.New(mc, md)
 	o.Config = cfg
 	o.Batch = batch.New(o.Log, o.Mirror, o.Manifest)
@@ -334,14 +421,20 @@ func (o *ExecutorSchema) Complete(args []string) error {
 		o.Opts.Mode = mirror.MirrorToDisk
 		rootDir = strings.TrimPrefix(args[0], fileProtocol)
 		o.Log.Debug("destination %s ", rootDir)
-	} else if strings.Contains(args[0], dockerProtocol) {
+	} else if strings.Contains(args[0], dockerProtocol) && o.Opts.Global.From != "" {
 		rootDir = strings.TrimPrefix(o.Opts.Global.From, fileProtocol)
 		o.Opts.Mode = mirror.DiskToMirror
+	} else if strings.Contains(args[0], dockerProtocol) {
+		o.Opts.Mode = mirror.MirrorToMirror
 	} else {
 		o.Log.Error("unable to determine the mode (the destination must be either file:// or docker://)")
 	}
 	o.Opts.Destination = args[0]
-	o.Opts.Global.WorkingDir = filepath.Join(rootDir, workingDir)
+	// in mirrorToMirror there is no archive to build or extract,
+	// the working-dir is used as passed with --dir
+	if !o.Opts.IsMirrorToMirror() {
+		o.Opts.Global.WorkingDir = filepath.Join(rootDir, workingDir)
+	}
 	o.Log.Info("mode %s ", o.Opts.Mode)
 	o.LocalStorageFQDN = "localhost:" + strconv.Itoa(int(o.Opts.Global.Port))
 
@@ -361,13 +454,34 @@ func (o *ExecutorSchema) Complete(args []string) error {
 
 	signature := release.NewSignatureClient(o.Log, o.Config, o.Opts)
 	cn := release.NewCincinnati(o.Log, &o.Config, o.Opts, client, false, signature)
-	o.Release = release.New(o.Log, o.Config, o.Opts, o.Mirror, o.Manifest, cn, o.LocalStorageFQDN, o.ImageBuilder)
-	o.Operator = operator.New(o.Log, o.Config, o.Opts, o.Mirror, o.Manifest, o.LocalStorageFQDN)
+	o.Release = release.New(o.Log, o.Config, o.Opts, o.Mirror, o.Manifest, cn, signature, o.LocalStorageFQDN, o.ImageBuilder)
+	o.Operator = operator.New(o.Log, o.Config, o.Opts, o.Mirror, o.Manifest, o.LocalStorageFQDN, o.ImageBuilder)
 	o.AdditionalImages = additional.New(o.Log, o.Config, o.Opts, o.Mirror, o.Manifest, o.LocalStorageFQDN)
+	o.Helm = helm.New(o.Log, o.Config, o.Opts, o.LocalStorageFQDN)
 	o.ClusterResources = clusterresources.New(o.Log, o.Config, o.Opts)
 
+	if o.Opts.Global.DryRun {
+		// the blobs of the previous mirrorToDisk runs are not counted as new
+		before, err := history.Before(o.Opts.Global.WorkingDir, o.Opts.Global.Since, o.Opts.Global.SinceSnapshot)
+		if err != nil {
+			return err
+		}
+		hist, err := history.NewHistory(o.Opts.Global.WorkingDir, before, o.Log, history.OSFileCreator{})
+		if err != nil {
+			return err
+		}
+		if o.Opts.Global.FromRequest != "" {
+			req, err := request.Read(o.Opts.Global.FromRequest)
+			if err != nil {
+				return err
+			}
+			hist = history.WithBlobs(hist, req.BlobSet())
+		}
+		o.Estimator = estimate.New(o.Log, archive.NewImageBlobSizesGatherer(&o.Opts), hist, o.Opts.Global.ParallelImages)
+	}
+
 	if o.Opts.IsMirrorToDisk() {
-		o.MirrorArchiver, err = archive.NewMirrorArchive(&o.Opts, rootDir, o.Opts.Global.ConfigPath, o.Opts.Global.WorkingDir, o.LocalStorageDisk, o.Log)
+		o.MirrorArchiver, err = archive.NewMirrorArchive(&o.Opts, rootDir, o.Opts.Global.ConfigPath, o.Opts.Global.WorkingDir, o.LocalStorageDisk, o.Config.ImageSetConfigurationSpec.ArchiveSize, o.Log)
 		if err != nil {
 			return err
 		}
@@ -376,6 +490,11 @@ func (o *ExecutorSchema) Complete(args []string) error {
 		if err != nil {
 			return err
 		}
+		if o.Opts.Global.WriteRequest != "" {
+			// the images are read from the destination registry
+			destOpts := o.Opts.WithDestinationAsSource()
+			o.Request = request.New(o.Log, o.Mirror, archive.NewImageBlobGatherer(&destOpts), &destOpts, o.Opts.Global.ParallelImages)
+		}
 	}
 	return nil
 }
@@ -445,6 +564,7 @@ func (o *ExecutorSchema) setupWorkingDir() error {
 
 // Run - start the mirror functionality
 func (o *ExecutorSchema) Run(cmd *cobra.Command, args []string) error {
+	startTime := time.Now()
 
 	// make sure we always get multi-arch images
 	o.Opts.MultiArch = "all"
@@ -452,10 +572,15 @@ func (o *ExecutorSchema) Run(cmd *cobra.Command, args []string) error {
 	if o.Opts.IsMirrorToDisk() {
 		err = o.RunMirrorToDisk(cmd, args)
 
+	} else if o.Opts.IsMirrorToMirror() {
+		err = o.RunMirrorToMirror(cmd, args)
+
 	} else {
 		err = o.RunDiskToMirror(cmd, args)
 
 	}
+	// the report is written even when the run failed
+	o.writeReport(startTime, err)
 	if err != nil {
 		// o.Log.Error(" %v ", err)
 		cleanUp()
@@ -465,6 +590,20 @@ func (o *ExecutorSchema) Run(cmd *cobra.Command, args []string) error {
 	defer cleanUp()
 	return nil
 }
+
+// writeReport - saves the report of the run (json and yaml) in the working-dir
+func (o *ExecutorSchema) writeReport(startTime time.Time, runErr error) {
+	images := append([]report.ImageReport{}, o.imageReports...)
+	images = append(images, o.blockedReports...)
+	r := report.New(o.Opts.Mode, startTime, time.Now(), images, runErr)
+	r.UnsatisfiedDependencies = o.unsatisfiedDependencies
+	files, err := r.Write(o.Opts.Global.WorkingDir)
+	if err != nil {
+		o.Log.Error("unable to write the run report: %v", err)
+		return
+	}
+	o.Log.Info("run report: %s", strings.Join(files, ", "))
+}
 func (o *ExecutorSchema) RunMirrorToDisk(cmd *cobra.Command, args []string) error {
 	startTime := time.Now()
 
@@ -475,15 +614,18 @@ func (o *ExecutorSchema) RunMirrorToDisk(cmd *cobra.Command, args []string) erro
 	if err != nil {
 		return err
 	}
+	if o.Opts.Global.DryRun {
+		return o.DryRun(cmd.Context(), allImages)
+	}
 	collectionFinish := time.Now()
 
 	//call the batch worker
-	err = o.Batch.Worker(cmd.Context(), allImages, o.Opts)
+	o.imageReports, err = o.Batch.Worker(cmd.Context(), allImages, o.Opts)
 	if err != nil {
 		return err
 	}
 
-	// Prepare tar.gz when mirror to disk
+	// Prepare the archive when mirror to disk
 	// First stop the registry
 	interruptSig := NormalStorageInterruptErrorf("end of mirroring to disk. Stopping local storage to prepare the archive")
 	o.localStorageInterruptChannel <- interruptSig
@@ -525,10 +667,16 @@ func (o *ExecutorSchema) RunDiskToMirror(cmd *cobra.Command, args []string) erro
 	if err != nil {
 		return err
 	}
+	if o.Opts.Global.WriteRequest != "" {
+		return o.WriteRequest(cmd.Context(), allImages)
+	}
+	if o.Opts.Global.DryRun {
+		return o.DryRun(cmd.Context(), allImages)
+	}
 	collectionFinish := time.Now()
 
 	//call the batch worker
-	err = o.Batch.Worker(cmd.Context(), allImages, o.Opts)
+	o.imageReports, err = o.Batch.Worker(cmd.Context(), allImages, o.Opts)
 	if err != nil {
 		return err
 	}
@@ -537,6 +685,20 @@ func (o *ExecutorSchema) RunDiskToMirror(cmd *cobra.Command, args []string) erro
 	if err != nil {
 		return err
 	}
+	//create the release signatures configmaps and the UpdateService
+	err = o.ClusterResources.ReleaseSignaturesGenerator(allImages)
+	if err != nil {
+		return err
+	}
+	err = o.ClusterResources.UpdateServiceGenerator(allImages)
+	if err != nil {
+		return err
+	}
+	//create the catalog sources
+	err = o.ClusterResources.CatalogSourceGenerator(allImages)
+	if err != nil {
+		return err
+	}
 
 	mirrorFinish := time.Now()
 	o.Log.Info("start time      : %v", startTime)
@@ -548,6 +710,58 @@ func (o *ExecutorSchema) RunDiskToMirror(cmd *cobra.Command, args []string) erro
 	return nil
 }
 
+// RunMirrorToMirror - copies the collected images directly from the
+// source registries to the destination registry, without going through an archive
+func (o *ExecutorSchema) RunMirrorToMirror(cmd *cobra.Command, args []string) error {
+	startTime := time.Now()
+
+	// the local storage is still needed for the images that oc-mirror
+	// builds itself (i.e. the graph image)
+	o.Log.Info("starting local storage on localhost:%v", o.Opts.Global.Port)
+	go startLocalRegistry(&o.LocalStorageService, o.localStorageInterruptChannel)
+
+	// collect
+	allImages, err := o.CollectAll(cmd.Context())
+	if err != nil {
+		return err
+	}
+	if o.Opts.Global.DryRun {
+		return o.DryRun(cmd.Context(), allImages)
+	}
+	collectionFinish := time.Now()
+
+	//call the batch worker
+	o.imageReports, err = o.Batch.Worker(cmd.Context(), allImages, o.Opts)
+	if err != nil {
+		return err
+	}
+	//create IDMS/ITMS
+	err = o.ClusterResources.IDMSGenerator(cmd.Context(), allImages, o.Opts)
+	if err != nil {
+		return err
+	}
+	//create the release signatures configmaps and the UpdateService
+	err = o.ClusterResources.ReleaseSignaturesGenerator(allImages)
+	if err != nil {
+		return err
+	}
+	err = o.ClusterResources.UpdateServiceGenerator(allImages)
+	if err != nil {
+		return err
+	}
+	//create the catalog sources
+	err = o.ClusterResources.CatalogSourceGenerator(allImages)
+	if err != nil {
+		return err
+	}
+
+	mirrorFinish := time.Now()
+	o.Log.Info("start time      : %v", startTime)
+	o.Log.Info("collection time : %v", collectionFinish)
+	o.Log.Info("mirror time     : %v", mirrorFinish)
+	return nil
+}
+
 func (o *ExecutorSchema) setupLogsLevelAndDir() error {
 	// override log level
 	o.Log.Level(o.Opts.Global.LogLevel)
@@ -573,7 +787,7 @@ func (o *ExecutorSchema) CollectAll(ctx context.Context) ([]v1alpha3.CopyImageSc
 	}
 	o.Log.Info("total release images to copy %d ", len(imgs))
 	o.Opts.ImageType = "release"
-	allRelatedImages = mergeImages(allRelatedImages, imgs)
+	allRelatedImages = mergeImages(allRelatedImages, withType(imgs, v1alpha3.TypeRelease))
 
 	// do operators
 	imgs, err = o.Operator.OperatorImageCollector(ctx)
@@ -582,8 +796,9 @@ func (o *ExecutorSchema) CollectAll(ctx context.Context) ([]v1alpha3.CopyImageSc
 		return []v1alpha3.CopyImageSchema{}, err
 	}
 	o.Log.Info("total operator images to copy %d ", len(imgs))
+	o.unsatisfiedDependencies = o.Operator.UnsatisfiedDependencies()
 	o.Opts.ImageType = "operator"
-	allRelatedImages = mergeImages(allRelatedImages, imgs)
+	allRelatedImages = mergeImages(allRelatedImages, withType(imgs, v1alpha3.TypeOperator))
 
 	// do additionalImages
 	imgs, err = o.AdditionalImages.AdditionalImagesCollector(ctx)
@@ -592,11 +807,81 @@ func (o *ExecutorSchema) CollectAll(ctx context.Context) ([]v1alpha3.CopyImageSc
 		return []v1alpha3.CopyImageSchema{}, err
 	}
 	o.Log.Info("total additional images to copy %d ", len(imgs))
-	allRelatedImages = mergeImages(allRelatedImages, imgs)
+	allRelatedImages = mergeImages(allRelatedImages, withType(imgs, v1alpha3.TypeAdditional))
+
+	// do helm charts
+	imgs, err = o.Helm.HelmImageCollector(ctx)
+	if err != nil {
+		cleanUp()
+		return []v1alpha3.CopyImageSchema{}, err
+	}
+	o.Log.Info("total helm images to copy %d ", len(imgs))
+	allRelatedImages = mergeImages(allRelatedImages, withType(imgs, v1alpha3.TypeHelm))
+
+	// remove the blocked images (the rebuilt catalogs already leave out
+	// the bundles that use them)
+	allRelatedImages, err = o.removeBlockedImages(allRelatedImages)
+	if err != nil {
+		cleanUp()
+		return []v1alpha3.CopyImageSchema{}, err
+	}
+
+	// rewrite the repositories in the destination registry
+	allRelatedImages, err = o.rewriteDestinations(allRelatedImages)
+	if err != nil {
+		cleanUp()
+		return []v1alpha3.CopyImageSchema{}, err
+	}
 
 	return allRelatedImages, nil
 }
 
+// removeBlockedImages - removes the images of the block list, they are added to the report.
+// The images of the release payloads can't be blocked
+func (o *ExecutorSchema) removeBlockedImages(allImages []v1alpha3.CopyImageSchema) ([]v1alpha3.CopyImageSchema, error) {
+	filter, err := blocked.New(o.Config.Mirror.BlockedImages)
+	if err != nil {
+		return []v1alpha3.CopyImageSchema{}, err
+	}
+	kept, blockedImages := filter.Filter(allImages)
+	var releaseImages []string
+	o.blockedReports = []report.ImageReport{}
+	for _, b := range blockedImages {
+		o.Log.Warn("%s image %s is blocked by %s", b.Image.Type, b.Reference, b.Pattern)
+		o.blockedReports = append(o.blockedReports, report.NewBlockedImageReport(b.Image, b.Pattern))
+		if b.Image.Type == v1alpha3.TypeRelease {
+			releaseImages = append(releaseImages, b.Reference)
+		}
+	}
+	if len(releaseImages) > 0 {
+		return []v1alpha3.CopyImageSchema{}, fmt.Errorf("the release payload images can't be blocked: %s", strings.Join(releaseImages, ", "))
+	}
+	if len(blockedImages) > 0 {
+		o.Log.Info("total blocked images %d ", len(blockedImages))
+	}
+	return kept, nil
+}
+
+// rewriteDestinations - rewrites the repositories of the images in the destination registry
+// with the destination rewrites of the ImageSetConfiguration and --max-nested-paths.
+// The copies, the IDMS/ITMS and the delete plan all use the rewritten destinations
+func (o *ExecutorSchema) rewriteDestinations(allImages []v1alpha3.CopyImageSchema) ([]v1alpha3.CopyImageSchema, error) {
+	rewriter, err := rewrite.New(o.Config.Mirror.DestinationRewrites, o.Opts.Global.MaxNestedPaths, o.Opts.Destination)
+	if err != nil {
+		return []v1alpha3.CopyImageSchema{}, err
+	}
+	rewritten, err := rewriter.RewriteAll(allImages)
+	if err != nil {
+		return []v1alpha3.CopyImageSchema{}, err
+	}
+	for i := range allImages {
+		if allImages[i].Destination != rewritten[i].Destination {
+			o.Log.Debug("destination of %s rewritten to %s", allImages[i].Destination, rewritten[i].Destination)
+		}
+	}
+	return rewritten, nil
+}
+
 // mergeImages - simple function to append related images
 // nolint
 func mergeImages(base, in []v1alpha3.CopyImageSchema) []v1alpha3.CopyImageSchema {
@@ -604,6 +889,14 @@ func mergeImages(base, in []v1alpha3.CopyImageSchema) []v1alpha3.CopyImageSchema
 	return base
 }
 
+// withType - sets the type of the images found by a collector
+func withType(imgs []v1alpha3.CopyImageSchema, imageType string) []v1alpha3.CopyImageSchema {
+	for i := range imgs {
+		imgs[i].Type = imageType
+	}
+	return imgs
+}
+
 // cleanUp - utility to clean directories
 func cleanUp() {
 	// close registry log file
@@ -673,6 +966,7 @@ func NewPrepareCommand(log clog.PluggableLoggerInterface) *cobra.Command {
 	cmd.Flags().StringVar(&opts.Global.WorkingDir, "dir", "working-dir", "Assets directory")
 	cmd.Flags().StringVar(&opts.Global.From, "from", "", "local storage directory for disk to mirror workflow")
 	cmd.Flags().Uint16VarP(&opts.Global.Port, "port", "p", 5000, "HTTP port used by oc-mirror's local storage instance")
+	cmd.Flags().StringVar(&opts.Global.Platform, "platform", manifest.DefaultPlatform, "Platform (os/arch[/variant]) of the catalog and release images to read the contents of, when they are multi-arch")
 	cmd.Flags().BoolVar(&opts.Global.V2, "v2", opts.Global.V2, "Redirect the flow to oc-mirror v2 - PLEASE DO NOT USE that. V2 is still under development and it is not ready to be used.")
 	// nolint: errcheck
 	cmd.Flags().MarkHidden("v2")
@@ -695,6 +989,9 @@ func (o ExecutorSchema) ValidatePrepare(dest []string) error {
 	if !strings.Contains(o.Opts.Global.From, fileProtocol) {
 		return fmt.Errorf("when --from is used, it must have file:// prefix")
 	}
+	if _, err := manifest.ParsePlatform(o.Opts.Global.Platform); err != nil {
+		return fmt.Errorf("--platform: %v", err)
+	}
 	return nil
 }
 
@@ -713,7 +1010,11 @@ func (o *ExecutorSchema) CompletePrepare(args []string) error {
 
 	// update all dependant modules
 	mc := mirror.NewMirrorCopy()
-	o.Manifest = manifest.New(o.Log)
+	platform, err := manifest.ParsePlatform(o.Opts.Global.Platform)
+	if err != nil {
+		return err
+	}
+	o.Manifest = manifest.New(o.Log, platform)
 	o.Mirror = mirror.New(mc, nil)
 	o.Config = cfg
 
@@ -732,9 +1033,10 @@ func (o *ExecutorSchema) CompletePrepare(args []string) error {
 
 	signature := release.NewSignatureClient(o.Log, o.Config, o.Opts)
 	cn := release.NewCincinnati(o.Log, &o.Config, o.Opts, client, false, signature)
-	o.Release = release.New(o.Log, o.Config, o.Opts, o.Mirror, o.Manifest, cn, o.LocalStorageFQDN, o.ImageBuilder)
-	o.Operator = operator.New(o.Log, o.Config, o.Opts, o.Mirror, o.Manifest, o.LocalStorageFQDN)
+	o.Release = release.New(o.Log, o.Config, o.Opts, o.Mirror, o.Manifest, cn, signature, o.LocalStorageFQDN, o.ImageBuilder)
+	o.Operator = operator.New(o.Log, o.Config, o.Opts, o.Mirror, o.Manifest, o.LocalStorageFQDN, o.ImageBuilder)
 	o.AdditionalImages = additional.New(o.Log, o.Config, o.Opts, o.Mirror, o.Manifest, o.LocalStorageFQDN)
+	o.Helm = helm.New(o.Log, o.Config, o.Opts, o.LocalStorageFQDN)
 	return nil
 }
 
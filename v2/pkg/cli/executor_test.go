@@ -1,12 +1,17 @@
 package cli
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/otiai10/copy"
 
@@ -15,8 +20,12 @@ import (
 	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha2"
 	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
 	"github.com/openshift/oc-mirror/v2/pkg/config"
+	"github.com/openshift/oc-mirror/v2/pkg/estimate"
+	"github.com/openshift/oc-mirror/v2/pkg/history"
 	clog "github.com/openshift/oc-mirror/v2/pkg/log"
 	"github.com/openshift/oc-mirror/v2/pkg/mirror"
+	"github.com/openshift/oc-mirror/v2/pkg/report"
+	"github.com/openshift/oc-mirror/v2/pkg/request"
 	"github.com/spf13/cobra"
 )
 
@@ -33,10 +42,12 @@ func TestExecutor(t *testing.T) {
 	log := clog.New("trace")
 
 	global := &mirror.GlobalOptions{
-		TlsVerify:    false,
-		SecurePolicy: false,
-		Force:        true,
-		WorkingDir:   workDir,
+		TlsVerify:      false,
+		SecurePolicy:   false,
+		Force:          true,
+		WorkingDir:     workDir,
+		ParallelImages: 8,
+		MaxPerRegistry: 6,
 	}
 	_, sharedOpts := mirror.SharedImageFlags()
 	_, deprecatedTLSVerifyOpt := mirror.DeprecatedTLSVerifyFlags()
@@ -86,6 +97,7 @@ func TestExecutor(t *testing.T) {
 			Operator:                     collector,
 			Release:                      collector,
 			AdditionalImages:             collector,
+			Helm:                         collector,
 			Batch:                        batch,
 			MirrorArchiver:               archiver,
 			LocalStorageService:          *reg,
@@ -101,6 +113,26 @@ func TestExecutor(t *testing.T) {
 			log.Error(" %v ", err)
 			t.Fatalf("should not fail")
 		}
+		runReport := readReport(t, workDir)
+		if runReport.Status != report.StatusSuccess || runReport.Workflow != mirror.MirrorToDisk {
+			t.Fatalf("unexpected report status %s for %s", runReport.Status, runReport.Workflow)
+		}
+		// 6 release images, 6 operator images, 6 additional images and 1 helm image
+		expected := []report.CollectorTotals{
+			{Type: v1alpha3.TypeRelease, Images: 6, Succeeded: 6, Bytes: 6144, Duration: 6},
+			{Type: v1alpha3.TypeOperator, Images: 6, Succeeded: 6, Bytes: 6144, Duration: 6},
+			{Type: v1alpha3.TypeAdditional, Images: 6, Succeeded: 6, Bytes: 6144, Duration: 6},
+			{Type: v1alpha3.TypeHelm, Images: 1, Succeeded: 1, Bytes: 1024, Duration: 1},
+		}
+		if !reflect.DeepEqual(runReport.Totals, expected) {
+			t.Fatalf("unexpected totals %v", runReport.Totals)
+		}
+		if len(runReport.Images) != 19 || runReport.Images[0].Digest == "" || runReport.Images[0].Type != v1alpha3.TypeRelease {
+			t.Fatalf("unexpected images in the report %v", runReport.Images)
+		}
+		if !reflect.DeepEqual(runReport.UnsatisfiedDependencies, collector.UnsatisfiedDependencies()) {
+			t.Fatalf("the unsatisfied dependencies should be reported %v", runReport.UnsatisfiedDependencies)
+		}
 	})
 
 	t.Run("Testing Executor : should fail (batch worker)", func(t *testing.T) {
@@ -113,6 +145,7 @@ func TestExecutor(t *testing.T) {
 			Operator:                     collector,
 			Release:                      collector,
 			AdditionalImages:             collector,
+			Helm:                         collector,
 			Batch:                        batch,
 			LocalStorageService:          *reg,
 			localStorageInterruptChannel: fakeStorageInterruptChan,
@@ -126,6 +159,17 @@ func TestExecutor(t *testing.T) {
 		if err == nil {
 			t.Fatalf("should fail")
 		}
+		// the report is written for a failed run
+		runReport := readReport(t, workDir)
+		if runReport.Status != report.StatusFailed || runReport.Error != "forced error" {
+			t.Fatalf("unexpected report status %s: %s", runReport.Status, runReport.Error)
+		}
+		if runReport.Totals[0].Failed != 6 || runReport.Images[0].Status != report.StatusFailed {
+			t.Fatalf("the failed images should be reported: %v", runReport.Totals)
+		}
+		if _, err := os.Stat(filepath.Join(workDir, "mirror-report.yaml")); err != nil {
+			t.Fatalf("the yaml report should be written: %v", err)
+		}
 	})
 
 	t.Run("Testing Executor : should fail (release collector)", func(t *testing.T) {
@@ -139,6 +183,7 @@ func TestExecutor(t *testing.T) {
 			Operator:                     operatorCollector,
 			Release:                      releaseCollector,
 			AdditionalImages:             releaseCollector,
+			Helm:                         releaseCollector,
 			Batch:                        batch,
 			LocalStorageService:          *reg,
 			localStorageInterruptChannel: fakeStorageInterruptChan,
@@ -165,6 +210,7 @@ func TestExecutor(t *testing.T) {
 			Operator:                     operatorCollector,
 			Release:                      releaseCollector,
 			AdditionalImages:             releaseCollector,
+			Helm:                         releaseCollector,
 			Batch:                        batch,
 			LocalStorageService:          *reg,
 			localStorageInterruptChannel: fakeStorageInterruptChan,
@@ -198,6 +244,483 @@ func TestExecutor(t *testing.T) {
 		}
 	})
 
+	t.Run("Testing Executor : validate parallel images should fail", func(t *testing.T) {
+		ex := &ExecutorSchema{
+			Log:  log,
+			Opts: opts,
+		}
+		ex.Opts.Global = &mirror.GlobalOptions{ConfigPath: "hello", ParallelImages: 0, MaxPerRegistry: 6}
+		err := ex.Validate([]string{"file://test"})
+		if err == nil {
+			t.Fatalf("should fail")
+		}
+	})
+
+	t.Run("Testing Executor : validate archive compression should fail", func(t *testing.T) {
+		ex := &ExecutorSchema{
+			Log:  log,
+			Opts: opts,
+		}
+		ex.Opts.Global = &mirror.GlobalOptions{ConfigPath: "hello", ParallelImages: 8, MaxPerRegistry: 6, ArchiveCompression: "bzip2"}
+		err := ex.Validate([]string{"file://test"})
+		if err == nil {
+			t.Fatalf("should fail")
+		}
+	})
+
+	t.Run("Testing Executor : validate mirror set scope should fail", func(t *testing.T) {
+		ex := &ExecutorSchema{
+			Log:  log,
+			Opts: opts,
+		}
+		ex.Opts.Global = &mirror.GlobalOptions{ConfigPath: "hello", ParallelImages: 8, MaxPerRegistry: 6, MirrorSetScope: "image"}
+		err := ex.Validate([]string{"file://test"})
+		if err == nil {
+			t.Fatalf("should fail")
+		}
+	})
+
+	t.Run("Testing Executor : validate since", func(t *testing.T) {
+		ex := &ExecutorSchema{
+			Log:  log,
+			Opts: opts,
+		}
+		ex.Opts.Global = &mirror.GlobalOptions{ConfigPath: "hello", ParallelImages: 8, MaxPerRegistry: 6, Since: "2023-11-22"}
+		err := ex.Validate([]string{"file://test"})
+		if err != nil {
+			log.Error(" %v ", err)
+			t.Fatalf("should not fail")
+		}
+		err = ex.Validate([]string{"docker://test"})
+		if err == nil {
+			t.Fatalf("should fail (mirrorToMirror)")
+		}
+		ex.Opts.Global.SinceSnapshot = "2023-11-21T11:47:46Z"
+		err = ex.Validate([]string{"file://test"})
+		if err == nil {
+			t.Fatalf("should fail (both since flags)")
+		}
+		ex.Opts.Global = &mirror.GlobalOptions{ConfigPath: "hello", ParallelImages: 8, MaxPerRegistry: 6, Since: "22/11/2023"}
+		err = ex.Validate([]string{"file://test"})
+		if err == nil {
+			t.Fatalf("should fail (invalid date)")
+		}
+	})
+
+	t.Run("Testing Executor : mirrorToMirror should pass", func(t *testing.T) {
+		collector := &Collector{Log: log, Config: cfg, Opts: opts, Fail: false}
+		batch := &Batch{Log: log, Config: cfg, Opts: opts}
+		ex := &ExecutorSchema{
+			Log:                          log,
+			Config:                       cfg,
+			Opts:                         opts,
+			Operator:                     collector,
+			Release:                      collector,
+			AdditionalImages:             collector,
+			Helm:                         collector,
+			Batch:                        batch,
+			ClusterResources:             &ClusterResourcesGenerator{},
+			LocalStorageService:          *reg,
+			localStorageInterruptChannel: fakeStorageInterruptChan,
+		}
+		res := &cobra.Command{}
+		res.SetContext(context.Background())
+		res.SilenceUsage = true
+		ex.Opts.Mode = mirror.MirrorToMirror
+		err := ex.Run(res, []string{"docker://test"})
+		if err != nil {
+			log.Error(" %v ", err)
+			t.Fatalf("should not fail")
+		}
+	})
+
+	t.Run("Testing Executor : validate mirrorToMirror should pass", func(t *testing.T) {
+		ex := &ExecutorSchema{
+			Log:  log,
+			Opts: opts,
+		}
+		ex.Opts.Global.ConfigPath = "hello"
+		ex.Opts.Global.From = ""
+		err := ex.Validate([]string{"docker://test"})
+		if err != nil {
+			log.Error(" %v ", err)
+			t.Fatalf("should not fail")
+		}
+	})
+
+	t.Run("Testing Executor : validate (negative max nested paths) should fail", func(t *testing.T) {
+		global := *opts.Global
+		global.ConfigPath = "hello"
+		global.From = ""
+		global.MaxNestedPaths = -1
+		ex := &ExecutorSchema{
+			Log:  log,
+			Opts: opts,
+		}
+		ex.Opts.Global = &global
+		err := ex.Validate([]string{"docker://test"})
+		if err == nil || err.Error() != "--max-nested-paths must be zero or positive" {
+			t.Fatalf("should fail: %v", err)
+		}
+	})
+
+	t.Run("Testing Executor : validate (max nested paths below the namespace) should fail", func(t *testing.T) {
+		global := *opts.Global
+		global.ConfigPath = "hello"
+		global.From = ""
+		global.MaxNestedPaths = 2
+		ex := &ExecutorSchema{
+			Log:  log,
+			Opts: opts,
+		}
+		ex.Opts.Global = &global
+		err := ex.Validate([]string{"docker://test/ns1/ns2"})
+		if err == nil || !strings.Contains(err.Error(), "--max-nested-paths: the namespace ns1/ns2") {
+			t.Fatalf("should fail: %v", err)
+		}
+	})
+
+	t.Run("Testing Executor : delete should pass", func(t *testing.T) {
+		collector := &Collector{Log: log, Config: cfg, Opts: opts, Fail: false}
+		deleteImages := &DeleteImages{}
+		ex := &ExecutorSchema{
+			Log:                          log,
+			Config:                       cfg,
+			Opts:                         opts,
+			Operator:                     collector,
+			Release:                      collector,
+			AdditionalImages:             collector,
+			Helm:                         collector,
+			Delete:                       deleteImages,
+			LocalStorageService:          *reg,
+			localStorageInterruptChannel: fakeStorageInterruptChan,
+		}
+		res := &cobra.Command{}
+		res.SetContext(context.Background())
+		res.SilenceUsage = true
+		ex.Opts.Mode = mirror.MirrorToMirror
+		err := ex.RunDelete(res, []string{"docker://test"})
+		if err != nil {
+			log.Error(" %v ", err)
+			t.Fatalf("should not fail")
+		}
+		if len(deleteImages.planned) != 19 || deleteImages.deleted != 0 {
+			t.Fatalf("should only write the delete plan")
+		}
+
+		// with --force-delete the images of the delete plan are deleted
+		ex.Opts.Global = &mirror.GlobalOptions{ForceDelete: true}
+		err = ex.RunDelete(res, []string{"docker://test"})
+		if err != nil {
+			log.Error(" %v ", err)
+			t.Fatalf("should not fail")
+		}
+		if deleteImages.deleted != 19 {
+			t.Fatalf("should delete the images of the delete plan")
+		}
+	})
+
+	t.Run("Testing Executor : archive verify should fail", func(t *testing.T) {
+		err := VerifyArchive(log, "file://"+filepath.Join(workDir, "does-not-exist"))
+		if err == nil {
+			t.Fatalf("should fail")
+		}
+		// no archive in the directory
+		err = VerifyArchive(log, "file://"+t.TempDir())
+		if err == nil {
+			t.Fatalf("should fail")
+		}
+	})
+
+	t.Run("Testing Executor : history should pass", func(t *testing.T) {
+		historyDir := t.TempDir()
+		historyWorkDir := historyWorkingDir("file://" + historyDir)
+		if err := os.MkdirAll(filepath.Join(historyWorkDir, ".history"), 0755); err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		for name, blobs := range map[string]string{
+			".history-2023-11-21T11:47:46Z": "sha256:a\n",
+			".history-2023-11-22T11:24:14Z": "sha256:a\nsha256:b\nsha256:c\n",
+			// sha256:a was pruned from the latest snapshot
+			".history-2023-11-23T08:00:00Z": "sha256:b\nsha256:c\nsha256:d\n",
+		} {
+			if err := os.WriteFile(filepath.Join(historyWorkDir, ".history", name), []byte(blobs), 0644); err != nil {
+				t.Fatalf("should not fail: %v", err)
+			}
+		}
+
+		var out bytes.Buffer
+		if err := ListHistory(&out, historyWorkDir); err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		if !strings.Contains(out.String(), "2023-11-22T11:24:14Z  2023-11-22T11:24:14Z  3      2") ||
+			!strings.Contains(out.String(), "2023-11-23T08:00:00Z  2023-11-23T08:00:00Z  3      1") {
+			t.Fatalf("unexpected list:\n%s", out.String())
+		}
+
+		out.Reset()
+		if err := InspectHistory(&out, historyWorkDir, "2023-11-22T11:24:14Z", true); err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		if !strings.Contains(out.String(), "added:    2\n  sha256:b\n  sha256:c\n") {
+			t.Fatalf("unexpected inspect:\n%s", out.String())
+		}
+
+		if err := DeleteHistory(log, historyWorkDir, nil, "", 0); err == nil {
+			t.Fatalf("should fail (nothing to delete)")
+		}
+		if err := DeleteHistory(log, historyWorkDir, nil, "", -1); err == nil || err.Error() != "--keep must be zero or positive" {
+			t.Fatalf("should fail (negative --keep): %v", err)
+		}
+		if err := DeleteHistory(log, historyWorkDir, []string{"2023-11-20T00:00:00Z"}, "", 0); err == nil {
+			t.Fatalf("should fail (unknown snapshot)")
+		}
+		if err := DeleteHistory(log, historyWorkDir, nil, "", 2); err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		if err := DeleteHistory(log, historyWorkDir, nil, "2023-11-23", 0); err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		snapshots, err := history.ListSnapshots(historyWorkDir)
+		if err != nil || len(snapshots) != 1 || snapshots[0].Name != ".history-2023-11-23T08:00:00Z" {
+			t.Fatalf("unexpected snapshots %v: %v", snapshots, err)
+		}
+	})
+
+	t.Run("Testing Executor : validate delete", func(t *testing.T) {
+		ex := &ExecutorSchema{
+			Log:  log,
+			Opts: opts,
+		}
+		ex.Opts.Global = &mirror.GlobalOptions{ConfigPath: "delete-isc.yaml"}
+		err := ex.ValidateDelete([]string{"docker://test"})
+		if err != nil {
+			log.Error(" %v ", err)
+			t.Fatalf("should not fail")
+		}
+		err = ex.ValidateDelete([]string{"file://test"})
+		if err == nil {
+			t.Fatalf("should fail")
+		}
+		ex.Opts.Global.MaxNestedPaths = -1
+		err = ex.ValidateDelete([]string{"docker://test"})
+		if err == nil || err.Error() != "--max-nested-paths must be zero or positive" {
+			t.Fatalf("a negative --max-nested-paths should fail: %v", err)
+		}
+	})
+
+	t.Run("Testing Executor (blocked images) : should pass", func(t *testing.T) {
+		blockedCfg := cfg
+		blockedCfg.Mirror.BlockedImages = []v1alpha2.Image{{Name: "registry/name/namespace/sometestimage-g"}}
+		collector := &Collector{Log: log, Config: blockedCfg, Opts: opts, Fail: false}
+		batch := &Batch{Log: log, Config: blockedCfg, Opts: opts}
+		interruptChan := make(chan error)
+		go skipSignalsToInterruptStorage(interruptChan)
+		ex := &ExecutorSchema{
+			Log:                          log,
+			Config:                       blockedCfg,
+			Opts:                         opts,
+			Operator:                     collector,
+			Release:                      collector,
+			AdditionalImages:             collector,
+			Helm:                         collector,
+			Batch:                        batch,
+			MirrorArchiver:               MockArchiver{opts.Destination},
+			LocalStorageService:          *reg,
+			localStorageInterruptChannel: interruptChan,
+		}
+		res := &cobra.Command{}
+		res.SetContext(context.Background())
+		res.SilenceUsage = true
+		ex.Opts.Mode = mirror.MirrorToDisk
+		err := ex.Run(res, []string{"file://" + testFolder})
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		runReport := readReport(t, workDir)
+		helm := runReport.Totals[3]
+		if runReport.Status != report.StatusSuccess || helm.Type != v1alpha3.TypeHelm || helm.Images != 1 || helm.Blocked != 1 || helm.Succeeded != 0 {
+			t.Fatalf("unexpected report %s %v", runReport.Status, runReport.Totals)
+		}
+		if last := runReport.Images[len(runReport.Images)-1]; last.Status != report.StatusBlocked || last.BlockedBy != "registry/name/namespace/sometestimage-g" {
+			t.Fatalf("unexpected blocked image report %v", last)
+		}
+	})
+
+	t.Run("Testing Executor (blocked release images) : should fail", func(t *testing.T) {
+		blockedCfg := cfg
+		blockedCfg.Mirror.BlockedImages = []v1alpha2.Image{{Name: "registry/name/namespace/sometestimage-a*"}}
+		ex := &ExecutorSchema{Log: log, Config: blockedCfg, Opts: opts}
+		collector := &Collector{Log: log, Config: blockedCfg, Opts: opts}
+		imgs, _ := collector.ReleaseImageCollector(context.Background())
+		_, err := ex.removeBlockedImages(withType(imgs, v1alpha3.TypeRelease))
+		if err == nil || !strings.Contains(err.Error(), "sometestimage-a") {
+			t.Fatalf("should fail, got %v", err)
+		}
+	})
+
+	t.Run("Testing Executor (dry-run) : should pass", func(t *testing.T) {
+		collector := &Collector{Log: log, Config: cfg, Opts: opts, Fail: false}
+		// the batch worker and the archive must not be called
+		batch := &Batch{Log: log, Config: cfg, Opts: opts, Fail: true}
+		dryRunOpts := opts
+		dryRunGlobal := *opts.Global
+		dryRunGlobal.DryRun = true
+		dryRunOpts.Global = &dryRunGlobal
+		ex := &ExecutorSchema{
+			Log:                          log,
+			Config:                       cfg,
+			Opts:                         dryRunOpts,
+			Operator:                     collector,
+			Release:                      collector,
+			AdditionalImages:             collector,
+			Helm:                         collector,
+			Batch:                        batch,
+			Mirror:                       Mirror{},
+			Estimator:                    MockEstimator{},
+			LocalStorageService:          *reg,
+			localStorageInterruptChannel: fakeStorageInterruptChan,
+		}
+		res := &cobra.Command{}
+		res.SetContext(context.Background())
+		res.SilenceUsage = true
+		ex.Opts.Mode = mirror.MirrorToDisk
+		err := ex.Run(res, []string{"file://" + testFolder})
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(workDir, dryRunDir, "size-estimate.json")); err != nil {
+			t.Fatalf("the size estimate should be written: %v", err)
+		}
+		for _, file := range []string{mappingFile, missingFile} {
+			data, err := os.ReadFile(filepath.Join(workDir, dryRunDir, file))
+			if err != nil {
+				t.Fatalf("%s should be written: %v", file, err)
+			}
+			lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+			if len(lines) != 19 || lines[0] != "registry/name/namespace/sometestimage-a@sha256:f30638f60452062aba36a26ee6c036feead2f03b28f2c47f2b0a991e41baebea=oci:test" {
+				t.Fatalf("unexpected %s:\n%s", file, string(data))
+			}
+		}
+	})
+
+	t.Run("Testing Executor (write request) : should pass", func(t *testing.T) {
+		requestOpts := opts
+		requestGlobal := *opts.Global
+		requestGlobal.WriteRequest = filepath.Join(testFolder, "delta-request.json")
+		requestOpts.Global = &requestGlobal
+		requestOpts.Destination = "docker://enclave:5000/mirror"
+		ex := &ExecutorSchema{
+			Log:     log,
+			Opts:    requestOpts,
+			Request: request.New(log, Mirror{}, nil, &requestOpts, 2),
+		}
+		allImages := []v1alpha3.CopyImageSchema{
+			{Source: "docker://localhost:55000/ubi8/ubi:latest", Destination: "docker://enclave:5000/mirror/ubi8/ubi:latest"},
+		}
+		err := ex.WriteRequest(context.Background(), allImages)
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		req, err := request.Read(requestGlobal.WriteRequest)
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		// the mock registry holds no image
+		if req.Destination != "enclave:5000/mirror" || len(req.Images) != 0 || len(req.Blobs) != 0 || !reflect.DeepEqual(req.Missing, []string{"enclave:5000/mirror/ubi8/ubi:latest"}) {
+			t.Fatalf("unexpected request %+v", req)
+		}
+	})
+
+	t.Run("Testing Executor : validate request", func(t *testing.T) {
+		ex := &ExecutorSchema{
+			Log:  log,
+			Opts: opts,
+		}
+		ex.Opts.Global = &mirror.GlobalOptions{ConfigPath: "hello", ParallelImages: 8, MaxPerRegistry: 6, FromRequest: "delta-request.json"}
+		if err := ex.Validate([]string{"file://test"}); err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		if err := ex.Validate([]string{"docker://test"}); err == nil {
+			t.Fatalf("should fail (--from-request with mirrorToMirror)")
+		}
+		ex.Opts.Global.Since = "2023-11-22"
+		if err := ex.Validate([]string{"file://test"}); err == nil {
+			t.Fatalf("should fail (--from-request with --since)")
+		}
+		ex.Opts.Global = &mirror.GlobalOptions{ConfigPath: "hello", ParallelImages: 8, MaxPerRegistry: 6, WriteRequest: "delta-request.json", From: "file://test"}
+		if err := ex.Validate([]string{"docker://test"}); err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		ex.Opts.Global.From = ""
+		if err := ex.Validate([]string{"docker://test"}); err == nil {
+			t.Fatalf("should fail (--write-request with mirrorToMirror)")
+		}
+	})
+
+	t.Run("Testing Executor : embedded imageset configuration", func(t *testing.T) {
+		rootDir := t.TempDir()
+		isc, err := os.ReadFile("../../tests/isc.yaml")
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		ex := &ExecutorSchema{
+			Log:  log,
+			Opts: opts,
+		}
+		ex.Opts.Global = &mirror.GlobalOptions{ParallelImages: 8, MaxPerRegistry: 6, From: "file://" + rootDir}
+		// --config is optional with diskToMirror only
+		if err := ex.Validate([]string{"docker://test"}); err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		if err := ex.Validate([]string{"file://test"}); err == nil {
+			t.Fatalf("should fail without --config")
+		}
+		// no archive and no --config
+		if err := ex.resolveConfig(rootDir); err == nil {
+			t.Fatalf("should fail without any imageset configuration")
+		}
+
+		// the configuration extracted from the previous archive
+		embedded := filepath.Join(rootDir, workingDir, "archive-imageset-config.yaml")
+		if err := os.MkdirAll(filepath.Dir(embedded), 0755); err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		if err := os.WriteFile(embedded, isc, 0644); err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		if err := ex.resolveConfig(rootDir); err != nil || ex.Opts.Global.ConfigPath != embedded {
+			t.Fatalf("should default to the embedded configuration, got %s: %v", ex.Opts.Global.ConfigPath, err)
+		}
+
+		// the same configuration, formatted differently
+		same := filepath.Join(t.TempDir(), "same-isc.yaml")
+		if err := os.WriteFile(same, []byte(strings.ReplaceAll(string(isc), "# This config", "# The config")), 0644); err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		diff, err := configDiff(embedded, same)
+		if err != nil || diff != "" {
+			t.Fatalf("should not find any difference, got %s: %v", diff, err)
+		}
+
+		different := filepath.Join(t.TempDir(), "different-isc.yaml")
+		if err := os.WriteFile(different, []byte(strings.ReplaceAll(string(isc), "maxVersion: 4.12.0", "maxVersion: 4.12.1")), 0644); err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		diff, err = configDiff(embedded, different)
+		if err != nil || !strings.Contains(diff, "maxVersion: 4.12.0") || !strings.Contains(diff, "maxVersion: 4.12.1") {
+			t.Fatalf("unexpected diff %s: %v", diff, err)
+		}
+		ex.Opts.Global.ConfigPath = different
+		if err := ex.resolveConfig(rootDir); err != nil || ex.Opts.Global.ConfigPath != different {
+			t.Fatalf("should keep --config, got %s: %v", ex.Opts.Global.ConfigPath, err)
+		}
+		ex.Opts.Global.StrictConfig = true
+		if err := ex.resolveConfig(rootDir); err == nil {
+			t.Fatalf("should fail with --strict-config")
+		}
+	})
+
 	t.Run("Testing Executor : should fail", func(t *testing.T) {
 		ex := &ExecutorSchema{
 			Log:                          log,
@@ -215,6 +738,19 @@ func TestExecutor(t *testing.T) {
 	})
 }
 
+// readReport - reads the json report of the run written in the working-dir
+func readReport(t *testing.T, workDir string) report.RunReport {
+	var runReport report.RunReport
+	data, err := os.ReadFile(filepath.Join(workDir, "mirror-report.json"))
+	if err != nil {
+		t.Fatalf("the report should be written: %v", err)
+	}
+	if err := json.Unmarshal(data, &runReport); err != nil {
+		t.Fatal(err)
+	}
+	return runReport
+}
+
 // setup mocks
 
 type Mirror struct{}
@@ -247,6 +783,28 @@ type MockArchiver struct {
 	destination string
 }
 
+type ClusterResourcesGenerator struct{}
+
+type MockEstimator struct{}
+
+type DeleteImages struct {
+	planned []v1alpha3.CopyImageSchema
+	deleted int
+}
+
+func (o Mirror) Run(ctx context.Context, src, dest string, mode mirror.Mode, opts *mirror.CopyOptions, stdout bufio.Writer) error {
+	return nil
+}
+
+// Check - none of the images are in the cache
+func (o Mirror) Check(ctx context.Context, image string, opts *mirror.CopyOptions) (bool, error) {
+	return false, nil
+}
+
+func (o MockEstimator) Estimate(ctx context.Context, images []v1alpha3.CopyImageSchema) (estimate.Estimate, error) {
+	return estimate.Estimate{Images: len(images), Blobs: 1, Bytes: 1024, NewBlobs: 1, NewBytes: 1024, ArchiveBytes: 2560}, nil
+}
+
 func (o *Diff) DeleteImages(ctx context.Context) error {
 	return nil
 }
@@ -255,11 +813,16 @@ func (o *Diff) CheckDiff(prevCfg v1alpha2.ImageSetConfiguration) (bool, error) {
 	return false, nil
 }
 
-func (o *Batch) Worker(ctx context.Context, images []v1alpha3.CopyImageSchema, opts mirror.CopyOptions) error {
+func (o *Batch) Worker(ctx context.Context, images []v1alpha3.CopyImageSchema, opts mirror.CopyOptions) ([]report.ImageReport, error) {
+	var err error
 	if o.Fail {
-		return fmt.Errorf("forced error")
+		err = fmt.Errorf("forced error")
 	}
-	return nil
+	reports := []report.ImageReport{}
+	for _, img := range images {
+		reports = append(reports, report.NewImageReport(img, "sha256:f30638f60452062aba36a26ee6c036feead2f03b28f2c47f2b0a991e41baebea", 1024, time.Second, err))
+	}
+	return reports, err
 }
 
 func (o *Collector) OperatorImageCollector(ctx context.Context) ([]v1alpha3.CopyImageSchema, error) {
@@ -277,6 +840,10 @@ func (o *Collector) OperatorImageCollector(ctx context.Context) ([]v1alpha3.Copy
 	return test, nil
 }
 
+func (o *Collector) UnsatisfiedDependencies() []string {
+	return []string{"bundle foo.v1.0.0 requires package bar >=1.0.0, which is not provided by any bundle of the catalog (catalog redhat-operator-index:v4.14)"}
+}
+
 func (o *Collector) ReleaseImageCollector(ctx context.Context) ([]v1alpha3.CopyImageSchema, error) {
 	if o.Fail {
 		return []v1alpha3.CopyImageSchema{}, fmt.Errorf("forced error release collector")
@@ -307,6 +874,16 @@ func (o *Collector) AdditionalImagesCollector(ctx context.Context) ([]v1alpha3.C
 	return test, nil
 }
 
+func (o *Collector) HelmImageCollector(ctx context.Context) ([]v1alpha3.CopyImageSchema, error) {
+	if o.Fail {
+		return []v1alpha3.CopyImageSchema{}, fmt.Errorf("forced error helm collector")
+	}
+	test := []v1alpha3.CopyImageSchema{
+		{Source: "docker://registry/name/namespace/sometestimage-g@sha256:f30638f60452062aba36a26ee6c036feead2f03b28f2c47f2b0a991e41baebea", Destination: "oci:test"},
+	}
+	return test, nil
+}
+
 func (o MockArchiver) BuildArchive(ctx context.Context, collectedImages []v1alpha3.CopyImageSchema) (string, error) {
 	return filepath.Join(o.destination, "mirror_000001.tar"), nil
 }
@@ -315,6 +892,40 @@ func (o MockArchiver) Close() error {
 	return nil
 }
 
+func (o *ClusterResourcesGenerator) IDMSGenerator(ctx context.Context, allRelatedImages []v1alpha3.CopyImageSchema, opts mirror.CopyOptions) error {
+	return nil
+}
+
+func (o *ClusterResourcesGenerator) ReleaseSignaturesGenerator(allRelatedImages []v1alpha3.CopyImageSchema) error {
+	return nil
+}
+
+func (o *ClusterResourcesGenerator) UpdateServiceGenerator(allRelatedImages []v1alpha3.CopyImageSchema) error {
+	return nil
+}
+
+func (o *ClusterResourcesGenerator) CatalogSourceGenerator(allRelatedImages []v1alpha3.CopyImageSchema) error {
+	return nil
+}
+
+func (o *DeleteImages) WriteDeletePlan(images []v1alpha3.CopyImageSchema) (string, error) {
+	o.planned = images
+	return "delete-images.yaml", nil
+}
+
+func (o *DeleteImages) ReadDeletePlan() (v1alpha3.DeleteImageList, error) {
+	plan := v1alpha3.DeleteImageList{}
+	for _, img := range o.planned {
+		plan.Items = append(plan.Items, v1alpha3.DeleteItem{ImageName: img.Origin, ImageReference: img.Destination})
+	}
+	return plan, nil
+}
+
+func (o *DeleteImages) DeleteRegistryImages(ctx context.Context, plan v1alpha3.DeleteImageList) error {
+	o.deleted = len(plan.Items)
+	return nil
+}
+
 func skipSignalsToInterruptStorage(errchan chan error) {
 	err := <-errchan
 	if err != nil {
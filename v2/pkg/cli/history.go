@@ -0,0 +1,263 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/openshift/oc-mirror/v2/pkg/history"
+	clog "github.com/openshift/oc-mirror/v2/pkg/log"
+)
+
+var (
+	historyLongDesc = templates.LongDesc(
+		`
+		Manage the history of the mirrorToDisk workflow.
+
+		Each time an archive is built, a snapshot of the blobs of all the archives built until then
+		is written in the working-dir of the destination directory, and the next archive only holds
+		the blobs that are not in the latest snapshot. An archive can be rebuilt against an older
+		snapshot with the --since or --since-snapshot flags of the mirrorToDisk workflow.
+		`,
+	)
+	historyExamples = templates.Examples(
+		`
+		# List the snapshots of the history
+		oc-mirror history list file:///home/user/mirror --v2
+
+		# Show the blobs added by the archive built with the snapshot
+		oc-mirror history inspect file:///home/user/mirror 2024-05-01T10:00:00Z --blobs --v2
+
+		# Delete all the snapshots except the 3 most recent ones
+		oc-mirror history delete file:///home/user/mirror --keep 3 --v2
+
+		# Rebuild the archive built after the snapshot, which was lost
+		oc-mirror --config isc.yaml file:///home/user/mirror --since-snapshot 2024-05-01T10:00:00Z --v2
+		`,
+	)
+)
+
+// NewHistoryCommand - cobra entry point for the history subcommands
+func NewHistoryCommand(log clog.PluggableLoggerInterface) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "history",
+		Short:   "Manages the history of the archives generated by the mirrorToDisk workflow",
+		Long:    historyLongDesc,
+		Example: historyExamples,
+	}
+	cmd.AddCommand(NewHistoryListCommand(log))
+	cmd.AddCommand(NewHistoryInspectCommand(log))
+	cmd.AddCommand(NewHistoryDeleteCommand(log))
+	return cmd
+}
+
+// NewHistoryListCommand - cobra entry point for history list
+func NewHistoryListCommand(log clog.PluggableLoggerInterface) *cobra.Command {
+	var v2 bool
+	cmd := &cobra.Command{
+		Use:   "list <directory>",
+		Short: "Lists the snapshots of the history, the oldest first",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := ListHistory(cmd.OutOrStdout(), historyWorkingDir(args[0]))
+			if err != nil {
+				log.Error("%v ", err)
+				os.Exit(1)
+			}
+		},
+	}
+	cmd.Flags().BoolVar(&v2, "v2", v2, "Redirect the flow to oc-mirror v2 - PLEASE DO NOT USE that. V2 is still under development and it is not ready to be used.")
+	// nolint: errcheck
+	cmd.Flags().MarkHidden("v2")
+	return cmd
+}
+
+// NewHistoryInspectCommand - cobra entry point for history inspect
+func NewHistoryInspectCommand(log clog.PluggableLoggerInterface) *cobra.Command {
+	var v2, blobs bool
+	cmd := &cobra.Command{
+		Use:   "inspect <directory> <snapshot>",
+		Short: "Shows a snapshot of the history, and the blobs added since the previous one",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := InspectHistory(cmd.OutOrStdout(), historyWorkingDir(args[0]), args[1], blobs)
+			if err != nil {
+				log.Error("%v ", err)
+				os.Exit(1)
+			}
+		},
+	}
+	cmd.Flags().BoolVar(&blobs, "blobs", false, "List the blobs added since the previous snapshot")
+	cmd.Flags().BoolVar(&v2, "v2", v2, "Redirect the flow to oc-mirror v2 - PLEASE DO NOT USE that. V2 is still under development and it is not ready to be used.")
+	// nolint: errcheck
+	cmd.Flags().MarkHidden("v2")
+	return cmd
+}
+
+// NewHistoryDeleteCommand - cobra entry point for history delete
+func NewHistoryDeleteCommand(log clog.PluggableLoggerInterface) *cobra.Command {
+	var v2 bool
+	var before string
+	var keep int
+	cmd := &cobra.Command{
+		Use:   "delete <directory> [snapshot...]",
+		Short: "Deletes snapshots of the history, by name, older than --before or all but the --keep most recent ones",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := DeleteHistory(log, historyWorkingDir(args[0]), args[1:], before, keep)
+			if err != nil {
+				log.Error("%v ", err)
+				os.Exit(1)
+			}
+		},
+	}
+	cmd.Flags().StringVar(&before, "before", "", "Delete the snapshots taken before this date (RFC3339 or YYYY-MM-DD)")
+	cmd.Flags().IntVar(&keep, "keep", 0, "Delete all the snapshots except the most recent ones")
+	cmd.Flags().BoolVar(&v2, "v2", v2, "Redirect the flow to oc-mirror v2 - PLEASE DO NOT USE that. V2 is still under development and it is not ready to be used.")
+	// nolint: errcheck
+	cmd.Flags().MarkHidden("v2")
+	return cmd
+}
+
+// ListHistory - writes the snapshots of the history of workingDir, with the number
+// of blobs added by each of them (the blobs not in the previous snapshot)
+func ListHistory(out io.Writer, workingDir string) error {
+	snapshots, err := history.ListSnapshots(workingDir)
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 {
+		fmt.Fprintf(out, "no history snapshot found in %s\n", workingDir)
+		return nil
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SNAPSHOT\tDATE\tBLOBS\tADDED")
+	previous := map[string]string{}
+	for _, s := range snapshots {
+		blobs, err := history.ReadSnapshot(workingDir, s.Name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\n", s.ShortName(), s.Time.Format(time.RFC3339), s.Blobs, len(addedBlobs(previous, blobs)))
+		previous = blobs
+	}
+	return w.Flush()
+}
+
+// InspectHistory - writes the snapshot and the blobs it added to the previous one
+// (listed when listBlobs is set)
+func InspectHistory(out io.Writer, workingDir, name string, listBlobs bool) error {
+	blobs, err := history.ReadSnapshot(workingDir, name)
+	if err != nil {
+		return err
+	}
+	snapshots, err := history.ListSnapshots(workingDir)
+	if err != nil {
+		return err
+	}
+	previous := map[string]string{}
+	var current history.Snapshot
+	for i, s := range snapshots {
+		if s.Name != history.SnapshotName(name) {
+			continue
+		}
+		current = s
+		if i > 0 {
+			previous, err = history.ReadSnapshot(workingDir, snapshots[i-1].Name)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	added := addedBlobs(previous, blobs)
+	fmt.Fprintf(out, "snapshot: %s\n", current.Name)
+	fmt.Fprintf(out, "date:     %s\n", current.Time.Format(time.RFC3339))
+	fmt.Fprintf(out, "blobs:    %d\n", len(blobs))
+	fmt.Fprintf(out, "added:    %d\n", len(added))
+	if listBlobs {
+		for _, blob := range added {
+			fmt.Fprintf(out, "  %s\n", blob)
+		}
+	}
+	return nil
+}
+
+// DeleteHistory - deletes the snapshots named, taken before the date, or all but the keep most recent ones
+func DeleteHistory(log clog.PluggableLoggerInterface, workingDir string, names []string, before string, keep int) error {
+	if keep < 0 {
+		return fmt.Errorf("--keep must be zero or positive")
+	}
+	if len(names) == 0 && before == "" && keep == 0 {
+		return fmt.Errorf("use either snapshot names, --before or --keep to choose the snapshots to delete")
+	}
+	snapshots, err := history.ListSnapshots(workingDir)
+	if err != nil {
+		return err
+	}
+	toDelete := map[string]bool{}
+	for _, name := range names {
+		// the snapshots named must exist
+		if _, err := history.ReadSnapshot(workingDir, name); err != nil {
+			return err
+		}
+		toDelete[history.SnapshotName(name)] = true
+	}
+	if before != "" {
+		date, err := history.ParseDate(before)
+		if err != nil {
+			return fmt.Errorf("--before: %v", err)
+		}
+		for _, s := range snapshots {
+			if s.Time.Before(date) {
+				toDelete[s.Name] = true
+			}
+		}
+	}
+	if keep > 0 {
+		for i := 0; i < len(snapshots)-keep; i++ {
+			toDelete[snapshots[i].Name] = true
+		}
+	}
+
+	deleted := 0
+	for i, s := range snapshots {
+		if !toDelete[s.Name] {
+			continue
+		}
+		if i == len(snapshots)-1 {
+			log.Warn("%s is the latest snapshot: the next archive will be built against the previous one", s.Name)
+		}
+		if err := history.DeleteSnapshot(workingDir, s.Name); err != nil {
+			return err
+		}
+		log.Info("deleted history snapshot %s", s.Name)
+		deleted++
+	}
+	log.Info("%d history snapshots deleted, %d left", deleted, len(snapshots)-deleted)
+	return nil
+}
+
+// historyWorkingDir - the working-dir of the mirrorToDisk destination directory
+func historyWorkingDir(dir string) string {
+	return filepath.Join(strings.TrimPrefix(dir, fileProtocol), workingDir)
+}
+
+// addedBlobs - the blobs of the snapshot that are not in the previous one, sorted
+func addedBlobs(previous, blobs map[string]string) []string {
+	added := []string{}
+	for blob := range blobs {
+		if _, ok := previous[blob]; !ok {
+			added = append(added, blob)
+		}
+	}
+	sort.Strings(added)
+	return added
+}
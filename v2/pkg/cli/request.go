@@ -0,0 +1,32 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
+)
+
+// WriteRequest - writes the delta request of the diskToMirror side instead of mirroring:
+// the collected images already in the destination registry, with their blobs.
+// The mirrorToDisk side builds an archive with the missing blobs and manifests only (--from-request)
+func (o *ExecutorSchema) WriteRequest(ctx context.Context, allImages []v1alpha3.CopyImageSchema) error {
+	if o.Request == nil {
+		return fmt.Errorf("no delta request builder")
+	}
+	req, err := o.Request.Build(ctx, o.Opts.Destination, allImages)
+	if err != nil {
+		return err
+	}
+	if err := req.Write(o.Opts.Global.WriteRequest); err != nil {
+		return err
+	}
+	if len(req.Missing) > 0 {
+		o.Log.Info("delta request: %d/%d images missing from %s", len(req.Missing), len(allImages), req.Destination)
+	} else {
+		o.Log.Info("delta request: all %d images are already in %s", len(allImages), req.Destination)
+	}
+	o.Log.Info("delta request: %d blobs in the destination registry, written to %s", len(req.Blobs), o.Opts.Global.WriteRequest)
+	o.Log.Info("build the next archive with: oc-mirror --config <isc> file://<directory> --from-request %s --v2", o.Opts.Global.WriteRequest)
+	return nil
+}
@@ -0,0 +1,216 @@
+package clusterresources
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	catalogSourceKind      string = "CatalogSource"
+	catalogSourceVersion   string = "operators.coreos.com/v1alpha1"
+	catalogSourceNamespace string = "openshift-marketplace"
+	catalogSourceFileFmt   string = "catalogSource-%s.yaml"
+)
+
+// CatalogSourceGenerator - writes a CatalogSource for each catalog of the configuration,
+// pointing at the mirrored catalog. The CatalogSource is based on the template
+// of the catalog (TargetCatalogSourceTemplate) when set
+func (c *ClusterResourcesGenerator) CatalogSourceGenerator(allRelatedImages []v1alpha3.CopyImageSchema) error {
+	// Keep track of the names and to make sure no
+	// manifest are overwritten.
+	// If found, increment the name suffix by one.
+	names := make(map[string]int, len(c.Config.Mirror.Operators))
+	for _, op := range c.Config.Mirror.Operators {
+		destination := ""
+		for _, img := range allRelatedImages {
+			if img.Origin == op.Catalog {
+				destination = stripTransport(img.Destination)
+				break
+			}
+		}
+		if destination == "" {
+			c.Log.Warn("catalog %s not mirrored, skipping its CatalogSource", op.Catalog)
+			continue
+		}
+
+		repo := repository(destination)
+		name, err := createRFC1035NameForCatalogSource(repo[strings.LastIndex(repo, "/")+1:])
+		// in theory this should never error
+		if err != nil {
+			return err
+		}
+		value, found := names[name]
+		if found {
+			value++
+			names[name] = value
+			name = fmt.Sprintf("%s-%d", name, value)
+		} else {
+			names[name] = 0
+		}
+
+		catalogSource, err := generateCatalogSource(name, destination, op.TargetCatalogSourceTemplate)
+		if err != nil {
+			return fmt.Errorf("unable to generate the CatalogSource of %s: %v", op.Catalog, err)
+		}
+		if err := c.writeResource(fmt.Sprintf(catalogSourceFileFmt, name), catalogSource); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generateCatalogSource - the CatalogSource of the catalog image, based on the template when set.
+// The name, the source type and the image of the template are replaced,
+// the namespace is kept when the template sets it
+func generateCatalogSource(name, image, template string) (map[string]interface{}, error) {
+	obj := map[string]interface{}{}
+	if template != "" {
+		data, err := os.ReadFile(template)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the template %s: %v", template, err)
+		}
+		if err := yaml.Unmarshal(data, &obj); err != nil {
+			return nil, fmt.Errorf("unable to unmarshal the template %s: %v", template, err)
+		}
+		if obj == nil {
+			obj = map[string]interface{}{}
+		}
+		if kind, ok := obj["kind"]; ok && kind != catalogSourceKind {
+			return nil, fmt.Errorf("the template %s is a %v, not a %s", template, kind, catalogSourceKind)
+		}
+	}
+
+	metadata, err := field(obj, "metadata")
+	if err != nil {
+		return nil, fmt.Errorf("invalid template %s: %v", template, err)
+	}
+	spec, err := field(obj, "spec")
+	if err != nil {
+		return nil, fmt.Errorf("invalid template %s: %v", template, err)
+	}
+
+	obj["apiVersion"] = catalogSourceVersion
+	obj["kind"] = catalogSourceKind
+	delete(obj, "status")
+	metadata["name"] = name
+	if namespace, ok := metadata["namespace"].(string); !ok || namespace == "" {
+		metadata["namespace"] = catalogSourceNamespace
+	}
+	spec["sourceType"] = "grpc"
+	spec["image"] = image
+	return obj, nil
+}
+
+// field - the object in the field name of obj, created when missing
+func field(obj map[string]interface{}, name string) (map[string]interface{}, error) {
+	value, ok := obj[name]
+	if !ok || value == nil {
+		f := map[string]interface{}{}
+		obj[name] = f
+		return f, nil
+	}
+	f, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s is not an object", name)
+	}
+	return f, nil
+}
+
+/*
+CreateRFC1035Name converts the provided name to an RFC 1035 compliant name suitable
+for use in a catalog source. Unacceptable characters are converted to a dash.
+Duplicate consecutive dashes are converted to a single dash.
+
+RFC 1035 compliant strings:
+
+- must consist of lower case alphanumeric characters or '-'
+
+- must start with an alphabetic character
+
+- must end with an alphanumeric character
+
+# Arguments
+
+• nameIn: the string to use as a basis for conversion. It is assumed that this is the
+name of the repository of the catalog
+
+# Returns
+
+• string: a string compliant with RFC 1035 or empty string if error occurs
+
+• error: non nil if error occurs, nil otherwise
+*/
+func createRFC1035NameForCatalogSource(nameIn string) (string, error) {
+	// start by making sure name starts with lower case alpha character, so prefix with `cs-`
+	name := strings.Join([]string{"cs", nameIn}, "-")
+	// modify name to be RFC 1035 compliant
+	name = strings.Map(toRFC1035, name)
+
+	// paranoid check to make sure the last character is alpha numeric
+	lastChar, _ := utf8.DecodeLastRuneInString(name)
+	if !(unicode.IsNumber(lastChar) || unicode.IsLetter(lastChar)) {
+		// convert name to have `-0` suffix
+		name = strings.Join([]string{name, "0"}, "-")
+	}
+
+	// remove duplicate dashes
+	stringBuilder := strings.Builder{}
+	var lastEncounteredRune rune
+	for position, currentRune := range name {
+		if currentRune != lastEncounteredRune || position == 0 || currentRune != '-' {
+			stringBuilder.WriteRune(currentRune)
+			lastEncounteredRune = currentRune
+		}
+	}
+	name = stringBuilder.String()
+
+	// truncate if necessary
+	if len(name) > validation.DNS1035LabelMaxLength {
+		// truncate the name to max length
+		truncatedName := name[:validation.DNS1035LabelMaxLength]
+		// is the last char a dash or a char that would be converted to a dash?
+		lastChar, _ := utf8.DecodeLastRuneInString(truncatedName)
+		if toRFC1035(lastChar) == '-' {
+			// truncate even more to allow -0 suffix
+			truncatedName = truncatedName[:validation.DNS1035LabelMaxLength-2]
+			// put suffix in place
+			name = strings.Join([]string{truncatedName, "0"}, "-")
+		} else {
+			// use truncated value as-is
+			name = truncatedName
+		}
+	}
+
+	// double check that the final name conforms to RFC 1035 (this should never fail)
+	errs := validation.IsDNS1035Label(name)
+	if len(errs) != 0 {
+		return "", fmt.Errorf("error creating catalog source name: %s", strings.Join(errs, ", "))
+	}
+	return name, nil
+}
+
+/*
+toRFC1035 converts the supplied rune to a dash if its not
+a through z, 0 through 9 or a dash
+*/
+func toRFC1035(r rune) rune {
+	r = unicode.ToLower(r)
+	switch {
+	case r >= 'a' && r <= 'z':
+		return r
+	case r >= '0' && r <= '9':
+		return r
+	case r == '-':
+		return r
+	default:
+		// convert unacceptable character
+		return '-'
+	}
+}
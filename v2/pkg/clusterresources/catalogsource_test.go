@@ -0,0 +1,201 @@
+package clusterresources
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
+	clog "github.com/openshift/oc-mirror/v2/pkg/log"
+	"github.com/openshift/oc-mirror/v2/pkg/mirror"
+	"sigs.k8s.io/yaml"
+)
+
+func TestCatalogSourceGenerator(t *testing.T) {
+	log := clog.New("trace")
+
+	newGenerator := func(t *testing.T, operators ...v1alpha2.Operator) *ClusterResourcesGenerator {
+		cfg := v1alpha2.ImageSetConfiguration{}
+		cfg.Mirror.Operators = operators
+		workingDir := filepath.Join(t.TempDir(), "working-dir")
+		return &ClusterResourcesGenerator{Log: log, Config: cfg, Opts: mirror.CopyOptions{Global: &mirror.GlobalOptions{WorkingDir: workingDir}}}
+	}
+
+	readCatalogSource := func(t *testing.T, cr *ClusterResourcesGenerator, name string) map[string]interface{} {
+		data, err := os.ReadFile(filepath.Join(cr.Opts.Global.WorkingDir, clusterResourcesDir, "catalogSource-"+name+".yaml"))
+		if err != nil {
+			t.Fatalf("the CatalogSource %s should be written: %v", name, err)
+		}
+		obj := map[string]interface{}{}
+		if err := yaml.Unmarshal(data, &obj); err != nil {
+			t.Fatal(err)
+		}
+		return obj
+	}
+
+	imageList := []v1alpha3.CopyImageSchema{
+		{
+			Source:      "docker://localhost:55000/redhat/redhat-operator-index:v4.14",
+			Destination: "docker://myregistry/mynamespace/redhat/redhat-operator-index:v4.14",
+			Origin:      "registry.redhat.io/redhat/redhat-operator-index:v4.14",
+		},
+		{
+			Source:      "docker://localhost:55000/other/redhat-operator-index:v4.14",
+			Destination: "docker://myregistry/mynamespace/other/redhat-operator-index:v4.14",
+			Origin:      "quay.io/other/redhat-operator-index:v4.14",
+		},
+		{
+			Source:      "docker://localhost:55000/community/my_catalog:1a2b3c4d5e6f",
+			Destination: "docker://myregistry/mynamespace/community/my-index:v1",
+			Origin:      "quay.io/community/my_catalog@sha256:1a2b3c4d5e6f7c4ef7434c97c8aaf6cd310874790b915b3c61fc902eea255f91",
+		},
+	}
+
+	t.Run("Testing CatalogSourceGenerator : should pass", func(t *testing.T) {
+		cr := newGenerator(t,
+			v1alpha2.Operator{Catalog: "registry.redhat.io/redhat/redhat-operator-index:v4.14"},
+			v1alpha2.Operator{Catalog: "quay.io/other/redhat-operator-index:v4.14"},
+			// the destination of the catalog is renamed by the collector (TargetName, TargetTag)
+			v1alpha2.Operator{Catalog: "quay.io/community/my_catalog@sha256:1a2b3c4d5e6f7c4ef7434c97c8aaf6cd310874790b915b3c61fc902eea255f91", TargetName: "my-index", TargetTag: "v1"},
+			// not mirrored
+			v1alpha2.Operator{Catalog: "quay.io/community/missing:v1"},
+		)
+		if err := cr.CatalogSourceGenerator(imageList); err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		entries, err := os.ReadDir(filepath.Join(cr.Opts.Global.WorkingDir, clusterResourcesDir))
+		if err != nil || len(entries) != 3 {
+			t.Fatalf("expected 3 CatalogSources: %v %v", entries, err)
+		}
+
+		cs := readCatalogSource(t, cr, "cs-redhat-operator-index")
+		if cs["apiVersion"] != "operators.coreos.com/v1alpha1" || cs["kind"] != "CatalogSource" {
+			t.Fatalf("unexpected CatalogSource %v", cs)
+		}
+		metadata := cs["metadata"].(map[string]interface{})
+		spec := cs["spec"].(map[string]interface{})
+		if metadata["name"] != "cs-redhat-operator-index" || metadata["namespace"] != "openshift-marketplace" {
+			t.Fatalf("unexpected metadata %v", metadata)
+		}
+		if spec["sourceType"] != "grpc" || spec["image"] != "myregistry/mynamespace/redhat/redhat-operator-index:v4.14" {
+			t.Fatalf("unexpected spec %v", spec)
+		}
+
+		// same repository name, the name is suffixed
+		cs = readCatalogSource(t, cr, "cs-redhat-operator-index-1")
+		if spec := cs["spec"].(map[string]interface{}); spec["image"] != "myregistry/mynamespace/other/redhat-operator-index:v4.14" {
+			t.Fatalf("unexpected spec %v", spec)
+		}
+
+		cs = readCatalogSource(t, cr, "cs-my-index")
+		if spec := cs["spec"].(map[string]interface{}); spec["image"] != "myregistry/mynamespace/community/my-index:v1" {
+			t.Fatalf("unexpected spec %v", spec)
+		}
+	})
+
+	t.Run("Testing CatalogSourceGenerator (template) : should pass", func(t *testing.T) {
+		template := filepath.Join(t.TempDir(), "catalogSource.yaml")
+		data := `apiVersion: operators.coreos.com/v1alpha1
+kind: CatalogSource
+metadata:
+  name: to-be-replaced
+  namespace: my-marketplace
+spec:
+  image: to-be-replaced
+  displayName: My Operators
+  updateStrategy:
+    registryPoll:
+      interval: 30m
+  grpcPodConfig:
+    nodeSelector:
+      node-role.kubernetes.io/infra: ""
+status:
+  message: to-be-removed
+`
+		if err := os.WriteFile(template, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cr := newGenerator(t, v1alpha2.Operator{Catalog: "registry.redhat.io/redhat/redhat-operator-index:v4.14", TargetCatalogSourceTemplate: template})
+		if err := cr.CatalogSourceGenerator(imageList); err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		cs := readCatalogSource(t, cr, "cs-redhat-operator-index")
+		if _, ok := cs["status"]; ok {
+			t.Fatalf("the status of the template should be removed")
+		}
+		metadata := cs["metadata"].(map[string]interface{})
+		spec := cs["spec"].(map[string]interface{})
+		if metadata["name"] != "cs-redhat-operator-index" || metadata["namespace"] != "my-marketplace" {
+			t.Fatalf("unexpected metadata %v", metadata)
+		}
+		if spec["image"] != "myregistry/mynamespace/redhat/redhat-operator-index:v4.14" || spec["sourceType"] != "grpc" || spec["displayName"] != "My Operators" {
+			t.Fatalf("unexpected spec %v", spec)
+		}
+		interval := spec["updateStrategy"].(map[string]interface{})["registryPoll"].(map[string]interface{})["interval"]
+		if interval != "30m" {
+			t.Fatalf("the updateStrategy of the template should be kept: %v", spec)
+		}
+		if _, ok := spec["grpcPodConfig"]; !ok {
+			t.Fatalf("the grpcPodConfig of the template should be kept: %v", spec)
+		}
+	})
+
+	type testCase struct {
+		caseName string
+		template string
+		expError string
+	}
+	testCases := []testCase{
+		{
+			caseName: "Testing CatalogSourceGenerator (template of another kind) : should fail",
+			template: "apiVersion: v1\nkind: ConfigMap\n",
+			expError: "not a CatalogSource",
+		},
+		{
+			caseName: "Testing CatalogSourceGenerator (invalid template) : should fail",
+			template: "kind: CatalogSource\nspec: grpc\n",
+			expError: "spec is not an object",
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.caseName, func(t *testing.T) {
+			template := filepath.Join(t.TempDir(), "catalogSource.yaml")
+			if err := os.WriteFile(template, []byte(c.template), 0644); err != nil {
+				t.Fatal(err)
+			}
+			cr := newGenerator(t, v1alpha2.Operator{Catalog: "registry.redhat.io/redhat/redhat-operator-index:v4.14", TargetCatalogSourceTemplate: template})
+			err := cr.CatalogSourceGenerator(imageList)
+			if err == nil || !strings.Contains(err.Error(), c.expError) {
+				t.Fatalf("should fail with %q: %v", c.expError, err)
+			}
+		})
+	}
+
+	t.Run("Testing CatalogSourceGenerator (missing template) : should fail", func(t *testing.T) {
+		cr := newGenerator(t, v1alpha2.Operator{Catalog: "registry.redhat.io/redhat/redhat-operator-index:v4.14", TargetCatalogSourceTemplate: filepath.Join(t.TempDir(), "missing.yaml")})
+		if err := cr.CatalogSourceGenerator(imageList); err == nil {
+			t.Fatalf("should fail")
+		}
+	})
+}
+
+func TestCreateRFC1035NameForCatalogSource(t *testing.T) {
+	cases := map[string]string{
+		"redhat-operator-index":        "cs-redhat-operator-index",
+		"My_Catalog.Index":             "cs-my-catalog-index",
+		"index--":                      "cs-index-0",
+		strings.Repeat("a", 70):        "cs-" + strings.Repeat("a", 60),
+		strings.Repeat("a", 59) + "_b": "cs-" + strings.Repeat("a", 58) + "-0",
+	}
+	for in, expected := range cases {
+		name, err := createRFC1035NameForCatalogSource(in)
+		if err != nil {
+			t.Fatalf("should not fail for %s: %v", in, err)
+		}
+		if name != expected {
+			t.Fatalf("expected %s for %s, got %s", expected, in, name)
+		}
+	}
+}
@@ -1,11 +1,16 @@
 package clusterresources
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -14,14 +19,63 @@ import (
 	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
 	clog "github.com/openshift/oc-mirror/v2/pkg/log"
 	"github.com/openshift/oc-mirror/v2/pkg/mirror"
+	"golang.org/x/crypto/openpgp"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/yaml"
 )
 
 const (
 	clusterResourcesDir string = "cluster-resources"
+	// signaturesDir - the folder of the working-dir where the release collector
+	// saves the signatures it verified, named after the release digests
+	signaturesDir string = "signatures"
+	// graphImageName - the image of the graph data built by the release collector
+	graphImageName string = "openshift/graph-image"
+	dockerProtocol string = "docker://"
+
+	signatureNamespace    string = "openshift-config-managed"
+	signatureLabel        string = "release.openshift.io/verification-signatures"
+	signatureFileNameFmt  string = "signature-%s-%s.yaml"
+	maxDigestHashLen      int    = 16
+	updateServiceName     string = "update-service-oc-mirror"
+	updateServiceFileName string = "updateService.yaml"
+	updateServiceKind     string = "UpdateService"
+	updateServiceVersion  string = "updateservice.operator.openshift.io/v1"
+	updateServiceReplicas int32  = 2
+
+	// the scopes of the sources of the IDMS/ITMS
+	RegistryScope   string = "registry"
+	NamespaceScope  string = "namespace"
+	RepositoryScope string = "repository"
+	// mirrorSetSizeLimit - the maximum size of an IDMS/ITMS, in bytes
+	mirrorSetSizeLimit int = 250000
 )
 
+// UpdateService - the resource of the OpenShift Update Service operator,
+// only the fields of the spec set by oc-mirror are defined
+type UpdateService struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              UpdateServiceSpec `json:"spec"`
+}
+
+type UpdateServiceSpec struct {
+	// Replicas: the number of replicas of the update service
+	Replicas int32 `json:"replicas"`
+	// Releases: the repository of the mirrored releases
+	Releases string `json:"releases"`
+	// GraphDataImage: the mirrored graph data image
+	GraphDataImage string `json:"graphDataImage"`
+}
+
+// mirroredRelease - a release that was mirrored, with its signature
+type mirroredRelease struct {
+	digest      string
+	destination string
+	signature   []byte
+}
+
 func New(log clog.PluggableLoggerInterface,
 	config v1alpha2.ImageSetConfiguration,
 	opts mirror.CopyOptions,
@@ -35,16 +89,72 @@ type ClusterResourcesGenerator struct {
 	Opts   mirror.CopyOptions
 }
 
+// IDMSGenerator - writes an ImageDigestMirrorSet for the images referenced by digest
+// and an ImageTagMirrorSet for the images referenced by tag. The sources are mapped
+// at the scope of opts (registry, namespace or repository), and the mirror sets are
+// split so that each of them stays under the size limit of the cluster objects
 func (c *ClusterResourcesGenerator) IDMSGenerator(ctx context.Context, allRelatedImages []v1alpha3.CopyImageSchema, opts mirror.CopyOptions) error {
 
-	// determine the name of the IDMS resource
+	// determine the name of the IDMS/ITMS resources
 	// TODO determine name (based on date?)
-	name := "idms_" + time.Now().Format(time.RFC3339)
+	suffix := "_" + time.Now().Format(time.RFC3339)
+
+	scope := opts.Global.MirrorSetScope
+	if scope == "" {
+		scope = NamespaceScope
+	}
 
-	// locate the output directory
-	idmsFileName := filepath.Join(opts.Global.WorkingDir, clusterResourcesDir, name+".yaml")
+	byDigest := []v1alpha3.CopyImageSchema{}
+	byTag := []v1alpha3.CopyImageSchema{}
+	for _, img := range allRelatedImages {
+		if strings.Contains(img.Origin, "@") {
+			byDigest = append(byDigest, img)
+		} else {
+			byTag = append(byTag, img)
+		}
+	}
 
-	// create a IDMS struct
+	// populate IDMS from the images by digest
+	digestMirrors, err := generateImageMirrors(byDigest, scope)
+	if err != nil {
+		return err
+	}
+	idmsList, err := generateMirrorSets(digestMirrors, mirrorSetSizeLimit, func(index int, sources []string) interface{} {
+		return newIDMS(fmt.Sprintf("idms%s-%d", suffix, index), sources, digestMirrors)
+	})
+	if err != nil {
+		return fmt.Errorf("unable to generate IDMS: %v", err)
+	}
+
+	// populate ITMS from the images by tag
+	tagMirrors, err := generateImageMirrors(byTag, scope)
+	if err != nil {
+		return err
+	}
+	itmsList, err := generateMirrorSets(tagMirrors, mirrorSetSizeLimit, func(index int, sources []string) interface{} {
+		return newITMS(fmt.Sprintf("itms%s-%d", suffix, index), sources, tagMirrors)
+	})
+	if err != nil {
+		return fmt.Errorf("unable to generate ITMS: %v", err)
+	}
+
+	if err := c.writeMirrorSets(filepath.Join(opts.Global.WorkingDir, clusterResourcesDir, "idms"+suffix+".yaml"), idmsList); err != nil {
+		return err
+	}
+	return c.writeMirrorSets(filepath.Join(opts.Global.WorkingDir, clusterResourcesDir, "itms"+suffix+".yaml"), itmsList)
+}
+
+// IsValidScope - true when scope is one of registry, namespace or repository,
+// no scope meaning namespace
+func IsValidScope(scope string) bool {
+	switch scope {
+	case "", RegistryScope, NamespaceScope, RepositoryScope:
+		return true
+	}
+	return false
+}
+
+func newIDMS(name string, sources []string, mirrors map[string][]confv1.ImageMirror) confv1.ImageDigestMirrorSet {
 	idms := confv1.ImageDigestMirrorSet{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: confv1.GroupVersion.String(),
@@ -57,73 +167,157 @@ func (c *ClusterResourcesGenerator) IDMSGenerator(ctx context.Context, allRelate
 			ImageDigestMirrors: []confv1.ImageDigestMirrors{},
 		},
 	}
-	// populate IDMS from allRelatedImages
-	mirrors, err := generateImageMirrors(allRelatedImages)
-	if err != nil {
-		return err
+	for _, source := range sources {
+		idms.Spec.ImageDigestMirrors = append(idms.Spec.ImageDigestMirrors, confv1.ImageDigestMirrors{
+			Source:  source,
+			Mirrors: mirrors[source],
+		})
 	}
+	return idms
+}
 
-	for source, imgMirrors := range mirrors {
-		idm := confv1.ImageDigestMirrors{
+func newITMS(name string, sources []string, mirrors map[string][]confv1.ImageMirror) confv1.ImageTagMirrorSet {
+	itms := confv1.ImageTagMirrorSet{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: confv1.GroupVersion.String(),
+			Kind:       "ImageTagMirrorSet",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: confv1.ImageTagMirrorSetSpec{
+			ImageTagMirrors: []confv1.ImageTagMirrors{},
+		},
+	}
+	for _, source := range sources {
+		itms.Spec.ImageTagMirrors = append(itms.Spec.ImageTagMirrors, confv1.ImageTagMirrors{
 			Source:  source,
-			Mirrors: imgMirrors,
+			Mirrors: mirrors[source],
+		})
+	}
+	return itms
+}
+
+// generateMirrorSets - groups the sources of mirrors (in alphabetical order) in as few
+// mirror sets as possible, each of them staying under byteLimit once marshalled.
+// newSet creates the index-th mirror set for the sources of a group
+func generateMirrorSets(mirrors map[string][]confv1.ImageMirror, byteLimit int, newSet func(index int, sources []string) interface{}) ([]interface{}, error) {
+	sources := make([]string, 0, len(mirrors))
+	for source := range mirrors {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	sets := []interface{}{}
+	group := []string{}
+	for _, source := range sources {
+		candidate := append(append([]string{}, group...), source)
+		fits, err := fitsIn(newSet(len(sets), candidate), byteLimit)
+		if err != nil {
+			return nil, err
+		}
+		if fits {
+			group = candidate
+			continue
+		}
+		if len(group) > 0 {
+			sets = append(sets, newSet(len(sets), group))
+			group = []string{source}
+			fits, err = fitsIn(newSet(len(sets), group), byteLimit)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if !fits {
+			return nil, fmt.Errorf("mirrors for %q cannot fit into any mirror set with byte limit %d", source, byteLimit)
 		}
-		idms.Spec.ImageDigestMirrors = append(idms.Spec.ImageDigestMirrors, idm)
 	}
+	if len(group) > 0 {
+		sets = append(sets, newSet(len(sets), group))
+	}
+	return sets, nil
+}
 
-	// put IDMS in yaml
-	bytes, err := yaml.Marshal(idms)
+func fitsIn(obj interface{}, byteLimit int) (bool, error) {
+	data, err := yaml.Marshal(obj)
 	if err != nil {
-		return err
+		return false, err
 	}
+	return len(data) <= byteLimit, nil
+}
 
-	// save IDMS struct to file
-	if _, err := os.Stat(idmsFileName); errors.Is(err, os.ErrNotExist) {
-		c.Log.Info("%s does not exist, creating it", idmsFileName)
-		err := os.MkdirAll(filepath.Dir(idmsFileName), 0755)
+// writeMirrorSets - saves the mirror sets in a single yaml file, nothing is written without mirror sets
+func (c *ClusterResourcesGenerator) writeMirrorSets(fileName string, sets []interface{}) error {
+	if len(sets) == 0 {
+		return nil
+	}
+	var data []byte
+	for _, set := range sets {
+		setData, err := yaml.Marshal(set)
 		if err != nil {
 			return err
 		}
-		c.Log.Info("%s dir created", filepath.Dir(idmsFileName))
+		data = append(data, []byte("---\n")...)
+		data = append(data, setData...)
 	}
-	idmsFile, err := os.Create(idmsFileName)
-	if err != nil {
+
+	// save the mirror sets to file
+	if _, err := os.Stat(fileName); errors.Is(err, os.ErrNotExist) {
+		c.Log.Info("%s does not exist, creating it", fileName)
+		err := os.MkdirAll(filepath.Dir(fileName), 0755)
+		if err != nil {
+			return err
+		}
+		c.Log.Info("%s dir created", filepath.Dir(fileName))
+	}
+	if err := os.WriteFile(fileName, data, 0644); err != nil {
 		return err
 	}
-	c.Log.Info("%s file created", idmsFileName)
-
-	defer idmsFile.Close()
-
-	_, err = idmsFile.Write(bytes)
-	return err
+	c.Log.Info("%s file created", fileName)
+	return nil
 }
 
-func generateImageMirrors(allRelatedImages []v1alpha3.CopyImageSchema) (map[string][]confv1.ImageMirror, error) {
+// generateImageMirrors - the mirrors of each source, the source and the mirrors
+// being the registries, the namespaces or the repositories of the images depending on scope
+func generateImageMirrors(allRelatedImages []v1alpha3.CopyImageSchema, scope string) (map[string][]confv1.ImageMirror, error) {
 	mirrors := make(map[string][]confv1.ImageMirror, 0)
 
 	for _, relatedImage := range allRelatedImages {
 		if relatedImage.Origin == "" {
 			return nil, fmt.Errorf("unable to generate IDMS/ITMS: original reference for (%s,%s) undetermined", relatedImage.Source, relatedImage.Destination)
 		}
-		// locate source namespace
-		// strip away protocol
-		originRef := relatedImage.Origin
-		srcTransportAndPath := strings.Split(relatedImage.Origin, "://")
-		if len(srcTransportAndPath) > 1 {
-			originRef = srcTransportAndPath[1]
-		}
-		srcPathComponents := strings.Split(originRef, "/")
-		srcNs := filepath.Join(srcPathComponents[:len(srcPathComponents)-1]...)
+		srcRepo := repository(stripTransport(relatedImage.Origin))
+		destRepo := repository(stripTransport(relatedImage.Destination))
 
-		// locate mirror namespace
-		destRef := relatedImage.Destination
-		// strip away protocol
-		destTransportAndPath := strings.Split(destRef, "://")
-		if len(destTransportAndPath) > 1 {
-			destRef = destTransportAndPath[1]
+		var srcNs, destNs string
+		switch scope {
+		case RegistryScope:
+			// the destination keeps the path of the source after its registry,
+			// otherwise only the repository can be mapped
+			srcPathComponents := strings.SplitN(srcRepo, "/", 2)
+			if len(srcPathComponents) == 2 && strings.HasSuffix(destRepo, "/"+srcPathComponents[1]) {
+				srcNs = srcPathComponents[0]
+				destNs = strings.TrimSuffix(destRepo, "/"+srcPathComponents[1])
+			} else {
+				srcNs = srcRepo
+				destNs = destRepo
+			}
+		case NamespaceScope:
+			// the destination keeps the name of the repository (i.e. unless rewritten),
+			// otherwise only the repository can be mapped
+			if path.Base(srcRepo) == path.Base(destRepo) {
+				srcNs = parent(srcRepo)
+				destNs = parent(destRepo)
+			} else {
+				srcNs = srcRepo
+				destNs = destRepo
+			}
+		case RepositoryScope:
+			srcNs = srcRepo
+			destNs = destRepo
+		default:
+			return nil, fmt.Errorf("invalid IDMS/ITMS scope %s", scope)
 		}
-		destPathComponents := strings.Split(destRef, "/")
-		destNs := filepath.Join(destPathComponents[:len(destPathComponents)-1]...)
 
 		// add entry to map
 		if mirrors[srcNs] == nil {
@@ -143,3 +337,185 @@ func generateImageMirrors(allRelatedImages []v1alpha3.CopyImageSchema) (map[stri
 	}
 	return mirrors, nil
 }
+
+// stripTransport - the reference without its transport (i.e. docker://)
+func stripTransport(ref string) string {
+	transportAndPath := strings.Split(ref, "://")
+	if len(transportAndPath) > 1 {
+		return transportAndPath[1]
+	}
+	return ref
+}
+
+// parent - the namespace of a repository, or the repository when it has no namespace
+func parent(repo string) string {
+	if i := strings.LastIndex(repo, "/"); i > 0 {
+		return repo[:i]
+	}
+	return repo
+}
+
+// ReleaseSignaturesGenerator - writes a ConfigMap holding the signature of each mirrored release,
+// so that the cluster can verify the releases without reaching the signature stores
+func (c *ClusterResourcesGenerator) ReleaseSignaturesGenerator(allRelatedImages []v1alpha3.CopyImageSchema) error {
+	releases, err := c.mirroredReleases(allRelatedImages)
+	if err != nil {
+		return err
+	}
+	for _, release := range releases {
+		name := "sha256-" + release.digest
+		cm := corev1.ConfigMap{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: corev1.SchemeGroupVersion.String(),
+				Kind:       "ConfigMap",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: signatureNamespace,
+				Labels: map[string]string{
+					signatureLabel: "",
+				},
+			},
+			BinaryData: map[string][]byte{
+				name + "-1": release.signature,
+			},
+		}
+		hash := release.digest
+		if len(hash) > maxDigestHashLen {
+			hash = hash[:maxDigestHashLen]
+		}
+		if err := c.writeResource(fmt.Sprintf(signatureFileNameFmt, "sha256", hash), cm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateServiceGenerator - when the graph data is mirrored, writes the UpdateService
+// pointing at the mirrored graph data image and at the repository of the mirrored releases
+func (c *ClusterResourcesGenerator) UpdateServiceGenerator(allRelatedImages []v1alpha3.CopyImageSchema) error {
+	if !c.Config.Mirror.Platform.Graph {
+		return nil
+	}
+	graphImage := ""
+	for _, img := range allRelatedImages {
+		if strings.Contains(img.Origin, graphImageName+":") || strings.Contains(img.Origin, graphImageName+"@") {
+			graphImage = strings.TrimPrefix(img.Destination, dockerProtocol)
+			break
+		}
+	}
+	if graphImage == "" {
+		return fmt.Errorf("unable to generate the UpdateService: graph image %s not mirrored", graphImageName)
+	}
+	releases, err := c.mirroredReleases(allRelatedImages)
+	if err != nil {
+		return err
+	}
+	if len(releases) == 0 {
+		return fmt.Errorf("unable to generate the UpdateService: no release mirrored")
+	}
+
+	updateService := UpdateService{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: updateServiceVersion,
+			Kind:       updateServiceKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: updateServiceName,
+		},
+		Spec: UpdateServiceSpec{
+			Replicas:       updateServiceReplicas,
+			Releases:       repository(releases[0].destination),
+			GraphDataImage: graphImage,
+		},
+	}
+	return c.writeResource(updateServiceFileName, updateService)
+}
+
+// mirroredReleases - the releases among allRelatedImages, identified by the signatures
+// that the release collector saved (and verified) in the working-dir
+func (c *ClusterResourcesGenerator) mirroredReleases(allRelatedImages []v1alpha3.CopyImageSchema) ([]mirroredRelease, error) {
+	dir := filepath.Join(c.Opts.Global.WorkingDir, signaturesDir)
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []mirroredRelease{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	destinations := map[string]string{}
+	for _, img := range allRelatedImages {
+		if img.Type != "" && img.Type != v1alpha3.TypeRelease {
+			continue
+		}
+		destinations[strings.TrimPrefix(img.Origin, dockerProtocol)] = img.Destination
+	}
+
+	releases := []mirroredRelease{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		reference, err := signedReference(data)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the signature %s: %v", entry.Name(), err)
+		}
+		// the signatures of the releases mirrored by previous runs are skipped
+		if destination, ok := destinations[reference]; ok {
+			releases = append(releases, mirroredRelease{digest: entry.Name(), destination: destination, signature: data})
+		}
+	}
+	sort.Slice(releases, func(i, j int) bool { return releases[i].destination < releases[j].destination })
+	return releases, nil
+}
+
+// signedReference - the release reference that a signature signs.
+// The signature itself was verified when the release was collected
+func signedReference(data []byte) (string, error) {
+	md, err := openpgp.ReadMessage(bytes.NewReader(data), openpgp.EntityList{}, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	content, err := io.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		return "", err
+	}
+	var signSchema v1alpha3.SignatureContentSchema
+	if err := json.Unmarshal(content, &signSchema); err != nil {
+		return "", err
+	}
+	return signSchema.Critical.Identity.DockerReference, nil
+}
+
+// repository - the reference without its transport, tag and digest
+func repository(ref string) string {
+	ref = strings.TrimPrefix(ref, dockerProtocol)
+	if i := strings.Index(ref, "@"); i >= 0 {
+		return ref[:i]
+	}
+	if colon := strings.LastIndex(ref, ":"); colon > strings.LastIndex(ref, "/") {
+		return ref[:colon]
+	}
+	return ref
+}
+
+// writeResource - saves obj in yaml in the cluster-resources folder of the working-dir
+func (c *ClusterResourcesGenerator) writeResource(fileName string, obj interface{}) error {
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(c.Opts.Global.WorkingDir, clusterResourcesDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, fileName), data, 0644); err != nil {
+		return err
+	}
+	c.Log.Info("%s file created", filepath.Join(dir, fileName))
+	return nil
+}
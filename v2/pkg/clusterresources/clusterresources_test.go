@@ -1,15 +1,24 @@
 package clusterresources
 
 import (
+	"bytes"
 	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	confv1 "github.com/openshift/api/config/v1"
 	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha2"
 	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
 	clog "github.com/openshift/oc-mirror/v2/pkg/log"
 	"github.com/openshift/oc-mirror/v2/pkg/mirror"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+	"sigs.k8s.io/yaml"
 )
 
 func TestIDMSGenerator(t *testing.T) {
@@ -156,7 +165,7 @@ func TestGenerateImageMirrors(t *testing.T) {
 
 	t.Run("Testing GenerateImageMirrors - Disk to Mirror : should have 1 namespace", func(t *testing.T) {
 
-		mirrors, err := generateImageMirrors(imageList)
+		mirrors, err := generateImageMirrors(imageList, NamespaceScope)
 		if err != nil {
 			t.Fatalf("should not fail")
 		}
@@ -174,3 +183,309 @@ func TestGenerateImageMirrors(t *testing.T) {
 		}
 	})
 }
+
+func TestMirrorSetsGenerator(t *testing.T) {
+	log := clog.New("trace")
+
+	imageList := []v1alpha3.CopyImageSchema{
+		{
+			Source:      "docker://localhost:55000/openshift-release-dev/ocp-v4.0-art-dev@sha256:7c4ef7434c97c8aaf6cd310874790b915b3c61fc902eea255f9177058ea9aff3",
+			Destination: "docker://myregistry/mynamespace/openshift-release-dev/ocp-v4.0-art-dev@sha256:7c4ef7434c97c8aaf6cd310874790b915b3c61fc902eea255f9177058ea9aff3",
+			Origin:      "quay.io/openshift-release-dev/ocp-v4.0-art-dev@sha256:7c4ef7434c97c8aaf6cd310874790b915b3c61fc902eea255f9177058ea9aff3",
+		},
+		{
+			Source:      "docker://localhost:55000/ubi8/ubi:8.8",
+			Destination: "docker://myregistry/mynamespace/ubi8/ubi:8.8",
+			Origin:      "docker://registry.redhat.io/ubi8/ubi:8.8",
+		},
+		{
+			Source:      "docker://localhost:55000/ubi8/ubi-minimal:latest",
+			Destination: "docker://myregistry/mynamespace/ubi8/ubi-minimal:latest",
+			Origin:      "docker://registry.redhat.io/ubi8/ubi-minimal:latest",
+		},
+	}
+
+	t.Run("Testing IDMSGenerator (images by tag) : should write an ITMS", func(t *testing.T) {
+		workingDir := filepath.Join(t.TempDir(), "working-dir")
+		opts := mirror.CopyOptions{Global: &mirror.GlobalOptions{WorkingDir: workingDir}}
+		cr := &ClusterResourcesGenerator{Log: log, Opts: opts}
+		if err := cr.IDMSGenerator(context.Background(), imageList, opts); err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		files, err := os.ReadDir(filepath.Join(workingDir, clusterResourcesDir))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) != 2 || !strings.HasPrefix(files[0].Name(), "idms") || !strings.HasPrefix(files[1].Name(), "itms") {
+			t.Fatalf("expected an idms and an itms file, got %v", files)
+		}
+		data, err := os.ReadFile(filepath.Join(workingDir, clusterResourcesDir, files[1].Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, expected := range []string{"kind: ImageTagMirrorSet", "source: registry.redhat.io/ubi8", "- myregistry/mynamespace/ubi8"} {
+			if !strings.Contains(string(data), expected) {
+				t.Fatalf("expected %q in the itms:\n%s", expected, string(data))
+			}
+		}
+		if strings.Contains(string(data), "openshift-release-dev") {
+			t.Fatalf("the images by digest should not be in the itms:\n%s", string(data))
+		}
+	})
+
+	type testCase struct {
+		scope    string
+		expected map[string]string
+	}
+	testCases := []testCase{
+		{
+			scope: RegistryScope,
+			expected: map[string]string{
+				"quay.io":            "myregistry/mynamespace",
+				"registry.redhat.io": "myregistry/mynamespace",
+			},
+		},
+		{
+			scope: NamespaceScope,
+			expected: map[string]string{
+				"quay.io/openshift-release-dev": "myregistry/mynamespace/openshift-release-dev",
+				"registry.redhat.io/ubi8":       "myregistry/mynamespace/ubi8",
+			},
+		},
+		{
+			scope: RepositoryScope,
+			expected: map[string]string{
+				"quay.io/openshift-release-dev/ocp-v4.0-art-dev": "myregistry/mynamespace/openshift-release-dev/ocp-v4.0-art-dev",
+				"registry.redhat.io/ubi8/ubi":                    "myregistry/mynamespace/ubi8/ubi",
+				"registry.redhat.io/ubi8/ubi-minimal":            "myregistry/mynamespace/ubi8/ubi-minimal",
+			},
+		},
+	}
+	for _, c := range testCases {
+		t.Run("Testing GenerateImageMirrors ("+c.scope+" scope) : should pass", func(t *testing.T) {
+			mirrors, err := generateImageMirrors(imageList, c.scope)
+			if err != nil {
+				t.Fatalf("should not fail: %v", err)
+			}
+			if len(mirrors) != len(c.expected) {
+				t.Fatalf("unexpected mirrors %v", mirrors)
+			}
+			for source, mirror := range c.expected {
+				if len(mirrors[source]) != 1 || string(mirrors[source][0]) != mirror {
+					t.Fatalf("unexpected mirrors for %s: %v", source, mirrors[source])
+				}
+			}
+		})
+	}
+
+	t.Run("Testing GenerateImageMirrors (rewritten destination, namespace scope) : should pass", func(t *testing.T) {
+		rewritten := []v1alpha3.CopyImageSchema{
+			{Origin: "docker://quay.io/community/team/app@sha256:f30638f60452062aba36a26ee6c036feead2f03b28f2c47f2b0a991e41baebea", Destination: "docker://myregistry/community/team-app@sha256:f30638f60452062aba36a26ee6c036feead2f03b28f2c47f2b0a991e41baebea"},
+			{Origin: "docker://quay.io/community/team/other@sha256:f30638f60452062aba36a26ee6c036feead2f03b28f2c47f2b0a991e41baebea", Destination: "docker://myregistry/community/team/other@sha256:f30638f60452062aba36a26ee6c036feead2f03b28f2c47f2b0a991e41baebea"},
+		}
+		mirrors, err := generateImageMirrors(rewritten, NamespaceScope)
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		expected := map[string]string{
+			"quay.io/community/team/app": "myregistry/community/team-app",
+			"quay.io/community/team":     "myregistry/community/team",
+		}
+		if len(mirrors) != len(expected) {
+			t.Fatalf("unexpected mirrors %v", mirrors)
+		}
+		for source, mirror := range expected {
+			if len(mirrors[source]) != 1 || string(mirrors[source][0]) != mirror {
+				t.Fatalf("unexpected mirrors for %s: %v", source, mirrors[source])
+			}
+		}
+	})
+
+	t.Run("Testing GenerateImageMirrors (invalid scope) : should fail", func(t *testing.T) {
+		if _, err := generateImageMirrors(imageList, "image"); err == nil {
+			t.Fatalf("should fail")
+		}
+	})
+
+	t.Run("Testing GenerateMirrorSets (size limit) : should split the mirror sets", func(t *testing.T) {
+		mirrors, err := generateImageMirrors(imageList, RepositoryScope)
+		if err != nil {
+			t.Fatal(err)
+		}
+		newSet := func(index int, sources []string) interface{} {
+			return newITMS(fmt.Sprintf("itms-%d", index), sources, mirrors)
+		}
+		sets, err := generateMirrorSets(mirrors, 1000, newSet)
+		if err != nil || len(sets) != 1 {
+			t.Fatalf("expected 1 mirror set: %v %v", sets, err)
+		}
+		sets, err = generateMirrorSets(mirrors, 400, newSet)
+		if err != nil || len(sets) < 2 {
+			t.Fatalf("expected the mirror sets to be split: %v %v", sets, err)
+		}
+		count := 0
+		for i, set := range sets {
+			itms := set.(confv1.ImageTagMirrorSet)
+			if itms.Name != fmt.Sprintf("itms-%d", i) {
+				t.Fatalf("unexpected name %s", itms.Name)
+			}
+			data, _ := yaml.Marshal(itms)
+			if len(data) > 400 {
+				t.Fatalf("mirror set over the size limit: %d bytes", len(data))
+			}
+			count += len(itms.Spec.ImageTagMirrors)
+		}
+		if count != len(mirrors) {
+			t.Fatalf("all the sources should be kept, got %d", count)
+		}
+		if _, err := generateMirrorSets(mirrors, 100, newSet); err == nil {
+			t.Fatalf("should fail when a source can't fit in a mirror set")
+		}
+	})
+}
+
+func TestReleaseResourcesGenerator(t *testing.T) {
+	log := clog.New("trace")
+
+	const (
+		digest    = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+		oldDigest = "60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752"
+	)
+
+	newGenerator := func(t *testing.T, graph bool) *ClusterResourcesGenerator {
+		workingDir := filepath.Join(t.TempDir(), "working-dir")
+		// the signatures saved by the release collector: one of them is from a previous run
+		writeSignature(t, workingDir, digest, "quay.io/openshift-release-dev/ocp-release:4.13.10-x86_64")
+		writeSignature(t, workingDir, oldDigest, "quay.io/openshift-release-dev/ocp-release:4.13.9-x86_64")
+		cfg := v1alpha2.ImageSetConfiguration{}
+		cfg.Mirror.Platform.Graph = graph
+		return &ClusterResourcesGenerator{Log: log, Config: cfg, Opts: mirror.CopyOptions{Global: &mirror.GlobalOptions{WorkingDir: workingDir}}}
+	}
+
+	imageList := []v1alpha3.CopyImageSchema{
+		{
+			Source:      "docker://localhost:55000/openshift-release-dev/ocp-release:4.13.10-x86_64",
+			Destination: "docker://myregistry/mynamespace/openshift-release-dev/ocp-release:4.13.10-x86_64",
+			Origin:      "docker://quay.io/openshift-release-dev/ocp-release:4.13.10-x86_64",
+			Type:        v1alpha3.TypeRelease,
+		},
+		{
+			Source:      "docker://localhost:55000/openshift-release-dev/ocp-v4.0-art-dev@sha256:7c4ef7434c97c8aaf6cd310874790b915b3c61fc902eea255f9177058ea9aff3",
+			Destination: "docker://myregistry/mynamespace/openshift-release-dev/ocp-v4.0-art-dev@sha256:7c4ef7434c97c8aaf6cd310874790b915b3c61fc902eea255f9177058ea9aff3",
+			Origin:      "quay.io/openshift-release-dev/ocp-v4.0-art-dev@sha256:7c4ef7434c97c8aaf6cd310874790b915b3c61fc902eea255f9177058ea9aff3",
+			Type:        v1alpha3.TypeRelease,
+		},
+		{
+			Source:      "docker://localhost:55000/openshift/graph-image:latest",
+			Destination: "docker://myregistry/mynamespace/openshift/graph-image:latest",
+			Origin:      "localhost:55000/openshift/graph-image:latest",
+			Type:        v1alpha3.TypeRelease,
+		},
+	}
+
+	t.Run("Testing ReleaseSignaturesGenerator : should pass", func(t *testing.T) {
+		cr := newGenerator(t, false)
+		if err := cr.ReleaseSignaturesGenerator(imageList); err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		files, err := os.ReadDir(filepath.Join(cr.Opts.Global.WorkingDir, clusterResourcesDir))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// only the release mirrored by this run
+		if len(files) != 1 || files[0].Name() != "signature-sha256-"+digest[:16]+".yaml" {
+			t.Fatalf("unexpected files %v", files)
+		}
+		data, err := os.ReadFile(filepath.Join(cr.Opts.Global.WorkingDir, clusterResourcesDir, files[0].Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var cm map[string]interface{}
+		if err := yaml.Unmarshal(data, &cm); err != nil {
+			t.Fatal(err)
+		}
+		for _, expected := range []string{"kind: ConfigMap", "namespace: openshift-config-managed", "release.openshift.io/verification-signatures: \"\"", "sha256-" + digest + "-1:"} {
+			if !strings.Contains(string(data), expected) {
+				t.Fatalf("expected %q in the configmap:\n%s", expected, string(data))
+			}
+		}
+	})
+
+	t.Run("Testing UpdateServiceGenerator : should pass", func(t *testing.T) {
+		cr := newGenerator(t, true)
+		if err := cr.UpdateServiceGenerator(imageList); err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		data, err := os.ReadFile(filepath.Join(cr.Opts.Global.WorkingDir, clusterResourcesDir, updateServiceFileName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var us UpdateService
+		if err := yaml.Unmarshal(data, &us); err != nil {
+			t.Fatal(err)
+		}
+		if us.Kind != updateServiceKind || us.Name != updateServiceName {
+			t.Fatalf("unexpected UpdateService %v", us)
+		}
+		if us.Spec.Releases != "myregistry/mynamespace/openshift-release-dev/ocp-release" {
+			t.Fatalf("unexpected releases repository %s", us.Spec.Releases)
+		}
+		if us.Spec.GraphDataImage != "myregistry/mynamespace/openshift/graph-image:latest" {
+			t.Fatalf("unexpected graph data image %s", us.Spec.GraphDataImage)
+		}
+	})
+
+	t.Run("Testing UpdateServiceGenerator (no graph) : should pass", func(t *testing.T) {
+		cr := newGenerator(t, false)
+		if err := cr.UpdateServiceGenerator(imageList); err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(cr.Opts.Global.WorkingDir, clusterResourcesDir, updateServiceFileName)); !os.IsNotExist(err) {
+			t.Fatalf("the UpdateService should not be created")
+		}
+	})
+
+	t.Run("Testing UpdateServiceGenerator (graph image not mirrored) : should fail", func(t *testing.T) {
+		cr := newGenerator(t, true)
+		if err := cr.UpdateServiceGenerator(imageList[:2]); err == nil {
+			t.Fatalf("should fail")
+		}
+	})
+}
+
+// writeSignature - saves in the working-dir a signature of the release, as the release collector does
+func writeSignature(t *testing.T, workingDir, digest, reference string) {
+	entity, err := openpgp.NewEntity("test", "", "test@example.com", &packet.Config{RSABits: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var signSchema v1alpha3.SignatureContentSchema
+	signSchema.Critical.Image.DockerManifestDigest = "sha256:" + digest
+	signSchema.Critical.Identity.DockerReference = reference
+	content, err := json.Marshal(signSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sha256 (id 8): RIPEMD160, used by default, is not compiled in
+	for _, id := range entity.Identities {
+		id.SelfSignature.PreferredHash = []uint8{8}
+	}
+	var buf bytes.Buffer
+	w, err := openpgp.Sign(&buf, entity, nil, &packet.Config{DefaultHash: crypto.SHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(workingDir, signaturesDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, digest), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
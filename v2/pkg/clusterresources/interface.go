@@ -9,4 +9,7 @@ import (
 
 type GeneratorInterface interface {
 	IDMSGenerator(ctx context.Context, allRelatedImages []v1alpha3.CopyImageSchema, opts mirror.CopyOptions) error
+	ReleaseSignaturesGenerator(allRelatedImages []v1alpha3.CopyImageSchema) error
+	UpdateServiceGenerator(allRelatedImages []v1alpha3.CopyImageSchema) error
+	CatalogSourceGenerator(allRelatedImages []v1alpha3.CopyImageSchema) error
 }
@@ -46,6 +46,39 @@ func ReadConfig(configPath string) (c v1alpha2.ImageSetConfiguration, err error)
 	return c, Validate(&c)
 }
 
+// ReadDeleteConfig opens a delete imageset configuration file at the given path
+// and loads it into a v1alpha2.DeleteImageSetConfiguration instance for processing and validation.
+func ReadDeleteConfig(configPath string) (c v1alpha2.DeleteImageSetConfiguration, err error) {
+
+	data, err := os.ReadFile(filepath.Clean(configPath))
+	if err != nil {
+		return c, err
+	}
+	typeMeta, err := getTypeMeta(data)
+
+	if err != nil {
+		return c, err
+	}
+
+	switch typeMeta.GroupVersionKind() {
+	case v1alpha2.GroupVersion.WithKind(v1alpha2.DeleteImageSetConfigurationKind):
+		c, err = LoadDeleteConfig(data)
+		if err != nil {
+			return c, err
+		}
+	default:
+		return c, fmt.Errorf("config GVK not recognized: %s", typeMeta.GroupVersionKind())
+	}
+
+	// the delete content types are validated and completed
+	// the same way as the mirror ones
+	isc := c.ToImageSetConfiguration()
+	Complete(&isc)
+	c.Delete.Platform = isc.Mirror.Platform
+
+	return c, Validate(&isc)
+}
+
 // LoadConfig loads data into a v1alpha2.ImageSetConfiguration instance
 func LoadConfig(data []byte) (c v1alpha2.ImageSetConfiguration, err error) {
 
@@ -66,6 +99,26 @@ func LoadConfig(data []byte) (c v1alpha2.ImageSetConfiguration, err error) {
 	return c, nil
 }
 
+// LoadDeleteConfig loads data into a v1alpha2.DeleteImageSetConfiguration instance
+func LoadDeleteConfig(data []byte) (c v1alpha2.DeleteImageSetConfiguration, err error) {
+
+	gvk := v1alpha2.GroupVersion.WithKind(v1alpha2.DeleteImageSetConfigurationKind)
+
+	if data, err = yaml.YAMLToJSON(data); err != nil {
+		return c, fmt.Errorf("yaml to json %s: %v", gvk, err)
+	}
+
+	dec := json.NewDecoder(bytes.NewBuffer(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		return c, fmt.Errorf("decode %s: %v", gvk, err)
+	}
+
+	c.SetGroupVersionKind(gvk)
+
+	return c, nil
+}
+
 // LoadMetadata loads data into a v1alpha2.Metadata instance
 func LoadMetadata(data []byte) (m v1alpha2.Metadata, err error) {
 
@@ -257,3 +257,23 @@ func TestReadConfig(t *testing.T) {
 		require.Equal(t, []string{"amd64"}, res.ImageSetConfigurationSpec.Mirror.Platform.Architectures)
 	})
 }
+
+func TestReadDeleteConfig(t *testing.T) {
+	t.Run("Testing ReadDeleteConfig : should pass ", func(t *testing.T) {
+		res, err := ReadDeleteConfig("../../tests/delete-isc.yaml")
+		if err != nil {
+			t.Fatalf("should not fail")
+		}
+		require.Equal(t, []string{"amd64"}, res.Delete.Platform.Architectures)
+		require.Equal(t, "registry.redhat.io/ubi8/ubi:latest", res.Delete.AdditionalImages[0].Name)
+
+		isc := res.ToImageSetConfiguration()
+		require.Equal(t, res.Delete.Operators, isc.Mirror.Operators)
+	})
+	t.Run("Testing ReadDeleteConfig : should fail with an ImageSetConfiguration ", func(t *testing.T) {
+		_, err := ReadDeleteConfig("../../tests/isc.yaml")
+		if err == nil {
+			t.Fatalf("should fail")
+		}
+	})
+}
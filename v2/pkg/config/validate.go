@@ -2,15 +2,18 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 
 	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/v2/pkg/blocked"
+	"github.com/openshift/oc-mirror/v2/pkg/rewrite"
 )
 
 type validationFunc func(cfg *v1alpha2.ImageSetConfiguration) error
 
-var validationChecks = []validationFunc{validateOperatorOptions, validateReleaseChannels}
+var validationChecks = []validationFunc{validateOperatorOptions, validateReleaseChannels, validateReleaseSignatures, validateBlockedImages, validateDestinationRewrites}
 
 // Validate will check an ImagesetConfiguration for input errors.
 func Validate(cfg *v1alpha2.ImageSetConfiguration) error {
@@ -52,3 +55,37 @@ func validateReleaseChannels(cfg *v1alpha2.ImageSetConfiguration) error {
 	}
 	return nil
 }
+
+func validateReleaseSignatures(cfg *v1alpha2.ImageSetConfiguration) error {
+	for _, source := range cfg.Mirror.Platform.Signatures.Sources {
+		if source == "" {
+			return fmt.Errorf("release signatures: empty source found in configuration")
+		}
+		u, err := url.Parse(source)
+		if err != nil {
+			return fmt.Errorf("release signatures source %q: %v", source, err)
+		}
+		switch u.Scheme {
+		case "", "file", "http", "https":
+		default:
+			return fmt.Errorf(
+				"release signatures source %q: unsupported scheme %q, use http(s), file or a directory", source, u.Scheme,
+			)
+		}
+	}
+	for _, keyring := range cfg.Mirror.Platform.Signatures.Keyrings {
+		if keyring == "" {
+			return fmt.Errorf("release signatures: empty keyring found in configuration")
+		}
+	}
+	return nil
+}
+
+func validateBlockedImages(cfg *v1alpha2.ImageSetConfiguration) error {
+	_, err := blocked.New(cfg.Mirror.BlockedImages)
+	return err
+}
+
+func validateDestinationRewrites(cfg *v1alpha2.ImageSetConfiguration) error {
+	return rewrite.Validate(cfg.Mirror.DestinationRewrites)
+}
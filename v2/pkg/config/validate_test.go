@@ -131,6 +131,81 @@ func TestValidate(t *testing.T) {
 			},
 			expError: "invalid configuration: release channel \"channel\": duplicate found in configuration",
 		},
+		{
+			name: "Valid/ReleaseSignatures",
+			config: &v1alpha2.ImageSetConfiguration{
+				ImageSetConfigurationSpec: v1alpha2.ImageSetConfigurationSpec{
+					Mirror: v1alpha2.Mirror{
+						Platform: v1alpha2.Platform{
+							Signatures: v1alpha2.ReleaseSignatures{
+								Sources:  []string{"https://mirror.example.com/signatures", "file:///var/signatures", "/var/signatures"},
+								Keyrings: []string{"/etc/keys/release.gpg"},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "Invalid/ReleaseSignaturesSource",
+			config: &v1alpha2.ImageSetConfiguration{
+				ImageSetConfigurationSpec: v1alpha2.ImageSetConfigurationSpec{
+					Mirror: v1alpha2.Mirror{
+						Platform: v1alpha2.Platform{
+							Signatures: v1alpha2.ReleaseSignatures{
+								Sources: []string{"ftp://mirror.example.com/signatures"},
+							},
+						},
+					},
+				},
+			},
+			expError: "invalid configuration: release signatures source \"ftp://mirror.example.com/signatures\": unsupported scheme \"ftp\", use http(s), file or a directory",
+		},
+		{
+			name: "Valid/BlockedImages",
+			config: &v1alpha2.ImageSetConfiguration{
+				ImageSetConfigurationSpec: v1alpha2.ImageSetConfigurationSpec{
+					Mirror: v1alpha2.Mirror{
+						BlockedImages: []v1alpha2.Image{{Name: "quay.io/a/b"}, {Name: "registry.example.com/legacy/*"}, {Name: `regex:^quay\.io/.*/debug`}},
+					},
+				},
+			},
+		},
+		{
+			name: "Invalid/BlockedImagesRegex",
+			config: &v1alpha2.ImageSetConfiguration{
+				ImageSetConfigurationSpec: v1alpha2.ImageSetConfigurationSpec{
+					Mirror: v1alpha2.Mirror{
+						BlockedImages: []v1alpha2.Image{{Name: "regex:quay.io/(a"}},
+					},
+				},
+			},
+			expError: "invalid configuration: blocked image \"regex:quay.io/(a\": invalid regular expression: error parsing regexp: missing closing ): `quay.io/(a`",
+		},
+		{
+			name: "Valid/DestinationRewrites",
+			config: &v1alpha2.ImageSetConfiguration{
+				ImageSetConfigurationSpec: v1alpha2.ImageSetConfigurationSpec{
+					Mirror: v1alpha2.Mirror{
+						DestinationRewrites: []v1alpha2.DestinationRewrite{
+							{Source: "quay.io/openshift-community-operators/*", Destination: "community/*", Flatten: true},
+							{Source: "quay.io/a/b", Destination: "c/d"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "Invalid/DestinationRewritesWildcard",
+			config: &v1alpha2.ImageSetConfiguration{
+				ImageSetConfigurationSpec: v1alpha2.ImageSetConfigurationSpec{
+					Mirror: v1alpha2.Mirror{
+						DestinationRewrites: []v1alpha2.DestinationRewrite{{Source: "quay.io/a/b", Destination: "c/*"}},
+					},
+				},
+			},
+			expError: "invalid configuration: destination rewrite quay.io/a/b -> c/*: the destination can only have a * when the source ends with /*",
+		},
 	}
 
 	for _, c := range cases {
@@ -0,0 +1,11 @@
+package deleteimages
+
+const (
+	deleteDir        string = "delete"
+	deletePlanFile   string = "delete-images.yaml"
+	deleteLogsDir    string = "logs"
+	deleteLogFile    string = "delete.log"
+	deleteListKind   string = "DeleteImageList"
+	deleteAPIVersion string = "mirror.openshift.io/v1alpha2"
+	manifestUnknown  string = "manifest unknown"
+)
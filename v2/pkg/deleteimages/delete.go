@@ -0,0 +1,141 @@
+package deleteimages
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
+	clog "github.com/openshift/oc-mirror/v2/pkg/log"
+	"github.com/openshift/oc-mirror/v2/pkg/mirror"
+)
+
+func New(log clog.PluggableLoggerInterface,
+	opts mirror.CopyOptions,
+	mirror mirror.MirrorInterface,
+) DeleteInterface {
+	return &DeleteImages{Log: log, Opts: opts, Mirror: mirror}
+}
+
+type DeleteImages struct {
+	Log    clog.PluggableLoggerInterface
+	Opts   mirror.CopyOptions
+	Mirror mirror.MirrorInterface
+}
+
+// WriteDeletePlan - writes the list of images to delete from the destination
+// registry to the working-dir, so that it can be reviewed before
+// the deletion is confirmed with --force-delete
+func (o DeleteImages) WriteDeletePlan(images []v1alpha3.CopyImageSchema) (string, error) {
+	plan := v1alpha3.DeleteImageList{
+		Kind:       deleteListKind,
+		APIVersion: deleteAPIVersion,
+		Items:      []v1alpha3.DeleteItem{},
+	}
+	seen := map[string]bool{}
+	for _, img := range images {
+		// images built by oc-mirror (i.e the graph image) only exist in the local storage
+		if !strings.HasPrefix(img.Destination, o.Opts.Destination) {
+			o.Log.Debug("skipping %s, not in the destination registry", img.Destination)
+			continue
+		}
+		if seen[img.Destination] {
+			continue
+		}
+		seen[img.Destination] = true
+		plan.Items = append(plan.Items, v1alpha3.DeleteItem{ImageName: img.Origin, ImageReference: img.Destination})
+	}
+
+	bytes, err := yaml.Marshal(plan)
+	if err != nil {
+		return "", err
+	}
+
+	planDir := filepath.Join(o.Opts.Global.WorkingDir, deleteDir)
+	err = os.MkdirAll(planDir, 0755)
+	if err != nil {
+		return "", err
+	}
+	planFile := filepath.Join(planDir, deletePlanFile)
+	err = os.WriteFile(planFile, bytes, 0644)
+	if err != nil {
+		return "", err
+	}
+	return planFile, nil
+}
+
+// ReadDeletePlan - reads the delete plan previously written to the working-dir
+func (o DeleteImages) ReadDeletePlan() (v1alpha3.DeleteImageList, error) {
+	var plan v1alpha3.DeleteImageList
+	planFile := filepath.Join(o.Opts.Global.WorkingDir, deleteDir, deletePlanFile)
+	bytes, err := os.ReadFile(planFile)
+	if err != nil {
+		return plan, fmt.Errorf("unable to read the delete plan %s: %v", planFile, err)
+	}
+	err = yaml.Unmarshal(bytes, &plan)
+	if err != nil {
+		return plan, fmt.Errorf("unable to parse the delete plan %s: %v", planFile, err)
+	}
+	if plan.Kind != deleteListKind {
+		return plan, fmt.Errorf("delete plan %s: kind %q not recognized", planFile, plan.Kind)
+	}
+	return plan, nil
+}
+
+// DeleteRegistryImages - deletes all the images of the delete plan from the
+// destination registry. Images that are already gone are only reported,
+// all other errors are collected and returned once every image was processed
+func (o DeleteImages) DeleteRegistryImages(ctx context.Context, plan v1alpha3.DeleteImageList) error {
+	var errArray []error
+
+	logsDir := filepath.Join(o.Opts.Global.WorkingDir, deleteLogsDir)
+	if err := os.MkdirAll(logsDir, 0755); err != nil {
+		o.Log.Error("[DeleteRegistryImages] %v", err)
+		return err
+	}
+	f, err := os.Create(filepath.Join(logsDir, deleteLogFile))
+	if err != nil {
+		o.Log.Error("[DeleteRegistryImages] %v", err)
+		return err
+	}
+	defer f.Close()
+	writer := bufio.NewWriter(f)
+	defer writer.Flush()
+
+	for _, item := range plan.Items {
+		if !strings.HasPrefix(item.ImageReference, o.Opts.Destination) {
+			return fmt.Errorf("image %s in the delete plan is not in the destination registry %s, regenerate the delete plan", item.ImageReference, o.Opts.Destination)
+		}
+	}
+
+	for _, item := range plan.Items {
+		o.Log.Debug("deleting %s", item.ImageReference)
+		err := o.Mirror.Run(ctx, item.ImageReference, "", mirror.DeleteMode, &o.Opts, *writer)
+		if err != nil {
+			if strings.Contains(err.Error(), manifestUnknown) {
+				o.Log.Warn("image %s already deleted from the destination registry", item.ImageReference)
+				continue
+			}
+			o.Log.Error("[DeleteRegistryImages] unable to delete %s: %v", item.ImageReference, err)
+			fmt.Fprintf(writer, "failed %s: %v\n", item.ImageReference, err)
+			errArray = append(errArray, fmt.Errorf("%s: %v", item.ImageReference, err))
+			continue
+		}
+		fmt.Fprintf(writer, "deleted %s\n", item.ImageReference)
+	}
+
+	if len(errArray) > 0 {
+		failed := make([]string, 0, len(errArray))
+		for _, err := range errArray {
+			failed = append(failed, err.Error())
+		}
+		return fmt.Errorf("[DeleteRegistryImages] %d of %d images could not be deleted: %s", len(errArray), len(plan.Items), strings.Join(failed, "; "))
+	}
+	o.Log.Info("deleted %d images from %s", len(plan.Items), o.Opts.Destination)
+	return nil
+}
@@ -0,0 +1,157 @@
+package deleteimages
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
+	clog "github.com/openshift/oc-mirror/v2/pkg/log"
+	"github.com/openshift/oc-mirror/v2/pkg/mirror"
+)
+
+func TestDeleteImages(t *testing.T) {
+	log := clog.New("trace")
+
+	global := &mirror.GlobalOptions{
+		TlsVerify:    false,
+		SecurePolicy: false,
+		WorkingDir:   t.TempDir(),
+	}
+	_, sharedOpts := mirror.SharedImageFlags()
+	_, deprecatedTLSVerifyOpt := mirror.DeprecatedTLSVerifyFlags()
+	_, srcOpts := mirror.ImageSrcFlags(global, sharedOpts, deprecatedTLSVerifyOpt, "src-", "screds")
+	_, destOpts := mirror.ImageDestFlags(global, sharedOpts, deprecatedTLSVerifyOpt, "dest-", "dcreds")
+	_, retryOpts := mirror.RetryFlags()
+
+	opts := mirror.CopyOptions{
+		Global:              global,
+		DeprecatedTLSVerify: deprecatedTLSVerifyOpt,
+		SrcImage:            srcOpts,
+		DestImage:           destOpts,
+		RetryOpts:           retryOpts,
+		Destination:         "docker://myregistry:5000/test",
+		Mode:                mirror.MirrorToMirror,
+	}
+
+	images := []v1alpha3.CopyImageSchema{
+		{Origin: "quay.io/name/sometestimage-a@sha256:f30638f60452062aba36a26ee6c036feead2f03b28f2c47f2b0a991e41baebea", Source: "docker://quay.io/name/sometestimage-a@sha256:f30638f60452062aba36a26ee6c036feead2f03b28f2c47f2b0a991e41baebea", Destination: "docker://myregistry:5000/test/name/sometestimage-a@sha256:f30638f60452062aba36a26ee6c036feead2f03b28f2c47f2b0a991e41baebea"},
+		{Origin: "quay.io/name/sometestimage-a@sha256:f30638f60452062aba36a26ee6c036feead2f03b28f2c47f2b0a991e41baebea", Source: "docker://quay.io/name/sometestimage-a@sha256:f30638f60452062aba36a26ee6c036feead2f03b28f2c47f2b0a991e41baebea", Destination: "docker://myregistry:5000/test/name/sometestimage-a@sha256:f30638f60452062aba36a26ee6c036feead2f03b28f2c47f2b0a991e41baebea"},
+		{Origin: "quay.io/name/sometestimage-b:v0.0.1", Source: "docker://quay.io/name/sometestimage-b:v0.0.1", Destination: "docker://myregistry:5000/test/name/sometestimage-b:v0.0.1"},
+		{Origin: "docker://localhost:9999/openshift/graph-image:latest", Source: "docker://localhost:9999/openshift/graph-image:latest", Destination: "docker://localhost:9999/openshift/graph-image:latest"},
+	}
+
+	t.Run("Testing WriteDeletePlan/ReadDeletePlan : should pass", func(t *testing.T) {
+		ex := New(log, opts, &mockMirror{})
+		planFile, err := ex.WriteDeletePlan(images)
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		log.Debug("delete plan %s", planFile)
+
+		plan, err := ex.ReadDeletePlan()
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		if len(plan.Items) != 2 {
+			t.Fatalf("should contain 2 images (duplicates and local storage images removed), got %d", len(plan.Items))
+		}
+		if plan.Items[0].ImageName != images[0].Origin {
+			t.Fatalf("image name should be the origin %s, got %s", images[0].Origin, plan.Items[0].ImageName)
+		}
+	})
+
+	t.Run("Testing DeleteRegistryImages : should pass", func(t *testing.T) {
+		mm := &mockMirror{}
+		ex := New(log, opts, mm)
+		plan, err := ex.ReadDeletePlan()
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		err = ex.DeleteRegistryImages(context.Background(), plan)
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		if len(mm.deleted) != 2 {
+			t.Fatalf("should delete 2 images, got %d", len(mm.deleted))
+		}
+		if _, err := os.Stat(filepath.Join(global.WorkingDir, deleteLogsDir, deleteLogFile)); err != nil {
+			t.Fatalf("the delete log should be written in the working-dir: %v", err)
+		}
+	})
+
+	t.Run("Testing DeleteRegistryImages : should pass (manifest unknown)", func(t *testing.T) {
+		ex := New(log, opts, &mockMirror{Err: fmt.Errorf("manifest unknown: manifest unknown")})
+		plan, _ := ex.ReadDeletePlan()
+		err := ex.DeleteRegistryImages(context.Background(), plan)
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+	})
+
+	t.Run("Testing DeleteRegistryImages : should fail", func(t *testing.T) {
+		ex := New(log, opts, &mockMirror{Err: fmt.Errorf("forced error")})
+		plan, _ := ex.ReadDeletePlan()
+		err := ex.DeleteRegistryImages(context.Background(), plan)
+		if err == nil {
+			t.Fatalf("should fail")
+		}
+		// the images that could not be deleted are in the error
+		for _, item := range plan.Items {
+			if !strings.Contains(err.Error(), item.ImageReference+": forced error") {
+				t.Fatalf("%s should be reported in %v", item.ImageReference, err)
+			}
+		}
+	})
+
+	t.Run("Testing DeleteRegistryImages : should fail (other destination)", func(t *testing.T) {
+		mm := &mockMirror{}
+		otherOpts := opts
+		otherOpts.Destination = "docker://otherregistry:5000/test"
+		ex := New(log, otherOpts, mm)
+		plan, _ := ex.ReadDeletePlan()
+		err := ex.DeleteRegistryImages(context.Background(), plan)
+		if err == nil {
+			t.Fatalf("should fail")
+		}
+		if len(mm.deleted) != 0 {
+			t.Fatalf("should not delete any image")
+		}
+	})
+
+	t.Run("Testing ReadDeletePlan : should fail (no plan)", func(t *testing.T) {
+		emptyOpts := opts
+		emptyOpts.Global = &mirror.GlobalOptions{WorkingDir: t.TempDir()}
+		ex := New(log, emptyOpts, &mockMirror{})
+		_, err := ex.ReadDeletePlan()
+		if err == nil {
+			t.Fatalf("should fail")
+		}
+	})
+}
+
+// mock
+
+type mockMirror struct {
+	Err     error
+	deleted []string
+}
+
+func (o *mockMirror) Run(ctx context.Context, src, dest string, mode mirror.Mode, opts *mirror.CopyOptions, stdout bufio.Writer) error {
+	if mode != mirror.DeleteMode {
+		return fmt.Errorf("unexpected mode %s", mode)
+	}
+	if o.Err != nil {
+		return o.Err
+	}
+	o.deleted = append(o.deleted, src)
+	return nil
+}
+
+func (o *mockMirror) Check(ctx context.Context, image string, opts *mirror.CopyOptions) (bool, error) {
+	return true, nil
+}
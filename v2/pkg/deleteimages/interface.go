@@ -0,0 +1,13 @@
+package deleteimages
+
+import (
+	"context"
+
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
+)
+
+type DeleteInterface interface {
+	WriteDeletePlan(images []v1alpha3.CopyImageSchema) (string, error)
+	ReadDeletePlan() (v1alpha3.DeleteImageList, error)
+	DeleteRegistryImages(ctx context.Context, plan v1alpha3.DeleteImageList) error
+}
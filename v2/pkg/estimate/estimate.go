@@ -0,0 +1,258 @@
+package estimate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
+	"github.com/openshift/oc-mirror/v2/pkg/archive"
+	"github.com/openshift/oc-mirror/v2/pkg/history"
+	clog "github.com/openshift/oc-mirror/v2/pkg/log"
+)
+
+const (
+	jsonEstimateFile = "size-estimate.json"
+	yamlEstimateFile = "size-estimate.yaml"
+
+	// the kinds of the groups of the breakdown
+	KindRelease    = "release"
+	KindCatalog    = "catalog"
+	KindPackage    = "package"
+	KindAdditional = "additional"
+	KindHelm       = "helm"
+
+	// tarBlockSize - the size of a tar header, the contents being padded to it
+	tarBlockSize int64 = 512
+)
+
+// the order of the kinds in the breakdown
+var kindOrder = []string{KindRelease, KindCatalog, KindPackage, KindAdditional, KindHelm}
+
+// GroupEstimate - the size of the images of a release, a catalog, a package or an image.
+// The blobs shared by several groups are counted in each of them
+type GroupEstimate struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Images int    `json:"images"`
+	// Bytes: the size of the distinct blobs of the group
+	Bytes int64 `json:"bytes"`
+	// NewBytes: the size of the distinct blobs of the group that are not in the history
+	NewBytes int64 `json:"newBytes"`
+}
+
+// Estimate - the size of an image set, computed from the manifests of its images only
+type Estimate struct {
+	Images int `json:"images"`
+	Blobs  int `json:"blobs"`
+	// Bytes: the size of the distinct blobs of all the images
+	Bytes int64 `json:"bytes"`
+	// NewBlobs, NewBytes: the distinct blobs that are not in the history of the previous mirrorToDisk runs
+	NewBlobs int   `json:"newBlobs"`
+	NewBytes int64 `json:"newBytes"`
+	// ArchiveBytes: the size of the new blobs in the (uncompressed) archive,
+	// without the working-dir and the metadata of the cache
+	ArchiveBytes int64 `json:"archiveBytes"`
+	// Unknown: the images whose manifests couldn't be read, not counted in the sizes
+	Unknown   []string        `json:"unknown,omitempty"`
+	Breakdown []GroupEstimate `json:"breakdown"`
+}
+
+type EstimatorInterface interface {
+	Estimate(ctx context.Context, images []v1alpha3.CopyImageSchema) (Estimate, error)
+}
+
+type Estimator struct {
+	Log      clog.PluggableLoggerInterface
+	Gatherer archive.BlobSizesGatherer
+	History  history.History
+	Parallel uint
+}
+
+// New - the estimator of the size of the images, reading their manifests
+// with parallel requests at most. The blobs of history are not new
+func New(log clog.PluggableLoggerInterface, gatherer archive.BlobSizesGatherer, hist history.History, parallel uint) EstimatorInterface {
+	if parallel == 0 {
+		parallel = 1
+	}
+	return &Estimator{Log: log, Gatherer: gatherer, History: hist, Parallel: parallel}
+}
+
+// Estimate - fetches the manifests of the images (their sources) and adds up the sizes
+// of their distinct blobs. The images whose manifests can't be read are reported as unknown
+func (o *Estimator) Estimate(ctx context.Context, images []v1alpha3.CopyImageSchema) (Estimate, error) {
+	historyBlobs, err := o.History.Read()
+	if err != nil && !errors.Is(err, &history.EmptyHistoryError{}) {
+		return Estimate{}, fmt.Errorf("unable to read history metadata from working-dir : %v", err)
+	}
+
+	imageBlobs := o.gather(ctx, images)
+
+	est := Estimate{Images: len(images)}
+	allBlobs := map[string]int64{}
+	groups := map[string]*groupBlobs{}
+	for i, img := range images {
+		blobs, ok := imageBlobs[i]
+		if !ok {
+			est.Unknown = append(est.Unknown, img.Source)
+			continue
+		}
+		for digest, size := range blobs {
+			allBlobs[digest] = size
+		}
+		for _, g := range groupsOf(img) {
+			key := g.Kind + "/" + g.Name
+			if groups[key] == nil {
+				groups[key] = &groupBlobs{GroupEstimate: g, blobs: map[string]int64{}}
+			}
+			groups[key].Images++
+			for digest, size := range blobs {
+				groups[key].blobs[digest] = size
+			}
+		}
+	}
+
+	for digest, size := range allBlobs {
+		size = max(size, 0)
+		est.Blobs++
+		est.Bytes += size
+		if _, ok := historyBlobs[digest]; ok {
+			continue
+		}
+		est.NewBlobs++
+		est.NewBytes += size
+		est.ArchiveBytes += tarBlockSize + (size+tarBlockSize-1)/tarBlockSize*tarBlockSize
+	}
+	if est.NewBlobs > 0 {
+		// the end of archive marker
+		est.ArchiveBytes += 2 * tarBlockSize
+	}
+
+	for _, g := range groups {
+		for digest, size := range g.blobs {
+			size = max(size, 0)
+			g.Bytes += size
+			if _, ok := historyBlobs[digest]; !ok {
+				g.NewBytes += size
+			}
+		}
+		est.Breakdown = append(est.Breakdown, g.GroupEstimate)
+	}
+	sort.Slice(est.Breakdown, func(i, j int) bool {
+		ki, kj := kindIndex(est.Breakdown[i].Kind), kindIndex(est.Breakdown[j].Kind)
+		if ki != kj {
+			return ki < kj
+		}
+		return est.Breakdown[i].Name < est.Breakdown[j].Name
+	})
+	return est, nil
+}
+
+// gather - the blobs of the images, by index in images, fetching the manifests in parallel
+func (o *Estimator) gather(ctx context.Context, images []v1alpha3.CopyImageSchema) map[int]map[string]int64 {
+	result := map[int]map[string]int64{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, o.Parallel)
+	for i, img := range images {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, img v1alpha3.CopyImageSchema) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			blobs, err := o.Gatherer.GatherBlobSizes(ctx, img.Source)
+			if err != nil {
+				o.Log.Warn("unable to read the manifests of %s, its size is unknown: %v", img.Source, err)
+				return
+			}
+			o.Log.Debug("%s: %d blobs", img.Source, len(blobs))
+			mu.Lock()
+			result[i] = blobs
+			mu.Unlock()
+		}(i, img)
+	}
+	wg.Wait()
+	return result
+}
+
+// Write - saves the estimate in dir, in json and in yaml,
+// and returns the paths of the files written
+func (e Estimate) Write(dir string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	jsonData, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal the size estimate: %v", err)
+	}
+	yamlData, err := yaml.JSONToYAML(jsonData)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal the size estimate: %v", err)
+	}
+	files := []string{filepath.Join(dir, jsonEstimateFile), filepath.Join(dir, yamlEstimateFile)}
+	for i, data := range [][]byte{jsonData, yamlData} {
+		if err := os.WriteFile(files[i], data, 0644); err != nil {
+			return nil, fmt.Errorf("unable to write the size estimate %s: %v", files[i], err)
+		}
+	}
+	return files, nil
+}
+
+// groupBlobs - the distinct blobs of a group of the breakdown
+type groupBlobs struct {
+	GroupEstimate
+	blobs map[string]int64
+}
+
+// groupsOf - the groups of the breakdown the image is counted in:
+// its release, its catalog and its package, or the image itself
+func groupsOf(img v1alpha3.CopyImageSchema) []GroupEstimate {
+	name := img.Origin
+	if name == "" {
+		name = img.Source
+	}
+	switch img.Type {
+	case v1alpha3.TypeRelease:
+		if img.Parent != "" {
+			return []GroupEstimate{{Kind: KindRelease, Name: img.Parent}}
+		}
+		return []GroupEstimate{{Kind: KindRelease, Name: name}}
+	case v1alpha3.TypeOperator:
+		catalog := img.Parent
+		if catalog == "" {
+			catalog = "unknown"
+		}
+		groups := []GroupEstimate{{Kind: KindCatalog, Name: catalog}}
+		if img.Package != "" {
+			groups = append(groups, GroupEstimate{Kind: KindPackage, Name: catalog + "/" + img.Package})
+		}
+		return groups
+	case v1alpha3.TypeHelm:
+		return []GroupEstimate{{Kind: KindHelm, Name: name}}
+	default:
+		return []GroupEstimate{{Kind: KindAdditional, Name: name}}
+	}
+}
+
+func kindIndex(kind string) int {
+	for i, k := range kindOrder {
+		if k == kind {
+			return i
+		}
+	}
+	return len(kindOrder)
+}
+
+func max(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
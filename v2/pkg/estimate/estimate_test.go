@@ -0,0 +1,96 @@
+package estimate
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
+	clog "github.com/openshift/oc-mirror/v2/pkg/log"
+)
+
+func TestEstimate(t *testing.T) {
+	log := clog.New("trace")
+	gatherer := mockGatherer{
+		"docker://quay.io/release/component-a:v1": {"sha256:shared": 1000, "sha256:a": 100},
+		"docker://quay.io/release/component-b:v1": {"sha256:shared": 1000, "sha256:b": 200},
+		"docker://quay.io/operator/bundle:v1":     {"sha256:old": 300, "sha256:bundle": 10},
+		"docker://quay.io/operator/controller:v1": {"sha256:shared": 1000, "sha256:controller": 400},
+		"docker://quay.io/additional/tools:v1":    {"sha256:tools": 600},
+	}
+	images := []v1alpha3.CopyImageSchema{
+		{Source: "docker://quay.io/release/component-a:v1", Type: v1alpha3.TypeRelease, Parent: "quay.io/release:4.14"},
+		{Source: "docker://quay.io/release/component-b:v1", Type: v1alpha3.TypeRelease, Parent: "quay.io/release:4.14"},
+		{Source: "docker://quay.io/operator/bundle:v1", Type: v1alpha3.TypeOperator, Parent: "quay.io/catalog:v4.14", Package: "foo"},
+		{Source: "docker://quay.io/operator/controller:v1", Type: v1alpha3.TypeOperator, Parent: "quay.io/catalog:v4.14", Package: "foo"},
+		{Origin: "quay.io/additional/tools:v1", Source: "docker://quay.io/additional/tools:v1", Type: v1alpha3.TypeAdditional},
+		{Origin: "quay.io/additional/missing:v1", Source: "docker://quay.io/additional/missing:v1", Type: v1alpha3.TypeAdditional},
+	}
+	estimator := New(log, gatherer, mockHistory{"sha256:old": ""}, 2)
+
+	t.Run("Testing Estimate : should pass", func(t *testing.T) {
+		est, err := estimator.Estimate(context.Background(), images)
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		if est.Images != 6 || est.Blobs != 7 || est.Bytes != 2610 || est.NewBlobs != 6 || est.NewBytes != 2310 {
+			t.Fatalf("unexpected totals %+v", est)
+		}
+		// 6 headers, the blobs padded to 512 bytes, and the end of archive
+		if est.ArchiveBytes != 6*512+(2+1+1+1+1+2)*512+2*512 {
+			t.Fatalf("unexpected archive size %d", est.ArchiveBytes)
+		}
+		if !reflect.DeepEqual(est.Unknown, []string{"docker://quay.io/additional/missing:v1"}) {
+			t.Fatalf("unexpected unknown images %v", est.Unknown)
+		}
+		expected := []GroupEstimate{
+			{Kind: KindRelease, Name: "quay.io/release:4.14", Images: 2, Bytes: 1300, NewBytes: 1300},
+			{Kind: KindCatalog, Name: "quay.io/catalog:v4.14", Images: 2, Bytes: 1710, NewBytes: 1410},
+			{Kind: KindPackage, Name: "quay.io/catalog:v4.14/foo", Images: 2, Bytes: 1710, NewBytes: 1410},
+			{Kind: KindAdditional, Name: "quay.io/additional/tools:v1", Images: 1, Bytes: 600, NewBytes: 600},
+		}
+		if !reflect.DeepEqual(est.Breakdown, expected) {
+			t.Fatalf("unexpected breakdown %+v", est.Breakdown)
+		}
+	})
+
+	t.Run("Testing Estimate Write : should pass", func(t *testing.T) {
+		est, err := estimator.Estimate(context.Background(), images)
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		dir := t.TempDir()
+		files, err := est.Write(dir)
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		for _, file := range []string{jsonEstimateFile, yamlEstimateFile} {
+			if _, err := os.Stat(filepath.Join(dir, file)); err != nil || len(files) != 2 {
+				t.Fatalf("%s should be written: %v", file, err)
+			}
+		}
+	})
+}
+
+type mockGatherer map[string]map[string]int64
+
+func (o mockGatherer) GatherBlobSizes(ctx context.Context, imgRef string) (map[string]int64, error) {
+	blobs, ok := o[imgRef]
+	if !ok {
+		return nil, fmt.Errorf("manifest unknown")
+	}
+	return blobs, nil
+}
+
+type mockHistory map[string]string
+
+func (o mockHistory) Read() (map[string]string, error) {
+	return o, nil
+}
+
+func (o mockHistory) Append(blobs map[string]string) (map[string]string, error) {
+	return blobs, nil
+}
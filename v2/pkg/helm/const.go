@@ -0,0 +1,16 @@
+package helm
+
+const (
+	dockerProtocol  string = "docker://"
+	hashTruncLen    int    = 12
+	helmDir         string = "helm"
+	helmChartsDir   string = "charts"
+	helmLocalDir    string = "local"
+	helmReposFile   string = "repositories.yaml"
+	helmCacheDir    string = "repository"
+	chartFile       string = "Chart.yaml"
+	valuesFile      string = "values.yaml"
+	releaseName     string = "NAME"
+	releaseNS       string = "RELEASE-NAMESPACE"
+	chartArchiveExt string = ".tgz"
+)
@@ -0,0 +1,102 @@
+package helm
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"k8s.io/client-go/util/jsonpath"
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha2"
+	clog "github.com/openshift/oc-mirror/v2/pkg/log"
+)
+
+// findImages - renders the chart found at path and
+// returns the images referenced in its manifests
+func findImages(log clog.PluggableLoggerInterface, path string, imagePaths ...string) ([]v1alpha2.Image, error) {
+	log.Debug("reading chart from path %s", path)
+
+	c, err := loader.Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	manifests, err := render(c)
+	if err != nil {
+		return nil, err
+	}
+
+	p := getImagesPath(imagePaths...)
+	seen := map[string]bool{}
+	var images []v1alpha2.Image
+	for _, single := range manifests {
+		imgs, err := search([]byte(single), p...)
+		if err != nil {
+			return nil, fmt.Errorf("unable to search images in chart %s: %v", c.Name(), err)
+		}
+		for _, img := range imgs {
+			if !seen[img.Name] {
+				seen[img.Name] = true
+				images = append(images, img)
+			}
+		}
+	}
+	return images, nil
+}
+
+// getImagesPath returns known jsonpaths and user defined
+// json paths where images are found
+func getImagesPath(paths ...string) []string {
+	pathlist := []string{
+		"{.spec.template.spec.initContainers[*].image}",
+		"{.spec.template.spec.containers[*].image}",
+		"{.spec.jobTemplate.spec.template.spec.initContainers[*].image}",
+		"{.spec.jobTemplate.spec.template.spec.containers[*].image}",
+		"{.spec.initContainers[*].image}",
+		"{.spec.containers[*].image}",
+	}
+	return append(pathlist, paths...)
+}
+
+// parseJSONPath will parse data and filter for a provided jsonpath template
+func parseJSONPath(input interface{}, parser *jsonpath.JSONPath, template string) ([]string, error) {
+	buf := new(bytes.Buffer)
+	if err := parser.Parse(template); err != nil {
+		return nil, err
+	}
+	if err := parser.Execute(buf, input); err != nil {
+		return nil, err
+	}
+
+	f := func(s rune) bool { return s == ' ' }
+	r := strings.FieldsFunc(buf.String(), f)
+	return r, nil
+}
+
+// search will return images from parsed object
+func search(yamlData []byte, paths ...string) (images []v1alpha2.Image, err error) {
+
+	var data interface{}
+	// yaml.Unmarshal will convert YAMl to JSON first
+	if err := yaml.Unmarshal(yamlData, &data); err != nil {
+		return nil, err
+	}
+
+	j := jsonpath.New("")
+	j.AllowMissingKeys(true)
+
+	for _, path := range paths {
+		results, err := parseJSONPath(data, j, path)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, result := range results {
+			images = append(images, v1alpha2.Image{Name: result})
+		}
+	}
+
+	return images, nil
+}
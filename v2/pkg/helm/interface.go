@@ -0,0 +1,11 @@
+package helm
+
+import (
+	"context"
+
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
+)
+
+type CollectorInterface interface {
+	HelmImageCollector(ctx context.Context) ([]v1alpha3.CopyImageSchema, error)
+}
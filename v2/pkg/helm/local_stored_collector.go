@@ -0,0 +1,245 @@
+package helm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"helm.sh/helm/v3/pkg/chart/loader"
+
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
+	"github.com/openshift/oc-mirror/v2/pkg/image"
+	clog "github.com/openshift/oc-mirror/v2/pkg/log"
+	"github.com/openshift/oc-mirror/v2/pkg/mirror"
+)
+
+type LocalStorageCollector struct {
+	Log              clog.PluggableLoggerInterface
+	Config           v1alpha2.ImageSetConfiguration
+	Opts             mirror.CopyOptions
+	LocalStorageFQDN string
+}
+
+// HelmImageCollector - this looks into the helm charts of the helm field
+// taking into account the mode we are in (mirrorToDisk, diskToMirror, mirrorToMirror)
+// the charts are pulled (or copied) to the working-dir so that they are carried
+// in the archive, and the images referenced by their templates are collected
+func (o LocalStorageCollector) HelmImageCollector(ctx context.Context) ([]v1alpha3.CopyImageSchema, error) {
+
+	var allImages []v1alpha3.CopyImageSchema
+	chartsDir := filepath.Join(o.Opts.Global.WorkingDir, helmDir, helmChartsDir)
+
+	var puller *chartPuller
+	if !o.Opts.IsDiskToMirror() && len(o.Config.ImageSetConfigurationSpec.Mirror.Helm.Repositories) > 0 {
+		var err error
+		puller, err = o.newChartPuller()
+		if err != nil {
+			o.Log.Error("[HelmImageCollector] %v", err)
+			return nil, err
+		}
+		defer puller.close()
+	}
+
+	for _, repo := range o.Config.ImageSetConfigurationSpec.Mirror.Helm.Repositories {
+		if len(repo.Charts) == 0 {
+			continue
+		}
+		repoDir := filepath.Join(chartsDir, repo.Name)
+
+		if puller != nil {
+			if err := puller.addRepository(repo); err != nil {
+				o.Log.Error("[HelmImageCollector] %v", err)
+				return nil, err
+			}
+		}
+
+		for _, ch := range repo.Charts {
+			var chartPath string
+			var err error
+			if o.Opts.IsDiskToMirror() {
+				chartPath, err = findChartArchive(repoDir, ch)
+			} else {
+				o.Log.Debug("pulling chart %s/%s version %s", repo.Name, ch.Name, ch.Version)
+				chartPath, err = puller.pullChart(repo, ch, repoDir)
+			}
+			if err != nil {
+				o.Log.Error("[HelmImageCollector] repository %s: %v", repo.Name, err)
+				return nil, err
+			}
+
+			imgs, err := o.collectChartImages(chartPath, ch)
+			if err != nil {
+				return nil, err
+			}
+			allImages = append(allImages, imgs...)
+		}
+	}
+
+	for _, ch := range o.Config.ImageSetConfigurationSpec.Mirror.Helm.Local {
+		chartPath := ch.Path
+		localPath := localChartPath(chartsDir, ch)
+		if o.Opts.IsMirrorToDisk() {
+			// the chart is copied to the working-dir, to be found in the archive
+			if err := copyChart(ch.Path, localPath); err != nil {
+				o.Log.Error("[HelmImageCollector] unable to copy local chart %s: %v", ch.Name, err)
+				return nil, err
+			}
+		}
+		if o.Opts.IsDiskToMirror() {
+			chartPath = localPath
+		}
+
+		imgs, err := o.collectChartImages(chartPath, ch)
+		if err != nil {
+			return nil, err
+		}
+		allImages = append(allImages, imgs...)
+	}
+
+	return allImages, nil
+}
+
+// collectChartImages - finds the images of the chart and
+// sets the source and destination of each image according to the mode
+func (o LocalStorageCollector) collectChartImages(chartPath string, ch v1alpha2.Chart) ([]v1alpha3.CopyImageSchema, error) {
+	images, err := findImages(o.Log, chartPath, ch.ImagePaths...)
+	if err != nil {
+		o.Log.Error("[HelmImageCollector] chart %s: %v", ch.Name, err)
+		return nil, err
+	}
+
+	var result []v1alpha3.CopyImageSchema
+	for _, img := range images {
+		imgSpec, err := image.ParseRef(img.Name)
+		if err != nil {
+			o.Log.Error("[HelmImageCollector] chart %s: %v", ch.Name, err)
+			return nil, err
+		}
+
+		var tagOrDigest string
+		if imgSpec.IsImageByDigest() {
+			tagOrDigest = imgSpec.Digest[:hashTruncLen]
+		} else {
+			tagOrDigest = imgSpec.Tag
+		}
+		cacheRef := dockerProtocol + strings.Join([]string{o.LocalStorageFQDN, imgSpec.PathComponent}, "/") + ":" + tagOrDigest
+		destRef := strings.Join([]string{o.Opts.Destination, imgSpec.PathComponent}, "/") + ":" + tagOrDigest
+
+		var src string
+		var dest string
+		switch {
+		case o.Opts.IsMirrorToDisk() || o.Opts.IsPrepare():
+			src = imgSpec.ReferenceWithTransport
+			dest = cacheRef
+		case o.Opts.IsDiskToMirror():
+			src = cacheRef
+			dest = destRef
+		case o.Opts.IsMirrorToMirror():
+			src = imgSpec.ReferenceWithTransport
+			dest = destRef
+		}
+
+		if src == "" || dest == "" {
+			return result, fmt.Errorf("unable to determine src %s or dst %s for %s", src, dest, img.Name)
+		}
+
+		o.Log.Debug("source %s", src)
+		o.Log.Debug("destination %s", dest)
+		result = append(result, v1alpha3.CopyImageSchema{Origin: img.Name, Source: src, Destination: dest})
+	}
+	return result, nil
+}
+
+// findChartArchive - looks up, in the working-dir, the archive of a chart
+// pulled from a repository: the requested version or else the latest one
+func findChartArchive(repoDir string, ch v1alpha2.Chart) (string, error) {
+	entries, err := os.ReadDir(repoDir)
+	if err != nil {
+		return "", fmt.Errorf("chart %s not found in %s: %v", ch.Name, repoDir, err)
+	}
+
+	var found string
+	var foundVersion semver.Version
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), chartArchiveExt) {
+			continue
+		}
+		chartPath := filepath.Join(repoDir, entry.Name())
+		c, err := loader.Load(chartPath)
+		if err != nil || c.Name() != ch.Name {
+			continue
+		}
+		if ch.Version != "" {
+			if strings.TrimPrefix(c.Metadata.Version, "v") == strings.TrimPrefix(ch.Version, "v") {
+				return chartPath, nil
+			}
+			continue
+		}
+		v, err := semver.ParseTolerant(c.Metadata.Version)
+		if err != nil {
+			continue
+		}
+		if found == "" || v.GT(foundVersion) {
+			found = chartPath
+			foundVersion = v
+		}
+	}
+	if found == "" {
+		return "", fmt.Errorf("chart %s version %s not found in %s", ch.Name, ch.Version, repoDir)
+	}
+	return found, nil
+}
+
+// localChartPath - the path of the copy of a local chart in the working-dir,
+// the hash of the path of the chart keeps apart the charts with the same name
+func localChartPath(chartsDir string, ch v1alpha2.Chart) string {
+	sum := sha256.Sum256([]byte(filepath.Clean(ch.Path)))
+	return filepath.Join(chartsDir, helmLocalDir, ch.Name+"-"+hex.EncodeToString(sum[:])[:hashTruncLen])
+}
+
+// copyChart - copies a chart directory or chart archive to dest
+func copyChart(src, dest string) error {
+	fi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return copyFile(src, dest)
+	}
+	// start from a clean copy of the chart
+	if err := os.RemoveAll(dest); err != nil {
+		return err
+	}
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		return copyFile(p, target)
+	})
+}
+
+func copyFile(src, dest string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(dest, data, 0644)
+}
@@ -0,0 +1,468 @@
+package helm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/opencontainers/go-digest"
+
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
+	clog "github.com/openshift/oc-mirror/v2/pkg/log"
+	"github.com/openshift/oc-mirror/v2/pkg/mirror"
+)
+
+const testChartDir = "../../tests/helm-chart"
+
+func TestFindImages(t *testing.T) {
+	log := clog.New("trace")
+
+	t.Run("Testing findImages : should pass", func(t *testing.T) {
+		imgs, err := findImages(log, testChartDir, "{.spec.customImage}")
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		got := []string{}
+		for _, img := range imgs {
+			got = append(got, img.Name)
+		}
+		sort.Strings(got)
+		expected := []string{
+			"quay.io/test/app:1.16.0",
+			"quay.io/test/backup:v1.0.0",
+			"quay.io/test/custom:v2.0.0",
+			"quay.io/test/init@sha256:f30638f60452062aba36a26ee6c036feead2f03b28f2c47f2b0a991e41baebea",
+			"quay.io/test/sub:v0.2.0",
+		}
+		if fmt.Sprint(got) != fmt.Sprint(expected) {
+			t.Fatalf("expected %v, got %v", expected, got)
+		}
+	})
+
+	t.Run("Testing findImages (without image paths) : should pass", func(t *testing.T) {
+		imgs, err := findImages(log, testChartDir)
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		for _, img := range imgs {
+			if img.Name == "quay.io/test/custom:v2.0.0" {
+				t.Fatalf("custom image should only be found with its image path")
+			}
+		}
+		if len(imgs) != 4 {
+			t.Fatalf("expected 4 images, got %d", len(imgs))
+		}
+	})
+
+	t.Run("Testing findImages (disabled subchart) : should pass", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := copyChart(testChartDir, dir); err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		values := "image:\n  repository: quay.io/test/app\n  tag: v1\ninit:\n  image: quay.io/test/init:v1\nbackup:\n  enabled: false\ncustom:\n  image: quay.io/test/custom:v1\nsub:\n  enabled: false\n"
+		if err := os.WriteFile(filepath.Join(dir, valuesFile), []byte(values), 0644); err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		imgs, err := findImages(log, dir)
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		got := []string{}
+		for _, img := range imgs {
+			got = append(got, img.Name)
+		}
+		sort.Strings(got)
+		expected := []string{"quay.io/test/app:v1", "quay.io/test/init:v1"}
+		if fmt.Sprint(got) != fmt.Sprint(expected) {
+			t.Fatalf("expected %v, got %v", expected, got)
+		}
+	})
+
+	t.Run("Testing findImages : should fail", func(t *testing.T) {
+		_, err := findImages(log, "../../tests/does-not-exist")
+		if err == nil {
+			t.Fatalf("should fail")
+		}
+	})
+
+	t.Run("Testing findImages (template not rendered) : should fail", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := copyChart(testChartDir, dir); err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		tpl := "apiVersion: v1\nkind: Pod\nspec:\n  containers:\n    - image: {{ .Values.doesnotexist.image }}\n"
+		if err := os.WriteFile(filepath.Join(dir, "templates", "broken.yaml"), []byte(tpl), 0644); err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		_, err := findImages(log, dir)
+		if err == nil {
+			t.Fatalf("should fail")
+		}
+	})
+}
+
+func TestHelmImageCollector(t *testing.T) {
+	log := clog.New("trace")
+
+	archive := chartArchive(t, testChartDir, "test-chart")
+	sum := sha256.Sum256(archive)
+	index := fmt.Sprintf(`apiVersion: v1
+entries:
+  test-chart:
+  - name: test-chart
+    version: 0.0.9
+    digest: 0000000000000000000000000000000000000000000000000000000000000000
+    urls:
+    - charts/test-chart-0.0.9.tgz
+  - name: test-chart
+    version: 0.1.0
+    digest: %s
+    urls:
+    - charts/test-chart-0.1.0.tgz
+`, hex.EncodeToString(sum[:]))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/index.yaml":
+			w.Write([]byte(index))
+		case "/charts/test-chart-0.1.0.tgz", "/charts/test-chart-0.0.9.tgz":
+			w.Write(archive)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	workingDir := t.TempDir()
+	cfg := v1alpha2.ImageSetConfiguration{
+		ImageSetConfigurationSpec: v1alpha2.ImageSetConfigurationSpec{
+			Mirror: v1alpha2.Mirror{
+				Helm: v1alpha2.Helm{
+					Repositories: []v1alpha2.Repository{
+						{
+							Name:   "test-repo",
+							URL:    server.URL,
+							Charts: []v1alpha2.Chart{{Name: "test-chart"}},
+						},
+					},
+					Local: []v1alpha2.Chart{
+						{Name: "test-chart", Path: testChartDir, ImagePaths: []string{"{.spec.customImage}"}},
+					},
+				},
+			},
+		},
+	}
+
+	t.Run("Testing HelmImageCollector - Mirror to disk : should pass", func(t *testing.T) {
+		ex := newCollector(log, cfg, workingDir, mirror.MirrorToDisk, "oci://test")
+		res, err := ex.HelmImageCollector(context.Background())
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		if len(res) != 9 {
+			t.Fatalf("expected 9 images, got %d", len(res))
+		}
+		expected := v1alpha3.CopyImageSchema{
+			Origin:      "quay.io/test/custom:v2.0.0",
+			Source:      "docker://quay.io/test/custom:v2.0.0",
+			Destination: "docker://localhost:9999/test/custom:v2.0.0",
+		}
+		found := false
+		for _, img := range res {
+			if img == expected {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("expected %v in %v", expected, res)
+		}
+		if _, err := os.Stat(filepath.Join(workingDir, helmDir, helmChartsDir, "test-repo", "test-chart-0.1.0.tgz")); err != nil {
+			t.Fatalf("chart should be pulled to the working-dir: %v", err)
+		}
+		localPath := localChartPath(filepath.Join(workingDir, helmDir, helmChartsDir), cfg.Mirror.Helm.Local[0])
+		if _, err := os.Stat(filepath.Join(localPath, chartFile)); err != nil {
+			t.Fatalf("local chart should be copied to the working-dir: %v", err)
+		}
+	})
+
+	t.Run("Testing HelmImageCollector - Disk to mirror : should pass", func(t *testing.T) {
+		// the charts are read from the working-dir, the repository must not be used
+		d2mCfg := cfg
+		d2mCfg.Mirror.Helm = v1alpha2.Helm{
+			Repositories: []v1alpha2.Repository{
+				{Name: "test-repo", URL: "http://does-not-exist", Charts: []v1alpha2.Chart{{Name: "test-chart", Version: "0.1.0"}}},
+			},
+			Local: cfg.Mirror.Helm.Local,
+		}
+		ex := newCollector(log, d2mCfg, workingDir, mirror.DiskToMirror, "docker://myregistry/mirror")
+		res, err := ex.HelmImageCollector(context.Background())
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		if len(res) != 9 {
+			t.Fatalf("expected 9 images, got %d", len(res))
+		}
+		for _, img := range res {
+			if img.Origin == "quay.io/test/init@sha256:f30638f60452062aba36a26ee6c036feead2f03b28f2c47f2b0a991e41baebea" {
+				if img.Source != "docker://localhost:9999/test/init:f30638f60452" || img.Destination != "docker://myregistry/mirror/test/init:f30638f60452" {
+					t.Fatalf("unexpected source %s or destination %s", img.Source, img.Destination)
+				}
+			}
+		}
+	})
+
+	t.Run("Testing HelmImageCollector - Mirror to mirror : should pass", func(t *testing.T) {
+		ex := newCollector(log, cfg, t.TempDir(), mirror.MirrorToMirror, "docker://myregistry/mirror")
+		res, err := ex.HelmImageCollector(context.Background())
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		if len(res) != 9 {
+			t.Fatalf("expected 9 images, got %d", len(res))
+		}
+		for _, img := range res {
+			if img.Origin == "quay.io/test/sub:v0.2.0" {
+				if img.Source != "docker://quay.io/test/sub:v0.2.0" || img.Destination != "docker://myregistry/mirror/test/sub:v0.2.0" {
+					t.Fatalf("unexpected source %s or destination %s", img.Source, img.Destination)
+				}
+			}
+		}
+	})
+
+	t.Run("Testing HelmImageCollector - unknown chart version : should fail", func(t *testing.T) {
+		failCfg := cfg
+		failCfg.Mirror.Helm = v1alpha2.Helm{
+			Repositories: []v1alpha2.Repository{
+				{Name: "test-repo", URL: server.URL, Charts: []v1alpha2.Chart{{Name: "test-chart", Version: "9.9.9"}}},
+			},
+		}
+		ex := newCollector(log, failCfg, t.TempDir(), mirror.MirrorToDisk, "oci://test")
+		_, err := ex.HelmImageCollector(context.Background())
+		if err == nil {
+			t.Fatalf("should fail")
+		}
+	})
+
+	t.Run("Testing HelmImageCollector - digest mismatch : should fail", func(t *testing.T) {
+		failCfg := cfg
+		failCfg.Mirror.Helm = v1alpha2.Helm{
+			Repositories: []v1alpha2.Repository{
+				{Name: "test-repo", URL: server.URL, Charts: []v1alpha2.Chart{{Name: "test-chart", Version: "0.0.9"}}},
+			},
+		}
+		ex := newCollector(log, failCfg, t.TempDir(), mirror.MirrorToDisk, "oci://test")
+		_, err := ex.HelmImageCollector(context.Background())
+		if err == nil {
+			t.Fatalf("should fail")
+		}
+	})
+}
+
+func TestHelmImageCollectorLocalCharts(t *testing.T) {
+	log := clog.New("trace")
+	workingDir := t.TempDir()
+
+	// two local charts with the same name in different directories
+	srcDir := t.TempDir()
+	for _, dir := range []string{"a", "b"} {
+		if err := copyChart(testChartDir, filepath.Join(srcDir, dir, "helm-chart")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	valuesPath := filepath.Join(srcDir, "b", "helm-chart", valuesFile)
+	values, err := os.ReadFile(valuesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values = bytes.ReplaceAll(values, []byte("quay.io/test/custom:v2.0.0"), []byte("quay.io/test/custom:v3.0.0"))
+	if err := os.WriteFile(valuesPath, values, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := v1alpha2.ImageSetConfiguration{
+		ImageSetConfigurationSpec: v1alpha2.ImageSetConfigurationSpec{
+			Mirror: v1alpha2.Mirror{
+				Helm: v1alpha2.Helm{
+					Local: []v1alpha2.Chart{
+						{Name: "helm-chart", Path: filepath.Join(srcDir, "a", "helm-chart"), ImagePaths: []string{"{.spec.customImage}"}},
+						{Name: "helm-chart", Path: filepath.Join(srcDir, "b", "helm-chart"), ImagePaths: []string{"{.spec.customImage}"}},
+					},
+				},
+			},
+		},
+	}
+
+	t.Run("Testing HelmImageCollector - local charts with the same name : should pass", func(t *testing.T) {
+		ex := newCollector(log, cfg, workingDir, mirror.MirrorToDisk, "oci://test")
+		if _, err := ex.HelmImageCollector(context.Background()); err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+
+		// the charts are read from the working-dir
+		if err := os.RemoveAll(srcDir); err != nil {
+			t.Fatal(err)
+		}
+		ex = newCollector(log, cfg, workingDir, mirror.DiskToMirror, "docker://myregistry/mirror")
+		res, err := ex.HelmImageCollector(context.Background())
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		var origins []string
+		for _, img := range res {
+			if img.Origin == "quay.io/test/custom:v2.0.0" || img.Origin == "quay.io/test/custom:v3.0.0" {
+				origins = append(origins, img.Origin)
+			}
+		}
+		sort.Strings(origins)
+		if len(origins) != 2 || origins[0] != "quay.io/test/custom:v2.0.0" || origins[1] != "quay.io/test/custom:v3.0.0" {
+			t.Fatalf("expected the custom images of both charts, got %v", origins)
+		}
+	})
+}
+
+func TestHelmImageCollectorOCI(t *testing.T) {
+	log := clog.New("trace")
+
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pushChart(t, u.Host, "charts/test-chart", "0.0.9", chartArchive(t, testChartDir, "test-chart"))
+	pushChart(t, u.Host, "charts/test-chart", "0.1.0", chartArchive(t, testChartDir, "test-chart"))
+
+	cfg := v1alpha2.ImageSetConfiguration{
+		ImageSetConfigurationSpec: v1alpha2.ImageSetConfigurationSpec{
+			Mirror: v1alpha2.Mirror{
+				Helm: v1alpha2.Helm{
+					Repositories: []v1alpha2.Repository{
+						{
+							Name:   "test-oci",
+							URL:    "oci://" + u.Host + "/charts",
+							Charts: []v1alpha2.Chart{{Name: "test-chart"}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	t.Run("Testing HelmImageCollector - oci repository : should pass", func(t *testing.T) {
+		workingDir := t.TempDir()
+		ex := newCollector(log, cfg, workingDir, mirror.MirrorToDisk, "oci://test")
+		res, err := ex.HelmImageCollector(context.Background())
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		if len(res) != 4 {
+			t.Fatalf("expected 4 images, got %d", len(res))
+		}
+		if _, err := os.Stat(filepath.Join(workingDir, helmDir, helmChartsDir, "test-oci", "test-chart-0.1.0.tgz")); err != nil {
+			t.Fatalf("chart should be pulled to the working-dir: %v", err)
+		}
+	})
+
+	t.Run("Testing HelmImageCollector - oci repository, unknown chart : should fail", func(t *testing.T) {
+		failCfg := cfg
+		failCfg.Mirror.Helm = v1alpha2.Helm{
+			Repositories: []v1alpha2.Repository{
+				{Name: "test-oci", URL: "oci://" + u.Host + "/charts", Charts: []v1alpha2.Chart{{Name: "does-not-exist", Version: "0.1.0"}}},
+			},
+		}
+		ex := newCollector(log, failCfg, t.TempDir(), mirror.MirrorToDisk, "oci://test")
+		_, err := ex.HelmImageCollector(context.Background())
+		if err == nil {
+			t.Fatalf("should fail")
+		}
+	})
+}
+
+func newCollector(log clog.PluggableLoggerInterface, cfg v1alpha2.ImageSetConfiguration, workingDir, mode, destination string) CollectorInterface {
+	global := &mirror.GlobalOptions{TlsVerify: false, SecurePolicy: false, WorkingDir: workingDir}
+	opts := mirror.CopyOptions{
+		Global:      global,
+		Destination: destination,
+		Mode:        mode,
+	}
+	return New(log, cfg, opts, "localhost:9999")
+}
+
+// chartArchive - packages the chart directory, as helm package does
+func chartArchive(t *testing.T, dir, name string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		header := &tar.Header{Name: name + "/" + filepath.ToSlash(rel), Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unable to package chart: %v", err)
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+// pushChart - pushes the chart archive to the registry, as helm push does
+func pushChart(t *testing.T, host, repo, version string, archive []byte) {
+	config := []byte("{}")
+	for _, blob := range [][]byte{config, archive} {
+		d := digest.FromBytes(blob)
+		resp, err := http.Post("http://"+host+"/v2/"+repo+"/blobs/uploads/?digest="+d.String(), "application/octet-stream", bytes.NewReader(blob))
+		if err != nil {
+			t.Fatalf("unable to push blob: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("unable to push blob: %s", resp.Status)
+		}
+	}
+	m := fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json",`+
+		`"config":{"mediaType":"application/vnd.cncf.helm.config.v1+json","digest":"%s","size":%d},`+
+		`"layers":[{"mediaType":"%s","digest":"%s","size":%d}]}`,
+		digest.FromBytes(config), len(config), "application/vnd.cncf.helm.chart.content.v1.tar+gzip", digest.FromBytes(archive), len(archive))
+	req, err := http.NewRequest(http.MethodPut, "http://"+host+"/v2/"+repo+"/manifests/"+version, bytes.NewReader([]byte(m)))
+	if err != nil {
+		t.Fatalf("unable to push manifest: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("unable to push manifest: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("unable to push manifest: %s", resp.Status)
+	}
+}
@@ -0,0 +1,15 @@
+package helm
+
+import (
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha2"
+	clog "github.com/openshift/oc-mirror/v2/pkg/log"
+	"github.com/openshift/oc-mirror/v2/pkg/mirror"
+)
+
+func New(log clog.PluggableLoggerInterface,
+	config v1alpha2.ImageSetConfiguration,
+	opts mirror.CopyOptions,
+	localStorageFQDN string,
+) CollectorInterface {
+	return &LocalStorageCollector{Log: log, Config: config, Opts: opts, LocalStorageFQDN: localStorageFQDN}
+}
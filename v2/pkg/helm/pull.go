@@ -0,0 +1,185 @@
+package helm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/containers/image/v5/types"
+	helmcli "helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/downloader"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/helmpath"
+	"helm.sh/helm/v3/pkg/provenance"
+	"helm.sh/helm/v3/pkg/registry"
+	helmrepo "helm.sh/helm/v3/pkg/repo"
+
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha2"
+)
+
+// chartPuller - pulls the charts of the helm repositories of the image set configuration
+// with the helm downloader, as `helm pull` does. The repositories are added to a
+// repositories file of its own, with the credentials and certificates of the
+// repositories added with `helm repo add` for the same url. The TLS verification and
+// the registry credentials of the source images apply to the oci repositories
+type chartPuller struct {
+	settings       *helmcli.EnvSettings
+	getters        getter.Providers
+	registryClient *registry.Client
+	insecure       bool
+	userRepos      *helmrepo.File
+	repos          *helmrepo.File
+	tmpDir         string
+}
+
+func (o LocalStorageCollector) newChartPuller() (*chartPuller, error) {
+	sysCtx := o.Opts.Global.NewSystemContext()
+	if o.Opts.SrcImage != nil {
+		var err error
+		sysCtx, err = o.Opts.SrcImage.NewSystemContext()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	settings := helmcli.New()
+	userRepos, err := helmrepo.LoadFile(settings.RepositoryConfig)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	clientOpts := []registry.ClientOption{registry.ClientOptEnableCache(true)}
+	if sysCtx.AuthFilePath != "" {
+		clientOpts = append(clientOpts, registry.ClientOptCredentialsFile(sysCtx.AuthFilePath))
+	}
+	registryClient, err := registry.NewClient(clientOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// the repositories file and the index cache are not carried in the archive
+	tmpDir, err := os.MkdirTemp("", "helm-")
+	if err != nil {
+		return nil, err
+	}
+	settings.RepositoryConfig = filepath.Join(tmpDir, helmReposFile)
+	settings.RepositoryCache = filepath.Join(tmpDir, helmCacheDir)
+
+	return &chartPuller{
+		settings:       settings,
+		getters:        getter.All(settings),
+		registryClient: registryClient,
+		insecure:       sysCtx.DockerInsecureSkipTLSVerify == types.OptionalBoolTrue,
+		userRepos:      userRepos,
+		repos:          helmrepo.NewFile(),
+		tmpDir:         tmpDir,
+	}, nil
+}
+
+// close - removes the repositories file and the index cache
+func (p *chartPuller) close() error {
+	return os.RemoveAll(p.tmpDir)
+}
+
+// addRepository - downloads the index of the http repository, as `helm repo add` does.
+// Nothing is needed for an oci repository
+func (p *chartPuller) addRepository(repo v1alpha2.Repository) error {
+	if registry.IsOCI(repo.URL) {
+		return nil
+	}
+
+	entry := &helmrepo.Entry{Name: repo.Name, URL: repo.URL}
+	for _, user := range p.userRepos.Repositories {
+		if strings.TrimSuffix(user.URL, "/") == strings.TrimSuffix(repo.URL, "/") {
+			copied := *user
+			copied.Name = repo.Name
+			copied.URL = repo.URL
+			entry = &copied
+			break
+		}
+	}
+	entry.InsecureSkipTLSverify = entry.InsecureSkipTLSverify || p.insecure
+
+	r, err := helmrepo.NewChartRepository(entry, p.getters)
+	if err != nil {
+		return err
+	}
+	r.CachePath = p.settings.RepositoryCache
+	if _, err := r.DownloadIndexFile(); err != nil {
+		return fmt.Errorf("invalid chart repository %q: %v", repo.URL, err)
+	}
+
+	p.repos.Update(entry)
+	if err := p.repos.WriteFile(p.settings.RepositoryConfig, 0600); err != nil {
+		return fmt.Errorf("error writing helm repo file: %v", err)
+	}
+	return nil
+}
+
+// pullChart - downloads the chart, the requested version or else the latest
+// stable one, to the destination directory and returns the path of its archive
+func (p *chartPuller) pullChart(repo v1alpha2.Repository, ch v1alpha2.Chart, destDir string) (string, error) {
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return "", err
+	}
+
+	ref := repo.Name + "/" + ch.Name
+	if registry.IsOCI(repo.URL) {
+		ref = strings.TrimSuffix(repo.URL, "/") + "/" + ch.Name
+	}
+
+	// the downloader adds the options of the repository to its own options,
+	// a new one is used for each chart
+	c := downloader.ChartDownloader{
+		Out:     io.Discard,
+		Verify:  downloader.VerifyNever,
+		Getters: p.getters,
+		Options: []getter.Option{
+			getter.WithInsecureSkipVerifyTLS(p.insecure),
+			getter.WithRegistryClient(p.registryClient),
+		},
+		RegistryClient:   p.registryClient,
+		RepositoryConfig: p.settings.RepositoryConfig,
+		RepositoryCache:  p.settings.RepositoryCache,
+	}
+	chartPath, _, err := c.DownloadTo(ref, ch.Version, destDir)
+	if err != nil {
+		return "", fmt.Errorf("error pulling chart %s: %v", ref, err)
+	}
+
+	if !registry.IsOCI(repo.URL) {
+		if err := p.verifyDigest(repo, ch, chartPath); err != nil {
+			os.Remove(chartPath)
+			return "", fmt.Errorf("error pulling chart %s: %v", ref, err)
+		}
+	}
+	return chartPath, nil
+}
+
+// verifyDigest - checks the downloaded archive against the digest of the
+// chart in the index of the repository, when the index has one
+func (p *chartPuller) verifyDigest(repo v1alpha2.Repository, ch v1alpha2.Chart, chartPath string) error {
+	index, err := helmrepo.LoadIndexFile(filepath.Join(p.settings.RepositoryCache, helmpath.CacheIndexFile(repo.Name)))
+	if err != nil {
+		return err
+	}
+	cv, err := index.Get(ch.Name, ch.Version)
+	if err != nil {
+		return err
+	}
+	if cv.Digest == "" {
+		return nil
+	}
+	sum, err := provenance.DigestFile(chartPath)
+	if err != nil {
+		return err
+	}
+	if sum != cv.Digest {
+		return fmt.Errorf("digest mismatch")
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package helm
+
+import (
+	"fmt"
+	"strings"
+
+	helmchart "helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+	"helm.sh/helm/v3/pkg/releaseutil"
+)
+
+// render - renders the templates of the chart and of its enabled subcharts,
+// with the default values of the chart, as `helm template` would.
+// It returns the manifests and the hooks, one yaml document each:
+// a template that cannot be rendered fails the chart
+func render(ch *helmchart.Chart) ([]string, error) {
+	values := map[string]interface{}{}
+	if err := chartutil.ProcessDependencies(ch, values); err != nil {
+		return nil, fmt.Errorf("error processing the dependencies of chart %s: %v", ch.Name(), err)
+	}
+
+	caps := chartutil.DefaultCapabilities
+	relOpts := chartutil.ReleaseOptions{
+		Name:      releaseName,
+		Namespace: releaseNS,
+		Revision:  1,
+		IsInstall: true,
+	}
+	valuesToRender, err := chartutil.ToRenderValues(ch, values, relOpts, caps)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering values of chart %s: %v", ch.Name(), err)
+	}
+
+	files, err := engine.Render(ch, valuesToRender)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering chart %s: %v", ch.Name(), err)
+	}
+
+	// the notes are not manifests
+	for name := range files {
+		if strings.HasSuffix(name, ".txt") {
+			delete(files, name)
+		}
+	}
+
+	hooks, manifests, err := releaseutil.SortManifests(files, caps.APIVersions, releaseutil.InstallOrder)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the manifests of chart %s: %v", ch.Name(), err)
+	}
+
+	docs := make([]string, 0, len(hooks)+len(manifests))
+	for _, h := range hooks {
+		docs = append(docs, h.Manifest)
+	}
+	for _, m := range manifests {
+		docs = append(docs, m.Content)
+	}
+	return docs, nil
+}
@@ -178,3 +178,24 @@ func (OSFileCreator) Create(filename string) (io.WriteCloser, error) {
 	}
 	return file, err
 }
+
+// blobsHistory - a history read from the blobs given instead of the latest snapshot,
+// the blobs appended being still written to the snapshots of the wrapped history
+type blobsHistory struct {
+	History
+	blobs map[string]string
+}
+
+// WithBlobs - the history h, read from blobs (i.e. the blobs of the delta request
+// of the diskToMirror side)
+func WithBlobs(h History, blobs map[string]string) History {
+	return blobsHistory{History: h, blobs: blobs}
+}
+
+func (o blobsHistory) Read() (map[string]string, error) {
+	historyMap := make(map[string]string, len(o.blobs))
+	for k, v := range o.blobs {
+		historyMap[k] = v
+	}
+	return historyMap, nil
+}
@@ -157,3 +157,20 @@ func TestAppend(t *testing.T) {
 	}
 
 }
+
+func TestWithBlobs(t *testing.T) {
+	t.Run("Testing WithBlobs : should pass", func(t *testing.T) {
+		history, err := NewHistory(historyFakePath, time.Time{}, clog.New("trace"), MockFileCreator{})
+		assert.NoError(t, err)
+		history = WithBlobs(history, map[string]string{"sha256:requested": ""})
+		historyBlobs, err := history.Read()
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"sha256:requested": ""}, historyBlobs)
+		// the blobs added are still appended to the latest snapshot
+		historyBlobs, err = history.Append(map[string]string{"sha256:added": ""})
+		assert.NoError(t, err)
+		assert.Contains(t, historyBlobs, "sha256:added")
+		assert.Contains(t, historyBlobs, "sha256:1dddb0988d16")
+		assert.NotContains(t, historyBlobs, "sha256:requested")
+	})
+}
@@ -0,0 +1,147 @@
+package history
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// Snapshot - a history file, written each time a mirrorToDisk archive is built:
+// the blobs of all the archives built until then
+type Snapshot struct {
+	Name  string
+	Time  time.Time
+	Blobs int
+}
+
+// ShortName - the name of the snapshot without the .history- prefix, its date
+func (s Snapshot) ShortName() string {
+	return strings.TrimPrefix(s.Name, historyNamePrefix)
+}
+
+// SnapshotName - the file name of the snapshot named .history-<date> or <date>
+func SnapshotName(name string) string {
+	name = filepath.Base(name)
+	if !strings.HasPrefix(name, historyNamePrefix) {
+		return historyNamePrefix + name
+	}
+	return name
+}
+
+// ListSnapshots - the snapshots of the history of workingDir, the oldest first
+func ListSnapshots(workingDir string) ([]Snapshot, error) {
+	historyDir := filepath.Join(workingDir, historyPath)
+	entries, err := os.ReadDir(historyDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Snapshot{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	snapshots := []Snapshot{}
+	for _, entry := range entries {
+		if !isHistoryFile(entry) {
+			continue
+		}
+		fileTime, err := getFileDate(entry)
+		if err != nil {
+			return nil, err
+		}
+		blobs, err := readBlobs(filepath.Join(historyDir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, Snapshot{Name: entry.Name(), Time: fileTime, Blobs: len(blobs)})
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Time.Before(snapshots[j].Time)
+	})
+	return snapshots, nil
+}
+
+// ReadSnapshot - the blobs of the snapshot, named .history-<date> or <date>
+func ReadSnapshot(workingDir, name string) (map[string]string, error) {
+	snapshot, err := findSnapshot(workingDir, name)
+	if err != nil {
+		return nil, err
+	}
+	return readBlobs(filepath.Join(workingDir, historyPath, snapshot.Name))
+}
+
+// DeleteSnapshot - removes the snapshot, named .history-<date> or <date>
+func DeleteSnapshot(workingDir, name string) error {
+	snapshot, err := findSnapshot(workingDir, name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(workingDir, historyPath, snapshot.Name))
+}
+
+// Before - the time to pass to NewHistory so that the archive is built against
+// the latest snapshot taken before since (RFC3339 or YYYY-MM-DD), or against the
+// snapshot sinceSnapshot. The zero time (the latest snapshot) when both are empty
+func Before(workingDir, since, sinceSnapshot string) (time.Time, error) {
+	switch {
+	case since != "" && sinceSnapshot != "":
+		return time.Time{}, fmt.Errorf("only one of the history date and snapshot can be set")
+	case since != "":
+		return ParseDate(since)
+	case sinceSnapshot != "":
+		snapshot, err := findSnapshot(workingDir, sinceSnapshot)
+		if err != nil {
+			return time.Time{}, err
+		}
+		// the snapshots are named to the second
+		return snapshot.Time.Add(time.Second), nil
+	}
+	return time.Time{}, nil
+}
+
+// ParseDate - parses a date in the RFC3339 or YYYY-MM-DD (UTC) formats
+func ParseDate(date string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, date); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(dateLayout, date)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, use the RFC3339 (2006-01-02T15:04:05Z) or YYYY-MM-DD formats", date)
+	}
+	return t, nil
+}
+
+// findSnapshot - the snapshot named .history-<date> or <date>
+func findSnapshot(workingDir, name string) (Snapshot, error) {
+	name = SnapshotName(name)
+	snapshots, err := ListSnapshots(workingDir)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	for _, s := range snapshots {
+		if s.Name == name {
+			return s, nil
+		}
+	}
+	return Snapshot{}, fmt.Errorf("history snapshot %s not found under %s", name, filepath.Join(workingDir, historyPath))
+}
+
+func readBlobs(historyFile string) (map[string]string, error) {
+	blobs := map[string]string{}
+	file, err := os.Open(historyFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if blob := scanner.Text(); blob != "" {
+			blobs[blob] = ""
+		}
+	}
+	return blobs, scanner.Err()
+}
@@ -0,0 +1,101 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListSnapshots(t *testing.T) {
+	t.Run("Testing ListSnapshots : should pass", func(t *testing.T) {
+		snapshots, err := ListSnapshots(historyFakePath)
+		assert.NoError(t, err)
+		assert.Equal(t, []Snapshot{
+			{Name: ".history-2023-11-21T11:47:46Z", Time: time.Date(2023, 11, 21, 11, 47, 46, 0, time.UTC), Blobs: 1},
+			{Name: ".history-2023-11-22T11:24:14Z", Time: time.Date(2023, 11, 22, 11, 24, 14, 0, time.UTC), Blobs: 4},
+		}, snapshots)
+	})
+
+	t.Run("Testing ListSnapshots (no history) : should pass", func(t *testing.T) {
+		snapshots, err := ListSnapshots(t.TempDir())
+		assert.NoError(t, err)
+		assert.Empty(t, snapshots)
+	})
+
+	t.Run("Testing ReadSnapshot : should pass", func(t *testing.T) {
+		blobs, err := ReadSnapshot(historyFakePath, "2023-11-21T11:47:46Z")
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"sha256:1dddb0988d16": ""}, blobs)
+		_, err = ReadSnapshot(historyFakePath, "2023-11-23T00:00:00Z")
+		assert.Error(t, err)
+	})
+}
+
+func TestSnapshotName(t *testing.T) {
+	t.Run("Testing SnapshotName : should pass", func(t *testing.T) {
+		for _, name := range []string{"2023-11-21T11:47:46Z", ".history-2023-11-21T11:47:46Z", "working-dir/.history/.history-2023-11-21T11:47:46Z"} {
+			assert.Equal(t, ".history-2023-11-21T11:47:46Z", SnapshotName(name))
+		}
+		assert.Equal(t, "2023-11-21T11:47:46Z", Snapshot{Name: ".history-2023-11-21T11:47:46Z"}.ShortName())
+	})
+}
+
+func TestBefore(t *testing.T) {
+	type testCase struct {
+		caseName      string
+		since         string
+		sinceSnapshot string
+		expected      time.Time
+		err           bool
+	}
+	testCases := []testCase{
+		{caseName: "Testing Before (latest) : should pass"},
+		{caseName: "Testing Before (date) : should pass", since: "2023-11-22", expected: time.Date(2023, 11, 22, 0, 0, 0, 0, time.UTC)},
+		{caseName: "Testing Before (RFC3339) : should pass", since: "2023-11-22T10:00:00Z", expected: time.Date(2023, 11, 22, 10, 0, 0, 0, time.UTC)},
+		{caseName: "Testing Before (snapshot) : should pass", sinceSnapshot: ".history-2023-11-21T11:47:46Z", expected: time.Date(2023, 11, 21, 11, 47, 47, 0, time.UTC)},
+		{caseName: "Testing Before (invalid date) : should fail", since: "22/11/2023", err: true},
+		{caseName: "Testing Before (unknown snapshot) : should fail", sinceSnapshot: "2023-11-20T00:00:00Z", err: true},
+		{caseName: "Testing Before (date and snapshot) : should fail", since: "2023-11-22", sinceSnapshot: "2023-11-21T11:47:46Z", err: true},
+	}
+	for _, c := range testCases {
+		t.Run(c.caseName, func(t *testing.T) {
+			before, err := Before(historyFakePath, c.since, c.sinceSnapshot)
+			if c.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected, before)
+		})
+	}
+
+	t.Run("Testing Before (snapshot read back) : should pass", func(t *testing.T) {
+		before, err := Before(historyFakePath, "", "2023-11-21T11:47:46Z")
+		assert.NoError(t, err)
+		history, err := NewHistory(historyFakePath, before, nil, MockFileCreator{})
+		assert.NoError(t, err)
+		blobs, err := history.Read()
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"sha256:1dddb0988d16": ""}, blobs)
+	})
+}
+
+func TestDeleteSnapshot(t *testing.T) {
+	workingDir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(workingDir, historyPath), 0755))
+	for _, name := range []string{".history-2023-11-21T11:47:46Z", ".history-2023-11-22T11:24:14Z"} {
+		data, err := os.ReadFile(filepath.Join(historyFakePath, historyPath, name))
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(filepath.Join(workingDir, historyPath, name), data, 0644))
+	}
+
+	assert.NoError(t, DeleteSnapshot(workingDir, "2023-11-21T11:47:46Z"))
+	snapshots, err := ListSnapshots(workingDir)
+	assert.NoError(t, err)
+	assert.Len(t, snapshots, 1)
+	assert.Equal(t, ".history-2023-11-22T11:24:14Z", snapshots[0].Name)
+	assert.Error(t, DeleteSnapshot(workingDir, "2023-11-21T11:47:46Z"))
+}
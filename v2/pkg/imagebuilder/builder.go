@@ -389,3 +389,68 @@ func LayerFromGzipByteArray(content []byte, outputFile string, contentPrefixDir
 	}
 	return layer, nil
 }
+
+// LayerFromPath creates a layer holding the content of the folder path under targetPath,
+// owned by uid and gid
+func LayerFromPath(targetPath, path string, uid, gid int) (v1.Layer, error) {
+	var b bytes.Buffer
+	tw := tar.NewWriter(&b)
+	err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(path, file)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(filepath.Join(targetPath, rel))
+		header.Uid = uid
+		header.Gid = gid
+		header.Uname = ""
+		header.Gname = ""
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b.Bytes())), nil
+	})
+}
+
+// DeleteLayer creates a layer that removes path (and its content) from the image,
+// using an OCI whiteout
+func DeleteLayer(path string) (v1.Layer, error) {
+	path = strings.TrimSuffix(path, "/")
+	whiteout := filepath.ToSlash(filepath.Join(filepath.Dir(path), ".wh."+filepath.Base(path)))
+	var b bytes.Buffer
+	tw := tar.NewWriter(&b)
+	if err := tw.WriteHeader(&tar.Header{Name: whiteout, Mode: 0644, Typeflag: tar.TypeReg}); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b.Bytes())), nil
+	})
+}
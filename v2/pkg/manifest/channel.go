@@ -0,0 +1,191 @@
+package manifest
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/blang/semver/v4"
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
+	"github.com/operator-framework/operator-registry/alpha/property"
+)
+
+// bundleVersions - the version of each bundle, from its olm.package property
+func bundleVersions(olm []v1alpha3.DeclarativeConfig) (map[string]semver.Version, error) {
+	versions := make(map[string]semver.Version)
+	for _, obj := range olm {
+		if obj.Schema != "olm.bundle" {
+			continue
+		}
+		props, err := property.Parse(obj.Properties)
+		if err != nil {
+			return nil, fmt.Errorf("bundle %s: %v", obj.Name, err)
+		}
+		if len(props.Packages) == 0 {
+			continue
+		}
+		version, err := semver.ParseTolerant(props.Packages[0].Version)
+		if err != nil {
+			return nil, fmt.Errorf("bundle %s: invalid version %q: %v", obj.Name, props.Packages[0].Version, err)
+		}
+		versions[obj.Name] = version
+	}
+	return versions, nil
+}
+
+// channelGraph - the upgrade graph of a channel: a bundle upgrades
+// to the entries that replace it, skip it or have it in their skipRange
+type channelGraph struct {
+	name     string
+	entries  map[string]v1alpha3.ChannelEntry
+	versions map[string]semver.Version
+	// the entries that upgrade to each entry
+	from map[string][]string
+}
+
+func newChannelGraph(channel v1alpha3.DeclarativeConfig, versions map[string]semver.Version) (*channelGraph, error) {
+	g := &channelGraph{
+		name:     channel.Name,
+		entries:  make(map[string]v1alpha3.ChannelEntry, len(channel.Entries)),
+		versions: make(map[string]semver.Version, len(channel.Entries)),
+		from:     make(map[string][]string, len(channel.Entries)),
+	}
+	for _, e := range channel.Entries {
+		version, ok := versions[e.Name]
+		if !ok {
+			return nil, fmt.Errorf("channel %s: bundle %s has no olm.package version", channel.Name, e.Name)
+		}
+		g.entries[e.Name] = e
+		g.versions[e.Name] = version
+	}
+	for _, e := range channel.Entries {
+		var skipRange semver.Range
+		if e.SkipRange != "" {
+			r, err := semver.ParseRange(e.SkipRange)
+			if err != nil {
+				return nil, fmt.Errorf("channel %s: bundle %s has an invalid skipRange %q: %v", channel.Name, e.Name, e.SkipRange, err)
+			}
+			skipRange = r
+		}
+		for _, other := range channel.Entries {
+			if other.Name == e.Name {
+				continue
+			}
+			if other.Name == e.Replaces || contains(e.Skips, other.Name) || (skipRange != nil && skipRange(g.versions[other.Name])) {
+				g.from[e.Name] = append(g.from[e.Name], other.Name)
+			}
+		}
+	}
+	return g, nil
+}
+
+// head - the entry that no other entry replaces or skips,
+// the newest one when there are several
+func (g *channelGraph) head() (string, error) {
+	superseded := make(map[string]bool, len(g.entries))
+	for _, e := range g.entries {
+		superseded[e.Replaces] = true
+		for _, s := range e.Skips {
+			superseded[s] = true
+		}
+	}
+	heads := []string{}
+	for name := range g.entries {
+		if !superseded[name] {
+			heads = append(heads, name)
+		}
+	}
+	if len(heads) == 0 {
+		return "", fmt.Errorf("channel %s has no head", g.name)
+	}
+	g.sort(heads)
+	return heads[len(heads)-1], nil
+}
+
+// upgradePath - the bundles of the channel on the upgrade path from the minimum to the maximum,
+// both included. The maximum is the newest bundle up to maxVersion (the head when not set),
+// the minimum is minBundle or minVersion: every bundle from which the maximum can be reached,
+// and which isn't older than the minimum, is on the path
+func (g *channelGraph) upgradePath(minVersion, maxVersion, minBundle string) ([]string, error) {
+	target, err := g.head()
+	if err != nil {
+		return nil, err
+	}
+	if maxVersion != "" {
+		max, err := semver.ParseTolerant(maxVersion)
+		if err != nil {
+			return nil, fmt.Errorf("channel %s: invalid maxVersion %q: %v", g.name, maxVersion, err)
+		}
+		target = ""
+		for name, version := range g.versions {
+			if version.LTE(max) && (target == "" || version.GT(g.versions[target])) {
+				target = name
+			}
+		}
+		if target == "" {
+			return nil, fmt.Errorf("channel %s has no bundle up to version %s", g.name, maxVersion)
+		}
+	}
+
+	var min *semver.Version
+	switch {
+	case minBundle != "":
+		version, ok := g.versions[minBundle]
+		if !ok {
+			return nil, fmt.Errorf("channel %s has no bundle %s", g.name, minBundle)
+		}
+		min = &version
+	case minVersion != "":
+		version, err := semver.ParseTolerant(minVersion)
+		if err != nil {
+			return nil, fmt.Errorf("channel %s: invalid minVersion %q: %v", g.name, minVersion, err)
+		}
+		min = &version
+	}
+	if min != nil && g.versions[target].LT(*min) {
+		return nil, fmt.Errorf("channel %s has no bundle between version %s and %s", g.name, min, g.versions[target])
+	}
+
+	// walk the upgrade graph back from the maximum
+	selected := map[string]bool{target: true}
+	queue := []string{target}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, from := range g.from[name] {
+			if selected[from] || (min != nil && g.versions[from].LT(*min)) {
+				continue
+			}
+			selected[from] = true
+			queue = append(queue, from)
+		}
+	}
+	if minBundle != "" && !selected[minBundle] {
+		return nil, fmt.Errorf("channel %s: bundle %s doesn't upgrade to %s", g.name, minBundle, target)
+	}
+
+	names := make([]string, 0, len(selected))
+	for name := range selected {
+		names = append(names, name)
+	}
+	g.sort(names)
+	return names, nil
+}
+
+// sort - sorts the names of the bundles by version
+func (g *channelGraph) sort(names []string) {
+	sort.Slice(names, func(i, j int) bool {
+		if c := g.versions[names[i]].Compare(g.versions[names[j]]); c != 0 {
+			return c < 0
+		}
+		return names[i] < names[j]
+	})
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,197 @@
+package manifest
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
+	clog "github.com/openshift/oc-mirror/v2/pkg/log"
+)
+
+// the upgrade graph of the test channel:
+// 1.0.0 <- 1.1.0 <- 1.2.0-0.1 (skips 1.1.1) <- 1.3.0 (skipRange >=1.0.0 <1.3.0) <- 1.4.0
+// 1.1.0 <- 1.1.1 (not replaced, but skipped)
+var testChannel = `{"schema":"olm.package","name":"foo","defaultChannel":"stable"}
+{"schema":"olm.channel","name":"stable","package":"foo","entries":[{"name":"foo.v1.0.0"},{"name":"foo.v1.1.0","replaces":"foo.v1.0.0"},{"name":"foo.v1.1.1","replaces":"foo.v1.1.0"},{"name":"foo.v1.2.3-0.1","replaces":"foo.v1.1.0","skips":["foo.v1.1.1"]},{"name":"foo-operator.4.14.0","replaces":"foo.v1.2.3-0.1","skipRange":">=1.0.0 <1.3.0"},{"name":"foo-operator.4.15.0","replaces":"foo-operator.4.14.0"}]}
+{"schema":"olm.channel","name":"fast","package":"foo","entries":[{"name":"foo-operator.4.14.0"},{"name":"foo.v2.0.0","replaces":"foo-operator.4.14.0"}]}
+{"schema":"olm.bundle","name":"foo.v1.0.0","package":"foo","properties":[{"type":"olm.package","value":{"packageName":"foo","version":"1.0.0"}}],"relatedImages":[{"name":"foo","image":"quay.io/foo/foo:v1.0.0"}]}
+{"schema":"olm.bundle","name":"foo.v1.1.0","package":"foo","properties":[{"type":"olm.package","value":{"packageName":"foo","version":"1.1.0"}}],"relatedImages":[{"name":"foo","image":"quay.io/foo/foo:v1.1.0"}]}
+{"schema":"olm.bundle","name":"foo.v1.1.1","package":"foo","properties":[{"type":"olm.package","value":{"packageName":"foo","version":"1.1.1"}}],"relatedImages":[{"name":"foo","image":"quay.io/foo/foo:v1.1.1"}]}
+{"schema":"olm.bundle","name":"foo.v1.2.3-0.1","package":"foo","properties":[{"type":"olm.package","value":{"packageName":"foo","version":"1.2.3-0.1"}}],"relatedImages":[{"name":"foo","image":"quay.io/foo/foo:v1.2.3-0.1"}]}
+{"schema":"olm.bundle","name":"foo-operator.4.14.0","package":"foo","properties":[{"type":"olm.package","value":{"packageName":"foo","version":"1.3.0"}}],"relatedImages":[{"name":"foo","image":"quay.io/foo/foo:v1.3.0"}]}
+{"schema":"olm.bundle","name":"foo-operator.4.15.0","package":"foo","properties":[{"type":"olm.package","value":{"packageName":"foo","version":"1.4.0"}}],"relatedImages":[{"name":"foo","image":"quay.io/foo/foo:v1.4.0"}]}
+{"schema":"olm.bundle","name":"foo.v2.0.0","package":"foo","properties":[{"type":"olm.package","value":{"packageName":"foo","version":"2.0.0"}}],"relatedImages":[{"name":"foo","image":"quay.io/foo/foo:v2.0.0"}]}
+`
+
+func readTestChannel(t *testing.T) []v1alpha3.DeclarativeConfig {
+	var olm []v1alpha3.DeclarativeConfig
+	decoder := json.NewDecoder(strings.NewReader(testChannel))
+	for decoder.More() {
+		var obj v1alpha3.DeclarativeConfig
+		if err := decoder.Decode(&obj); err != nil {
+			t.Fatal(err)
+		}
+		olm = append(olm, obj)
+	}
+	return olm
+}
+
+func TestChannelGraph(t *testing.T) {
+	olm := readTestChannel(t)
+	versions, err := bundleVersions(olm)
+	if err != nil {
+		t.Fatalf("should not fail: %v", err)
+	}
+	graph, err := newChannelGraph(olm[1], versions)
+	if err != nil {
+		t.Fatalf("should not fail: %v", err)
+	}
+
+	t.Run("Testing head : should pass", func(t *testing.T) {
+		head, err := graph.head()
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		if head != "foo-operator.4.15.0" {
+			t.Fatalf("expected head foo-operator.4.15.0, got %s", head)
+		}
+	})
+
+	type testCase struct {
+		caseName   string
+		minVersion string
+		maxVersion string
+		minBundle  string
+		expected   []string
+		err        bool
+	}
+	testCases := []testCase{
+		{
+			caseName: "Testing upgradePath (no range) : should pass",
+			expected: []string{"foo.v1.0.0", "foo.v1.1.0", "foo.v1.1.1", "foo.v1.2.3-0.1", "foo-operator.4.14.0", "foo-operator.4.15.0"},
+		},
+		{
+			caseName:   "Testing upgradePath (minVersion is included) : should pass",
+			minVersion: "1.1.1",
+			expected:   []string{"foo.v1.1.1", "foo.v1.2.3-0.1", "foo-operator.4.14.0", "foo-operator.4.15.0"},
+		},
+		{
+			caseName:   "Testing upgradePath (min and max) : should pass",
+			minVersion: "1.1.0",
+			maxVersion: "1.2.3-0.1",
+			expected:   []string{"foo.v1.1.0", "foo.v1.1.1", "foo.v1.2.3-0.1"},
+		},
+		{
+			caseName:   "Testing upgradePath (maxVersion between bundles) : should pass",
+			maxVersion: "1.2.0",
+			expected:   []string{"foo.v1.0.0", "foo.v1.1.0", "foo.v1.1.1"},
+		},
+		{
+			caseName:   "Testing upgradePath (min equals max) : should pass",
+			minVersion: "1.3.0",
+			maxVersion: "1.3.0",
+			expected:   []string{"foo-operator.4.14.0"},
+		},
+		{
+			caseName:  "Testing upgradePath (minBundle) : should pass",
+			minBundle: "foo.v1.2.3-0.1",
+			expected:  []string{"foo.v1.2.3-0.1", "foo-operator.4.14.0", "foo-operator.4.15.0"},
+		},
+		{
+			caseName:  "Testing upgradePath (unknown minBundle) : should fail",
+			minBundle: "foo.v0.1.0",
+			err:       true,
+		},
+		{
+			caseName:   "Testing upgradePath (min above max) : should fail",
+			minVersion: "1.4.0",
+			maxVersion: "1.3.0",
+			err:        true,
+		},
+		{
+			caseName:   "Testing upgradePath (invalid version) : should fail",
+			minVersion: "latest",
+			err:        true,
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.caseName, func(t *testing.T) {
+			res, err := graph.upgradePath(c.minVersion, c.maxVersion, c.minBundle)
+			if c.err {
+				if err == nil {
+					t.Fatalf("should fail, got %v", res)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("should not fail: %v", err)
+			}
+			if strings.Join(res, ",") != strings.Join(c.expected, ",") {
+				t.Fatalf("expected bundles %v, got %v", c.expected, res)
+			}
+		})
+	}
+
+	t.Run("Testing newChannelGraph (bundle without version) : should fail", func(t *testing.T) {
+		delete(versions, "foo.v1.0.0")
+		defer func() { versions["foo.v1.0.0"] = graph.versions["foo.v1.0.0"] }()
+		if _, err := newChannelGraph(olm[1], versions); err == nil {
+			t.Fatalf("should fail")
+		}
+	})
+}
+
+func TestGetRelatedImageByChannelGraph(t *testing.T) {
+	log := clog.New("trace")
+	olm := readTestChannel(t)
+
+	type testCase struct {
+		caseName string
+		pkg      *v1alpha3.ISCPackage
+		expected []string
+	}
+	testCases := []testCase{
+		{
+			caseName: "Testing getRelatedImageByDefaultChannel : should pass",
+			expected: []string{"foo-operator.4.15.0"},
+		},
+		{
+			caseName: "Testing getRelatedImageByFilter (head of every channel) : should pass",
+			pkg:      &v1alpha3.ISCPackage{},
+			expected: []string{"foo-operator.4.15.0", "foo.v2.0.0"},
+		},
+		{
+			caseName: "Testing getRelatedImageByFilter (range on the default channel) : should pass",
+			pkg:      &v1alpha3.ISCPackage{MinVersion: "1.3.0"},
+			expected: []string{"foo-operator.4.14.0", "foo-operator.4.15.0"},
+		},
+		{
+			caseName: "Testing getRelatedImageByFilter (channel) : should pass",
+			pkg:      &v1alpha3.ISCPackage{Channel: "fast"},
+			expected: []string{"foo-operator.4.14.0", "foo.v2.0.0"},
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.caseName, func(t *testing.T) {
+			var res map[string][]v1alpha3.RelatedImage
+			var err error
+			if c.pkg == nil {
+				res, err = getRelatedImageByDefaultChannel(log, olm)
+			} else {
+				res, err = getRelatedImageByFilter(log, olm, *c.pkg)
+			}
+			if err != nil {
+				t.Fatalf("should not fail: %v", err)
+			}
+			if len(res) != len(c.expected) {
+				t.Fatalf("expected bundles %v, got %v", c.expected, res)
+			}
+			for _, name := range c.expected {
+				if _, ok := res[name]; !ok {
+					t.Fatalf("expected bundles %v, got %v", c.expected, res)
+				}
+			}
+		})
+	}
+}
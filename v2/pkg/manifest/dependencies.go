@@ -0,0 +1,149 @@
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/blang/semver/v4"
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
+	clog "github.com/openshift/oc-mirror/v2/pkg/log"
+	"github.com/operator-framework/operator-registry/alpha/property"
+)
+
+// catalogBundle - a bundle of the catalog, with what it provides and requires
+type catalogBundle struct {
+	name          string
+	pkg           string
+	version       semver.Version
+	relatedImages []v1alpha3.RelatedImage
+	gvks          []property.GVK
+	required      *property.Properties
+}
+
+// readCatalogBundles - reads the bundles of all the packages of the catalog
+// (one folder per package in filePath)
+func readCatalogBundles(filePath string) ([]catalogBundle, error) {
+	dirs, err := os.ReadDir(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var bundles []catalogBundle
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		olm, err := readOperatorCatalog(filePath + "/" + dir.Name())
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range olm {
+			if obj.Schema != "olm.bundle" {
+				continue
+			}
+			props, err := property.Parse(obj.Properties)
+			if err != nil {
+				return nil, fmt.Errorf("bundle %s: %v", obj.Name, err)
+			}
+			b := catalogBundle{name: obj.Name, pkg: obj.Package, relatedImages: obj.RelatedImages, gvks: props.GVKs, required: props}
+			if len(props.Packages) > 0 {
+				// a bundle without a valid version can still provide apis
+				b.version, _ = semver.Parse(props.Packages[0].Version)
+			}
+			bundles = append(bundles, b)
+		}
+	}
+	return bundles, nil
+}
+
+// resolveDependencies - adds to relatedImages (keyed by bundle name) the newest bundle of the catalog
+// that satisfies each olm.package.required and olm.gvk.required property of the bundles
+// already selected, and of the bundles added along the way. A requirement satisfied by a
+// selected bundle doesn't add anything. The requirements that can't be satisfied are returned
+func resolveDependencies(log clog.PluggableLoggerInterface, catalog []catalogBundle, relatedImages map[string][]v1alpha3.RelatedImage) ([]string, error) {
+	// newest first, so that the first match is the one to add
+	sort.SliceStable(catalog, func(i, j int) bool {
+		return catalog[i].version.GT(catalog[j].version)
+	})
+
+	var queue []catalogBundle
+	for _, b := range catalog {
+		if _, ok := relatedImages[b.name]; ok {
+			queue = append(queue, b)
+		}
+	}
+	selected := func(b catalogBundle) bool {
+		_, ok := relatedImages[b.name]
+		return ok
+	}
+
+	var unsatisfied []string
+	for len(queue) > 0 {
+		b := queue[0]
+		queue = queue[1:]
+
+		type requirement struct {
+			description string
+			match       func(catalogBundle) bool
+		}
+		var requirements []requirement
+		for _, req := range b.required.PackagesRequired {
+			req := req
+			versionRange, err := semver.ParseRange(req.VersionRange)
+			if err != nil {
+				return nil, fmt.Errorf("bundle %s: invalid version range %q for package %s: %v", b.name, req.VersionRange, req.PackageName, err)
+			}
+			requirements = append(requirements, requirement{
+				description: fmt.Sprintf("package %s %s", req.PackageName, req.VersionRange),
+				match: func(c catalogBundle) bool {
+					return c.pkg == req.PackageName && versionRange(c.version)
+				},
+			})
+		}
+		for _, req := range b.required.GVKsRequired {
+			req := req
+			requirements = append(requirements, requirement{
+				description: fmt.Sprintf("api %s/%s %s", req.Group, req.Version, req.Kind),
+				match: func(c catalogBundle) bool {
+					for _, gvk := range c.gvks {
+						if gvk.Group == req.Group && gvk.Version == req.Version && gvk.Kind == req.Kind {
+							return true
+						}
+					}
+					return false
+				},
+			})
+		}
+
+		for _, req := range requirements {
+			var candidate *catalogBundle
+			satisfied := false
+			for i := range catalog {
+				if !req.match(catalog[i]) {
+					continue
+				}
+				if selected(catalog[i]) {
+					satisfied = true
+					break
+				}
+				if candidate == nil {
+					candidate = &catalog[i]
+				}
+			}
+			switch {
+			case satisfied:
+			case candidate != nil:
+				log.Debug("bundle %s requires %s: adding %s", b.name, req.description, candidate.name)
+				relatedImages[candidate.name] = withPackage(candidate.relatedImages, candidate.pkg)
+				queue = append(queue, *candidate)
+			default:
+				unsatisfied = append(unsatisfied, fmt.Sprintf("bundle %s requires %s, which is not provided by any bundle of the catalog", b.name, req.description))
+			}
+		}
+	}
+	return unsatisfied, nil
+}
@@ -0,0 +1,138 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
+	clog "github.com/openshift/oc-mirror/v2/pkg/log"
+)
+
+// the declarative config of each package of the test catalog
+var testDependencies = map[string]string{
+	"foo": `{"schema":"olm.package","name":"foo","defaultChannel":"stable"}
+{"schema":"olm.channel","name":"stable","package":"foo","entries":[{"name":"foo.v1.0.0"}]}
+{"schema":"olm.bundle","name":"foo.v1.0.0","package":"foo","image":"quay.io/foo/bundle:v1.0.0","properties":[{"type":"olm.package","value":{"packageName":"foo","version":"1.0.0"}},{"type":"olm.package.required","value":{"packageName":"bar","versionRange":">=1.0.0 <2.0.0"}},{"type":"olm.gvk.required","value":{"group":"example.com","version":"v1","kind":"Widget"}}],"relatedImages":[{"name":"foo","image":"quay.io/foo/foo:v1.0.0"}]}
+`,
+	"bar": `{"schema":"olm.package","name":"bar","defaultChannel":"stable"}
+{"schema":"olm.channel","name":"stable","package":"bar","entries":[{"name":"bar.v1.0.0"},{"name":"bar.v1.2.0","replaces":"bar.v1.0.0"},{"name":"bar.v2.0.0","replaces":"bar.v1.2.0"}]}
+{"schema":"olm.bundle","name":"bar.v1.0.0","package":"bar","image":"quay.io/bar/bundle:v1.0.0","properties":[{"type":"olm.package","value":{"packageName":"bar","version":"1.0.0"}}],"relatedImages":[{"name":"bar","image":"quay.io/bar/bar:v1.0.0"}]}
+{"schema":"olm.bundle","name":"bar.v1.2.0","package":"bar","image":"quay.io/bar/bundle:v1.2.0","properties":[{"type":"olm.package","value":{"packageName":"bar","version":"1.2.0"}}],"relatedImages":[{"name":"bar","image":"quay.io/bar/bar:v1.2.0"}]}
+{"schema":"olm.bundle","name":"bar.v2.0.0","package":"bar","image":"quay.io/bar/bundle:v2.0.0","properties":[{"type":"olm.package","value":{"packageName":"bar","version":"2.0.0"}}],"relatedImages":[{"name":"bar","image":"quay.io/bar/bar:v2.0.0"}]}
+`,
+	"baz": `{"schema":"olm.package","name":"baz","defaultChannel":"stable"}
+{"schema":"olm.channel","name":"stable","package":"baz","entries":[{"name":"baz.v0.1.0"},{"name":"baz.v0.2.0","replaces":"baz.v0.1.0"}]}
+{"schema":"olm.bundle","name":"baz.v0.1.0","package":"baz","image":"quay.io/baz/bundle:v0.1.0","properties":[{"type":"olm.package","value":{"packageName":"baz","version":"0.1.0"}},{"type":"olm.gvk","value":{"group":"example.com","version":"v1","kind":"Widget"}}],"relatedImages":[{"name":"baz","image":"quay.io/baz/baz:v0.1.0"}]}
+{"schema":"olm.bundle","name":"baz.v0.2.0","package":"baz","image":"quay.io/baz/bundle:v0.2.0","properties":[{"type":"olm.package","value":{"packageName":"baz","version":"0.2.0"}},{"type":"olm.gvk","value":{"group":"example.com","version":"v1","kind":"Widget"}},{"type":"olm.package.required","value":{"packageName":"qux","versionRange":">=1.0.0"}}],"relatedImages":[{"name":"baz","image":"quay.io/baz/baz:v0.2.0"}]}
+`,
+}
+
+func TestGetRelatedImagesWithDependencies(t *testing.T) {
+	log := clog.New("trace")
+	manifest := &Manifest{Log: log}
+
+	catalogDir := t.TempDir()
+	for pkg, content := range testDependencies {
+		if err := os.MkdirAll(filepath.Join(catalogDir, "configs", pkg), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(catalogDir, "configs", pkg, catalogJson), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type testCase struct {
+		caseName string
+		op       v1alpha2.Operator
+		filter   map[string]v1alpha3.ISCPackage
+		expected []string
+	}
+	testCases := []testCase{
+		{
+			caseName: "Testing GetRelatedImagesFromCatalogByFilter (dependencies) : should pass",
+			op: v1alpha2.Operator{
+				Catalog:       "certified-operators:v4.7",
+				IncludeConfig: v1alpha2.IncludeConfig{Packages: []v1alpha2.IncludePackage{{Name: "foo"}}},
+			},
+			filter: map[string]v1alpha3.ISCPackage{"foo": {Channel: "stable"}},
+			// the newest bar in the range and the newest bundle providing the api,
+			// whose own requirement can't be satisfied
+			expected: []string{"bar.v1.2.0", "baz.v0.2.0", "foo.v1.0.0"},
+		},
+		{
+			caseName: "Testing GetRelatedImagesFromCatalogByFilter (dependency already selected) : should pass",
+			op: v1alpha2.Operator{
+				Catalog:       "certified-operators:v4.7",
+				IncludeConfig: v1alpha2.IncludeConfig{Packages: []v1alpha2.IncludePackage{{Name: "foo"}, {Name: "bar"}, {Name: "baz"}}},
+			},
+			filter: map[string]v1alpha3.ISCPackage{
+				"foo": {Channel: "stable"},
+				"bar": {Channel: "stable", MinVersion: "0.9.0", MaxVersion: "1.0.0"},
+				"baz": {Channel: "stable", MinVersion: "0.0.1", MaxVersion: "0.1.0"},
+			},
+			expected: []string{"bar.v1.0.0", "baz.v0.1.0", "foo.v1.0.0"},
+		},
+		{
+			caseName: "Testing GetRelatedImagesFromCatalogByFilter (skip dependencies) : should pass",
+			op: v1alpha2.Operator{
+				Catalog:          "certified-operators:v4.7",
+				SkipDependencies: true,
+				IncludeConfig:    v1alpha2.IncludeConfig{Packages: []v1alpha2.IncludePackage{{Name: "foo"}}},
+			},
+			filter:   map[string]v1alpha3.ISCPackage{"foo": {Channel: "stable"}},
+			expected: []string{"foo.v1.0.0"},
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.caseName, func(t *testing.T) {
+			res, _, err := manifest.GetRelatedImagesFromCatalogByFilter(catalogDir, "configs", c.op, c.filter)
+			if err != nil {
+				t.Fatalf("should not fail: %v", err)
+			}
+			var bundles []string
+			for name := range res {
+				bundles = append(bundles, name)
+			}
+			sort.Strings(bundles)
+			if strings.Join(bundles, ",") != strings.Join(c.expected, ",") {
+				t.Fatalf("expected bundles %v, got %v", c.expected, bundles)
+			}
+		})
+	}
+
+	t.Run("Testing GetRelatedImagesFromCatalogByFilter (unsatisfied requirements) : should pass", func(t *testing.T) {
+		op := v1alpha2.Operator{
+			Catalog:       "certified-operators:v4.7",
+			IncludeConfig: v1alpha2.IncludeConfig{Packages: []v1alpha2.IncludePackage{{Name: "baz"}}},
+		}
+		res, unsatisfied, err := manifest.GetRelatedImagesFromCatalogByFilter(catalogDir, "configs", op, map[string]v1alpha3.ISCPackage{"baz": {Channel: "stable"}})
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		if _, ok := res["baz.v0.2.0"]; !ok {
+			t.Fatalf("the selected bundle should be kept %v", res)
+		}
+		if len(unsatisfied) != 1 || unsatisfied[0] != "bundle baz.v0.2.0 requires package qux >=1.0.0, which is not provided by any bundle of the catalog (catalog certified-operators:v4.7)" {
+			t.Fatalf("unexpected unsatisfied requirements %v", unsatisfied)
+		}
+	})
+
+	t.Run("Testing resolveDependencies (unsatisfied requirements) : should pass", func(t *testing.T) {
+		catalog, err := readCatalogBundles(filepath.Join(catalogDir, "configs"))
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		relatedImages := map[string][]v1alpha3.RelatedImage{"baz.v0.2.0": nil}
+		unsatisfied, err := resolveDependencies(log, catalog, relatedImages)
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		if len(unsatisfied) != 1 || !strings.Contains(unsatisfied[0], "package qux >=1.0.0") {
+			t.Fatalf("unexpected unsatisfied requirements %v", unsatisfied)
+		}
+	})
+}
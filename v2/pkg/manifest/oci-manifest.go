@@ -8,11 +8,11 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 
 	digest "github.com/opencontainers/go-digest"
 
-	"github.com/blang/semver/v4"
 	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha2"
 	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
 	clog "github.com/openshift/oc-mirror/v2/pkg/log"
@@ -23,25 +23,29 @@ const (
 	index                   string = "index.json"
 	catalogJson             string = "catalog.json"
 	operatorImageExtractDir string = "hold-operator"
-	errorSemver             string = " semver %v "
+	whiteoutPrefix          string = ".wh."
+	whiteoutOpaqueDir       string = ".wh..wh..opq"
 )
 
 type ManifestInterface interface {
 	GetImageIndex(dir string) (*v1alpha3.OCISchema, error)
 	GetImageManifest(file string) (*v1alpha3.OCISchema, error)
+	GetPlatformManifest(dir string, oci *v1alpha3.OCISchema) (*v1alpha3.OCISchema, error)
 	GetOperatorConfig(file string) (*v1alpha3.OperatorConfigSchema, error)
 	GetRelatedImagesFromCatalog(filePath, label string) (map[string][]v1alpha3.RelatedImage, error)
-	GetRelatedImagesFromCatalogByFilter(filePath, label string, op v1alpha2.Operator, mp map[string]v1alpha3.ISCPackage) (map[string][]v1alpha3.RelatedImage, error)
+	GetRelatedImagesFromCatalogByFilter(filePath, label string, op v1alpha2.Operator, mp map[string]v1alpha3.ISCPackage) (map[string][]v1alpha3.RelatedImage, []string, error)
 	ExtractLayersOCI(filePath, toPath, label string, oci *v1alpha3.OCISchema) error
 	GetReleaseSchema(filePath string) ([]v1alpha3.RelatedImage, error)
 }
 
 type Manifest struct {
 	Log clog.PluggableLoggerInterface
+	// the platform of the manifests read from multi-arch images
+	Platform v1alpha3.OCIPlatform
 }
 
-func New(log clog.PluggableLoggerInterface) ManifestInterface {
-	return &Manifest{Log: log}
+func New(log clog.PluggableLoggerInterface, platform v1alpha3.OCIPlatform) ManifestInterface {
+	return &Manifest{Log: log, Platform: platform}
 }
 
 // GetImageIndex - used to get the oci index.json
@@ -114,20 +118,22 @@ func (o *Manifest) GetRelatedImagesFromCatalog(filePath, label string) (map[stri
 	return relatedImages, nil
 }
 
-// GetRelatedImagesFromCatalogByFilter
-func (o *Manifest) GetRelatedImagesFromCatalogByFilter(filePath, label string, op v1alpha2.Operator, mp map[string]v1alpha3.ISCPackage) (map[string][]v1alpha3.RelatedImage, error) {
+// GetRelatedImagesFromCatalogByFilter - the related images of the bundles selected by the filter,
+// and of the bundles they depend on unless SkipDependencies is set.
+// The dependencies that no bundle of the catalog satisfies are returned
+func (o *Manifest) GetRelatedImagesFromCatalogByFilter(filePath, label string, op v1alpha2.Operator, mp map[string]v1alpha3.ISCPackage) (map[string][]v1alpha3.RelatedImage, []string, error) {
 	relatedImages := make(map[string][]v1alpha3.RelatedImage)
 	for _, pkg := range op.Packages {
 		// the catalog.json - does not really conform to json standards
 		// this needs some thorough testing
 		olm, err := readOperatorCatalog(filePath + "/" + label + "/" + pkg.Name)
 		if err != nil {
-			return relatedImages, err
+			return relatedImages, nil, err
 		}
 
 		ri, err := getRelatedImageByFilter(o.Log, olm, mp[pkg.Name])
 		if err != nil {
-			return relatedImages, err
+			return relatedImages, nil, err
 		}
 		// append to reletedImages map
 		for k, v := range ri {
@@ -135,10 +141,30 @@ func (o *Manifest) GetRelatedImagesFromCatalogByFilter(filePath, label string, o
 		}
 		o.Log.Trace("related images %v", relatedImages)
 	}
-	return relatedImages, nil
+
+	// the bundles required by the selected bundles
+	// (olm.package.required, olm.gvk.required) are added
+	var unsatisfied []string
+	if !op.SkipDependencies {
+		catalog, err := readCatalogBundles(filePath + "/" + label)
+		if err != nil {
+			return relatedImages, nil, err
+		}
+		requirements, err := resolveDependencies(o.Log, catalog, relatedImages)
+		if err != nil {
+			return relatedImages, nil, err
+		}
+		for _, u := range requirements {
+			u = fmt.Sprintf("%s (catalog %s)", u, op.Catalog)
+			o.Log.Warn("[GetRelatedImagesFromCatalogByFilter] %s", u)
+			unsatisfied = append(unsatisfied, u)
+		}
+	}
+	return relatedImages, unsatisfied, nil
 }
 
-// ExtractLayersOCI
+// ExtractLayersOCI - extracts the files under label of the layers,
+// in order, honoring the whiteouts of the upper layers
 func (o *Manifest) ExtractLayersOCI(fromPath, toPath, label string, oci *v1alpha3.OCISchema) error {
 	if _, err := os.Stat(toPath + "/" + label); errors.Is(err, os.ErrNotExist) {
 		for _, blob := range oci.Layers {
@@ -151,6 +177,7 @@ func (o *Manifest) ExtractLayersOCI(fromPath, toPath, label string, oci *v1alpha
 				return err
 			}
 			err = untar(f, toPath, label)
+			f.Close()
 			if err != nil {
 				return err
 			}
@@ -192,6 +219,8 @@ func untar(gzipStream io.Reader, path string, cfgDirName string) error {
 		return fmt.Errorf("untar: gzipStream - %w", err)
 	}
 
+	// the files of this layer, that its opaque whiteouts don't remove
+	extracted := make(map[string]bool)
 	tarReader := tar.NewReader(uncompressedStream)
 	for {
 		header, err := tarReader.Next()
@@ -205,6 +234,21 @@ func untar(gzipStream io.Reader, path string, cfgDirName string) error {
 		}
 
 		if strings.Contains(header.Name, cfgDirName) {
+			// whiteouts remove the files of the lower layers
+			base := filepath.Base(header.Name)
+			if base == whiteoutOpaqueDir {
+				if err := removeDirContents(filepath.Join(path, filepath.Dir(header.Name)), extracted); err != nil {
+					return fmt.Errorf("untar: opaque whiteout %s failed: %v", header.Name, err)
+				}
+				continue
+			}
+			if strings.HasPrefix(base, whiteoutPrefix) {
+				if err := os.RemoveAll(filepath.Join(path, filepath.Dir(header.Name), strings.TrimPrefix(base, whiteoutPrefix))); err != nil {
+					return fmt.Errorf("untar: whiteout %s failed: %v", header.Name, err)
+				}
+				continue
+			}
+			extracted[filepath.Join(path, header.Name)] = true
 			switch header.Typeflag {
 			case tar.TypeDir:
 				if header.Name != "./" {
@@ -213,6 +257,10 @@ func untar(gzipStream io.Reader, path string, cfgDirName string) error {
 					}
 				}
 			case tar.TypeReg:
+				// the parent directory may have been removed by a whiteout
+				if err := os.MkdirAll(filepath.Dir(path+"/"+header.Name), 0755); err != nil {
+					return fmt.Errorf("untar: Mkdir() failed: %v", err)
+				}
 				outFile, err := os.Create(path + "/" + header.Name)
 				if err != nil {
 					return fmt.Errorf("untar: Create() failed: %v", err)
@@ -231,22 +279,66 @@ func untar(gzipStream io.Reader, path string, cfgDirName string) error {
 	return nil
 }
 
+// removeDirContents - removes the contents of the directory, if it exists,
+// but the files in keep (the directories in keep are emptied)
+func removeDirContents(dir string, keep map[string]bool) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		entry := filepath.Join(dir, e.Name())
+		if !e.IsDir() && keep[entry] {
+			continue
+		}
+		if e.IsDir() && keepsAny(entry, keep) {
+			if err := removeDirContents(entry, keep); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.RemoveAll(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keepsAny - true when keep has the directory or a file under it
+func keepsAny(dir string, keep map[string]bool) bool {
+	for k := range keep {
+		if k == dir || strings.HasPrefix(k, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
 // readOperatorCatalog - simple function tha treads the specific catalog.json file
 // and unmarshals it to DeclarativeConfig struct
 func readOperatorCatalog(path string) ([]v1alpha3.DeclarativeConfig, error) {
-	// the catalog.json - dos not really conform to json standards
-	// this needs some thorough testing
+	// the catalog.json is a stream of json objects, not a json array:
+	// the objects are decoded one by one (the values, i.e. the version
+	// ranges of the dependencies, are kept as they are)
 	// operatorImageExtractDir + "/" + label + "/" + name + "/" + catalogJson
 	var olm []v1alpha3.DeclarativeConfig
-	data, err := os.ReadFile(path + "/" + catalogJson)
+	f, err := os.Open(path + "/" + catalogJson)
 	if err != nil {
 		return []v1alpha3.DeclarativeConfig{}, err
 	}
-	tmp := strings.NewReplacer(" ", "").Replace(string(data))
-	updatedJson := "[" + strings.ReplaceAll(tmp, "}\n{", "},{") + "]"
-	err = json.Unmarshal([]byte(updatedJson), &olm)
-	if err != nil {
-		return []v1alpha3.DeclarativeConfig{}, err
+	defer f.Close()
+	decoder := json.NewDecoder(f)
+	for {
+		var obj v1alpha3.DeclarativeConfig
+		if err := decoder.Decode(&obj); err == io.EOF {
+			break
+		} else if err != nil {
+			return []v1alpha3.DeclarativeConfig{}, err
+		}
+		olm = append(olm, obj)
 	}
 	return olm, nil
 }
@@ -257,150 +349,113 @@ func getRelatedImageByDefaultChannel(log clog.PluggableLoggerInterface, olm []v1
 	// relevant variables
 	relatedImages := make(map[string][]v1alpha3.RelatedImage)
 	bundles := make(map[string]bool)
-	var defaultChannel string
+	versions, err := bundleVersions(olm)
+	if err != nil {
+		return relatedImages, err
+	}
+	defaultChannel := packageDefaultChannel(olm)
 
 	// iterate through the catalog objects
-	for i, obj := range olm {
-		switch {
-		case obj.Schema == "olm.channel":
-			if defaultChannel == obj.Name {
-				log.Debug("found channel : %v", obj)
-				log.Debug("bundle image to use : %v", obj.Entries[0].Name)
-				name, err := semverFindMax(obj.Entries)
-				if err != nil {
-					log.Error(errorSemver, err)
-				}
-				bundles[name] = true
+	for _, obj := range olm {
+		if obj.Schema == "olm.channel" && obj.Name == defaultChannel {
+			log.Debug("found channel : %v", obj)
+			graph, err := newChannelGraph(obj, versions)
+			if err != nil {
+				return relatedImages, err
 			}
-		case obj.Schema == "olm.bundle":
-			if bundles[obj.Name] {
-				log.Debug("config bundle: %d %v", i, obj.Name)
-				log.Trace("config relatedImages: %d %v", i, obj.RelatedImages)
-				relatedImages[obj.Name] = obj.RelatedImages
+			head, err := graph.head()
+			if err != nil {
+				return relatedImages, err
 			}
-		case obj.Schema == "olm.package":
-			log.Debug("Config package: %v", obj.Name)
-			defaultChannel = obj.DefaultChannel
+			log.Debug("bundle image to use : %v", head)
+			bundles[head] = true
 		}
 	}
+	addRelatedImages(log, olm, bundles, false, relatedImages)
 	return relatedImages, nil
 }
 
 // getRelatedImageByFilter - get the DeclarativeConfig for a specifc channel with
-// min,max version if set
+// min,max version if set. Without channel, the version range applies to the
+// default channel, and the HEAD of every channel is used when no range is set
 func getRelatedImageByFilter(log clog.PluggableLoggerInterface, olm []v1alpha3.DeclarativeConfig, pkg v1alpha3.ISCPackage) (map[string][]v1alpha3.RelatedImage, error) {
 	// relevant variables
 	relatedImages := make(map[string][]v1alpha3.RelatedImage)
 	bundles := make(map[string]bool)
+	versions, err := bundleVersions(olm)
+	if err != nil {
+		return relatedImages, err
+	}
+	hasRange := pkg.MinVersion != "" || pkg.MaxVersion != "" || pkg.MinBundle != ""
+	channel := pkg.Channel
+	if channel == "" && hasRange {
+		channel = packageDefaultChannel(olm)
+	}
+
 	// iterate through the catalog objects
-	for i, obj := range olm {
+	for _, obj := range olm {
+		if obj.Schema != "olm.channel" {
+			continue
+		}
+		var names []string
 		switch {
-		case obj.Schema == "olm.channel":
-			if len(pkg.Channel) > 0 {
-				if pkg.Channel == obj.Name {
-					log.Debug("found channel : %v", obj)
-					name, err := semverFindRange(obj.Entries, pkg.MinVersion, pkg.MaxVersion)
-					if err != nil {
-						log.Error(errorSemver, err)
-					}
-					for _, x := range name {
-						bundles[x] = true
-					}
-				}
-			} else {
-				name, err := semverFindMax(obj.Entries)
-				if err != nil {
-					log.Error(errorSemver, err)
-				}
-				log.Debug("adding channel : %s", name)
-				bundles[name] = true
+		case channel == "":
+			graph, err := newChannelGraph(obj, versions)
+			if err != nil {
+				return relatedImages, err
 			}
-		case obj.Schema == "olm.bundle":
-			if bundles[obj.Name] && !pkg.Full {
-				log.Debug("config bundle: %d %v", i, obj.Name)
-				log.Trace("config relatedImages: %d %v", i, obj.RelatedImages)
-				relatedImages[obj.Name] = obj.RelatedImages
+			head, err := graph.head()
+			if err != nil {
+				return relatedImages, err
+			}
+			log.Debug("adding channel : %s", head)
+			names = []string{head}
+		case channel == obj.Name:
+			log.Debug("found channel : %v", obj)
+			graph, err := newChannelGraph(obj, versions)
+			if err != nil {
+				return relatedImages, err
 			}
-			// add all bundles
-			if pkg.Full {
-				relatedImages[obj.Name] = obj.RelatedImages
+			names, err = graph.upgradePath(pkg.MinVersion, pkg.MaxVersion, pkg.MinBundle)
+			if err != nil {
+				return relatedImages, err
 			}
-		case obj.Schema == "olm.package":
-			log.Debug("config package: %v", obj.Name)
-			bundles[obj.DefaultChannel] = true
+		}
+		for _, name := range names {
+			bundles[name] = true
 		}
 	}
+	addRelatedImages(log, olm, bundles, pkg.Full, relatedImages)
 	return relatedImages, nil
 }
 
-// semverFindMax - finds the max bundle version
-func semverFindMax(entries []v1alpha3.ChannelEntry) (string, error) {
-	var max semver.Version
-	var index int
-	for id, s := range entries {
-		hld := strings.Split(s.Name, ".")
-		// we are only interested in 1,2,3 positions
-		if len(hld) < 4 {
-			return "", fmt.Errorf("versioning of string is not correct %s ", s.Name)
-		}
-		hld[1] = strings.Replace(hld[1], "v", "", -1)
-		end := strings.Split(hld[3], "-")
-		semStr := strings.Join([]string{hld[1], hld[2], end[0]}, ".")
-		version, err := semver.Parse(semStr)
-		if err != nil {
-			return "", err
-		}
-
-		if version.Compare(max) == 1 {
-			max = version
-			index = id
+// addRelatedImages - the related images of the bundles (all of them when full)
+func addRelatedImages(log clog.PluggableLoggerInterface, olm []v1alpha3.DeclarativeConfig, bundles map[string]bool, full bool, relatedImages map[string][]v1alpha3.RelatedImage) {
+	for i, obj := range olm {
+		if obj.Schema == "olm.bundle" && (full || bundles[obj.Name]) {
+			log.Debug("config bundle: %d %v", i, obj.Name)
+			log.Trace("config relatedImages: %d %v", i, obj.RelatedImages)
+			relatedImages[obj.Name] = withPackage(obj.RelatedImages, obj.Package)
 		}
 	}
-	return entries[index].Name, nil
 }
 
-// semverFindRange - finds the bundles between ranges version
-func semverFindRange(entries []v1alpha3.ChannelEntry, min, max string) ([]string, error) {
-
-	var minVersion semver.Version
-	var maxVersion semver.Version
-	var err error
-	var results []string
-
-	// parse the min max strings
-	if len(min) > 0 {
-		minVersion, err = semver.Parse(min)
-		if err != nil {
-			return []string{}, err
-		}
-	} else {
-		minVersion, _ = semver.Parse("0.0.0")
-	}
-	if len(max) > 0 {
-		maxVersion, err = semver.Parse(max)
-		if err != nil {
-			return []string{}, err
-		}
-	} else {
-		maxVersion, _ = semver.Parse("9.9.9")
+// withPackage - the related images of a bundle of the package
+func withPackage(images []v1alpha3.RelatedImage, pkg string) []v1alpha3.RelatedImage {
+	result := make([]v1alpha3.RelatedImage, 0, len(images))
+	for _, img := range images {
+		img.Package = pkg
+		result = append(result, img)
 	}
+	return result
+}
 
-	for _, s := range entries {
-		hld := strings.Split(s.Name, ".")
-		// we are only interested in 1,2,3 positions
-		if len(hld) < 4 {
-			return []string{}, fmt.Errorf("versioning of string is not correct %s ", s.Name)
-		}
-		hld[1] = strings.Replace(hld[1], "v", "", -1)
-		end := strings.Split(hld[3], "-")
-		semStr := strings.Join([]string{hld[1], hld[2], end[0]}, ".")
-		version, err := semver.Parse(semStr)
-		if err != nil {
-			return []string{}, err
-		}
-		if version.Compare(maxVersion) <= 0 && version.Compare(minVersion) >= 1 {
-			results = append(results, s.Name)
+// packageDefaultChannel - the default channel of the package
+func packageDefaultChannel(olm []v1alpha3.DeclarativeConfig) string {
+	for _, obj := range olm {
+		if obj.Schema == "olm.package" {
+			return obj.DefaultChannel
 		}
 	}
-	return results, nil
+	return ""
 }
@@ -74,7 +74,7 @@ func TestGetAllManifests(t *testing.T) {
 		filter := make(map[string]v1alpha3.ISCPackage)
 		iscp := v1alpha3.ISCPackage{Channel: "threescale-mas", MinVersion: "0.11.0", MaxVersion: "0.11.0"}
 		filter["3scale-operator"] = iscp
-		res, err := manifest.GetRelatedImagesFromCatalogByFilter("../../tests", "configs/", cfg, filter)
+		res, _, err := manifest.GetRelatedImagesFromCatalogByFilter("../../tests", "configs/", cfg, filter)
 		if err != nil {
 			log.Error(" %v ", err)
 			t.Fatalf("should not fail")
@@ -107,7 +107,7 @@ func TestGetAllManifests(t *testing.T) {
 		filter := make(map[string]v1alpha3.ISCPackage)
 		iscp := v1alpha3.ISCPackage{Channel: "threescale-mas", MinVersion: "0.11.0", MaxVersion: "0.11.0"}
 		filter["3scale-operator"] = iscp
-		res, err := manifest.GetRelatedImagesFromCatalogByFilter("../../tests", "configs/", cfg, filter)
+		res, _, err := manifest.GetRelatedImagesFromCatalogByFilter("../../tests", "configs/", cfg, filter)
 		if err != nil {
 			log.Error(" %v ", err)
 			t.Fatalf("should not fail")
@@ -131,7 +131,7 @@ func TestGetAllManifests(t *testing.T) {
 		filter := make(map[string]v1alpha3.ISCPackage)
 		iscp := v1alpha3.ISCPackage{}
 		filter["3scale-operator"] = iscp
-		res, err := manifest.GetRelatedImagesFromCatalogByFilter("../../tests", "configs/", cfg, filter)
+		res, _, err := manifest.GetRelatedImagesFromCatalogByFilter("../../tests", "configs/", cfg, filter)
 		if err != nil {
 			log.Error(" %v ", err)
 			t.Fatalf("should not fail")
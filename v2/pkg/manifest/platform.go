@@ -0,0 +1,123 @@
+package manifest
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	digest "github.com/opencontainers/go-digest"
+
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
+)
+
+const (
+	// DefaultPlatform - the platform of the manifests read from multi-arch images
+	DefaultPlatform string = "linux/amd64"
+
+	blobsDir          string = "blobs"
+	ociIndex          string = "application/vnd.oci.image.index.v1+json"
+	dockerList        string = "application/vnd.docker.distribution.manifest.list.v2+json"
+	maxNestedIndexes  int    = 8
+	platformSeparator string = "/"
+)
+
+// ParsePlatform - parses a platform in the os/arch[/variant] format,
+// DefaultPlatform when empty
+func ParsePlatform(platform string) (v1alpha3.OCIPlatform, error) {
+	if platform == "" {
+		platform = DefaultPlatform
+	}
+	parts := strings.Split(platform, platformSeparator)
+	if len(parts) < 2 || len(parts) > 3 {
+		return v1alpha3.OCIPlatform{}, fmt.Errorf("platform %q must be in the os/arch[/variant] format", platform)
+	}
+	for _, part := range parts {
+		if part == "" {
+			return v1alpha3.OCIPlatform{}, fmt.Errorf("platform %q must be in the os/arch[/variant] format", platform)
+		}
+	}
+	p := v1alpha3.OCIPlatform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		p.Variant = parts[2]
+	}
+	return p, nil
+}
+
+// GetPlatformManifest - the image manifest of the platform of the index (an OCI index
+// or a Docker manifest list, nested or not) of the oci layout in dir.
+// The first manifest is used when the index doesn't tell their platform
+func (o *Manifest) GetPlatformManifest(dir string, oci *v1alpha3.OCISchema) (*v1alpha3.OCISchema, error) {
+	platform := o.platform()
+	for i := 0; i < maxNestedIndexes; i++ {
+		desc, err := selectManifest(oci.Manifests, platform)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", dir, err)
+		}
+		validDigest, err := digest.Parse(desc.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("the digest format is not correct %s ", desc.Digest)
+		}
+		o.Log.Debug("manifest %s (%s) for platform %s", validDigest, desc.MediaType, platformString(platform))
+		oci, err = o.GetImageManifest(filepath.Join(dir, blobsDir, validDigest.Algorithm().String(), validDigest.Encoded()))
+		if err != nil {
+			return nil, err
+		}
+		if !isIndex(oci) {
+			return oci, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: more than %d nested indexes", dir, maxNestedIndexes)
+}
+
+// platform - the platform set for the manifest, linux/amd64 when not set
+func (o *Manifest) platform() v1alpha3.OCIPlatform {
+	if o.Platform.OS == "" || o.Platform.Architecture == "" {
+		p, _ := ParsePlatform("")
+		return p
+	}
+	return o.Platform
+}
+
+// selectManifest - the manifest of the platform, or the first one
+// when none of the manifests tell their platform
+func selectManifest(manifests []v1alpha3.OCIManifest, platform v1alpha3.OCIPlatform) (v1alpha3.OCIManifest, error) {
+	if len(manifests) == 0 {
+		return v1alpha3.OCIManifest{}, fmt.Errorf("no manifests found")
+	}
+	var available []string
+	for _, m := range manifests {
+		if m.Platform == nil {
+			continue
+		}
+		available = append(available, platformString(*m.Platform))
+		if m.Platform.OS == platform.OS && m.Platform.Architecture == platform.Architecture &&
+			(platform.Variant == "" || m.Platform.Variant == platform.Variant) {
+			return m, nil
+		}
+	}
+	if len(available) == 0 {
+		return manifests[0], nil
+	}
+	return v1alpha3.OCIManifest{}, fmt.Errorf("no manifest found for platform %s (available: %s)", platformString(platform), strings.Join(available, ", "))
+}
+
+// isIndex - true for an OCI index or a Docker manifest list
+func isIndex(oci *v1alpha3.OCISchema) bool {
+	switch oci.MediaType {
+	case ociIndex, dockerList:
+		return true
+	case "":
+		// the media type is optional in an OCI index
+		return len(oci.Manifests) > 0 && oci.Config.Digest == ""
+	default:
+		return false
+	}
+}
+
+func platformString(p v1alpha3.OCIPlatform) string {
+	parts := []string{p.OS, p.Architecture}
+	if p.Variant != "" {
+		parts = append(parts, p.Variant)
+	}
+	return strings.Join(parts, platformSeparator)
+}
@@ -0,0 +1,204 @@
+package manifest
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
+	clog "github.com/openshift/oc-mirror/v2/pkg/log"
+)
+
+// writeTestBlob - writes the blob to the oci layout in dir, the digest is returned
+func writeTestBlob(t *testing.T, dir string, blob []byte) string {
+	sum := fmt.Sprintf("%x", sha256.Sum256(blob))
+	if err := os.MkdirAll(filepath.Join(dir, blobsDir, "sha256"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, blobsDir, "sha256", sum), blob, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return "sha256:" + sum
+}
+
+func writeTestJSON(t *testing.T, dir string, obj interface{}) string {
+	blob, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writeTestBlob(t, dir, blob)
+}
+
+// writeTestLayer - a gzipped tar layer with the files (a nil content is a directory)
+func writeTestLayer(t *testing.T, dir string, files []string, contents map[string][]byte) string {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range files {
+		content := contents[name]
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if content == nil {
+			header = &tar.Header{Name: name, Mode: 0755, Typeflag: tar.TypeDir}
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return writeTestBlob(t, dir, buf.Bytes())
+}
+
+func TestParsePlatform(t *testing.T) {
+	type testCase struct {
+		caseName string
+		platform string
+		expected v1alpha3.OCIPlatform
+		err      bool
+	}
+	testCases := []testCase{
+		{caseName: "Testing ParsePlatform (default) : should pass", platform: "", expected: v1alpha3.OCIPlatform{OS: "linux", Architecture: "amd64"}},
+		{caseName: "Testing ParsePlatform (os/arch) : should pass", platform: "linux/arm64", expected: v1alpha3.OCIPlatform{OS: "linux", Architecture: "arm64"}},
+		{caseName: "Testing ParsePlatform (os/arch/variant) : should pass", platform: "linux/arm/v7", expected: v1alpha3.OCIPlatform{OS: "linux", Architecture: "arm", Variant: "v7"}},
+		{caseName: "Testing ParsePlatform (arch only) : should fail", platform: "arm64", err: true},
+		{caseName: "Testing ParsePlatform (empty arch) : should fail", platform: "linux/", err: true},
+	}
+	for _, c := range testCases {
+		t.Run(c.caseName, func(t *testing.T) {
+			res, err := ParsePlatform(c.platform)
+			if c.err {
+				if err == nil {
+					t.Fatalf("should fail, got %v", res)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("should not fail: %v", err)
+			}
+			if res != c.expected {
+				t.Fatalf("expected %v, got %v", c.expected, res)
+			}
+		})
+	}
+}
+
+func TestGetPlatformManifest(t *testing.T) {
+	log := clog.New("trace")
+	dir := t.TempDir()
+
+	// a Docker manifest list of two images, in the index of the layout
+	manifests := map[string]string{}
+	var list []v1alpha3.OCIManifest
+	for _, arch := range []string{"amd64", "arm64"} {
+		config := writeTestJSON(t, dir, map[string]string{"architecture": arch})
+		manifests[arch] = writeTestJSON(t, dir, v1alpha3.OCISchema{
+			SchemaVersion: 2,
+			MediaType:     "application/vnd.docker.distribution.manifest.v2+json",
+			Config:        v1alpha3.OCIManifest{MediaType: "application/vnd.docker.container.image.v1+json", Digest: config},
+		})
+		list = append(list, v1alpha3.OCIManifest{
+			MediaType: "application/vnd.docker.distribution.manifest.v2+json",
+			Digest:    manifests[arch],
+			Platform:  &v1alpha3.OCIPlatform{OS: "linux", Architecture: arch},
+		})
+	}
+	// the arm64 image is first, as the index order must not matter
+	list[0], list[1] = list[1], list[0]
+	listDigest := writeTestJSON(t, dir, v1alpha3.OCISchema{SchemaVersion: 2, MediaType: dockerList, Manifests: list})
+	index := &v1alpha3.OCISchema{
+		SchemaVersion: 2,
+		MediaType:     ociIndex,
+		Manifests:     []v1alpha3.OCIManifest{{MediaType: dockerList, Digest: listDigest}},
+	}
+
+	type testCase struct {
+		caseName string
+		platform v1alpha3.OCIPlatform
+		expected string
+		err      bool
+	}
+	testCases := []testCase{
+		{caseName: "Testing GetPlatformManifest (default platform) : should pass", expected: "amd64"},
+		{caseName: "Testing GetPlatformManifest (arm64) : should pass", platform: v1alpha3.OCIPlatform{OS: "linux", Architecture: "arm64"}, expected: "arm64"},
+		{caseName: "Testing GetPlatformManifest (s390x) : should fail", platform: v1alpha3.OCIPlatform{OS: "linux", Architecture: "s390x"}, err: true},
+	}
+	for _, c := range testCases {
+		t.Run(c.caseName, func(t *testing.T) {
+			manifest := &Manifest{Log: log, Platform: c.platform}
+			res, err := manifest.GetPlatformManifest(dir, index)
+			if c.err {
+				if err == nil {
+					t.Fatalf("should fail, got %v", res)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("should not fail: %v", err)
+			}
+			config, err := os.ReadFile(filepath.Join(dir, blobsDir, "sha256", res.Config.Digest[len("sha256:"):]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(config) != fmt.Sprintf(`{"architecture":%q}`, c.expected) {
+				t.Fatalf("expected the %s manifest, got %s", c.expected, string(config))
+			}
+		})
+	}
+
+	t.Run("Testing GetPlatformManifest (single image) : should pass", func(t *testing.T) {
+		manifest := &Manifest{Log: log, Platform: v1alpha3.OCIPlatform{OS: "linux", Architecture: "arm64"}}
+		single := &v1alpha3.OCISchema{SchemaVersion: 2, Manifests: []v1alpha3.OCIManifest{{Digest: manifests["amd64"]}}}
+		res, err := manifest.GetPlatformManifest(dir, single)
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		if res.Config.Digest == "" {
+			t.Fatalf("expected the image manifest, got %v", res)
+		}
+	})
+}
+
+func TestExtractLayersWhiteouts(t *testing.T) {
+	log := clog.New("trace")
+	dir := t.TempDir()
+	toDir := t.TempDir()
+
+	lower := writeTestLayer(t, dir,
+		[]string{"configs/", "configs/foo/", "configs/foo/catalog.json", "configs/bar/", "configs/bar/catalog.json", "configs/baz/", "configs/baz/catalog.json"},
+		map[string][]byte{"configs/foo/catalog.json": []byte("foo"), "configs/bar/catalog.json": []byte("bar"), "configs/baz/catalog.json": []byte("baz")})
+	// removes bar, and replaces the contents of baz
+	upper := writeTestLayer(t, dir,
+		[]string{"configs/.wh.bar", "configs/baz/new.json", "configs/baz/.wh..wh..opq"},
+		map[string][]byte{"configs/.wh.bar": {}, "configs/baz/new.json": []byte("new"), "configs/baz/.wh..wh..opq": {}})
+
+	manifest := &Manifest{Log: log}
+	oci := &v1alpha3.OCISchema{Layers: []v1alpha3.OCIManifest{{Digest: lower}, {Digest: upper}}}
+	if err := manifest.ExtractLayersOCI(filepath.Join(dir, blobsDir, "sha256"), toDir, "/configs", oci); err != nil {
+		t.Fatalf("should not fail: %v", err)
+	}
+	for file, exists := range map[string]bool{
+		"configs/foo/catalog.json": true,
+		"configs/bar":              false,
+		"configs/baz/catalog.json": false,
+		"configs/baz/new.json":     true,
+		"configs/.wh.bar":          false,
+	} {
+		_, err := os.Stat(filepath.Join(toDir, file))
+		if exists != (err == nil) {
+			t.Fatalf("%s: expected exists %v, got %v", file, exists, err)
+		}
+	}
+}
@@ -1,10 +1,16 @@
 package mirror
 
+import "time"
+
 const (
-	MirrorToDisk      = "mirrorToDisk"
-	DiskToMirror      = "diskToMirror"
-	Prepare           = "prepare"
-	CopyMode     Mode = "copy"
-	DeleteMode   Mode = "delete"
-	CheckMode    Mode = "check"
+	MirrorToDisk        = "mirrorToDisk"
+	DiskToMirror        = "diskToMirror"
+	MirrorToMirror      = "mirrorToMirror"
+	Prepare             = "prepare"
+	CopyMode       Mode = "copy"
+	DeleteMode     Mode = "delete"
+	CheckMode      Mode = "check"
 )
+
+// progressInterval - how often the progress of a copy is reported, to count the bytes transferred
+const progressInterval = time.Second
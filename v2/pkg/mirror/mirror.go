@@ -35,6 +35,13 @@ type MirrorDeleteInterface interface {
 	DeleteImage(ctx context.Context, image string, opts *CopyOptions) error
 }
 
+// CopyResult - the result of a copy: the digest of the manifest copied,
+// and the bytes of the blobs transferred (the blobs already at the destination are not counted)
+type CopyResult struct {
+	Digest string
+	Bytes  int64
+}
+
 // Mirror
 type Mirror struct {
 	mc   MirrorCopyInterface
@@ -70,8 +77,28 @@ func (o *MirrorCopy) CopyImage(ctx context.Context, pc *signature.PolicyContext,
 	return copy.Image(ctx, pc, destRef, srcRef, co)
 }
 
-func (o *MirrorDelete) DeleteImage(ctx context.Context, image string, co *CopyOptions) error {
-	return nil
+// DeleteImage - deletes the manifest referenced by image from its registry
+func (o *MirrorDelete) DeleteImage(ctx context.Context, image string, opts *CopyOptions) error {
+	imageRef, err := alltransports.ParseImageName(image)
+	if err != nil {
+		return fmt.Errorf("Invalid source name %s: %v", image, err)
+	}
+
+	sysCtx, err := opts.DestImage.NewSystemContext()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := opts.Global.CommandTimeoutContext()
+	defer cancel()
+
+	return retry.IfNecessary(ctx, func() error {
+		err := imageRef.DeleteImage(ctx, sysCtx)
+		if err != nil {
+			return err
+		}
+		return nil
+	}, opts.RetryOpts)
 }
 
 // copy - copy images setup and execute
@@ -242,6 +269,32 @@ func (o *Mirror) copy(ctx context.Context, src, dest string, opts *CopyOptions,
 		//OciEncryptConfig:                 encConfig,
 	}
 
+	// count the bytes transferred, when the result of the copy is requested.
+	// Each blob is counted once, even when the copy is retried
+	if opts.Result != nil {
+		progress := make(chan types.ProgressProperties)
+		done := make(chan struct{})
+		transferred := map[string]int64{}
+		go func() {
+			defer close(done)
+			for p := range progress {
+				if p.Event == types.ProgressEventDone {
+					transferred[p.Artifact.Digest.String()] = int64(p.Offset)
+				}
+			}
+		}()
+		co.Progress = progress
+		co.ProgressInterval = progressInterval
+		defer func() {
+			close(progress)
+			<-done
+			opts.Result.Bytes = 0
+			for _, size := range transferred {
+				opts.Result.Bytes += size
+			}
+		}()
+	}
+
 	return retry.IfNecessary(ctx, func() error {
 
 		//manifestBytes, err := copy.Image(ctx, policyContext, destRef, srcRef, &copy.Options{
@@ -250,6 +303,13 @@ func (o *Mirror) copy(ctx context.Context, src, dest string, opts *CopyOptions,
 			return err
 		}
 		out.Flush()
+		if opts.Result != nil {
+			manifestDigest, err := manifest.Digest(manifestBytes)
+			if err != nil {
+				return err
+			}
+			opts.Result.Digest = manifestDigest.String()
+		}
 		if opts.DigestFile != "" {
 			manifestDigest, err := manifest.Digest(manifestBytes)
 			if err != nil {
@@ -263,7 +323,8 @@ func (o *Mirror) copy(ctx context.Context, src, dest string, opts *CopyOptions,
 	}, opts.RetryOpts)
 }
 
-// check exists - checks if image exists
+// check exists - checks if image exists,
+// and reads the digest of its manifest when the result is requested
 func (o *Mirror) Check(ctx context.Context, image string, opts *CopyOptions) (bool, error) {
 
 	if err := ReexecIfNecessaryForImages([]string{image}...); err != nil {
@@ -284,10 +345,22 @@ func (o *Mirror) Check(ctx context.Context, image string, opts *CopyOptions) (bo
 	defer cancel()
 
 	err = retry.IfNecessary(ctx, func() error {
-		_, err := imageRef.NewImageSource(ctx, sysCtx)
+		src, err := imageRef.NewImageSource(ctx, sysCtx)
 		if err != nil {
 			return err
 		}
+		defer src.Close()
+		if opts.Result != nil {
+			manifestBytes, _, err := src.GetManifest(ctx, nil)
+			if err != nil {
+				return err
+			}
+			manifestDigest, err := manifest.Digest(manifestBytes)
+			if err != nil {
+				return err
+			}
+			opts.Result.Digest = manifestDigest.String()
+		}
 		return nil
 	}, opts.RetryOpts)
 
@@ -307,26 +380,7 @@ func (o *Mirror) delete(ctx context.Context, image string, opts *CopyOptions) er
 		return err
 	}
 
-	imageRef, err := alltransports.ParseImageName(image)
-	if err != nil {
-		return fmt.Errorf("Invalid source name %s: %v", image, err)
-	}
-
-	sysCtx, err := opts.DestImage.NewSystemContext()
-	if err != nil {
-		return err
-	}
-
-	ctx, cancel := opts.Global.CommandTimeoutContext()
-	defer cancel()
-
-	return retry.IfNecessary(ctx, func() error {
-		err := imageRef.DeleteImage(ctx, sysCtx)
-		if err != nil {
-			return err
-		}
-		return nil
-	}, opts.RetryOpts)
+	return o.md.DeleteImage(ctx, image, opts)
 }
 
 // parseMultiArch
@@ -4,11 +4,15 @@ import (
 	"bufio"
 	"context"
 	"os"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/containers/common/pkg/retry"
 	"github.com/containers/image/v5/copy"
 	"github.com/containers/image/v5/signature"
 	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
 )
 
 func TestMirror(t *testing.T) {
@@ -45,17 +49,75 @@ func TestMirror(t *testing.T) {
 			t.Fatal("should pass")
 		}
 	})
+
+	t.Run("Testing Worker (with result) : should pass", func(t *testing.T) {
+		resultOpts := opts
+		resultOpts.Result = &CopyResult{}
+		err := m.Run(context.Background(), "docker://localhost.localdomain:5000/test", "oci:test", "copy", &resultOpts, *writer)
+		if err != nil {
+			t.Fatal("should pass")
+		}
+		// sha256 of "test"
+		if resultOpts.Result.Digest != "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08" {
+			t.Fatalf("unexpected digest %s", resultOpts.Result.Digest)
+		}
+		// the skipped blob is not counted
+		if resultOpts.Result.Bytes != 300 {
+			t.Fatalf("expected 300 bytes transferred, got %d", resultOpts.Result.Bytes)
+		}
+	})
+
+	t.Run("Testing Worker (with result, retried) : should pass", func(t *testing.T) {
+		retried := New(&mockMirrorCopy{failures: 1}, md)
+		resultOpts := opts
+		resultOpts.Result = &CopyResult{}
+		resultOpts.RetryOpts = &retry.Options{MaxRetry: 1, Delay: time.Millisecond}
+		err := retried.Run(context.Background(), "docker://localhost.localdomain:5000/test", "oci:test", "copy", &resultOpts, *writer)
+		if err != nil {
+			t.Fatalf("should pass: %v", err)
+		}
+		// the blobs copied again are counted once
+		if resultOpts.Result.Bytes != 300 {
+			t.Fatalf("expected 300 bytes transferred, got %d", resultOpts.Result.Bytes)
+		}
+	})
+
+	t.Run("Testing Worker delete : should pass", func(t *testing.T) {
+		err := m.Run(context.Background(), "docker://localhost.localdomain:5000/test:v0.0.1", "", DeleteMode, &opts, *writer)
+		if err != nil {
+			t.Fatal("should pass")
+		}
+		if len(md.deleted) != 1 || md.deleted[0] != "docker://localhost.localdomain:5000/test:v0.0.1" {
+			t.Fatalf("should call DeleteImage for the image, got %v", md.deleted)
+		}
+	})
 }
 
 // mock
 
-type mockMirrorCopy struct{}
-type mockMirrorDelete struct{}
+type mockMirrorCopy struct {
+	// the number of copies that fail (after transferring the blobs)
+	failures int
+}
+type mockMirrorDelete struct {
+	deleted []string
+}
 
 func (o *mockMirrorCopy) CopyImage(ctx context.Context, pc *signature.PolicyContext, destRef, srcRef types.ImageReference, opts *copy.Options) ([]byte, error) {
+	if opts.Progress != nil {
+		opts.Progress <- types.ProgressProperties{Event: types.ProgressEventRead, Offset: 50}
+		opts.Progress <- types.ProgressProperties{Event: types.ProgressEventDone, Offset: 100, Artifact: types.BlobInfo{Digest: digest.FromString("a")}}
+		opts.Progress <- types.ProgressProperties{Event: types.ProgressEventSkipped, Offset: 1000, Artifact: types.BlobInfo{Digest: digest.FromString("b")}}
+		opts.Progress <- types.ProgressProperties{Event: types.ProgressEventDone, Offset: 200, Artifact: types.BlobInfo{Digest: digest.FromString("c")}}
+	}
+	if o.failures > 0 {
+		o.failures--
+		return nil, syscall.ECONNRESET
+	}
 	return []byte("test"), nil
 }
 
 func (o *mockMirrorDelete) DeleteImage(ctx context.Context, dest string, opts *CopyOptions) error {
+	o.deleted = append(o.deleted, dest)
 	return nil
 }
@@ -50,6 +50,20 @@ type GlobalOptions struct {
 	AdditionalFrom     string        // Used for additionalImages mirroring (diskToMirror)
 	Quiet              bool          // Suppress output information when copying images
 	Force              bool          // Force the copy/mirror even if there is nothing to update
+	ForceDelete        bool          // Delete the images listed in the delete plan from the destination registry
+	ParallelImages     uint          // Number of images copied in parallel
+	MaxPerRegistry     uint          // Number of concurrent copies allowed per registry
+	ArchiveCompression string        // Compression of the archive chunks: none, gzip or zstd
+	Resume             bool          // Skip the images copied successfully by the previous run
+	MirrorSetScope     string        // Scope of the sources of the IDMS/ITMS: registry, namespace or repository
+	Platform           string        // Platform (os/arch[/variant]) of the manifests read from multi-arch catalog and release images
+	MaxNestedPaths     int           // Maximum number of path components of the repositories in the destination registry, 0 for no limit
+	DryRun             bool          // Collect the images and write the mapping (and the images missing from the cache) without copying them
+	Since              string        // The archive holds the blobs not in the latest history snapshot taken before this date (mirrorToDisk)
+	SinceSnapshot      string        // The archive holds the blobs not in this history snapshot (mirrorToDisk)
+	FromRequest        string        // The archive holds the blobs not in this delta request of the diskToMirror side (mirrorToDisk)
+	WriteRequest       string        // Write the delta request of the images and blobs in the destination registry to this file (diskToMirror)
+	StrictConfig       bool          // Fail when --config differs from the image set configuration embedded in the archive (diskToMirror)
 	V2                 bool          // Redirect the flow to oc-mirror v2 - PLEASE DO NOT USE that. V2 is still under development and it is not ready to be used.
 }
 
@@ -59,25 +73,26 @@ type CopyOptions struct {
 	SrcImage                 *imageOptions
 	DestImage                *imageDestOptions
 	RetryOpts                *retry.Options
-	AdditionalTags           []string  // For docker-archive: destinations, in addition to the name:tag specified as destination, also add these
-	RemoveSignatures         bool      // Do not copy signatures from the source image
-	SignByFingerprint        string    // Sign the image using a GPG key with the specified fingerprint
-	SignBySigstorePrivateKey string    // Sign the image using a sigstore private key
-	SignPassphraseFile       string    // Path pointing to a passphrase file when signing (for either signature format, but only one of them)
-	SignIdentity             string    // Identity of the signed image, must be a fully specified docker reference
-	DigestFile               string    // Write digest to this file
-	Format                   string    // Force conversion of the image to a specified format
-	All                      bool      // Copy all of the images if the source is a list
-	MultiArch                string    // How to handle multi architecture images
-	PreserveDigests          bool      // Preserve digests during copy
-	EncryptLayer             []int     // The list of layers to encrypt
-	EncryptionKeys           []string  // Keys needed to encrypt the image
-	DecryptionKeys           []string  // Keys needed to decrypt the image
-	Mode                     string    // 2 options disktoMirror or mirrorToDisk (for now)
-	Dev                      bool      // developer mode - will be removed when completed
-	Destination              string    // what to target to
-	UUID                     uuid.UUID // set uuid
-	ImageType                string    // release, catalog-operator, additionalImage
+	AdditionalTags           []string    // For docker-archive: destinations, in addition to the name:tag specified as destination, also add these
+	RemoveSignatures         bool        // Do not copy signatures from the source image
+	SignByFingerprint        string      // Sign the image using a GPG key with the specified fingerprint
+	SignBySigstorePrivateKey string      // Sign the image using a sigstore private key
+	SignPassphraseFile       string      // Path pointing to a passphrase file when signing (for either signature format, but only one of them)
+	SignIdentity             string      // Identity of the signed image, must be a fully specified docker reference
+	DigestFile               string      // Write digest to this file
+	Format                   string      // Force conversion of the image to a specified format
+	All                      bool        // Copy all of the images if the source is a list
+	MultiArch                string      // How to handle multi architecture images
+	PreserveDigests          bool        // Preserve digests during copy
+	EncryptLayer             []int       // The list of layers to encrypt
+	EncryptionKeys           []string    // Keys needed to encrypt the image
+	DecryptionKeys           []string    // Keys needed to decrypt the image
+	Mode                     string      // one of mirrorToDisk, diskToMirror, mirrorToMirror or prepare
+	Dev                      bool        // developer mode - will be removed when completed
+	Destination              string      // what to target to
+	UUID                     uuid.UUID   // set uuid
+	ImageType                string      // release, catalog-operator, additionalImage
+	Result                   *CopyResult // when set, filled with the result of the copy
 }
 
 // deprecatedTLSVerifyOption represents a deprecated --tls-verify option,
@@ -139,10 +154,23 @@ func (cp CopyOptions) IsDiskToMirror() bool {
 	return cp.Mode == DiskToMirror
 }
 
+func (cp CopyOptions) IsMirrorToMirror() bool {
+	return cp.Mode == MirrorToMirror
+}
+
 func (cp CopyOptions) IsPrepare() bool {
 	return cp.Mode == Prepare
 }
 
+// WithDestinationAsSource - a copy of the options reading the images of the destination
+// registry with the --dest-* flags (credentials, tls verification...)
+func (cp CopyOptions) WithDestinationAsSource() CopyOptions {
+	if cp.DestImage != nil {
+		cp.SrcImage = cp.DestImage.imageOptions
+	}
+	return cp
+}
+
 // noteCloseFailure returns (possibly-nil) err modified to account for (non-nil) closeErr.
 // The error for closeErr is annotated with description (which is not a format string)
 // Typical usage:
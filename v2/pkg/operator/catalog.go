@@ -0,0 +1,414 @@
+package operator
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
+	"github.com/openshift/oc-mirror/v2/pkg/blocked"
+	"github.com/openshift/oc-mirror/v2/pkg/image"
+	"github.com/openshift/oc-mirror/v2/pkg/imagebuilder"
+	"github.com/openshift/oc-mirror/v2/pkg/manifest"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+const (
+	schemaPackage string = "olm.package"
+	schemaChannel string = "olm.channel"
+	schemaBundle  string = "olm.bundle"
+)
+
+// isFiltered - true when only part of the catalog is mirrored,
+// in which case the catalog is rebuilt with the selected bundles only
+func isFiltered(op v1alpha2.Operator) bool {
+	return !op.IsFBCOCI() && (len(op.Packages) > 0 || !op.Full)
+}
+
+// catalogBundles - the bundles kept in the rebuilt catalog. A bundle with an image
+// of the block list can't be installed from the mirror, it is left out of the catalog
+func (o LocalStorageCollector) catalogBundles(op v1alpha2.Operator, catalogImages map[string][]v1alpha3.RelatedImage) (map[string]bool, error) {
+	filter, err := blocked.New(o.Config.Mirror.BlockedImages)
+	if err != nil {
+		return nil, err
+	}
+	bundles := make(map[string]bool, len(catalogImages))
+	for name, images := range catalogImages {
+		bundles[name] = true
+		for _, img := range images {
+			if pattern, ok := filter.IsBlocked(img.Image); ok {
+				o.Log.Warn("bundle %s is left out of the rebuilt catalog %s: image %s is blocked by %s", name, op.Catalog, img.Image, pattern)
+				delete(bundles, name)
+				break
+			}
+		}
+	}
+	return bundles, nil
+}
+
+// catalogCacheReference - the reference of the catalog in the local cache,
+// named after its TargetName and TargetTag when set
+func (o LocalStorageCollector) catalogCacheReference(op v1alpha2.Operator) (string, error) {
+	imgSpec, err := image.ParseRef(op.Catalog)
+	if err != nil {
+		return "", err
+	}
+	ref := strings.Join([]string{o.LocalStorageFQDN, imgSpec.PathComponent}, "/") + ":" + imgSpec.Tag
+	if imgSpec.IsImageByDigest() {
+		ref = strings.Join([]string{o.LocalStorageFQDN, imgSpec.PathComponent}, "/") + ":" + imgSpec.Digest[:hashTruncLen]
+	}
+	return targetReference(ref, op.TargetName, op.TargetTag), nil
+}
+
+// rebuildCatalog - renders the declarative config of the bundles selected in the catalog
+// (configsDir is the declarative config extracted from the catalog image) and builds
+// the catalog image again on top of the original one, with the opm cache regenerated.
+// The catalog is pushed to the local cache, the reference is returned.
+// Nothing is built with --dry-run, the reference is returned only
+func (o LocalStorageCollector) rebuildCatalog(ctx context.Context, op v1alpha2.Operator, configsDir, label string, bundles map[string]bool) (string, error) {
+	ref, err := o.catalogCacheReference(op)
+	if err != nil {
+		return "", err
+	}
+	if o.Opts.Global.DryRun {
+		o.Log.Info("dry-run: catalog %s would be rebuilt as %s", op.Catalog, ref)
+		return ref, nil
+	}
+	platform, err := manifest.ParsePlatform(o.Opts.Global.Platform)
+	if err != nil {
+		return "", err
+	}
+	hld := strings.Split(op.Catalog, "/")
+	workDir := filepath.Join(o.Opts.Global.WorkingDir, operatorCatalogsDir, strings.Replace(hld[len(hld)-1], ":", "/", -1))
+	if err := os.RemoveAll(workDir); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		return "", err
+	}
+
+	filteredDir := filepath.Join(workDir, catalogConfigsDir)
+	if err := filterCatalog(configsDir, filteredDir, bundles); err != nil {
+		return "", fmt.Errorf("unable to render the declarative config of %s: %v", op.Catalog, err)
+	}
+
+	layoutPath, err := o.ImageBuilder.SaveImageLayoutToDir(ctx, op.Catalog, filepath.Join(workDir, catalogLayoutDir))
+	if err != nil {
+		return "", fmt.Errorf("unable to pull %s: %v", op.Catalog, err)
+	}
+
+	// the original configs are removed first, and then added back from the filtered ones
+	configsPath := path.Join("/", label)
+	deleteLayer, err := imagebuilder.DeleteLayer(configsPath)
+	if err != nil {
+		return "", err
+	}
+	configsLayer, err := imagebuilder.LayerFromPath(configsPath, filteredDir, 0, 0)
+	if err != nil {
+		return "", err
+	}
+	layers := []v1.Layer{deleteLayer, configsLayer}
+
+	// catalogs built with opm >= 1.25 serve a pre-computed cache, which
+	// must be regenerated for the filtered configs. The cache is added under
+	// /cache, the catalogs without cache are served as they were
+	var cmd []string
+	img, err := platformImage(layoutPath, platform)
+	if err != nil {
+		return "", err
+	}
+	if hasCache, err := servesCache(img); err != nil {
+		return "", err
+	} else if hasCache {
+		cacheDir := filepath.Join(workDir, catalogCacheDir)
+		if err := o.regenerateCache(ctx, img, filteredDir, cacheDir); err != nil {
+			return "", fmt.Errorf("unable to regenerate the cache of %s: %v", op.Catalog, err)
+		}
+		cacheLayer, err := imagebuilder.LayerFromPath(catalogCachePath, cacheDir, 0, 0)
+		if err != nil {
+			return "", err
+		}
+		layers = append(layers, cacheLayer)
+		cmd = []string{"serve", configsPath, "--cache-dir=" + catalogCachePath}
+	}
+
+	if err := o.ImageBuilder.BuildAndPush(ctx, ref, layoutPath, cmd, layers...); err != nil {
+		return "", fmt.Errorf("unable to rebuild %s: %v", op.Catalog, err)
+	}
+	o.Log.Info("catalog %s rebuilt as %s", op.Catalog, ref)
+	return ref, nil
+}
+
+// filterCatalog - writes the declarative config in fromDir to toDir, keeping
+// the selected bundles and the packages and channels they belong to only
+func filterCatalog(fromDir, toDir string, bundles map[string]bool) error {
+	var objs []map[string]interface{}
+	err := filepath.Walk(fromDir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		switch filepath.Ext(file) {
+		case ".json", ".yaml", ".yml":
+		default:
+			return nil
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		decoder := utilyaml.NewYAMLOrJSONDecoder(f, 4096)
+		for {
+			obj := map[string]interface{}{}
+			if err := decoder.Decode(&obj); err != nil {
+				if errors.Is(err, io.EOF) {
+					return nil
+				}
+				return fmt.Errorf("%s: %v", file, err)
+			}
+			if len(obj) > 0 {
+				objs = append(objs, obj)
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	// the packages of the selected bundles
+	packages := map[string]bool{}
+	for _, obj := range objs {
+		if obj["schema"] == schemaBundle && bundles[stringField(obj, "name")] {
+			packages[stringField(obj, "package")] = true
+		}
+	}
+
+	var result []map[string]interface{}
+	channels := map[string][]string{}
+	for _, obj := range objs {
+		switch obj["schema"] {
+		case schemaPackage:
+			if !packages[stringField(obj, "name")] {
+				continue
+			}
+		case schemaChannel:
+			if !packages[stringField(obj, "package")] {
+				continue
+			}
+			entries, _ := obj["entries"].([]interface{})
+			var kept []interface{}
+			for _, e := range entries {
+				if entry, ok := e.(map[string]interface{}); ok && bundles[stringField(entry, "name")] {
+					kept = append(kept, entry)
+				}
+			}
+			if len(kept) == 0 {
+				continue
+			}
+			obj["entries"] = kept
+			channels[stringField(obj, "package")] = append(channels[stringField(obj, "package")], stringField(obj, "name"))
+		case schemaBundle:
+			if !bundles[stringField(obj, "name")] || !packages[stringField(obj, "package")] {
+				continue
+			}
+		default:
+			if !packages[stringField(obj, "package")] {
+				continue
+			}
+		}
+		result = append(result, obj)
+	}
+
+	// the default channel of a package must still be in the catalog
+	for _, obj := range result {
+		if obj["schema"] != schemaPackage {
+			continue
+		}
+		names := channels[stringField(obj, "name")]
+		if len(names) == 0 || contains(names, stringField(obj, "defaultChannel")) {
+			continue
+		}
+		sort.Strings(names)
+		obj["defaultChannel"] = names[0]
+	}
+
+	if err := os.MkdirAll(toDir, 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(filepath.Join(toDir, indexJson))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	encoder := json.NewEncoder(f)
+	for _, obj := range result {
+		if err := encoder.Encode(obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// platformImage - the image of the catalog for the platform, the first image
+// of the layout when none of the manifests tell their platform
+func platformImage(layoutPath layout.Path, platform v1alpha3.OCIPlatform) (v1.Image, error) {
+	idx, err := layoutPath.ImageIndex()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		indexManifest, err := idx.IndexManifest()
+		if err != nil {
+			return nil, err
+		}
+		var first, nested *v1.Descriptor
+		var available []string
+		for i, desc := range indexManifest.Manifests {
+			if desc.Platform != nil {
+				available = append(available, desc.Platform.String())
+				if !matchesPlatform(*desc.Platform, platform) {
+					continue
+				}
+				if desc.MediaType.IsImage() {
+					return idx.Image(desc.Digest)
+				}
+				if desc.MediaType.IsIndex() {
+					nested = &indexManifest.Manifests[i]
+					break
+				}
+			}
+			switch {
+			case desc.MediaType.IsIndex() && nested == nil:
+				nested = &indexManifest.Manifests[i]
+			case desc.MediaType.IsImage() && first == nil:
+				first = &indexManifest.Manifests[i]
+			}
+		}
+		switch {
+		case nested != nil:
+			if idx, err = idx.ImageIndex(nested.Digest); err != nil {
+				return nil, err
+			}
+		case len(available) > 0:
+			return nil, fmt.Errorf("no image found in %s for platform %s (available: %s)", layoutPath, platformString(platform), strings.Join(available, ", "))
+		case first != nil:
+			return idx.Image(first.Digest)
+		default:
+			return nil, fmt.Errorf("no image found in %s", layoutPath)
+		}
+	}
+}
+
+// matchesPlatform - true when the platform of the manifest is the one requested,
+// any variant matching when none is requested
+func matchesPlatform(p v1.Platform, platform v1alpha3.OCIPlatform) bool {
+	return p.OS == platform.OS && p.Architecture == platform.Architecture &&
+		(platform.Variant == "" || p.Variant == platform.Variant)
+}
+
+func platformString(p v1alpha3.OCIPlatform) string {
+	return v1.Platform{OS: p.OS, Architecture: p.Architecture, Variant: p.Variant}.String()
+}
+
+// servesCache - true when the command of the catalog image serves a pre-computed cache
+func servesCache(img v1.Image) (bool, error) {
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return false, err
+	}
+	for _, arg := range cfg.Config.Cmd {
+		if strings.HasPrefix(arg, "--cache-dir") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// regenerateCache - runs the opm binary of the catalog image to compute
+// the cache of the declarative config in configsDir. The image must be built
+// for the platform oc-mirror runs on, for its opm binary to run
+func (o LocalStorageCollector) regenerateCache(ctx context.Context, img v1.Image, configsDir, cacheDir string) error {
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return err
+	}
+	if cfg.OS != "" && (cfg.OS != runtime.GOOS || cfg.Architecture != runtime.GOARCH) {
+		return fmt.Errorf("the opm binary of the catalog image is built for %s/%s and cannot run on %s/%s: run oc-mirror on %s/%s, or mirror the catalog with --platform %s/%s",
+			cfg.OS, cfg.Architecture, runtime.GOOS, runtime.GOARCH, cfg.OS, cfg.Architecture, runtime.GOOS, runtime.GOARCH)
+	}
+	opm, err := extractOPM(img, filepath.Dir(cacheDir))
+	if err != nil {
+		return err
+	}
+	absConfigs, err := filepath.Abs(configsDir)
+	if err != nil {
+		return err
+	}
+	absCache, err := filepath.Abs(cacheDir)
+	if err != nil {
+		return err
+	}
+	out, err := exec.CommandContext(ctx, opm, "serve", absConfigs, "--cache-dir", absCache, "--cache-only").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, string(out))
+	}
+	o.Log.Debug("opm cache regenerated in %s", cacheDir)
+	return nil
+}
+
+// extractOPM - saves the opm binary of the catalog image in dir
+func extractOPM(img v1.Image, dir string) (string, error) {
+	rc := mutate.Extract(img)
+	defer rc.Close()
+	tr := tar.NewReader(rc)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return "", fmt.Errorf("opm binary not found in the catalog image")
+		}
+		if err != nil {
+			return "", err
+		}
+		if header.Typeflag != tar.TypeReg || path.Base(header.Name) != "opm" {
+			continue
+		}
+		opm := filepath.Join(dir, "opm")
+		f, err := os.OpenFile(opm, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+		if err != nil {
+			return "", err
+		}
+		if _, err := io.Copy(f, tr); err != nil {
+			f.Close()
+			return "", err
+		}
+		return opm, f.Close()
+	}
+}
+
+func stringField(obj map[string]interface{}, name string) string {
+	s, _ := obj[name].(string)
+	return s
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,482 @@
+package operator
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
+	clog "github.com/openshift/oc-mirror/v2/pkg/log"
+	"github.com/openshift/oc-mirror/v2/pkg/manifest"
+	"github.com/openshift/oc-mirror/v2/pkg/mirror"
+)
+
+const testCatalog = `{"schema":"olm.package","name":"foo","defaultChannel":"stable"}
+{"schema":"olm.channel","name":"stable","package":"foo","entries":[{"name":"foo.v0.3.0"},{"name":"foo.v0.3.1","replaces":"foo.v0.3.0"}]}
+{"schema":"olm.channel","name":"fast","package":"foo","entries":[{"name":"foo.v0.3.1"},{"name":"foo.v0.4.0","replaces":"foo.v0.3.1"}]}
+{"schema":"olm.bundle","name":"foo.v0.3.0","package":"foo","image":"quay.io/foo/bundle@sha256:30"}
+{"schema":"olm.bundle","name":"foo.v0.3.1","package":"foo","image":"quay.io/foo/bundle@sha256:31"}
+{"schema":"olm.bundle","name":"foo.v0.4.0","package":"foo","image":"quay.io/foo/bundle@sha256:40","properties":[{"type":"olm.package","value":{"packageName":"foo","version":"0.4.0"}}]}
+{"schema":"olm.deprecations","package":"foo"}
+`
+
+const testOtherCatalog = `---
+schema: olm.package
+name: bar
+defaultChannel: stable
+---
+schema: olm.channel
+name: stable
+package: bar
+entries:
+- name: bar.v1.0.0
+---
+schema: olm.bundle
+name: bar.v1.0.0
+package: bar
+image: quay.io/bar/bundle@sha256:10
+`
+
+type mockImageBuilder struct {
+	layoutPath layout.Path
+	ref        string
+	cmd        []string
+	layers     []v1.Layer
+}
+
+func TestFilterCatalog(t *testing.T) {
+	fromDir := writeTestCatalog(t)
+
+	t.Run("Testing filterCatalog : should pass", func(t *testing.T) {
+		toDir := filepath.Join(t.TempDir(), "configs")
+		err := filterCatalog(fromDir, toDir, map[string]bool{"foo.v0.3.1": true, "foo.v0.4.0": true})
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		objs := readTestCatalog(t, filepath.Join(toDir, indexJson))
+		if len(objs) != 6 {
+			t.Fatalf("expected the package, 2 channels, 2 bundles and the deprecations of foo, got %v", objs)
+		}
+		for _, obj := range objs {
+			if obj["package"] == "bar" || obj["name"] == "bar" || obj["name"] == "foo.v0.3.0" {
+				t.Fatalf("unexpected object %v", obj)
+			}
+			if obj["schema"] == schemaChannel && obj["name"] == "stable" {
+				entries := obj["entries"].([]interface{})
+				if len(entries) != 1 || entries[0].(map[string]interface{})["replaces"] != "foo.v0.3.0" {
+					t.Fatalf("unexpected entries %v", entries)
+				}
+			}
+			if obj["schema"] == schemaBundle && obj["name"] == "foo.v0.4.0" && obj["properties"] == nil {
+				t.Fatalf("the bundles should be kept as they are %v", obj)
+			}
+		}
+	})
+
+	t.Run("Testing filterCatalog (default channel not selected) : should pass", func(t *testing.T) {
+		toDir := filepath.Join(t.TempDir(), "configs")
+		err := filterCatalog(fromDir, toDir, map[string]bool{"foo.v0.4.0": true, "bar.v1.0.0": true})
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		objs := readTestCatalog(t, filepath.Join(toDir, indexJson))
+		for _, obj := range objs {
+			if obj["schema"] == schemaChannel && obj["name"] == "stable" && obj["package"] == "foo" {
+				t.Fatalf("the channel without selected bundles should be removed")
+			}
+			if obj["schema"] == schemaPackage && obj["name"] == "foo" && obj["defaultChannel"] != "fast" {
+				t.Fatalf("the default channel should be updated %v", obj)
+			}
+			if obj["schema"] == schemaPackage && obj["name"] == "bar" && obj["defaultChannel"] != "stable" {
+				t.Fatalf("the default channel should be kept %v", obj)
+			}
+		}
+	})
+
+	t.Run("Testing filterCatalog (invalid declarative config) : should fail", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "catalog.json"), []byte(`{"schema":`), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := filterCatalog(dir, t.TempDir(), map[string]bool{}); err == nil {
+			t.Fatalf("should fail")
+		}
+	})
+}
+
+func TestRebuildCatalog(t *testing.T) {
+	log := clog.New("trace")
+
+	newCollector := func(t *testing.T, mode string, op v1alpha2.Operator, builder *mockImageBuilder) LocalStorageCollector {
+		cfg := v1alpha2.ImageSetConfiguration{}
+		cfg.Mirror.Operators = []v1alpha2.Operator{op}
+		return LocalStorageCollector{
+			Log:              log,
+			Config:           cfg,
+			Opts:             mirror.CopyOptions{Mode: mode, Destination: "myregistry/ns", Global: &mirror.GlobalOptions{WorkingDir: t.TempDir()}},
+			LocalStorageFQDN: "localhost:55000",
+			ImageBuilder:     builder,
+		}
+	}
+
+	op := v1alpha2.Operator{
+		Catalog:    "registry.redhat.io/redhat/redhat-operator-index:v4.14",
+		TargetName: "my-index",
+		TargetTag:  "v1",
+	}
+
+	t.Run("Testing rebuildCatalog : should pass", func(t *testing.T) {
+		builder := &mockImageBuilder{layoutPath: writeTestLayout(t, []string{"serve", "/configs"})}
+		o := newCollector(t, mirror.MirrorToDisk, op, builder)
+		ref, err := o.rebuildCatalog(context.Background(), op, writeTestCatalog(t), "/configs", map[string]bool{"foo.v0.4.0": true})
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		if ref != "localhost:55000/redhat/my-index:v1" || builder.ref != ref {
+			t.Fatalf("the catalog should be pushed to the cache with its target name and tag, got %s", builder.ref)
+		}
+		// no cache to regenerate
+		if builder.cmd != nil || len(builder.layers) != 2 {
+			t.Fatalf("unexpected cmd %v or layers %v", builder.cmd, builder.layers)
+		}
+		files := layerFiles(t, builder.layers[0])
+		if len(files) != 1 || files[0] != "/.wh.configs" {
+			t.Fatalf("the first layer should remove the configs %v", files)
+		}
+		files = layerFiles(t, builder.layers[1])
+		if !contains(files, "/configs/index.json") {
+			t.Fatalf("the second layer should add the filtered configs %v", files)
+		}
+		objs := readTestCatalog(t, filepath.Join(o.Opts.Global.WorkingDir, operatorCatalogsDir, "redhat-operator-index", "v4.14", catalogConfigsDir, indexJson))
+		if len(objs) != 4 {
+			t.Fatalf("unexpected declarative config %v", objs)
+		}
+	})
+
+	t.Run("Testing rebuildCatalog (no opm in the catalog) : should fail", func(t *testing.T) {
+		builder := &mockImageBuilder{layoutPath: writeTestLayout(t, []string{"serve", "/configs", "--cache-dir=/tmp/cache"})}
+		o := newCollector(t, mirror.MirrorToDisk, op, builder)
+		_, err := o.rebuildCatalog(context.Background(), op, writeTestCatalog(t), "/configs", map[string]bool{"foo.v0.4.0": true})
+		if err == nil || !strings.Contains(err.Error(), "opm binary not found") {
+			t.Fatalf("should fail: %v", err)
+		}
+	})
+
+	t.Run("Testing rebuildCatalog (opm built for another platform) : should fail", func(t *testing.T) {
+		arch := "s390x"
+		if runtime.GOARCH == arch {
+			arch = "arm64"
+		}
+		builder := &mockImageBuilder{layoutPath: writeTestIndex(t, []string{"serve", "/configs", "--cache-dir=/tmp/cache"}, "linux/amd64", "linux/"+arch)}
+		o := newCollector(t, mirror.MirrorToDisk, op, builder)
+		o.Opts.Global.Platform = "linux/" + arch
+		_, err := o.rebuildCatalog(context.Background(), op, writeTestCatalog(t), "/configs", map[string]bool{"foo.v0.4.0": true})
+		if err == nil || !strings.Contains(err.Error(), "is built for linux/"+arch+" and cannot run on "+runtime.GOOS+"/"+runtime.GOARCH) {
+			t.Fatalf("should fail: %v", err)
+		}
+		if builder.ref != "" {
+			t.Fatalf("the catalog should not be pushed %s", builder.ref)
+		}
+	})
+
+	t.Run("Testing rebuildCatalog (dry-run) : should pass", func(t *testing.T) {
+		builder := &mockImageBuilder{}
+		o := newCollector(t, mirror.MirrorToDisk, op, builder)
+		o.Opts.Global.DryRun = true
+		ref, err := o.rebuildCatalog(context.Background(), op, writeTestCatalog(t), "/configs", map[string]bool{"foo.v0.4.0": true})
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		if ref != "localhost:55000/redhat/my-index:v1" || builder.ref != "" {
+			t.Fatalf("the catalog should not be rebuilt with --dry-run, got %s (pushed %s)", ref, builder.ref)
+		}
+		if _, err := os.Stat(filepath.Join(o.Opts.Global.WorkingDir, operatorCatalogsDir)); !os.IsNotExist(err) {
+			t.Fatalf("nothing should be written with --dry-run: %v", err)
+		}
+	})
+
+	t.Run("Testing OperatorImageCollector (dry-run) : should not copy or build", func(t *testing.T) {
+		builder := &mockImageBuilder{}
+		recorder := &recordingMirror{}
+		o := newCollector(t, mirror.MirrorToMirror, op, builder)
+		o.Opts.Global.DryRun = true
+		o.Mirror = recorder
+		o.Manifest = &MockManifest{Log: log}
+		o.Config.Mirror.Operators[0].Packages = []v1alpha2.IncludePackage{{Name: "foo"}}
+		res, err := o.OperatorImageCollector(context.Background())
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		// the catalog is only read to the working-dir
+		for _, dest := range recorder.destinations {
+			if !strings.HasPrefix(dest, ociProtocolTrimmed) {
+				t.Fatalf("nothing should be copied to a registry with --dry-run, got %s", dest)
+			}
+		}
+		if builder.ref != "" {
+			t.Fatalf("the catalog should not be rebuilt with --dry-run, pushed %s", builder.ref)
+		}
+		found := false
+		for _, img := range res {
+			if img.Destination == "myregistry/ns/redhat/my-index:v1" {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("the rebuilt catalog should be collected %v", res)
+		}
+	})
+
+	t.Run("Testing withCatalogTargets : should pass", func(t *testing.T) {
+		type testCase struct {
+			mode      string
+			rebuilt   bool
+			src, dest string
+			expSrc    string
+			expDest   string
+		}
+		testCases := []testCase{
+			{mirror.MirrorToDisk, true, "docker://" + op.Catalog, "docker://localhost:55000/redhat/redhat-operator-index:v4.14", "docker://localhost:55000/redhat/my-index:v1", "docker://localhost:55000/redhat/my-index:v1"},
+			{mirror.MirrorToDisk, false, "docker://" + op.Catalog, "docker://localhost:55000/redhat/redhat-operator-index:v4.14", "docker://" + op.Catalog, "docker://localhost:55000/redhat/my-index:v1"},
+			{mirror.DiskToMirror, false, "docker://localhost:55000/redhat/redhat-operator-index:v4.14", "docker://myregistry/ns/redhat/redhat-operator-index:v4.14", "docker://localhost:55000/redhat/my-index:v1", "docker://myregistry/ns/redhat/my-index:v1"},
+			{mirror.MirrorToMirror, true, "docker://" + op.Catalog, "myregistry/ns/redhat/redhat-operator-index:v4.14", "docker://localhost:55000/redhat/my-index:v1", "myregistry/ns/redhat/my-index:v1"},
+			{mirror.MirrorToMirror, false, "docker://" + op.Catalog, "myregistry/ns/redhat/redhat-operator-index:v4.14", "docker://" + op.Catalog, "myregistry/ns/redhat/my-index:v1"},
+		}
+		for _, c := range testCases {
+			o := newCollector(t, c.mode, op, &mockImageBuilder{})
+			images := []v1alpha3.CopyImageSchema{
+				{Origin: op.Catalog, Source: c.src, Destination: c.dest},
+				{Origin: "quay.io/foo/bundle@sha256:40", Source: "docker://quay.io/foo/bundle@sha256:40", Destination: "docker://localhost:55000/foo/bundle:40"},
+			}
+			res, err := o.withCatalogTargets(images, map[string]bool{op.Catalog: c.rebuilt})
+			if err != nil {
+				t.Fatalf("should not fail: %v", err)
+			}
+			if res[0].Source != c.expSrc || res[0].Destination != c.expDest {
+				t.Fatalf("%s (rebuilt %v): unexpected catalog copy %v", c.mode, c.rebuilt, res[0])
+			}
+			if res[1].Destination != "docker://localhost:55000/foo/bundle:40" {
+				t.Fatalf("only the catalog should be renamed %v", res[1])
+			}
+		}
+	})
+}
+
+// recordingMirror - records the destinations of the copies
+type recordingMirror struct {
+	MockMirror
+	destinations []string
+}
+
+func (o *recordingMirror) Run(ctx context.Context, src, dest string, mode mirror.Mode, opts *mirror.CopyOptions, stdout bufio.Writer) error {
+	o.destinations = append(o.destinations, dest)
+	return nil
+}
+
+func TestCatalogBundles(t *testing.T) {
+	catalogImages := map[string][]v1alpha3.RelatedImage{
+		"foo.v0.3.1": {{Name: "foo.v0.3.1", Image: "quay.io/foo/bundle@sha256:31"}, {Name: "operator", Image: "quay.io/foo/operator:v0.3.1"}},
+		"foo.v0.4.0": {{Name: "foo.v0.4.0", Image: "quay.io/foo/bundle@sha256:40"}, {Name: "operator", Image: "quay.io/foo/debug:v0.4.0"}},
+	}
+	op := v1alpha2.Operator{Catalog: "redhat/my-index:v1"}
+
+	t.Run("Testing catalogBundles : should pass", func(t *testing.T) {
+		o := LocalStorageCollector{Log: clog.New("trace")}
+		o.Config.Mirror.BlockedImages = []v1alpha2.Image{{Name: "quay.io/foo/debug"}}
+		bundles, err := o.catalogBundles(op, catalogImages)
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		if len(bundles) != 1 || !bundles["foo.v0.3.1"] {
+			t.Fatalf("the bundle with a blocked image should be left out %v", bundles)
+		}
+	})
+
+	t.Run("Testing catalogBundles (no block list) : should pass", func(t *testing.T) {
+		o := LocalStorageCollector{Log: clog.New("trace")}
+		bundles, err := o.catalogBundles(op, catalogImages)
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		if len(bundles) != 2 {
+			t.Fatalf("all the bundles should be kept %v", bundles)
+		}
+	})
+
+	t.Run("Testing catalogBundles (invalid block list) : should fail", func(t *testing.T) {
+		o := LocalStorageCollector{Log: clog.New("trace")}
+		o.Config.Mirror.BlockedImages = []v1alpha2.Image{{Name: "regex:("}}
+		if _, err := o.catalogBundles(op, catalogImages); err == nil {
+			t.Fatalf("should fail")
+		}
+	})
+}
+
+func TestPlatformImage(t *testing.T) {
+	t.Run("Testing platformImage : should pass", func(t *testing.T) {
+		p := writeTestIndex(t, nil, "linux/amd64", "linux/arm64", "linux/arm/v7")
+		for _, platform := range []string{"linux/amd64", "linux/arm64", "linux/arm", "linux/arm/v7"} {
+			exp, err := manifest.ParsePlatform(platform)
+			if err != nil {
+				t.Fatal(err)
+			}
+			img, err := platformImage(p, exp)
+			if err != nil {
+				t.Fatalf("%s: should not fail: %v", platform, err)
+			}
+			cfg, err := img.ConfigFile()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.OS != exp.OS || cfg.Architecture != exp.Architecture {
+				t.Fatalf("%s: unexpected image for %s/%s", platform, cfg.OS, cfg.Architecture)
+			}
+		}
+	})
+
+	t.Run("Testing platformImage (no platform in the index) : should pass", func(t *testing.T) {
+		p := writeTestLayout(t, nil)
+		if _, err := platformImage(p, v1alpha3.OCIPlatform{OS: "linux", Architecture: "s390x"}); err != nil {
+			t.Fatalf("the first image should be used: %v", err)
+		}
+	})
+
+	t.Run("Testing platformImage (platform not found) : should fail", func(t *testing.T) {
+		p := writeTestIndex(t, nil, "linux/amd64", "linux/arm64")
+		_, err := platformImage(p, v1alpha3.OCIPlatform{OS: "linux", Architecture: "s390x"})
+		if err == nil || !strings.Contains(err.Error(), "for platform linux/s390x (available: linux/amd64, linux/arm64)") {
+			t.Fatalf("should fail: %v", err)
+		}
+	})
+}
+
+// writeTestCatalog - a declarative config, with a folder per package
+func writeTestCatalog(t *testing.T) string {
+	dir := t.TempDir()
+	for pkg, content := range map[string]string{"foo/catalog.json": testCatalog, "bar/catalog.yaml": testOtherCatalog} {
+		file := filepath.Join(dir, pkg)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func readTestCatalog(t *testing.T, file string) []map[string]interface{} {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var objs []map[string]interface{}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	for decoder.More() {
+		obj := map[string]interface{}{}
+		if err := decoder.Decode(&obj); err != nil {
+			t.Fatal(err)
+		}
+		objs = append(objs, obj)
+	}
+	return objs
+}
+
+// writeTestLayout - an OCI layout holding an image with cmd
+func writeTestLayout(t *testing.T, cmd []string) layout.Path {
+	img, err := random.Image(64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err = mutate.Config(img, v1.Config{Cmd: cmd})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := layout.Write(t.TempDir(), empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AppendImage(img); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// writeTestIndex - an OCI layout holding an image with cmd for each platform
+func writeTestIndex(t *testing.T, cmd []string, platforms ...string) layout.Path {
+	idx := v1.ImageIndex(empty.Index)
+	for _, platform := range platforms {
+		parsed, err := manifest.ParsePlatform(platform)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := random.Image(64, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := img.ConfigFile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.OS, cfg.Architecture, cfg.Variant = parsed.OS, parsed.Architecture, parsed.Variant
+		cfg.Config.Cmd = cmd
+		if img, err = mutate.ConfigFile(img, cfg); err != nil {
+			t.Fatal(err)
+		}
+		idx = mutate.AppendManifests(idx, mutate.IndexAddendum{
+			Add: img,
+			Descriptor: v1.Descriptor{
+				Platform: &v1.Platform{OS: parsed.OS, Architecture: parsed.Architecture, Variant: parsed.Variant},
+			},
+		})
+	}
+	p, err := layout.Write(t.TempDir(), idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func layerFiles(t *testing.T, layer v1.Layer) []string {
+	rc, err := layer.Uncompressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	var files []string
+	tr := tar.NewReader(rc)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, header.Name)
+	}
+}
+
+func (o *mockImageBuilder) BuildAndPush(ctx context.Context, targetRef string, layoutPath layout.Path, cmd []string, layers ...v1.Layer) error {
+	o.ref = targetRef
+	o.cmd = cmd
+	o.layers = layers
+	return nil
+}
+
+func (o *mockImageBuilder) SaveImageLayoutToDir(ctx context.Context, imgRef string, layoutDir string) (layout.Path, error) {
+	return o.layoutPath, nil
+}
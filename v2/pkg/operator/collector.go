@@ -31,6 +31,8 @@ type Collector struct {
 	Manifest manifest.ManifestInterface
 	Config   v1alpha2.ImageSetConfiguration
 	Opts     mirror.CopyOptions
+	// the dependencies not satisfied by the catalogs of the last collect
+	unsatisfied []string
 }
 
 // OperatorImageCollector - this looks into the operator index image
@@ -45,6 +47,7 @@ func (o *Collector) OperatorImageCollector(ctx context.Context) ([]v1alpha3.Copy
 		dir       string
 	)
 	compare := make(map[string]v1alpha3.ISCPackage)
+	o.unsatisfied = nil
 	relatedImages := make(map[string][]v1alpha3.RelatedImage)
 
 	// compile a map to compare channels,min & max versions
@@ -52,8 +55,12 @@ func (o *Collector) OperatorImageCollector(ctx context.Context) ([]v1alpha3.Copy
 		o.Log.Info("isc operators: %s\n", ops.Catalog)
 		for _, pkg := range ops.Packages {
 			o.Log.Info("catalog packages: %s \n", pkg.Name)
+			// the version range of the package applies to its default channel
+			if len(pkg.Channels) == 0 {
+				compare[pkg.Name] = v1alpha3.ISCPackage{MinVersion: pkg.MinVersion, MaxVersion: pkg.MaxVersion, MinBundle: pkg.MinBundle, Full: ops.Full}
+			}
 			for _, channel := range pkg.Channels {
-				compare[pkg.Name] = v1alpha3.ISCPackage{Channel: channel.Name, MinVersion: channel.MinVersion, MaxVersion: channel.MaxVersion, Full: ops.Full}
+				compare[pkg.Name] = v1alpha3.ISCPackage{Channel: channel.Name, MinVersion: channel.MinVersion, MaxVersion: channel.MaxVersion, MinBundle: channel.MinBundle, Full: ops.Full}
 				o.Log.Info("channels: %v \n", compare)
 			}
 		}
@@ -105,19 +112,10 @@ func (o *Collector) OperatorImageCollector(ctx context.Context) ([]v1alpha3.Copy
 			if len(oci.Manifests) == 0 {
 				return []v1alpha3.CopyImageSchema{}, fmt.Errorf("[OperatorImageCollector] no manifests found for %s ", op.Catalog)
 			}
-			validDigest, err := digest.Parse(oci.Manifests[0].Digest)
+			// read the operator image manifest of the platform
+			oci, err = o.Manifest.GetPlatformManifest(dir, oci)
 			if err != nil {
-				return []v1alpha3.CopyImageSchema{}, fmt.Errorf("[OperatorImageCollector] the disgets seems to be incorrect for %s ", op.Catalog)
-			}
-
-			manifest := validDigest.Encoded()
-			o.Log.Info("manifest %v", manifest)
-
-			// read the operator image manifest
-			manifestDir := strings.Join([]string{dir, blobsDir, manifest}, "/")
-			oci, err = o.Manifest.GetImageManifest(manifestDir)
-			if err != nil {
-				return []v1alpha3.CopyImageSchema{}, err
+				return []v1alpha3.CopyImageSchema{}, fmt.Errorf("[OperatorImageCollector] %s: %v ", op.Catalog, err)
 			}
 
 			// read the config digest to get the detailed manifest
@@ -153,10 +151,12 @@ func (o *Collector) OperatorImageCollector(ctx context.Context) ([]v1alpha3.Copy
 				}
 			} else {
 				// iterate through each package
-				relatedImages, err = o.Manifest.GetRelatedImagesFromCatalogByFilter(cacheDir, label, op, compare)
+				var unsatisfied []string
+				relatedImages, unsatisfied, err = o.Manifest.GetRelatedImagesFromCatalogByFilter(cacheDir, label, op, compare)
 				if err != nil {
 					return []v1alpha3.CopyImageSchema{}, err
 				}
+				o.unsatisfied = append(o.unsatisfied, unsatisfied...)
 			}
 		}
 
@@ -207,6 +207,12 @@ func (o *Collector) OperatorImageCollector(ctx context.Context) ([]v1alpha3.Copy
 	return allImages, nil
 }
 
+// UnsatisfiedDependencies - the dependencies of the bundles collected
+// that no bundle of their catalog satisfies
+func (o *Collector) UnsatisfiedDependencies() []string {
+	return o.unsatisfied
+}
+
 // customImageParser - simple image string parser
 func customImageParser(image string) (*v1alpha3.ImageRefSchema, error) {
 	var irs *v1alpha3.ImageRefSchema
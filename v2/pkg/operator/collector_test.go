@@ -181,8 +181,8 @@ func (o MockManifest) GetOperatorConfig(file string) (*v1alpha3.OperatorConfigSc
 	return ocs, nil
 }
 
-func (o MockManifest) GetRelatedImagesFromCatalogByFilter(filePath, label string, op v1alpha2.Operator, mp map[string]v1alpha3.ISCPackage) (map[string][]v1alpha3.RelatedImage, error) {
-	return nil, nil
+func (o MockManifest) GetRelatedImagesFromCatalogByFilter(filePath, label string, op v1alpha2.Operator, mp map[string]v1alpha3.ISCPackage) (map[string][]v1alpha3.RelatedImage, []string, error) {
+	return nil, nil, nil
 }
 
 func (o MockManifest) GetReleaseSchema(filePath string) ([]v1alpha3.RelatedImage, error) {
@@ -208,6 +208,10 @@ func (o MockManifest) GetImageIndex(name string) (*v1alpha3.OCISchema, error) {
 	}, nil
 }
 
+func (o MockManifest) GetPlatformManifest(dir string, oci *v1alpha3.OCISchema) (*v1alpha3.OCISchema, error) {
+	return o.GetImageManifest(dir)
+}
+
 func (o MockManifest) GetImageManifest(name string) (*v1alpha3.OCISchema, error) {
 	return &v1alpha3.OCISchema{
 		SchemaVersion: 2,
@@ -242,3 +246,20 @@ func (o MockManifest) ExtractLayersOCI(filePath, toPath, label string, oci *v1al
 func (o MockManifest) ExtractLayers(filePath, name, label string) error {
 	return nil
 }
+
+func TestTargetReference(t *testing.T) {
+	cases := []struct {
+		ref, name, tag, expected string
+	}{
+		{"docker://myregistry/ns/redhat/redhat-operator-index:v4.14", "", "", "docker://myregistry/ns/redhat/redhat-operator-index:v4.14"},
+		{"docker://myregistry/ns/redhat/redhat-operator-index:v4.14", "my-index", "", "docker://myregistry/ns/redhat/my-index:v4.14"},
+		{"docker://myregistry/ns/redhat/redhat-operator-index:v4.14", "", "v1", "docker://myregistry/ns/redhat/redhat-operator-index:v1"},
+		{"myregistry:5000/redhat/redhat-operator-index:1a2b3c4d5e6f", "my-index", "v1", "myregistry:5000/redhat/my-index:v1"},
+		{"myregistry:5000/redhat/redhat-operator-index@sha256:1a2b", "", "v1", "myregistry:5000/redhat/redhat-operator-index:v1"},
+	}
+	for _, c := range cases {
+		if res := targetReference(c.ref, c.name, c.tag); res != c.expected {
+			t.Fatalf("expected %s, got %s", c.expected, res)
+		}
+	}
+}
@@ -10,4 +10,9 @@ const (
 	blobsDir                string = "blobs/sha256" // TODO blobsDir should not make assumptions about algorithm
 	errMsg                  string = "[OperatorImageCollector] %v "
 	logsFile                string = "logs/operator.log"
+	operatorCatalogsDir     string = "operator-catalogs"
+	catalogConfigsDir       string = "configs"
+	catalogLayoutDir        string = "layout"
+	catalogCacheDir         string = "cache"
+	catalogCachePath        string = "/cache"
 )
@@ -8,4 +8,7 @@ import (
 
 type CollectorInterface interface {
 	OperatorImageCollector(ctx context.Context) ([]v1alpha3.CopyImageSchema, error)
+	// UnsatisfiedDependencies - the dependencies of the bundles collected
+	// that no bundle of their catalog satisfies
+	UnsatisfiedDependencies() []string
 }
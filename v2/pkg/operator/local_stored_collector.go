@@ -14,6 +14,7 @@ import (
 	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha2"
 	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
 	"github.com/openshift/oc-mirror/v2/pkg/image"
+	"github.com/openshift/oc-mirror/v2/pkg/imagebuilder"
 	clog "github.com/openshift/oc-mirror/v2/pkg/log"
 	"github.com/openshift/oc-mirror/v2/pkg/manifest"
 	"github.com/openshift/oc-mirror/v2/pkg/mirror"
@@ -30,6 +31,9 @@ type LocalStorageCollector struct {
 	Config           v1alpha2.ImageSetConfiguration
 	Opts             mirror.CopyOptions
 	LocalStorageFQDN string
+	ImageBuilder     imagebuilder.ImageBuilderInterface
+	// the dependencies not satisfied by the catalogs of the last collect
+	unsatisfied []string
 }
 
 // OperatorImageCollector - this looks into the operator index image
@@ -44,15 +48,21 @@ func (o *LocalStorageCollector) OperatorImageCollector(ctx context.Context) ([]v
 		dir       string
 	)
 	compare := make(map[string]v1alpha3.ISCPackage)
+	o.unsatisfied = nil
 	relatedImages := make(map[string][]v1alpha3.RelatedImage)
+	rebuilt := make(map[string]bool)
 
 	// compile a map to compare channels,min & max versions
 	for _, ops := range o.Config.Mirror.Operators {
 		o.Log.Info("isc operators: %s\n", ops.Catalog)
 		for _, pkg := range ops.Packages {
 			o.Log.Info("catalog packages: %s \n", pkg.Name)
+			// the version range of the package applies to its default channel
+			if len(pkg.Channels) == 0 {
+				compare[pkg.Name] = v1alpha3.ISCPackage{MinVersion: pkg.MinVersion, MaxVersion: pkg.MaxVersion, MinBundle: pkg.MinBundle, Full: ops.Full}
+			}
 			for _, channel := range pkg.Channels {
-				compare[pkg.Name] = v1alpha3.ISCPackage{Channel: channel.Name, MinVersion: channel.MinVersion, MaxVersion: channel.MaxVersion, Full: ops.Full}
+				compare[pkg.Name] = v1alpha3.ISCPackage{Channel: channel.Name, MinVersion: channel.MinVersion, MaxVersion: channel.MaxVersion, MinBundle: channel.MinBundle, Full: ops.Full}
 				o.Log.Info("channels: %v \n", compare)
 			}
 		}
@@ -102,18 +112,10 @@ func (o *LocalStorageCollector) OperatorImageCollector(ctx context.Context) ([]v
 		if len(oci.Manifests) == 0 {
 			return []v1alpha3.CopyImageSchema{}, fmt.Errorf("[OperatorImageCollector] no manifests found for %s ", op.Catalog)
 		}
-		validDigest, err := digest.Parse(oci.Manifests[0].Digest)
+		// read the operator image manifest of the platform
+		oci, err = o.Manifest.GetPlatformManifest(dir, oci)
 		if err != nil {
-			return []v1alpha3.CopyImageSchema{}, fmt.Errorf("[OperatorImageCollector] the disgests seem to incorrect for %s: %v ", op.Catalog, err)
-		}
-
-		manifest := validDigest.Encoded()
-		o.Log.Info("manifest %v", manifest)
-		// read the operator image manifest
-		manifestDir := filepath.Join(dir, blobsDir, manifest)
-		oci, err = o.Manifest.GetImageManifest(manifestDir)
-		if err != nil {
-			return []v1alpha3.CopyImageSchema{}, err
+			return []v1alpha3.CopyImageSchema{}, fmt.Errorf("[OperatorImageCollector] %s: %v ", op.Catalog, err)
 		}
 
 		// read the config digest to get the detailed manifest
@@ -139,27 +141,49 @@ func (o *LocalStorageCollector) OperatorImageCollector(ctx context.Context) ([]v
 			return []v1alpha3.CopyImageSchema{}, err
 		}
 
+		var catalogImages map[string][]v1alpha3.RelatedImage
 		// select all packages
 		// this is the equivalent of the headOnly mode
 		// only the latest version of each operator will be selected
 		if len(op.Packages) == 0 {
-			relatedImages, err = o.Manifest.GetRelatedImagesFromCatalog(cacheDir, label)
+			catalogImages, err = o.Manifest.GetRelatedImagesFromCatalog(cacheDir, label)
 			if err != nil {
 				return []v1alpha3.CopyImageSchema{}, err
 			}
 		} else {
 			// iterate through each package
-			relatedImages, err = o.Manifest.GetRelatedImagesFromCatalogByFilter(cacheDir, label, op, compare)
+			var unsatisfied []string
+			catalogImages, unsatisfied, err = o.Manifest.GetRelatedImagesFromCatalogByFilter(cacheDir, label, op, compare)
 			if err != nil {
 				return []v1alpha3.CopyImageSchema{}, err
 			}
+			o.unsatisfied = append(o.unsatisfied, unsatisfied...)
 		}
-		relatedImages["index"] = []v1alpha3.RelatedImage{
-			{
-				Name:  "index",
-				Image: op.Catalog,
-			},
+		// keep the images of all the catalogs
+		for k, v := range catalogImages {
+			for i := range v {
+				v[i].Catalog = op.Catalog
+			}
+			relatedImages[k] = append(relatedImages[k], v...)
 		}
+
+		// the catalog is rebuilt with the selected bundles only,
+		// so that the cluster sees what is actually mirrored
+		if (o.Opts.IsMirrorToDisk() || o.Opts.IsMirrorToMirror()) && isFiltered(op) {
+			bundles, err := o.catalogBundles(op, catalogImages)
+			if err != nil {
+				return []v1alpha3.CopyImageSchema{}, err
+			}
+			if _, err := o.rebuildCatalog(ctx, op, filepath.Join(cacheDir, label), label, bundles); err != nil {
+				return []v1alpha3.CopyImageSchema{}, err
+			}
+			rebuilt[op.Catalog] = true
+		}
+		relatedImages["index"] = append(relatedImages["index"], v1alpha3.RelatedImage{
+			Name:    "index",
+			Image:   op.Catalog,
+			Catalog: op.Catalog,
+		})
 	}
 
 	o.Log.Info("related images length %d ", len(relatedImages))
@@ -183,9 +207,123 @@ func (o *LocalStorageCollector) OperatorImageCollector(ctx context.Context) ([]v
 			return []v1alpha3.CopyImageSchema{}, err
 		}
 	}
+
+	if o.Opts.IsMirrorToMirror() {
+		allImages, err = o.prepareM2MCopyBatch(o.Log, dir, relatedImages)
+		if err != nil {
+			return []v1alpha3.CopyImageSchema{}, err
+		}
+	}
+
+	allImages, err = o.withCatalogTargets(allImages, rebuilt)
+	if err != nil {
+		return []v1alpha3.CopyImageSchema{}, err
+	}
 	return allImages, nil
 }
 
+// UnsatisfiedDependencies - the dependencies of the bundles collected
+// that no bundle of their catalog satisfies
+func (o *LocalStorageCollector) UnsatisfiedDependencies() []string {
+	return o.unsatisfied
+}
+
+// withCatalogTargets - the catalogs are kept in the cache and mirrored to the destination
+// with their TargetName and TargetTag, when set.
+// The catalogs that were rebuilt are copied from the cache, where they were pushed
+func (o LocalStorageCollector) withCatalogTargets(images []v1alpha3.CopyImageSchema, rebuilt map[string]bool) ([]v1alpha3.CopyImageSchema, error) {
+	for _, op := range o.Config.Mirror.Operators {
+		if op.IsFBCOCI() || (op.TargetName == "" && op.TargetTag == "" && !rebuilt[op.Catalog]) {
+			continue
+		}
+		cacheRef, err := o.catalogCacheReference(op)
+		if err != nil {
+			return nil, err
+		}
+		for i := range images {
+			if images[i].Origin != op.Catalog {
+				continue
+			}
+			switch {
+			case o.Opts.IsMirrorToDisk() || o.Opts.IsPrepare():
+				images[i].Destination = dockerProtocol + cacheRef
+				if rebuilt[op.Catalog] {
+					images[i].Source = images[i].Destination
+				}
+			case o.Opts.IsDiskToMirror():
+				images[i].Source = dockerProtocol + cacheRef
+				images[i].Destination = targetReference(images[i].Destination, op.TargetName, op.TargetTag)
+			case o.Opts.IsMirrorToMirror():
+				if rebuilt[op.Catalog] {
+					images[i].Source = dockerProtocol + cacheRef
+				}
+				images[i].Destination = targetReference(images[i].Destination, op.TargetName, op.TargetTag)
+			}
+			o.Log.Debug("catalog %s copied from %s to %s", op.Catalog, images[i].Source, images[i].Destination)
+		}
+	}
+	return images, nil
+}
+
+// targetReference - ref with the last component of its path replaced by name
+// and its tag (or digest) replaced by tag, when they are set
+func targetReference(ref, name, tag string) string {
+	repo, suffix := ref, ""
+	if i := strings.Index(ref, "@"); i >= 0 {
+		repo, suffix = ref[:i], ref[i:]
+	} else if colon := strings.LastIndex(ref, ":"); colon > strings.LastIndex(ref, "/") {
+		repo, suffix = ref[:colon], ref[colon:]
+	}
+	if name != "" {
+		repo = repo[:strings.LastIndex(repo, "/")+1] + name
+	}
+	if tag != "" {
+		suffix = ":" + tag
+	}
+	return repo + suffix
+}
+
+// prepareM2MCopyBatch - the images are copied from their original location
+// straight to the destination registry, using the same destination
+// references as the diskToMirror workflow
+func (o LocalStorageCollector) prepareM2MCopyBatch(log clog.PluggableLoggerInterface, dir string, images map[string][]v1alpha3.RelatedImage) ([]v1alpha3.CopyImageSchema, error) {
+	var result []v1alpha3.CopyImageSchema
+	for _, relatedImgs := range images {
+		for _, img := range relatedImgs {
+			var src string
+			var dest string
+			if !strings.HasPrefix(img.Image, ociProtocol) {
+
+				imgSpec, err := image.ParseRef(img.Image)
+				if err != nil {
+					o.Log.Error("%s", err.Error())
+					return nil, err
+				}
+				src = imgSpec.ReferenceWithTransport
+
+				if imgSpec.IsImageByDigest() {
+					dest = strings.Join([]string{o.Opts.Destination, imgSpec.PathComponent + ":" + imgSpec.Digest[:hashTruncLen]}, "/")
+				} else {
+					dest = strings.Join([]string{o.Opts.Destination, imgSpec.PathComponent}, "/") + ":" + imgSpec.Tag
+				}
+			} else {
+				src = img.Image
+				transportAndPath := strings.Split(img.Image, "://")
+				dest = strings.Join([]string{o.Opts.Destination, transportAndPath[1]}, "/")
+			}
+
+			if src == "" || dest == "" {
+				return result, fmt.Errorf("unable to determine src %s or dst %s for %s", src, dest, img.Image)
+			}
+
+			o.Log.Debug("source %s", src)
+			o.Log.Debug("destination %s", dest)
+			result = append(result, v1alpha3.CopyImageSchema{Origin: img.Image, Source: src, Destination: dest, Parent: img.Catalog, Package: img.Package})
+		}
+	}
+	return result, nil
+}
+
 func (o LocalStorageCollector) prepareD2MCopyBatch(log clog.PluggableLoggerInterface, dir string, images map[string][]v1alpha3.RelatedImage) ([]v1alpha3.CopyImageSchema, error) {
 	var result []v1alpha3.CopyImageSchema
 	for _, relatedImgs := range images {
@@ -223,7 +361,7 @@ func (o LocalStorageCollector) prepareD2MCopyBatch(log clog.PluggableLoggerInter
 
 			o.Log.Debug("source %s", src)
 			o.Log.Debug("destination %s", dest)
-			result = append(result, v1alpha3.CopyImageSchema{Origin: img.Image, Source: src, Destination: dest})
+			result = append(result, v1alpha3.CopyImageSchema{Origin: img.Image, Source: src, Destination: dest, Parent: img.Catalog, Package: img.Package})
 		}
 	}
 	return result, nil
@@ -250,7 +388,7 @@ func (o LocalStorageCollector) prepareM2DCopyBatch(log clog.PluggableLoggerInter
 
 			o.Log.Debug("source %s", src)
 			o.Log.Debug("destination %s", dest)
-			result = append(result, v1alpha3.CopyImageSchema{Source: src, Destination: dest})
+			result = append(result, v1alpha3.CopyImageSchema{Origin: img.Image, Source: src, Destination: dest, Parent: img.Catalog, Package: img.Package})
 
 		}
 	}
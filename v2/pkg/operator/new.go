@@ -2,6 +2,7 @@ package operator
 
 import (
 	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/v2/pkg/imagebuilder"
 	clog "github.com/openshift/oc-mirror/v2/pkg/log"
 	"github.com/openshift/oc-mirror/v2/pkg/manifest"
 	"github.com/openshift/oc-mirror/v2/pkg/mirror"
@@ -13,9 +14,10 @@ func New(log clog.PluggableLoggerInterface,
 	mirror mirror.MirrorInterface,
 	manifest manifest.ManifestInterface,
 	localStorageFQDN string,
+	imageBuilder imagebuilder.ImageBuilderInterface,
 ) CollectorInterface {
 	if localStorageFQDN != "" {
-		return &LocalStorageCollector{Log: log, Config: config, Opts: opts, Mirror: mirror, Manifest: manifest, LocalStorageFQDN: localStorageFQDN}
+		return &LocalStorageCollector{Log: log, Config: config, Opts: opts, Mirror: mirror, Manifest: manifest, LocalStorageFQDN: localStorageFQDN, ImageBuilder: imageBuilder}
 	} else {
 		return &Collector{Log: log, Config: config, Opts: opts, Mirror: mirror, Manifest: manifest}
 	}
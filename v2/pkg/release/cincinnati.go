@@ -99,7 +99,9 @@ func (o CincinnatiSchema) NewOKDClient(uuid uuid.UUID) (Client, error) {
 	return o.Client, nil
 }
 
-func (o *CincinnatiSchema) GetReleaseReferenceImages(ctx context.Context) []v1alpha3.CopyImageSchema {
+// GetReleaseReferenceImages - the releases of the channels in the configuration,
+// only the releases with a valid signature are returned
+func (o *CincinnatiSchema) GetReleaseReferenceImages(ctx context.Context) ([]v1alpha3.CopyImageSchema, error) {
 
 	var (
 		allImages []v1alpha3.CopyImageSchema
@@ -206,15 +208,15 @@ func (o *CincinnatiSchema) GetReleaseReferenceImages(ctx context.Context) []v1al
 		}
 	}
 
-	imgs, err := o.Signature.GenerateReleaseSignatures(ctx, allImages)
-	if err != nil {
-		o.Log.Error("error list %v ", err)
-	}
-
 	for _, e := range errs {
 		o.Log.Error("error list %v ", e)
 	}
-	return imgs
+
+	imgs, err := o.Signature.GenerateReleaseSignatures(ctx, allImages)
+	if err != nil {
+		return []v1alpha3.CopyImageSchema{}, err
+	}
+	return imgs, nil
 }
 
 // getDownloads will prepare the downloads map for mirroring
@@ -2,6 +2,7 @@ package release
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -16,6 +17,9 @@ import (
 
 type mockSignature struct {
 	Log clog.PluggableLoggerInterface
+	// Signed: the references signed, by release digest reference
+	Signed map[string]string
+	Fail   bool
 }
 
 func TestGetReleaseReferenceImages(t *testing.T) {
@@ -88,7 +92,10 @@ func TestGetReleaseReferenceImages(t *testing.T) {
 		}
 		c.url = endpoint
 		sch := NewCincinnati(log, &cfg, opts, c, false, signature)
-		res := sch.GetReleaseReferenceImages(context.Background())
+		res, err := sch.GetReleaseReferenceImages(context.Background())
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
 
 		log.Debug("result from cincinnati %v", res)
 		if res == nil {
@@ -114,16 +121,54 @@ func TestGetReleaseReferenceImages(t *testing.T) {
 		}
 		c.url = endpoint
 		sch := NewCincinnati(log, &cfg, opts, c, true, signature)
-		res := sch.GetReleaseReferenceImages(context.Background())
+		res, err := sch.GetReleaseReferenceImages(context.Background())
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
 
 		log.Debug("result from cincinnati %v", res)
 		if res == nil {
 			t.Fatalf("should return a related images")
 		}
 	})
+
+	t.Run("TestGetReleaseReferenceImages (invalid signature) should fail", func(t *testing.T) {
+
+		c := &mockClient{}
+		signature := &mockSignature{Log: log, Fail: true}
+		requestQuery := make(chan string, 1)
+		defer close(requestQuery)
+
+		handler := getHandlerMulti(t, requestQuery)
+
+		ts := httptest.NewServer(http.HandlerFunc(handler))
+		t.Cleanup(ts.Close)
+
+		endpoint, err := url.Parse(ts.URL)
+		if err != nil {
+			t.Fatalf("should not fail endpoint parse")
+		}
+		c.url = endpoint
+		sch := NewCincinnati(log, &cfg, opts, c, false, signature)
+		_, err = sch.GetReleaseReferenceImages(context.Background())
+		if err == nil {
+			t.Fatalf("should fail")
+		}
+	})
 }
 
 func (o mockSignature) GenerateReleaseSignatures(ctx context.Context, rd []v1alpha3.CopyImageSchema) ([]v1alpha3.CopyImageSchema, error) {
 	o.Log.Info("signature verification (mock)")
-	return []v1alpha3.CopyImageSchema{}, nil
+	if o.Fail {
+		return []v1alpha3.CopyImageSchema{}, fmt.Errorf("forced signature error")
+	}
+	imgs := []v1alpha3.CopyImageSchema{}
+	for _, img := range rd {
+		if ref, ok := o.Signed[img.Source]; ok {
+			img.Origin = img.Source
+			img.Source = ref
+		}
+		imgs = append(imgs, img)
+	}
+	return imgs, nil
 }
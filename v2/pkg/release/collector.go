@@ -36,7 +36,10 @@ func (o *Collector) ReleaseImageCollector(ctx context.Context) ([]v1alpha3.CopyI
 	var imageIndexDir string
 
 	if o.Opts.IsMirrorToDisk() {
-		releases := o.Cincinnati.GetReleaseReferenceImages(ctx)
+		releases, err := o.Cincinnati.GetReleaseReferenceImages(ctx)
+		if err != nil {
+			return []v1alpha3.CopyImageSchema{}, fmt.Errorf(errMsg, err)
+		}
 		f, err := os.Create(logFile)
 		if err != nil {
 			o.Log.Error("[ReleaseImageCollector] %v", err)
@@ -85,15 +88,12 @@ func (o *Collector) ReleaseImageCollector(ctx context.Context) ([]v1alpha3.CopyI
 			if len(oci.Manifests) == 0 {
 				return []v1alpha3.CopyImageSchema{}, fmt.Errorf(errMsg, "image index not found ")
 			}
-			manifest := strings.Split(oci.Manifests[0].Digest, ":")[1]
-			o.Log.Debug("image index %v", manifest)
-
-			manifestDir := strings.Join([]string{dir, blobsDir, manifest}, "/")
-			mfst, err := o.Manifest.GetImageManifest(manifestDir)
+			// the release image manifest of the platform
+			mfst, err := o.Manifest.GetPlatformManifest(dir, oci)
 			if err != nil {
 				return []v1alpha3.CopyImageSchema{}, fmt.Errorf(errMsg, err)
 			}
-			o.Log.Debug("manifest %v ", oci.Config.Digest)
+			o.Log.Debug("manifest %v ", mfst.Config.Digest)
 
 			fromDir := strings.Join([]string{dir, blobsDir}, "/")
 			err = o.Manifest.ExtractLayersOCI(fromDir, cacheDir, releaseManifests, mfst)
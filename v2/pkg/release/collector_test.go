@@ -249,8 +249,8 @@ func (o MockManifest) GetOperatorConfig(file string) (*v1alpha3.OperatorConfigSc
 	return nil, nil
 }
 
-func (o MockManifest) GetRelatedImagesFromCatalogByFilter(filePath, label string, op v1alpha2.Operator, mp map[string]v1alpha3.ISCPackage) (map[string][]v1alpha3.RelatedImage, error) {
-	return nil, nil
+func (o MockManifest) GetRelatedImagesFromCatalogByFilter(filePath, label string, op v1alpha2.Operator, mp map[string]v1alpha3.ISCPackage) (map[string][]v1alpha3.RelatedImage, []string, error) {
+	return nil, nil, nil
 }
 
 func (o MockManifest) GetReleaseSchema(filePath string) ([]v1alpha3.RelatedImage, error) {
@@ -279,6 +279,10 @@ func (o MockManifest) GetImageIndex(name string) (*v1alpha3.OCISchema, error) {
 	}, nil
 }
 
+func (o MockManifest) GetPlatformManifest(dir string, oci *v1alpha3.OCISchema) (*v1alpha3.OCISchema, error) {
+	return o.GetImageManifest(dir)
+}
+
 func (o MockManifest) GetImageManifest(name string) (*v1alpha3.OCISchema, error) {
 	if o.FailImageManifest {
 		return &v1alpha3.OCISchema{}, fmt.Errorf("forced error image index")
@@ -317,10 +321,10 @@ func (o MockManifest) ExtractLayersOCI(filePath, toPath, label string, oci *v1al
 	return nil
 }
 
-func (o MockCincinnati) GetReleaseReferenceImages(ctx context.Context) []v1alpha3.CopyImageSchema {
+func (o MockCincinnati) GetReleaseReferenceImages(ctx context.Context) ([]v1alpha3.CopyImageSchema, error) {
 	var res []v1alpha3.CopyImageSchema
 	res = append(res, v1alpha3.CopyImageSchema{Source: "docker://localhost:9999/openshift/graph-image:latest", Destination: "docker://localhost:9999/openshift/graph-image:latest"})
-	return res
+	return res, nil
 }
 
 func (o MockCincinnati) NewOCPClient(uuid uuid.UUID) (Client, error) {
@@ -16,7 +16,7 @@ type GraphBuilderInterface interface {
 }
 
 type CincinnatiInterface interface {
-	GetReleaseReferenceImages(context.Context) []v1alpha3.CopyImageSchema
+	GetReleaseReferenceImages(context.Context) ([]v1alpha3.CopyImageSchema, error)
 	NewOCPClient(uuid.UUID) (Client, error)
 	NewOKDClient(uuid.UUID) (Client, error)
 }
@@ -11,8 +11,6 @@ import (
 	"path/filepath"
 	"strings"
 
-	digest "github.com/opencontainers/go-digest"
-
 	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha2"
 	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
 	"github.com/openshift/oc-mirror/v2/pkg/image"
@@ -34,6 +32,7 @@ type LocalStorageCollector struct {
 	Config           v1alpha2.ImageSetConfiguration
 	Opts             mirror.CopyOptions
 	Cincinnati       CincinnatiInterface
+	Signature        SignatureInterface
 	LocalStorageFQDN string
 	ImageBuilder     imagebuilder.ImageBuilderInterface
 }
@@ -42,8 +41,11 @@ func (o *LocalStorageCollector) ReleaseImageCollector(ctx context.Context) ([]v1
 	var allImages []v1alpha3.CopyImageSchema
 	var imageIndexDir string
 	filterCopy := o.Config.Mirror.Platform.DeepCopy()
-	if o.Opts.IsMirrorToDisk() || o.Opts.IsPrepare() {
-		releases := o.Cincinnati.GetReleaseReferenceImages(ctx)
+	if o.Opts.IsMirrorToDisk() || o.Opts.IsPrepare() || o.Opts.IsMirrorToMirror() {
+		releases, err := o.Cincinnati.GetReleaseReferenceImages(ctx)
+		if err != nil {
+			return []v1alpha3.CopyImageSchema{}, fmt.Errorf(errMsg, err)
+		}
 
 		releasesForFilter := releasesForFilter{
 			Filter: filterCopy,
@@ -67,9 +69,12 @@ func (o *LocalStorageCollector) ReleaseImageCollector(ctx context.Context) ([]v1
 			//Save to releasesForFilter so that we can reuse it during Disk To Mirror flow
 			src := dockerProtocol + value.Source
 			dest := ociProtocolTrimmed + dir
+			// the origin is the release referenced by the digest that was signed,
+			// the signature is verified again during Disk To Mirror flow
 			r := v1alpha3.CopyImageSchema{
 				Source:      src,
 				Destination: dest,
+				Origin:      value.Origin,
 			}
 			releasesForFilter.Releases = append(releasesForFilter.Releases, r)
 
@@ -107,20 +112,12 @@ func (o *LocalStorageCollector) ReleaseImageCollector(ctx context.Context) ([]v1
 			if len(oci.Manifests) == 0 {
 				return []v1alpha3.CopyImageSchema{}, fmt.Errorf(errMsg, "image index not found ")
 			}
-			validDigest, err := digest.Parse(oci.Manifests[0].Digest)
-			if err != nil {
-				return []v1alpha3.CopyImageSchema{}, fmt.Errorf("[ReleaseImageCollector] invalid digest for image index %s: %v", oci.Manifests[0].Digest, err)
-			}
-
-			manifest := validDigest.Encoded()
-			o.Log.Debug("image index %v", manifest)
-
-			manifestDir := filepath.Join(dir, blobsDir, manifest)
-			mfst, err := o.Manifest.GetImageManifest(manifestDir)
+			// the release image manifest of the platform
+			mfst, err := o.Manifest.GetPlatformManifest(dir, oci)
 			if err != nil {
 				return []v1alpha3.CopyImageSchema{}, fmt.Errorf(errMsg, err)
 			}
-			o.Log.Debug("manifest %v ", oci.Config.Digest)
+			o.Log.Debug("manifest %v ", mfst.Config.Digest)
 
 			fromDir := strings.Join([]string{dir, blobsDir}, "/")
 			err = o.Manifest.ExtractLayersOCI(fromDir, cacheDir, releaseManifests, mfst)
@@ -137,10 +134,18 @@ func (o *LocalStorageCollector) ReleaseImageCollector(ctx context.Context) ([]v1
 			}
 			//add the release image itself
 			allRelatedImages = append(allRelatedImages, v1alpha3.RelatedImage{Image: value.Source, Name: value.Source})
-			tmpAllImages, err := o.prepareM2DCopyBatch(o.Log, allRelatedImages)
+			var tmpAllImages []v1alpha3.CopyImageSchema
+			if o.Opts.IsMirrorToMirror() {
+				tmpAllImages, err = o.prepareM2MCopyBatch(o.Log, allRelatedImages)
+			} else {
+				tmpAllImages, err = o.prepareM2DCopyBatch(o.Log, allRelatedImages)
+			}
 			if err != nil {
 				return []v1alpha3.CopyImageSchema{}, err
 			}
+			for i := range tmpAllImages {
+				tmpAllImages[i].Parent = value.Source
+			}
 			allImages = append(allImages, tmpAllImages...)
 		}
 		// save the releasesForFilter to json cache,
@@ -157,18 +162,31 @@ func (o *LocalStorageCollector) ReleaseImageCollector(ctx context.Context) ([]v1
 				return []v1alpha3.CopyImageSchema{}, err
 			}
 			o.Log.Info("graph image created and pushed to cache.")
-			// still add the graph image to the `allImages` so that we later can add it in the tar.gz archive
-			graphCopy := v1alpha3.CopyImageSchema{
-				Source:      graphImgRef,
-				Destination: graphImgRef,
-				Origin:      graphImgRef,
+			if o.Opts.IsMirrorToMirror() {
+				// the graph image only exists in the cache: copy it from there to the destination
+				graphRelatedImage := v1alpha3.RelatedImage{
+					Name:  graphImageName,
+					Image: strings.TrimPrefix(graphImgRef, dockerProtocol),
+				}
+				graphCopies, err := o.prepareD2MCopyBatch(o.Log, []v1alpha3.RelatedImage{graphRelatedImage})
+				if err != nil {
+					return []v1alpha3.CopyImageSchema{}, err
+				}
+				allImages = append(allImages, graphCopies...)
+			} else {
+				// still add the graph image to the `allImages` so that we later can add it in the tar.gz archive
+				graphCopy := v1alpha3.CopyImageSchema{
+					Source:      graphImgRef,
+					Destination: graphImgRef,
+					Origin:      graphImgRef,
+				}
+				allImages = append(allImages, graphCopy)
 			}
-			allImages = append(allImages, graphCopy)
 		}
 
 	} else if o.Opts.IsDiskToMirror() {
 
-		releaseImages, releaseFolders, err := o.identifyReleases()
+		releaseImages, releaseFolders, err := o.identifyReleases(ctx)
 		if err != nil {
 			return allImages, err
 		}
@@ -263,14 +281,39 @@ func (o LocalStorageCollector) prepareM2DCopyBatch(log clog.PluggableLoggerInter
 	return result, nil
 }
 
-func (o LocalStorageCollector) identifyReleases() ([]v1alpha3.RelatedImage, []string, error) {
+// prepareM2MCopyBatch - the images are copied from their original location
+// straight to the destination registry, using the same destination
+// references as the diskToMirror workflow
+func (o LocalStorageCollector) prepareM2MCopyBatch(log clog.PluggableLoggerInterface, images []v1alpha3.RelatedImage) ([]v1alpha3.CopyImageSchema, error) {
+	var result []v1alpha3.CopyImageSchema
+	for _, img := range images {
+		var src string
+		var dest string
+
+		imgSpec, err := image.ParseRef(img.Image)
+		if err != nil {
+			o.Log.Error("%s", err.Error())
+			return nil, err
+		}
+		src = imgSpec.ReferenceWithTransport
+		if imgSpec.IsImageByDigest() {
+			dest = strings.Join([]string{o.Opts.Destination, imgSpec.PathComponent + "@" + imgSpec.Algorithm + ":" + imgSpec.Digest}, "/")
+		} else {
+			dest = strings.Join([]string{o.Opts.Destination, imgSpec.PathComponent}, "/") + ":" + imgSpec.Tag
+		}
+		o.Log.Debug("source %s", src)
+		o.Log.Debug("destination %s", dest)
+		result = append(result, v1alpha3.CopyImageSchema{Origin: img.Image, Source: src, Destination: dest})
+	}
+	return result, nil
+}
+
+func (o LocalStorageCollector) identifyReleases(ctx context.Context) ([]v1alpha3.RelatedImage, []string, error) {
 	//Find the filter file, containing all the images that correspond to the filter
 	rff := releasesForFilter{
 		Filter: o.Config.Mirror.Platform,
 	}
-	filter := fmt.Sprintf("%v", rff.Filter)
-	filterFileName := fmt.Sprintf("%x", md5.Sum([]byte(filter)))[0:32]
-	filterFilePath := filepath.Join(o.Opts.Global.WorkingDir, releaseFiltersDir, filterFileName)
+	filterFilePath := filepath.Join(o.Opts.Global.WorkingDir, releaseFiltersDir, filterFileName(rff.Filter))
 	dat, err := os.ReadFile(filterFilePath)
 	if err != nil {
 		return nil, nil, fmt.Errorf("unable to read file %s: %v", filterFilePath, err)
@@ -282,6 +325,9 @@ func (o LocalStorageCollector) identifyReleases() ([]v1alpha3.RelatedImage, []st
 	}
 
 	releaseImageCopies := rff.Releases
+	if err := o.verifyReleaseSignatures(ctx, releaseImageCopies); err != nil {
+		return nil, nil, err
+	}
 	releaseFolders := []string{}
 	releaseImages := []v1alpha3.RelatedImage{}
 	for _, copy := range releaseImageCopies {
@@ -299,9 +345,6 @@ func (o LocalStorageCollector) saveReleasesForFilter(r releasesForFilter, to str
 	if err != nil {
 		return err
 	}
-	filter := fmt.Sprintf("%v", r.Filter)
-	filterFileName := fmt.Sprintf("%x", md5.Sum([]byte(filter)))[0:32]
-
 	if _, err := os.Stat(to); errors.Is(err, os.ErrNotExist) {
 		o.Log.Info("copying  cincinnati response to %s", to)
 		err := os.MkdirAll(to, 0755)
@@ -310,7 +353,7 @@ func (o LocalStorageCollector) saveReleasesForFilter(r releasesForFilter, to str
 		}
 	}
 
-	filterFile, err := os.Create(filepath.Join(to, filterFileName))
+	filterFile, err := os.Create(filepath.Join(to, filterFileName(r.Filter)))
 	if err != nil {
 		return err
 	}
@@ -322,3 +365,40 @@ func (o LocalStorageCollector) saveReleasesForFilter(r releasesForFilter, to str
 	}
 	return nil
 }
+
+// filterFileName - the name of the file holding the releases of the filter.
+// Only the fields of the filter that choose the releases are hashed: the name
+// doesn't change when fields like the signatures are added to the platform,
+// and the files saved by previous runs are still found
+func filterFileName(filter v1alpha2.Platform) string {
+	key := struct {
+		Graph         bool
+		Channels      []v1alpha2.ReleaseChannel
+		Architectures []string
+		Release       string
+	}{
+		Graph:         filter.Graph,
+		Channels:      filter.Channels,
+		Architectures: filter.Architectures,
+		Release:       filter.Release,
+	}
+	return fmt.Sprintf("%x", md5.Sum([]byte(fmt.Sprintf("%v", key))))[0:32]
+}
+
+// verifyReleaseSignatures - the signatures saved in the working-dir during the
+// Mirror To Disk flow are verified again, and must sign the releases that are mirrored
+func (o LocalStorageCollector) verifyReleaseSignatures(ctx context.Context, releases []v1alpha3.CopyImageSchema) error {
+	for _, release := range releases {
+		if release.Origin == "" {
+			return fmt.Errorf("[ReleaseImageCollector] no signed digest recorded for release %s, run the mirror to disk workflow again", release.Source)
+		}
+		signed, err := o.Signature.GenerateReleaseSignatures(ctx, []v1alpha3.CopyImageSchema{{Source: release.Origin}})
+		if err != nil {
+			return fmt.Errorf("[ReleaseImageCollector] %v", err)
+		}
+		if len(signed) != 1 || dockerProtocol+signed[0].Source != release.Source {
+			return fmt.Errorf("[ReleaseImageCollector] the signature of %s doesn't sign release %s", release.Origin, release.Source)
+		}
+	}
+	return nil
+}
@@ -157,6 +157,14 @@ func TestReleaseLocalStoredCollector(t *testing.T) {
 	}
 
 	cincinnati := &MockCincinnati{Config: cfgm2d, Opts: m2dOpts}
+	// the releases of the filter in tests/working-dir-fake/release-filters, by the digest signed
+	signature := &mockSignature{
+		Log: log,
+		Signed: map[string]string{
+			"quay.io/openshift-release-dev/ocp-release@sha256:a3e1fc3fe7ec5da8bd4a4e3c1f0a3b9e4d7c3f2ba3b9f1a8c1e6a5d8c2b4e9f1": "quay.io/openshift-release-dev/ocp-release:4.13.9-x86_64",
+			"quay.io/openshift-release-dev/ocp-release@sha256:b7c4e2a9f1d3e5c7a9b1d3f5e7c9a1b3d5f7e9c1a3b5d7f9e1c3a5b7d9f1e3c5": "quay.io/openshift-release-dev/ocp-release:4.13.10-x86_64",
+		},
+	}
 	ctx := context.Background()
 
 	// this test should cover over 80% M2D
@@ -202,6 +210,7 @@ func TestReleaseLocalStoredCollector(t *testing.T) {
 			Manifest:         manifest,
 			Opts:             d2mOpts,
 			Cincinnati:       cincinnati,
+			Signature:        signature,
 			LocalStorageFQDN: "localhost:9999",
 		}
 
@@ -216,7 +225,56 @@ func TestReleaseLocalStoredCollector(t *testing.T) {
 			t.Fatalf("source images should be from local storage")
 		}
 		log.Debug("completed test related images %v ", res)
+
+		// the signatures carried in the working-dir must be valid
+		ex.Signature = &mockSignature{Log: log, Fail: true}
+		if _, err := ex.ReleaseImageCollector(ctx); err == nil {
+			t.Fatalf("should fail when a signature is not valid")
+		}
+
+		// and must sign the releases of the filter
+		ex.Signature = &mockSignature{Log: log, Signed: map[string]string{}}
+		if _, err := ex.ReleaseImageCollector(ctx); err == nil || !strings.Contains(err.Error(), "doesn't sign release") {
+			t.Fatalf("should fail when a signature is for another release: %v", err)
+		}
 	})
+	t.Run("Testing ReleaseImageCollector - Mirror to mirror : should pass", func(t *testing.T) {
+		m2mOpts := m2dOpts
+		m2mOpts.Mode = mirror.MirrorToMirror
+		m2mOpts.Destination = "docker://localhost:5000/test"
+		manifest := &MockManifest{Log: log}
+		ex := &LocalStorageCollector{
+			Log:              log,
+			Mirror:           &MockMirror{Fail: false},
+			Config:           cfgm2d,
+			Manifest:         manifest,
+			Opts:             m2mOpts,
+			Cincinnati:       cincinnati,
+			LocalStorageFQDN: "localhost:9999",
+		}
+
+		res, err := ex.ReleaseImageCollector(ctx)
+		if err != nil {
+			t.Fatalf("should not fail")
+		}
+		if len(res) == 0 {
+			t.Fatalf("should contain at least 1 image")
+		}
+		for _, img := range res {
+			// the graph image is built locally, so it is the only image sourced from the cache
+			if strings.Contains(img.Source, ex.LocalStorageFQDN) && !strings.Contains(img.Source, graphImageName) {
+				t.Fatalf("source images should not be from local storage: %s", img.Source)
+			}
+			if !strings.HasPrefix(img.Destination, m2mOpts.Destination) {
+				t.Fatalf("destination images should be in the destination registry: %s", img.Destination)
+			}
+			if img.Origin == "" {
+				t.Fatalf("origin should be set for %s", img.Source)
+			}
+		}
+		log.Debug("completed test related images %v ", res)
+	})
+
 	t.Run("Testing ReleaseImageCollector : should fail mirror", func(t *testing.T) {
 		os.RemoveAll(m2dOpts.Global.WorkingDir)
 		manifest := &MockManifest{Log: log}
@@ -295,3 +353,27 @@ func TestReleaseLocalStoredCollector(t *testing.T) {
 	})
 
 }
+
+func TestFilterFileName(t *testing.T) {
+	filter := v1alpha2.Platform{
+		Channels: []v1alpha2.ReleaseChannel{
+			{Name: "stable-4.13", Type: v1alpha2.TypeOCP, MinVersion: "4.13.9", MaxVersion: "4.13.10"},
+		},
+		Architectures: []string{"amd64"},
+	}
+
+	t.Run("Testing filterFileName : should not depend on the signatures", func(t *testing.T) {
+		signed := filter.DeepCopy()
+		signed.Signatures = v1alpha2.ReleaseSignatures{Sources: []string{"file:///signatures"}, Keyrings: []string{"/keys/pub.gpg"}}
+		if filterFileName(signed) != filterFileName(filter) {
+			t.Fatalf("the signatures should not change the name of the filter file")
+		}
+	})
+
+	t.Run("Testing filterFileName : should keep the name of previous runs", func(t *testing.T) {
+		// the name of the filter file written before the signatures were added to the platform
+		if name := filterFileName(filter); name != "d5f8153de54b0327ad20d24d4dbba7a6" {
+			t.Fatalf("expected d5f8153de54b0327ad20d24d4dbba7a6, got %s", name)
+		}
+	})
+}
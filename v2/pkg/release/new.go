@@ -14,11 +14,12 @@ func New(log clog.PluggableLoggerInterface,
 	mirror mirror.MirrorInterface,
 	manifest manifest.ManifestInterface,
 	cincinnati CincinnatiInterface,
+	signature SignatureInterface,
 	localStorageFQDN string,
 	imageBuilder imagebuilder.ImageBuilderInterface,
 ) CollectorInterface {
 	if localStorageFQDN != "" {
-		return &LocalStorageCollector{Log: log, Config: config, Opts: opts, Mirror: mirror, Manifest: manifest, Cincinnati: cincinnati, LocalStorageFQDN: localStorageFQDN, ImageBuilder: imageBuilder}
+		return &LocalStorageCollector{Log: log, Config: config, Opts: opts, Mirror: mirror, Manifest: manifest, Cincinnati: cincinnati, Signature: signature, LocalStorageFQDN: localStorageFQDN, ImageBuilder: imageBuilder}
 	} else {
 		return &Collector{Log: log, Config: config, Opts: opts, Mirror: mirror, Manifest: manifest, Cincinnati: cincinnati}
 	}
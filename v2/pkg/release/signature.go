@@ -9,6 +9,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -29,123 +30,204 @@ func NewSignatureClient(log clog.PluggableLoggerInterface, config v1alpha2.Image
 	return &SignatureSchema{Log: log, Config: config, Opts: opts}
 }
 
-// GenerateReleaseSignatures
+// GenerateReleaseSignatures - looks up the signature of each release (referenced by digest),
+// in the cache of the working-dir first and then in the configured sources.
+// A signature that can't be found, that is not signed by a trusted key, that has expired
+// or that doesn't sign the digest of the release fails the lookup.
+// The signatures are saved in the working-dir, so that they are carried in the archive,
+// and the images returned are referenced as in the signatures
 func (o SignatureSchema) GenerateReleaseSignatures(ctx context.Context, images []v1alpha3.CopyImageSchema) ([]v1alpha3.CopyImageSchema, error) {
 
-	var data []byte
-	var err error
 	var imgs []v1alpha3.CopyImageSchema
+	if len(images) == 0 {
+		return imgs, nil
+	}
+
+	keyring, err := o.keyring()
+	if err != nil {
+		return []v1alpha3.CopyImageSchema{}, err
+	}
+
 	// set up http object
 	tr := &http.Transport{
 		TLSClientConfig: &tls.Config{InsecureSkipVerify: false},
 	}
 	httpClient := &http.Client{Transport: tr}
+	cacheDir := filepath.Join(o.Opts.Global.WorkingDir, SignatureDir)
 
 	for _, image := range images {
-		digest := strings.Split(image.Source, ":")[1]
+		digest, err := releaseDigest(image.Source)
+		if err != nil {
+			return []v1alpha3.CopyImageSchema{}, err
+		}
 		// check if the image is in the cache else
 		// do a lookup and download it to cache
-		data, err = os.ReadFile(o.Opts.Global.WorkingDir + SignatureDir + digest)
+		cached := true
+		data, err := os.ReadFile(filepath.Join(cacheDir, digest))
 		if err != nil {
-			if os.IsNotExist(err) {
-				o.Log.Warn("signature for %s not in cache", digest)
+			if !os.IsNotExist(err) {
+				return []v1alpha3.CopyImageSchema{}, fmt.Errorf("unable to read the signature of %s: %v", image.Source, err)
+			}
+			o.Log.Debug("signature for %s not in cache", digest)
+			cached = false
+			data, err = o.lookupSignature(ctx, httpClient, digest)
+			if err != nil {
+				return []v1alpha3.CopyImageSchema{}, fmt.Errorf("no signature found for %s image %s: %v", digest, image.Source, err)
 			}
 		}
 
-		o.Log.Info("signature %s", digest)
+		signed, err := verifySignature(data, keyring, digest)
+		if err != nil {
+			return []v1alpha3.CopyImageSchema{}, fmt.Errorf("signature verification failed for %s image %s: %v", digest, image.Source, err)
+		}
+		o.Log.Debug("signature %s verified, signed by %s", digest, signed.fingerprint)
 
-		// we have the current digest in cache
-		if len(data) == 0 {
-			req, _ := http.NewRequest("GET", SignatureURL+"sha256="+digest+"/signature-1", nil)
-			//req.Header.Set("Authorization", "Basic "+generic.Token)
-			req.Header.Set(ContentType, ApplicationJson)
-			resp, err := httpClient.Do(req)
-			if err != nil {
-				o.Log.Error("http request %v", err)
+		if !cached {
+			// write signature to cache
+			if err := os.MkdirAll(cacheDir, 0755); err != nil {
+				return []v1alpha3.CopyImageSchema{}, err
 			}
-			defer resp.Body.Close()
-			if resp.StatusCode == http.StatusOK {
-				o.Log.Debug("response from signature lookup %d", resp.StatusCode)
-
-				data, err = io.ReadAll(resp.Body)
-				if err != nil {
-					o.Log.Error("%v", err)
-				}
+			if err := os.WriteFile(filepath.Join(cacheDir, digest), data, 0644); err != nil {
+				return []v1alpha3.CopyImageSchema{}, fmt.Errorf("unable to save the signature of %s: %v", image.Source, err)
 			}
 		}
 
-		if len(data) > 0 {
-			keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader([]byte(pk)))
-			//keyring, err := openpgp.ReadKeyRing(bytes.NewReader([]byte(data)))
-			if err != nil {
-				o.Log.Error("%v", err)
-			}
-			o.Log.Debug("keyring %v", keyring)
+		// update the image with the actual reference from the contents json,
+		// keeping the reference by digest that was signed
+		image.Origin = image.Source
+		image.Source = signed.reference
+		o.Log.Info("image found : %s", signed.reference)
+		imgs = append(imgs, image)
+	}
+	return imgs, nil
+}
 
-			md, err := openpgp.ReadMessage(bytes.NewReader(data), keyring, nil, nil)
-			if err != nil {
-				o.Log.Error("%v could not read the message:", err)
-			}
-			if !md.IsSigned {
-				o.Log.Error("not signed")
-			}
-			content, err := io.ReadAll(md.UnverifiedBody)
-			if err != nil {
-				o.Log.Error("%v", err)
-			}
-			if md.SignatureError != nil {
-				o.Log.Error("signature error:", md.SignatureError)
-			}
-			if md.SignedBy == nil {
-				o.Log.Error("invalid signature")
-			}
+// keyring - the Red Hat release key and the keys of the keyrings in the configuration
+func (o SignatureSchema) keyring() (openpgp.EntityList, error) {
+	keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(pk))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the release key: %v", err)
+	}
+	for _, file := range o.Config.Mirror.Platform.Signatures.Keyrings {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read keyring %s: %v", file, err)
+		}
+		keys, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+		if err != nil {
+			keys, err = openpgp.ReadKeyRing(bytes.NewReader(data))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read keyring %s: %v", file, err)
+		}
+		keyring = append(keyring, keys...)
+	}
+	return keyring, nil
+}
 
-			o.Log.Trace("field isEncrypted %v", md.IsEncrypted)
-			o.Log.Trace("field EencryptedToKeyIds %v", md.EncryptedToKeyIds)
-			o.Log.Trace("field IsSymmetricallyEncrypted %v", md.IsSymmetricallyEncrypted)
-			o.Log.Trace("field DecryptedWith %v", md.DecryptedWith)
-			o.Log.Trace("field IsSigned %v", md.IsSigned)
-			o.Log.Trace("field SignedByKeyId %v", md.SignedByKeyId)
-			o.Log.Trace("field SignedBy %v", md.SignedBy)
-			o.Log.Trace("field LiteralData %v", md.LiteralData)
-			o.Log.Trace("field SignatureError %v", md.SignatureError)
-			o.Log.Trace("field Signature %v", md.Signature)
-			// o.Log.Trace("field SignatureV3 %v", md.SignatureV3.IssuerKeyId)
-			// o.Log.Trace("field SignatureV3 %v", md.SignatureV3.CreationTime)
-
-			if md.Signature != nil {
-				if md.Signature.SigLifetimeSecs != nil {
-					expiry := md.Signature.CreationTime.Add(time.Duration(*md.Signature.SigLifetimeSecs) * time.Second)
-					if time.Now().After(expiry) {
-						o.Log.Debug("signature expired on %v ", expiry)
-					}
-				}
-			} else if md.SignatureV3 == nil {
-				o.Log.Error("unexpected openpgp.MessageDetails: neither Signature nor SignatureV3 is set")
-			}
+// lookupSignature - tries the sources of the configuration in order,
+// until one of them holds the signature of digest
+func (o SignatureSchema) lookupSignature(ctx context.Context, httpClient *http.Client, digest string) ([]byte, error) {
+	sources := o.Config.Mirror.Platform.Signatures.Sources
+	if len(sources) == 0 {
+		sources = []string{SignatureURL}
+	}
+	errs := []string{}
+	for _, source := range sources {
+		data, err := fetchSignature(ctx, httpClient, source, digest)
+		if err != nil {
+			o.Log.Debug("signature %s not found in %s: %v", digest, source, err)
+			errs = append(errs, err.Error())
+			continue
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("%s", strings.Join(errs, "; "))
+}
 
-			o.Log.Info("content %s", string(content))
-			// update the image with the actaul reference from the contents json
-			var signSchema *v1alpha3.SignatureContentSchema
-			err = json.Unmarshal(content, &signSchema)
-			if err != nil {
-				o.Log.Error("could not unmarshal json %v", err)
-				return []v1alpha3.CopyImageSchema{}, err
-			}
-			image.Source = signSchema.Critical.Identity.DockerReference
-			o.Log.Info("image found : %s", signSchema.Critical.Identity.DockerReference)
-			o.Log.Info("public Key : %s", strings.ToUpper(fmt.Sprintf("%x", md.SignedBy.PublicKey.Fingerprint)))
+// fetchSignature - reads the signature of digest from source,
+// which is an http(s) url, a file:// url or a local directory
+func fetchSignature(ctx context.Context, httpClient *http.Client, source, digest string) ([]byte, error) {
+	location := "sha256=" + digest + "/signature-1"
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		url := strings.TrimSuffix(source, "/") + "/" + location
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(ContentType, ApplicationJson)
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%s: unexpected status %d", url, resp.StatusCode)
+		}
+		return io.ReadAll(resp.Body)
+	}
+	return os.ReadFile(filepath.Join(strings.TrimPrefix(source, "file://"), filepath.FromSlash(location)))
+}
 
-			// write signature to cache
-			ferr := os.WriteFile(o.Opts.Global.WorkingDir+SignatureDir+digest, data, 0644)
-			if ferr != nil {
-				o.Log.Error("%v", ferr)
+// signedRelease - what a verified signature tells about a release
+type signedRelease struct {
+	reference   string
+	fingerprint string
+}
+
+// verifySignature - checks that data is signed by one of the keys of keyring,
+// that the signature has not expired and that it signs the manifest digest
+func verifySignature(data []byte, keyring openpgp.EntityList, digest string) (signedRelease, error) {
+	md, err := openpgp.ReadMessage(bytes.NewReader(data), keyring, nil, nil)
+	if err != nil {
+		return signedRelease{}, fmt.Errorf("could not read the message: %v", err)
+	}
+	if !md.IsSigned {
+		return signedRelease{}, fmt.Errorf("not signed")
+	}
+	// the signature is checked once the whole body has been read
+	content, err := io.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		return signedRelease{}, err
+	}
+	if md.SignatureError != nil {
+		return signedRelease{}, fmt.Errorf("signature error: %v", md.SignatureError)
+	}
+	if md.SignedBy == nil {
+		return signedRelease{}, fmt.Errorf("signed by an untrusted key %X", md.SignedByKeyId)
+	}
+	if md.Signature != nil {
+		if md.Signature.SigLifetimeSecs != nil {
+			expiry := md.Signature.CreationTime.Add(time.Duration(*md.Signature.SigLifetimeSecs) * time.Second)
+			if time.Now().After(expiry) {
+				return signedRelease{}, fmt.Errorf("signature expired on %v", expiry)
 			}
-			imgs = append(imgs, image)
-		} else {
-			o.Log.Warn("no signature found for %s", digest)
-			return []v1alpha3.CopyImageSchema{}, fmt.Errorf("no signature found for %s image %s", digest, image.Source)
 		}
+	} else if md.SignatureV3 == nil {
+		return signedRelease{}, fmt.Errorf("unexpected openpgp.MessageDetails: neither Signature nor SignatureV3 is set")
 	}
-	return imgs, nil
+
+	var signSchema v1alpha3.SignatureContentSchema
+	if err := json.Unmarshal(content, &signSchema); err != nil {
+		return signedRelease{}, fmt.Errorf("could not unmarshal json %v", err)
+	}
+	if signSchema.Critical.Image.DockerManifestDigest != "sha256:"+digest {
+		return signedRelease{}, fmt.Errorf("the signature is for the manifest %s", signSchema.Critical.Image.DockerManifestDigest)
+	}
+	if signSchema.Critical.Identity.DockerReference == "" {
+		return signedRelease{}, fmt.Errorf("the signature has no docker reference")
+	}
+	return signedRelease{
+		reference:   signSchema.Critical.Identity.DockerReference,
+		fingerprint: strings.ToUpper(fmt.Sprintf("%x", md.SignedBy.PublicKey.Fingerprint)),
+	}, nil
+}
+
+// releaseDigest - the hex encoded sha256 digest of a release referenced by digest
+func releaseDigest(ref string) (string, error) {
+	i := strings.LastIndex(ref, "@sha256:")
+	if i < 0 || i+len("@sha256:") == len(ref) {
+		return "", fmt.Errorf("release %s is not referenced by a sha256 digest", ref)
+	}
+	return ref[i+len("@sha256:"):], nil
 }
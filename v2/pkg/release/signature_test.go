@@ -0,0 +1,267 @@
+package release
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
+	clog "github.com/openshift/oc-mirror/v2/pkg/log"
+	"github.com/openshift/oc-mirror/v2/pkg/mirror"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+const (
+	testDigest    = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	testReference = "quay.io/openshift-release-dev/ocp-release:4.13.9-x86_64"
+	testRelease   = "quay.io/openshift-release-dev/ocp-release@sha256:" + testDigest
+)
+
+func TestGenerateReleaseSignatures(t *testing.T) {
+
+	log := clog.New("trace")
+
+	trusted := newTestEntity(t)
+	untrusted := newTestEntity(t)
+	keyring := writeTestKeyring(t, trusted)
+
+	valid := signTestContent(t, trusted, testDigest, time.Now(), nil)
+
+	newClient := func(workingDir string, sources ...string) SignatureSchema {
+		cfg := v1alpha2.ImageSetConfiguration{}
+		cfg.Mirror.Platform.Signatures = v1alpha2.ReleaseSignatures{Sources: sources, Keyrings: []string{keyring}}
+		return SignatureSchema{Log: log, Config: cfg, Opts: mirror.CopyOptions{Global: &mirror.GlobalOptions{WorkingDir: workingDir}}}
+	}
+
+	t.Run("Testing GenerateReleaseSignatures (sources tried in order) : should pass", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/signatures/sha256="+testDigest+"/signature-1" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write(valid)
+		}))
+		defer ts.Close()
+
+		workingDir := t.TempDir()
+		// the first source can't be reached, the second doesn't have the signature
+		o := newClient(workingDir, "http://127.0.0.1:0/signatures", ts.URL+"/missing", ts.URL+"/signatures/")
+		imgs, err := o.GenerateReleaseSignatures(context.Background(), []v1alpha3.CopyImageSchema{{Source: testRelease}})
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		if len(imgs) != 1 || imgs[0].Source != testReference || imgs[0].Origin != testRelease {
+			t.Fatalf("unexpected images %v", imgs)
+		}
+		cached, err := os.ReadFile(filepath.Join(workingDir, SignatureDir, testDigest))
+		if err != nil || !bytes.Equal(cached, valid) {
+			t.Fatalf("the signature should be saved in the working-dir: %v", err)
+		}
+
+		// the signature saved in the working-dir is verified again, without any source
+		o = newClient(workingDir, "http://127.0.0.1:0/signatures")
+		if _, err := o.GenerateReleaseSignatures(context.Background(), []v1alpha3.CopyImageSchema{{Source: testRelease}}); err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+	})
+
+	t.Run("Testing GenerateReleaseSignatures (file source) : should pass", func(t *testing.T) {
+		source := writeTestSignature(t, valid)
+		for _, s := range []string{"file://" + source, source} {
+			o := newClient(t.TempDir(), s)
+			imgs, err := o.GenerateReleaseSignatures(context.Background(), []v1alpha3.CopyImageSchema{{Source: "docker://" + testRelease}})
+			if err != nil {
+				t.Fatalf("should not fail: %v", err)
+			}
+			if len(imgs) != 1 || imgs[0].Source != testReference {
+				t.Fatalf("unexpected images %v", imgs)
+			}
+		}
+	})
+
+	expired := uint32(60)
+	invalid := append([]byte{}, valid...)
+	invalid[len(invalid)-10] ^= 0xff
+	type testCase struct {
+		caseName  string
+		signature []byte
+		release   string
+		expError  string
+	}
+	testCases := []testCase{
+		{
+			caseName:  "Testing GenerateReleaseSignatures (untrusted key) : should fail",
+			signature: signTestContent(t, untrusted, testDigest, time.Now(), nil),
+			release:   testRelease,
+			expError:  "untrusted key",
+		},
+		{
+			caseName:  "Testing GenerateReleaseSignatures (expired signature) : should fail",
+			signature: signTestContent(t, trusted, testDigest, time.Now().Add(-time.Hour), &expired),
+			release:   testRelease,
+			expError:  "signature expired",
+		},
+		{
+			caseName:  "Testing GenerateReleaseSignatures (invalid signature) : should fail",
+			signature: invalid,
+			release:   testRelease,
+			expError:  "signature verification failed",
+		},
+		{
+			caseName:  "Testing GenerateReleaseSignatures (signature of another release) : should fail",
+			signature: signTestContent(t, trusted, strings.Repeat("0", 64), time.Now(), nil),
+			release:   testRelease,
+			expError:  "the signature is for the manifest",
+		},
+		{
+			caseName:  "Testing GenerateReleaseSignatures (not found) : should fail",
+			signature: nil,
+			release:   testRelease,
+			expError:  "no signature found",
+		},
+		{
+			caseName:  "Testing GenerateReleaseSignatures (release by tag) : should fail",
+			signature: valid,
+			release:   testReference,
+			expError:  "not referenced by a sha256 digest",
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.caseName, func(t *testing.T) {
+			source := t.TempDir()
+			if c.signature != nil {
+				source = writeTestSignature(t, c.signature)
+			}
+			workingDir := t.TempDir()
+			o := newClient(workingDir, source)
+			_, err := o.GenerateReleaseSignatures(context.Background(), []v1alpha3.CopyImageSchema{{Source: c.release}})
+			if err == nil || !strings.Contains(err.Error(), c.expError) {
+				t.Fatalf("should fail with %q: %v", c.expError, err)
+			}
+			if _, err := os.Stat(filepath.Join(workingDir, SignatureDir, testDigest)); !os.IsNotExist(err) {
+				t.Fatalf("the signature should not be saved in the working-dir")
+			}
+		})
+	}
+
+	t.Run("Testing GenerateReleaseSignatures (invalid keyring) : should fail", func(t *testing.T) {
+		o := newClient(t.TempDir(), writeTestSignature(t, valid))
+		o.Config.Mirror.Platform.Signatures.Keyrings = []string{filepath.Join(t.TempDir(), "missing.gpg")}
+		if _, err := o.GenerateReleaseSignatures(context.Background(), []v1alpha3.CopyImageSchema{{Source: testRelease}}); err == nil {
+			t.Fatalf("should fail")
+		}
+	})
+}
+
+func newTestEntity(t *testing.T) *openpgp.Entity {
+	entity, err := openpgp.NewEntity("test", "release signatures", "test@example.com", &packet.Config{RSABits: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entity
+}
+
+// writeTestKeyring - saves the public key of entity, armored
+func writeTestKeyring(t *testing.T, entity *openpgp.Entity) string {
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "keyring.asc")
+	if err := os.WriteFile(file, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// writeTestSignature - a signature source, holding the signature of testDigest
+func writeTestSignature(t *testing.T, signature []byte) string {
+	source := t.TempDir()
+	dir := filepath.Join(source, "sha256="+testDigest)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "signature-1"), signature, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return source
+}
+
+// signTestContent - a signed message, as in the signature stores, for the release with digest
+func signTestContent(t *testing.T, entity *openpgp.Entity, digest string, created time.Time, lifetime *uint32) []byte {
+	var signSchema v1alpha3.SignatureContentSchema
+	signSchema.Critical.Type = "atomic container signature"
+	signSchema.Critical.Image.DockerManifestDigest = "sha256:" + digest
+	signSchema.Critical.Identity.DockerReference = testReference
+	content, err := json.Marshal(signSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	ops := &packet.OnePassSignature{
+		SigType:    packet.SigTypeBinary,
+		Hash:       crypto.SHA256,
+		PubKeyAlgo: entity.PrivateKey.PubKeyAlgo,
+		KeyId:      entity.PrivateKey.KeyId,
+		IsLast:     true,
+	}
+	if err := ops.Serialize(&buf); err != nil {
+		t.Fatal(err)
+	}
+	literal, err := packet.SerializeLiteral(nopCloser{&buf}, true, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := literal.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := literal.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	sig := &packet.Signature{
+		SigType:         packet.SigTypeBinary,
+		PubKeyAlgo:      entity.PrivateKey.PubKeyAlgo,
+		Hash:            crypto.SHA256,
+		CreationTime:    created,
+		IssuerKeyId:     &entity.PrivateKey.KeyId,
+		SigLifetimeSecs: lifetime,
+	}
+	h := sha256.New()
+	h.Write(content)
+	if err := sig.Sign(h, entity.PrivateKey, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := sig.Serialize(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+type nopCloser struct {
+	*bytes.Buffer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
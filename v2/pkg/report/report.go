@@ -0,0 +1,181 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
+)
+
+const (
+	jsonReportFile = "mirror-report.json"
+	yamlReportFile = "mirror-report.yaml"
+	StatusSuccess  = "success"
+	StatusFailed   = "failed"
+	StatusSkipped  = "skipped"
+	StatusBlocked  = "blocked"
+)
+
+// the order of the totals in the report, the other types follow in alphabetical order
+var collectorOrder = []string{v1alpha3.TypeRelease, v1alpha3.TypeOperator, v1alpha3.TypeAdditional, v1alpha3.TypeHelm}
+
+// ImageReport - what happened to one of the images of the run
+type ImageReport struct {
+	Origin      string  `json:"origin"`
+	Source      string  `json:"source"`
+	Destination string  `json:"destination"`
+	Type        string  `json:"type"`
+	Digest      string  `json:"digest,omitempty"`
+	Bytes       int64   `json:"bytes"`
+	Duration    float64 `json:"durationSeconds"`
+	Status      string  `json:"status"`
+	Error       string  `json:"error,omitempty"`
+	BlockedBy   string  `json:"blockedBy,omitempty"`
+}
+
+// CollectorTotals - the totals of the images found by one collector
+type CollectorTotals struct {
+	Type      string  `json:"type"`
+	Images    int     `json:"images"`
+	Succeeded int     `json:"succeeded"`
+	Failed    int     `json:"failed"`
+	Skipped   int     `json:"skipped"`
+	Blocked   int     `json:"blocked"`
+	Bytes     int64   `json:"bytes"`
+	Duration  float64 `json:"durationSeconds"`
+}
+
+// RunReport - the machine readable report of a run, saved in the working-dir
+type RunReport struct {
+	Workflow  string            `json:"workflow"`
+	StartTime time.Time         `json:"startTime"`
+	EndTime   time.Time         `json:"endTime"`
+	Status    string            `json:"status"`
+	Error     string            `json:"error,omitempty"`
+	Totals    []CollectorTotals `json:"totals"`
+	Images    []ImageReport     `json:"images"`
+	// the dependencies of the operators mirrored that their catalog doesn't satisfy
+	UnsatisfiedDependencies []string `json:"unsatisfiedDependencies,omitempty"`
+}
+
+// NewImageReport - the report of the copy of img, err being the result of the copy
+func NewImageReport(img v1alpha3.CopyImageSchema, digest string, bytes int64, duration time.Duration, err error) ImageReport {
+	ir := ImageReport{
+		Origin:      img.Origin,
+		Source:      img.Source,
+		Destination: img.Destination,
+		Type:        img.Type,
+		Digest:      digest,
+		Bytes:       bytes,
+		Duration:    duration.Seconds(),
+		Status:      StatusSuccess,
+	}
+	if err != nil {
+		ir.Status = StatusFailed
+		ir.Error = err.Error()
+	}
+	return ir
+}
+
+// NewSkippedImageReport - the report of an image that was not copied again,
+// since a previous run copied it already
+func NewSkippedImageReport(img v1alpha3.CopyImageSchema, digest string) ImageReport {
+	ir := NewImageReport(img, digest, 0, 0, nil)
+	ir.Status = StatusSkipped
+	return ir
+}
+
+// NewBlockedImageReport - the report of an image that was not copied,
+// since it matches the entry pattern of the block list
+func NewBlockedImageReport(img v1alpha3.CopyImageSchema, pattern string) ImageReport {
+	ir := NewImageReport(img, "", 0, 0, nil)
+	ir.Status = StatusBlocked
+	ir.BlockedBy = pattern
+	return ir
+}
+
+// New - the report of a run, with the totals per collector.
+// The run failed when runErr is set or when any of the images failed
+func New(workflow string, start, end time.Time, images []ImageReport, runErr error) RunReport {
+	r := RunReport{
+		Workflow:  workflow,
+		StartTime: start,
+		EndTime:   end,
+		Status:    StatusSuccess,
+		Totals:    []CollectorTotals{},
+		Images:    images,
+	}
+	if r.Images == nil {
+		r.Images = []ImageReport{}
+	}
+	totals := map[string]*CollectorTotals{}
+	for _, img := range r.Images {
+		t, ok := totals[img.Type]
+		if !ok {
+			t = &CollectorTotals{Type: img.Type}
+			totals[img.Type] = t
+		}
+		t.Images++
+		t.Bytes += img.Bytes
+		t.Duration += img.Duration
+		switch img.Status {
+		case StatusSuccess:
+			t.Succeeded++
+		case StatusSkipped:
+			t.Skipped++
+		case StatusBlocked:
+			t.Blocked++
+		default:
+			t.Failed++
+			r.Status = StatusFailed
+		}
+	}
+	for _, collector := range collectorOrder {
+		if t, ok := totals[collector]; ok {
+			r.Totals = append(r.Totals, *t)
+			delete(totals, collector)
+		}
+	}
+	others := []string{}
+	for collector := range totals {
+		others = append(others, collector)
+	}
+	sort.Strings(others)
+	for _, collector := range others {
+		r.Totals = append(r.Totals, *totals[collector])
+	}
+	if runErr != nil {
+		r.Status = StatusFailed
+		r.Error = runErr.Error()
+	}
+	return r
+}
+
+// Write - saves the report in dir, in json and in yaml,
+// and returns the paths of the files written
+func (r RunReport) Write(dir string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	jsonData, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal the report: %v", err)
+	}
+	yamlData, err := yaml.JSONToYAML(jsonData)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal the report: %v", err)
+	}
+	files := []string{filepath.Join(dir, jsonReportFile), filepath.Join(dir, yamlReportFile)}
+	for i, data := range [][]byte{jsonData, yamlData} {
+		if err := os.WriteFile(files[i], data, 0644); err != nil {
+			return nil, fmt.Errorf("unable to write the report %s: %v", files[i], err)
+		}
+	}
+	return files, nil
+}
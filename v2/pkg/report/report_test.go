@@ -0,0 +1,99 @@
+package report
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
+	"github.com/openshift/oc-mirror/v2/pkg/mirror"
+)
+
+func TestReport(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	images := []ImageReport{
+		NewImageReport(v1alpha3.CopyImageSchema{Origin: "quay.io/a/helm:v1", Source: "docker://quay.io/a/helm:v1", Destination: "docker://localhost:5000/a/helm:v1", Type: v1alpha3.TypeHelm}, "sha256:aaa", 10, time.Second, nil),
+		NewImageReport(v1alpha3.CopyImageSchema{Origin: "quay.io/a/b:v1", Source: "docker://quay.io/a/b:v1", Destination: "docker://localhost:5000/a/b:v1", Type: v1alpha3.TypeAdditional}, "sha256:bbb", 100, 2*time.Second, nil),
+		NewImageReport(v1alpha3.CopyImageSchema{Origin: "quay.io/a/c:v1", Source: "docker://quay.io/a/c:v1", Destination: "docker://localhost:5000/a/c:v1", Type: v1alpha3.TypeRelease}, "sha256:ccc", 1000, 3*time.Second, nil),
+		NewImageReport(v1alpha3.CopyImageSchema{Origin: "quay.io/a/d:v1", Source: "docker://quay.io/a/d:v1", Destination: "docker://localhost:5000/a/d:v1", Type: v1alpha3.TypeRelease}, "", 0, time.Second, fmt.Errorf("forced error")),
+	}
+
+	t.Run("Testing New : should pass", func(t *testing.T) {
+		r := New(mirror.MirrorToDisk, start, start.Add(time.Minute), images, nil)
+		if r.Status != StatusFailed {
+			t.Fatalf("a failed image should fail the run, got %s", r.Status)
+		}
+		if len(r.Totals) != 3 {
+			t.Fatalf("expected 3 totals, got %v", r.Totals)
+		}
+		// the totals follow the order of the collectors
+		release := r.Totals[0]
+		if release.Type != v1alpha3.TypeRelease || release.Images != 2 || release.Succeeded != 1 || release.Failed != 1 || release.Bytes != 1000 || release.Duration != 4 {
+			t.Fatalf("unexpected release totals %v", release)
+		}
+		if r.Totals[1].Type != v1alpha3.TypeAdditional || r.Totals[2].Type != v1alpha3.TypeHelm {
+			t.Fatalf("unexpected order of the totals %v", r.Totals)
+		}
+		if images[3].Error != "forced error" || images[3].Status != StatusFailed {
+			t.Fatalf("unexpected image report %v", images[3])
+		}
+	})
+
+	t.Run("Testing New (successful run) : should pass", func(t *testing.T) {
+		skipped := NewSkippedImageReport(v1alpha3.CopyImageSchema{Source: "docker://quay.io/a/e:v1", Type: v1alpha3.TypeHelm}, "sha256:eee")
+		r := New(mirror.MirrorToMirror, start, start.Add(time.Minute), append(images[:3:3], skipped), nil)
+		if r.Status != StatusSuccess || r.Error != "" {
+			t.Fatalf("unexpected status %s", r.Status)
+		}
+		// the images copied by a previous run don't fail the run
+		helm := r.Totals[2]
+		if helm.Images != 2 || helm.Succeeded != 1 || helm.Skipped != 1 || helm.Failed != 0 {
+			t.Fatalf("unexpected helm totals %v", helm)
+		}
+	})
+
+	t.Run("Testing New (blocked images) : should pass", func(t *testing.T) {
+		blocked := NewBlockedImageReport(v1alpha3.CopyImageSchema{Origin: "quay.io/a/f:v1", Source: "docker://quay.io/a/f:v1", Type: v1alpha3.TypeAdditional}, "quay.io/a/f")
+		r := New(mirror.MirrorToMirror, start, start.Add(time.Minute), append(images[:3:3], blocked), nil)
+		// the blocked images don't fail the run
+		if r.Status != StatusSuccess {
+			t.Fatalf("unexpected status %s", r.Status)
+		}
+		additional := r.Totals[1]
+		if additional.Images != 2 || additional.Succeeded != 1 || additional.Blocked != 1 || additional.Failed != 0 {
+			t.Fatalf("unexpected additional totals %v", additional)
+		}
+		if blocked.Status != StatusBlocked || blocked.BlockedBy != "quay.io/a/f" {
+			t.Fatalf("unexpected image report %v", blocked)
+		}
+	})
+
+	t.Run("Testing New (failed run, no images) : should pass", func(t *testing.T) {
+		r := New(mirror.DiskToMirror, start, start.Add(time.Minute), nil, fmt.Errorf("collection failed"))
+		if r.Status != StatusFailed || r.Error != "collection failed" || r.Images == nil || r.Totals == nil {
+			t.Fatalf("unexpected report %v", r)
+		}
+	})
+
+	t.Run("Testing Write : should pass", func(t *testing.T) {
+		dir := t.TempDir()
+		files, err := New(mirror.MirrorToDisk, start, start.Add(time.Minute), images, nil).Write(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) != 2 {
+			t.Fatalf("expected json and yaml reports, got %v", files)
+		}
+		data, err := os.ReadFile(files[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, expected := range []string{"workflow: mirrorToDisk", "status: failed", "origin: quay.io/a/b:v1", "digest: sha256:bbb", "durationSeconds: 2"} {
+			if !strings.Contains(string(data), expected) {
+				t.Fatalf("expected %q in the yaml report:\n%s", expected, string(data))
+			}
+		}
+	})
+}
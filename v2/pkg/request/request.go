@@ -0,0 +1,149 @@
+package request
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
+	clog "github.com/openshift/oc-mirror/v2/pkg/log"
+	"github.com/openshift/oc-mirror/v2/pkg/mirror"
+)
+
+const dockerProtocol = "docker://"
+
+// Request - the delta request written by the diskToMirror side: what the enclave
+// registry already holds, for the mirrorToDisk side to build an archive with the rest only
+type Request struct {
+	Created time.Time `json:"created"`
+	// Destination: the enclave registry (and namespace) the images were checked in
+	Destination string `json:"destination"`
+	// Images: the destination images present in the enclave registry
+	Images []string `json:"images"`
+	// Missing: the destination images absent from the enclave registry
+	Missing []string `json:"missing,omitempty"`
+	// Blobs: the digests of the manifests, configs and layers of the images present
+	Blobs []string `json:"blobs"`
+}
+
+// BlobsGatherer - the digests of the manifests, configs and layers of an image
+type BlobsGatherer interface {
+	GatherBlobs(ctx context.Context, imgRef string) (map[string]string, error)
+}
+
+type BuilderInterface interface {
+	Build(ctx context.Context, destination string, images []v1alpha3.CopyImageSchema) (Request, error)
+}
+
+type Builder struct {
+	Log      clog.PluggableLoggerInterface
+	Mirror   mirror.MirrorInterface
+	Gatherer BlobsGatherer
+	Opts     *mirror.CopyOptions
+	Parallel uint
+}
+
+// New - the builder of the delta request, checking the images in the enclave registry
+// with opts, with parallel requests at most
+func New(log clog.PluggableLoggerInterface, m mirror.MirrorInterface, gatherer BlobsGatherer, opts *mirror.CopyOptions, parallel uint) BuilderInterface {
+	if parallel == 0 {
+		parallel = 1
+	}
+	return &Builder{Log: log, Mirror: m, Gatherer: gatherer, Opts: opts, Parallel: parallel}
+}
+
+// Build - checks the destination of each image in the enclave registry, and gathers the blobs
+// of the images present. An image whose manifests can't be read is reported as missing
+func (o *Builder) Build(ctx context.Context, destination string, images []v1alpha3.CopyImageSchema) (Request, error) {
+	req := Request{
+		Created:     time.Now().UTC(),
+		Destination: strings.TrimPrefix(destination, dockerProtocol),
+		Images:      []string{},
+		Blobs:       []string{},
+	}
+	blobs := map[string]string{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, o.Parallel)
+	for _, img := range images {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(img v1alpha3.CopyImageSchema) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			ref := strings.TrimPrefix(img.Destination, dockerProtocol)
+			imgBlobs, err := o.gather(ctx, img.Destination)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil || imgBlobs == nil {
+				if err != nil {
+					o.Log.Warn("unable to read the manifests of %s, requesting it: %v", img.Destination, err)
+				}
+				req.Missing = append(req.Missing, ref)
+				return
+			}
+			req.Images = append(req.Images, ref)
+			for digest := range imgBlobs {
+				blobs[digest] = ""
+			}
+		}(img)
+	}
+	wg.Wait()
+
+	for digest := range blobs {
+		req.Blobs = append(req.Blobs, digest)
+	}
+	sort.Strings(req.Images)
+	sort.Strings(req.Missing)
+	sort.Strings(req.Blobs)
+	return req, nil
+}
+
+// gather - the blobs of the image, nil when it isn't in the registry
+func (o *Builder) gather(ctx context.Context, image string) (map[string]string, error) {
+	exists, err := o.Mirror.Check(ctx, image, o.Opts)
+	if err != nil || !exists {
+		return nil, err
+	}
+	return o.Gatherer.GatherBlobs(ctx, image)
+}
+
+// BlobSet - the blobs of the request, in the format of the history
+func (r Request) BlobSet() map[string]string {
+	blobs := make(map[string]string, len(r.Blobs))
+	for _, digest := range r.Blobs {
+		blobs[digest] = ""
+	}
+	return blobs
+}
+
+// Write - saves the request in path (json)
+func (r Request) Write(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshal the delta request: %v", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Read - loads the request saved in path
+func Read(path string) (Request, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Request{}, fmt.Errorf("unable to read the delta request: %v", err)
+	}
+	var req Request
+	if err := json.Unmarshal(data, &req); err != nil {
+		return Request{}, fmt.Errorf("unable to parse the delta request %s: %v", path, err)
+	}
+	return req, nil
+}
@@ -0,0 +1,97 @@
+package request
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
+	clog "github.com/openshift/oc-mirror/v2/pkg/log"
+	"github.com/openshift/oc-mirror/v2/pkg/mirror"
+)
+
+func TestRequest(t *testing.T) {
+	log := clog.New("trace")
+	enclave := mockEnclave{
+		"docker://enclave:5000/mirror/openshift/release:4.14.1": {"sha256:manifest-a": "", "sha256:shared": "", "sha256:a": ""},
+		"docker://enclave:5000/mirror/ubi8/ubi:latest":          {"sha256:manifest-b": "", "sha256:shared": ""},
+		// present, but its manifests can't be read
+		"docker://enclave:5000/mirror/broken/image:v1": nil,
+	}
+	images := []v1alpha3.CopyImageSchema{
+		{Source: "docker://localhost:55000/openshift/release:4.14.1", Destination: "docker://enclave:5000/mirror/openshift/release:4.14.1"},
+		{Source: "docker://localhost:55000/ubi8/ubi:latest", Destination: "docker://enclave:5000/mirror/ubi8/ubi:latest"},
+		{Source: "docker://localhost:55000/openshift/release:4.14.2", Destination: "docker://enclave:5000/mirror/openshift/release:4.14.2"},
+		{Source: "docker://localhost:55000/broken/image:v1", Destination: "docker://enclave:5000/mirror/broken/image:v1"},
+	}
+	builder := New(log, enclave, enclave, &mirror.CopyOptions{}, 2)
+
+	t.Run("Testing Build : should pass", func(t *testing.T) {
+		req, err := builder.Build(context.Background(), "docker://enclave:5000/mirror", images)
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		if req.Destination != "enclave:5000/mirror" {
+			t.Fatalf("unexpected destination %s", req.Destination)
+		}
+		if !reflect.DeepEqual(req.Images, []string{"enclave:5000/mirror/openshift/release:4.14.1", "enclave:5000/mirror/ubi8/ubi:latest"}) {
+			t.Fatalf("unexpected images %v", req.Images)
+		}
+		if !reflect.DeepEqual(req.Missing, []string{"enclave:5000/mirror/broken/image:v1", "enclave:5000/mirror/openshift/release:4.14.2"}) {
+			t.Fatalf("unexpected missing images %v", req.Missing)
+		}
+		if !reflect.DeepEqual(req.Blobs, []string{"sha256:a", "sha256:manifest-a", "sha256:manifest-b", "sha256:shared"}) {
+			t.Fatalf("unexpected blobs %v", req.Blobs)
+		}
+	})
+
+	t.Run("Testing Write and Read : should pass", func(t *testing.T) {
+		req, err := builder.Build(context.Background(), "docker://enclave:5000/mirror", images)
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		path := filepath.Join(t.TempDir(), "request", "delta-request.json")
+		if err := req.Write(path); err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		read, err := Read(path)
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		if !read.Created.Equal(req.Created) || !reflect.DeepEqual(read.Blobs, req.Blobs) || !reflect.DeepEqual(read.Images, req.Images) {
+			t.Fatalf("unexpected request read %+v", read)
+		}
+		if !reflect.DeepEqual(read.BlobSet(), map[string]string{"sha256:a": "", "sha256:manifest-a": "", "sha256:manifest-b": "", "sha256:shared": ""}) {
+			t.Fatalf("unexpected blob set %v", read.BlobSet())
+		}
+	})
+
+	t.Run("Testing Read : should fail", func(t *testing.T) {
+		if _, err := Read(filepath.Join(t.TempDir(), "does-not-exist.json")); err == nil {
+			t.Fatalf("should fail")
+		}
+	})
+}
+
+// mockEnclave - the images of the enclave registry, with their blobs
+type mockEnclave map[string]map[string]string
+
+func (o mockEnclave) Run(ctx context.Context, src, dest string, mode mirror.Mode, opts *mirror.CopyOptions, stdout bufio.Writer) error {
+	return nil
+}
+
+func (o mockEnclave) Check(ctx context.Context, image string, opts *mirror.CopyOptions) (bool, error) {
+	_, ok := o[image]
+	return ok, nil
+}
+
+func (o mockEnclave) GatherBlobs(ctx context.Context, imgRef string) (map[string]string, error) {
+	blobs := o[imgRef]
+	if blobs == nil {
+		return nil, fmt.Errorf("manifest unknown")
+	}
+	return blobs, nil
+}
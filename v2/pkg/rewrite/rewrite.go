@@ -0,0 +1,199 @@
+package rewrite
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
+)
+
+const (
+	wildcard     string = "*"
+	wildcardPath string = "/*"
+	transportSep string = "://"
+	pathSep      string = "/"
+	flattenSep   string = "-"
+	digestSep    string = "@"
+	tagSep       string = ":"
+)
+
+// Rewriter - rewrites the repositories of the images in the destination registry,
+// with the rewrite rules of the ImageSetConfiguration first, and the nesting limit
+// of the destination registry then
+type Rewriter struct {
+	rules          []v1alpha2.DestinationRewrite
+	maxNestedPaths int
+	// the destination registry (and namespace), without transport
+	root string
+}
+
+// New - the rewriter of the images copied to destination.
+// maxNestedPaths is the maximum number of path components of the repositories
+// in the destination registry, 0 for no limit. The namespace of the destination
+// counts in it, and must leave room for at least one component
+func New(rules []v1alpha2.DestinationRewrite, maxNestedPaths int, destination string) (*Rewriter, error) {
+	if err := Validate(rules); err != nil {
+		return nil, err
+	}
+	if err := ValidateNestedPaths(maxNestedPaths, destination); err != nil {
+		return nil, err
+	}
+	return &Rewriter{rules: rules, maxNestedPaths: maxNestedPaths, root: strings.TrimSuffix(stripTransport(destination), pathSep)}, nil
+}
+
+// ValidateNestedPaths - checks the maximum number of nested paths, and that the namespace
+// of the destination leaves room for at least one path component of the images
+func ValidateNestedPaths(maxNestedPaths int, destination string) error {
+	if maxNestedPaths < 0 {
+		return fmt.Errorf("the maximum number of nested paths must be zero or positive, got %d", maxNestedPaths)
+	}
+	_, namespace := splitRoot(strings.TrimSuffix(stripTransport(destination), pathSep))
+	if maxNestedPaths > 0 && namespace != "" && len(strings.Split(namespace, pathSep)) >= maxNestedPaths {
+		return fmt.Errorf("the namespace %s of the destination has more path components than the maximum number of nested paths %d allows", namespace, maxNestedPaths)
+	}
+	return nil
+}
+
+// Validate - checks the rewrite rules
+func Validate(rules []v1alpha2.DestinationRewrite) error {
+	for _, r := range rules {
+		if r.Source == "" || r.Destination == "" {
+			return fmt.Errorf("destination rewrite %s -> %s: source and destination are mandatory", r.Source, r.Destination)
+		}
+		source := strings.TrimSuffix(r.Source, wildcardPath)
+		if strings.Contains(source, wildcard) {
+			return fmt.Errorf("destination rewrite %s: the source can only end with /*", r.Source)
+		}
+		if strings.Count(r.Destination, wildcard) > 1 {
+			return fmt.Errorf("destination rewrite %s: the destination can only have one *", r.Destination)
+		}
+		if strings.Contains(r.Destination, wildcard) && !strings.HasSuffix(r.Source, wildcardPath) {
+			return fmt.Errorf("destination rewrite %s -> %s: the destination can only have a * when the source ends with /*", r.Source, r.Destination)
+		}
+		if strings.HasPrefix(r.Destination, pathSep) || strings.HasSuffix(r.Destination, pathSep) || strings.Contains(r.Destination, pathSep+pathSep) {
+			return fmt.Errorf("destination rewrite %s: invalid destination repository", r.Destination)
+		}
+	}
+	return nil
+}
+
+// RewriteAll - rewrites the destination of the images copied to the destination registry,
+// the other images (i.e. copied to the local cache) are left as they are.
+// Two repositories can't be rewritten to the same repository
+func (o *Rewriter) RewriteAll(images []v1alpha3.CopyImageSchema) ([]v1alpha3.CopyImageSchema, error) {
+	if len(o.rules) == 0 && o.maxNestedPaths == 0 {
+		return images, nil
+	}
+	origins := map[string]string{}
+	result := make([]v1alpha3.CopyImageSchema, 0, len(images))
+	for _, img := range images {
+		dest, rewritten := o.Rewrite(img)
+		if rewritten {
+			destRepo, _ := splitReference(stripTransport(dest))
+			originRepo, _ := splitReference(stripTransport(img.Origin))
+			if other, ok := origins[destRepo]; ok && other != originRepo {
+				return nil, fmt.Errorf("%s and %s are both rewritten to %s", other, originRepo, destRepo)
+			}
+			origins[destRepo] = originRepo
+			img.Destination = dest
+		}
+		result = append(result, img)
+	}
+	return result, nil
+}
+
+// Rewrite - the destination of the image, and true when it was rewritten
+func (o *Rewriter) Rewrite(img v1alpha3.CopyImageSchema) (string, bool) {
+	// the transports are kept as they are
+	prefix := ""
+	ref := img.Destination
+	for strings.Contains(ref, transportSep) {
+		i := strings.Index(ref, transportSep) + len(transportSep)
+		prefix, ref = prefix+ref[:i], ref[i:]
+	}
+	if o.root == "" || !strings.HasPrefix(ref, o.root+pathSep) {
+		return img.Destination, false
+	}
+	path, suffix := splitReference(strings.TrimPrefix(ref, o.root+pathSep))
+
+	// the namespace of the destination is kept as it is,
+	// but counts in the nested paths
+	_, namespace := splitRoot(o.root)
+	maxNestedPaths := o.maxNestedPaths
+	if maxNestedPaths > 0 && namespace != "" {
+		maxNestedPaths -= len(strings.Split(namespace, pathSep))
+	}
+	newPath := limitNestedPaths(o.applyRules(path, img.Origin), maxNestedPaths)
+	if newPath == path {
+		return img.Destination, false
+	}
+	return prefix + o.root + pathSep + newPath + suffix, true
+}
+
+// applyRules - the path of the repository in the destination, rewritten by the first rule
+// matching the origin of the image. The * of the rule is the path matched in the origin
+// (when the path of the destination starts with the one of the source, the rest of it)
+func (o *Rewriter) applyRules(path, origin string) string {
+	originRepo, _ := splitReference(stripTransport(origin))
+	for _, r := range o.rules {
+		if !strings.HasSuffix(r.Source, wildcardPath) {
+			if originRepo == r.Source {
+				return r.Destination
+			}
+			continue
+		}
+		source := strings.TrimSuffix(r.Source, wildcardPath)
+		if !strings.HasPrefix(originRepo, source+pathSep) {
+			continue
+		}
+		// the path of the source, without its registry
+		rest := path
+		if i := strings.Index(source, pathSep); i >= 0 {
+			rest = strings.TrimPrefix(path, source[i+1:]+pathSep)
+		}
+		if r.Flatten {
+			rest = strings.ReplaceAll(rest, pathSep, flattenSep)
+		}
+		return strings.Replace(r.Destination, wildcard, rest, 1)
+	}
+	return path
+}
+
+// limitNestedPaths - the path of the repository under the destination root,
+// with its last components joined by - when it has more components than the limit
+// (as v1 does, only the path of the image is flattened)
+func limitNestedPaths(path string, maxNestedPaths int) string {
+	components := strings.Split(path, pathSep)
+	if maxNestedPaths <= 0 || len(components) <= maxNestedPaths {
+		return path
+	}
+	last := strings.Join(components[maxNestedPaths-1:], flattenSep)
+	return strings.Join(append(components[:maxNestedPaths-1:maxNestedPaths-1], last), pathSep)
+}
+
+// splitRoot - the registry of the destination, and its namespace
+func splitRoot(root string) (string, string) {
+	if i := strings.Index(root, pathSep); i >= 0 {
+		return root[:i], root[i+1:]
+	}
+	return root, ""
+}
+
+// splitReference - the repository of the reference, and its tag or digest
+func splitReference(ref string) (string, string) {
+	if i := strings.Index(ref, digestSep); i >= 0 {
+		return ref[:i], ref[i:]
+	}
+	if i := strings.LastIndex(ref, tagSep); i > strings.LastIndex(ref, pathSep) {
+		return ref[:i], ref[i:]
+	}
+	return ref, ""
+}
+
+func stripTransport(ref string) string {
+	for strings.Contains(ref, transportSep) {
+		ref = ref[strings.Index(ref, transportSep)+len(transportSep):]
+	}
+	return ref
+}
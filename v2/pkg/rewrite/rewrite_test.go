@@ -0,0 +1,131 @@
+package rewrite
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha2"
+	"github.com/openshift/oc-mirror/v2/pkg/api/v1alpha3"
+)
+
+func TestRewrite(t *testing.T) {
+	type testCase struct {
+		caseName       string
+		rules          []v1alpha2.DestinationRewrite
+		maxNestedPaths int
+		destination    string
+		image          v1alpha3.CopyImageSchema
+		expected       string
+	}
+	testCases := []testCase{
+		{
+			caseName:    "Testing Rewrite (wildcard rule) : should pass",
+			rules:       []v1alpha2.DestinationRewrite{{Source: "quay.io/openshift-community-operators/*", Destination: "community/*"}},
+			destination: "docker://mirror.example.com",
+			image:       v1alpha3.CopyImageSchema{Origin: "docker://quay.io/openshift-community-operators/team/app:v1", Destination: "docker://mirror.example.com/openshift-community-operators/team/app:v1"},
+			expected:    "docker://mirror.example.com/community/team/app:v1",
+		},
+		{
+			caseName:    "Testing Rewrite (wildcard rule, flatten) : should pass",
+			rules:       []v1alpha2.DestinationRewrite{{Source: "quay.io/openshift-community-operators/*", Destination: "community/*", Flatten: true}},
+			destination: "docker://mirror.example.com/ns",
+			image:       v1alpha3.CopyImageSchema{Origin: "quay.io/openshift-community-operators/team/app@sha256:f30638f60452062aba36a26ee6c036feead2f03b28f2c47f2b0a991e41baebea", Destination: "docker://mirror.example.com/ns/openshift-community-operators/team/app@sha256:f30638f60452062aba36a26ee6c036feead2f03b28f2c47f2b0a991e41baebea"},
+			expected:    "docker://mirror.example.com/ns/community/team-app@sha256:f30638f60452062aba36a26ee6c036feead2f03b28f2c47f2b0a991e41baebea",
+		},
+		{
+			caseName:    "Testing Rewrite (exact rule) : should pass",
+			rules:       []v1alpha2.DestinationRewrite{{Source: "quay.io/a/b", Destination: "c"}, {Source: "quay.io/a/*", Destination: "d/*"}},
+			destination: "docker://mirror.example.com",
+			image:       v1alpha3.CopyImageSchema{Origin: "quay.io/a/b:v1", Destination: "docker://mirror.example.com/a/b:v1"},
+			expected:    "docker://mirror.example.com/c:v1",
+		},
+		{
+			caseName:    "Testing Rewrite (no rule matching) : should pass",
+			rules:       []v1alpha2.DestinationRewrite{{Source: "quay.io/a/*", Destination: "d/*"}},
+			destination: "docker://mirror.example.com",
+			image:       v1alpha3.CopyImageSchema{Origin: "quay.io/ab/c:v1", Destination: "docker://mirror.example.com/ab/c:v1"},
+			expected:    "docker://mirror.example.com/ab/c:v1",
+		},
+		{
+			caseName:       "Testing Rewrite (max nested paths) : should pass",
+			maxNestedPaths: 2,
+			destination:    "docker://mirror.example.com/ns",
+			image:          v1alpha3.CopyImageSchema{Origin: "quay.io/a/b/c:v1", Destination: "docker://mirror.example.com/ns/a/b/c:v1"},
+			expected:       "docker://mirror.example.com/ns/a-b-c:v1",
+		},
+		{
+			caseName:       "Testing Rewrite (max nested paths, nested namespace) : should pass",
+			maxNestedPaths: 3,
+			destination:    "docker://mirror.example.com/ns1/ns2",
+			image:          v1alpha3.CopyImageSchema{Origin: "quay.io/a/b/c:v1", Destination: "docker://mirror.example.com/ns1/ns2/a/b/c:v1"},
+			expected:       "docker://mirror.example.com/ns1/ns2/a-b-c:v1",
+		},
+		{
+			caseName:       "Testing Rewrite (rule then max nested paths) : should pass",
+			rules:          []v1alpha2.DestinationRewrite{{Source: "quay.io/a/*", Destination: "x/y/*"}},
+			maxNestedPaths: 3,
+			destination:    "docker://mirror.example.com",
+			image:          v1alpha3.CopyImageSchema{Origin: "quay.io/a/b/c:v1", Destination: "docker://mirror.example.com/a/b/c:v1"},
+			expected:       "docker://mirror.example.com/x/y/b-c:v1",
+		},
+		{
+			caseName:       "Testing Rewrite (local cache) : should pass",
+			maxNestedPaths: 1,
+			destination:    "docker://mirror.example.com",
+			image:          v1alpha3.CopyImageSchema{Origin: "quay.io/a/b/c:v1", Destination: "docker://localhost:55000/a/b/c:v1"},
+			expected:       "docker://localhost:55000/a/b/c:v1",
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.caseName, func(t *testing.T) {
+			r, err := New(c.rules, c.maxNestedPaths, c.destination)
+			if err != nil {
+				t.Fatalf("should not fail: %v", err)
+			}
+			res, err := r.RewriteAll([]v1alpha3.CopyImageSchema{c.image})
+			if err != nil {
+				t.Fatalf("should not fail: %v", err)
+			}
+			if res[0].Destination != c.expected {
+				t.Fatalf("expected %s, got %s", c.expected, res[0].Destination)
+			}
+			if res[0].Origin != c.image.Origin || res[0].Source != c.image.Source {
+				t.Fatalf("only the destination should be rewritten, got %v", res[0])
+			}
+		})
+	}
+
+	t.Run("Testing RewriteAll (same destination) : should fail", func(t *testing.T) {
+		r, err := New(nil, 1, "docker://mirror.example.com")
+		if err != nil {
+			t.Fatalf("should not fail: %v", err)
+		}
+		_, err = r.RewriteAll([]v1alpha3.CopyImageSchema{
+			{Origin: "quay.io/a/b-c:v1", Destination: "docker://mirror.example.com/a/b-c:v1"},
+			{Origin: "quay.io/a-b/c:v1", Destination: "docker://mirror.example.com/a-b/c:v1"},
+		})
+		if err == nil {
+			t.Fatalf("should fail")
+		}
+	})
+}
+
+func TestValidate(t *testing.T) {
+	for _, r := range []v1alpha2.DestinationRewrite{
+		{Source: "quay.io/a/*"},
+		{Source: "quay.io/*/b", Destination: "c"},
+		{Source: "quay.io/a/*", Destination: "*/*"},
+		{Source: "quay.io/a", Destination: "b/*"},
+		{Source: "quay.io/a", Destination: "/b"},
+	} {
+		if err := Validate([]v1alpha2.DestinationRewrite{r}); err == nil {
+			t.Fatalf("%v should fail", r)
+		}
+	}
+	if _, err := New(nil, -1, "docker://mirror.example.com"); err == nil || !strings.Contains(err.Error(), "must be zero or positive") {
+		t.Fatalf("a negative max nested paths should fail: %v", err)
+	}
+	if _, err := New(nil, 2, "docker://mirror.example.com/ns1/ns2"); err == nil || !strings.Contains(err.Error(), "namespace ns1/ns2") {
+		t.Fatalf("a namespace without room for the images should fail: %v", err)
+	}
+}